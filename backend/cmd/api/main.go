@@ -1,45 +1,118 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/config"
 	httpDelivery "github.com/ruth987/CHub.git/internal/delivery/http"
 	"github.com/ruth987/CHub.git/internal/delivery/http/handler"
+	"github.com/ruth987/CHub.git/internal/domain"
 	"github.com/ruth987/CHub.git/internal/repository/postgres"
-	"github.com/ruth987/CHub.git/internal/services/s3"
+	"github.com/ruth987/CHub.git/internal/services/backup"
+	"github.com/ruth987/CHub.git/internal/services/files"
+	"github.com/ruth987/CHub.git/internal/services/realtime"
 	"github.com/ruth987/CHub.git/internal/usecase"
+	"github.com/ruth987/CHub.git/internal/worker"
 	"github.com/ruth987/CHub.git/pkg/auth"
 	"github.com/ruth987/CHub.git/pkg/database"
+	"github.com/ruth987/CHub.git/pkg/logger"
+	"github.com/ruth987/CHub.git/pkg/mailer"
+	"github.com/ruth987/CHub.git/pkg/metrics"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
+	"github.com/ruth987/CHub.git/pkg/server"
+	"github.com/ruth987/CHub.git/pkg/storage"
+	"github.com/ruth987/CHub.git/pkg/unfurl"
 )
 
 func main() {
-	err := godotenv.Load()
+	opts, err := config.ParseFlags(os.Args[0], os.Args[1:])
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		os.Exit(2)
 	}
-
-	// Initialize database
-	dbConfig := &database.Config{
-		Host:     os.Getenv("DB_HOST"),
-		Port:     os.Getenv("DB_PORT"),
-		User:     os.Getenv("DB_USER"),
-		Password: os.Getenv("DB_PASSWORD"),
-		DBName:   os.Getenv("DB_NAME"),
+	cfg, err := config.Load(opts)
+	if err != nil {
+		log.Fatal(err)
 	}
+	if opts.Print {
+		fmt.Print(cfg)
+		return
+	}
+
+	// Initialize logger: JSON in production, text everywhere else. It also
+	// becomes the default so stray log calls share the same format.
+	appLogger := logger.New(os.Stdout, logger.ConfigForEnv(cfg.Env))
+	slog.SetDefault(appLogger)
+	appLogger.Info("loaded configuration", "config", cfg)
+
+	// ctx is cancelled on SIGINT or SIGTERM, which starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	db, err := database.NewPostgresDB(dbConfig)
+	// Initialize database
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:             cfg.Database.Host,
+		Port:             cfg.Database.Port,
+		User:             cfg.Database.User,
+		Password:         cfg.Database.Password,
+		DBName:           cfg.Database.Name,
+		SSLMode:          cfg.Database.SSLMode,
+		StatementTimeout: cfg.Database.StatementTimeout,
+		ConnectTimeout:   cfg.Database.ConnectTimeout,
+		MaxOpenConns:     cfg.Database.MaxOpenConns,
+		MaxIdleConns:     cfg.Database.MaxIdleConns,
+		ConnMaxLifetime:  cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime:  cfg.Database.ConnMaxIdleTime,
+	}, appLogger)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
+	defer func() {
+		// Runs after the server and workers have stopped
+		if err := db.Close(); err != nil {
+			appLogger.Error("failed to close database", "error", err)
+		}
+	}()
 
 	// Initialize JWT service
-	jwtService := auth.NewJWTService(os.Getenv("JWT_SECRET"))
+	jwtService := auth.NewJWTService(cfg.Auth.JWTSecret)
+
+	// Initialize mailer
+	mail, err := mailer.New(&mailer.Config{
+		Driver:   cfg.Mail.Driver,
+		Host:     cfg.Mail.SMTPHost,
+		Port:     cfg.Mail.SMTPPort,
+		Username: cfg.Mail.SMTPUsername,
+		Password: cfg.Mail.SMTPPassword,
+		From:     cfg.Mail.From,
+		Dir:      cfg.Mail.LogDir,
+		Logger:   appLogger,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize rate limiting. The Postgres store shares limits between
+	// instances; the memory store is fine for a single one.
+	var rateLimitStore ratelimit.Store
+	if cfg.RateLimit.Store == "postgres" {
+		rateLimitStore = ratelimit.NewPostgresStore(db)
+	} else {
+		rateLimitStore = ratelimit.NewMemoryStore()
+	}
+	loginLockout := ratelimit.NewLockout(rateLimitStore, ratelimit.DefaultLockoutPolicy())
+	loginLimit := ratelimit.NewLimiter(rateLimitStore, "login:email", ratelimit.PerHour(30))
 
 	// Initialize repositories
 	userRepo := postgres.NewUserRepository(db)
@@ -47,29 +120,159 @@ func main() {
 	commentRepo := postgres.NewCommentRepository(db)
 	savedPostRepo := postgres.NewSavedPostRepository(db)
 	prayerRequestRepo := postgres.NewPrayerRequestRepository(db)
+	conversationRepo := postgres.NewConversationRepository(db)
+	userBlockRepo := postgres.NewUserBlockRepository(db)
+	userMuteRepo := postgres.NewUserMuteRepository(db)
+	revisionRepo := postgres.NewRevisionRepository(db)
+	userTokenRepo := postgres.NewUserTokenRepository(db)
+	uploadRepo := postgres.NewUploadRepository(db)
+	uploadSessionRepo := postgres.NewUploadSessionRepository(db)
+	attachmentRepo := postgres.NewAttachmentRepository(db)
+	linkPreviewRepo := postgres.NewLinkPreviewRepository(db)
+	mediaRepo := postgres.NewMediaRepository(db)
+	auditRepo := postgres.NewAuditRepository(db)
+	accountRepo := postgres.NewAccountRepository(db)
+	transactor := postgres.NewTransactor(db)
+
+	// Initialize realtime hub for live message delivery
+	messageHub := realtime.NewHub()
+
+	// Backups, audit exports and data exports go to their own store: the
+	// uploads store is publicly readable
+	backupStore, err := storage.New(ctx, &storage.Config{
+		Driver: cfg.Backup.Driver,
+		Bucket: cfg.Backup.Bucket,
+		Region: cfg.Backup.Region,
+		Dir:    cfg.Backup.LocalDir,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize backup storage: %v", err)
+	}
+	// Snapshots made while backups were on still need scrubbing when an
+	// account is erased, so the service exists either way
+	backupService := backup.NewService(db, backupStore, appLogger)
 
 	// Initialize usecases
-	userUsecase := usecase.NewUserUsecase(userRepo, jwtService)
-	postUsecase := usecase.NewPostUsecase(postRepo, commentRepo)
-	commentUsecase := usecase.NewCommentUsecase(commentRepo, postRepo)
-	savedPostUsecase := usecase.NewSavedPostUsecase(savedPostRepo, postRepo)
+	auditUsecase := usecase.NewAuditUsecase(auditRepo, userRepo, backupStore, cfg.Audit.Retention, appLogger)
+	linkPreviewQueue := worker.NewLinkPreviewQueue(256)
+	linkPreviewUsecase := usecase.NewLinkPreviewUsecase(linkPreviewRepo, unfurl.New(unfurl.DefaultConfig()), linkPreviewQueue, appLogger)
+	userUsecase := usecase.NewUserUsecase(userRepo, userBlockRepo, userTokenRepo, uploadRepo, attachmentRepo, mediaRepo, linkPreviewUsecase, auditUsecase, transactor, jwtService, mail, loginLockout, loginLimit, appLogger, cfg.AppURL)
+	postUsecase := usecase.NewPostUsecase(postRepo, commentRepo, revisionRepo, userRepo, uploadRepo, attachmentRepo, mediaRepo, linkPreviewUsecase, auditUsecase, transactor)
+	commentUsecase := usecase.NewCommentUsecase(commentRepo, postRepo, userBlockRepo, revisionRepo, userRepo, auditUsecase, transactor)
+	savedPostUsecase := usecase.NewSavedPostUsecase(savedPostRepo, postRepo, uploadRepo, attachmentRepo, mediaRepo, linkPreviewUsecase)
 	prayerRequestUsecase := usecase.NewPrayerRequestUsecase(prayerRequestRepo)
+	messageUsecase := usecase.NewMessageUsecase(conversationRepo, userRepo, userBlockRepo, messageHub, transactor, jwtService)
+	userBlockUsecase := usecase.NewUserBlockUsecase(userBlockRepo, userRepo)
+	userMuteUsecase := usecase.NewUserMuteUsecase(userMuteRepo, userRepo)
+	trashUsecase := usecase.NewTrashUsecase(postRepo, commentRepo)
+
+	// Start background workers. They stop when a shutdown signal cancels ctx.
+	var workers sync.WaitGroup
+	workers.Add(4)
+	go func() {
+		defer workers.Done()
+		worker.NewTrashPurger(trashUsecase, time.Hour, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewRateLimitPruner(rateLimitStore, time.Hour, 24*time.Hour, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewAuditArchiver(auditUsecase, cfg.Audit.Export, time.Hour, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewLinkPreviewFetcher(linkPreviewUsecase, linkPreviewQueue, 2, appLogger).Run(ctx)
+	}()
 
-	// Initialize S3 service
-	s3Service, err := s3.NewService()
+	// Initialize file storage
+	store, err := storage.New(ctx, &storage.Config{
+		Driver:     cfg.Storage.Driver,
+		Bucket:     cfg.Storage.Bucket,
+		Region:     cfg.Storage.Region,
+		Dir:        cfg.Storage.LocalDir,
+		PublicURL:  cfg.Storage.PublicURL,
+		UploadURL:  cfg.Storage.UploadURL,
+		SigningKey: cfg.Storage.SigningKey,
+	})
 	if err != nil {
-		log.Fatalf("Failed to initialize S3 service: %v", err)
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+	fileService := files.NewService(store)
+	imageQueue := worker.NewImageQueue(cfg.Images.QueueSize)
+	uploadUsecase := usecase.NewUploadUsecase(uploadRepo, uploadSessionRepo, userRepo, postUsecase, store, imageQueue, transactor, appLogger)
+	mediaUsecase := usecase.NewMediaUsecase(mediaRepo, uploadRepo, store, appLogger)
+	accountUsecase := usecase.NewAccountUsecase(accountRepo, userRepo, uploadRepo, auditRepo, auditUsecase, transactor, store, backupStore,
+		[]domain.Scrubber{backupService, auditUsecase, fileService}, mail, cfg.Account.DeletionGrace, cfg.Account.ExportTTL, appLogger)
+	workers.Add(5)
+	go func() {
+		defer workers.Done()
+		worker.NewImageProcessor(uploadUsecase, imageQueue, cfg.Images.Workers, time.Minute, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewUploadSessionCleaner(uploadUsecase, 10*time.Minute, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewMediaCollector(uploadUsecase, cfg.MediaGC.Interval, cfg.MediaGC.Grace, cfg.MediaGC.DryRun, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewDataExporter(accountUsecase, 30*time.Second, appLogger).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		worker.NewAccountEraser(accountUsecase, time.Hour, appLogger).Run(ctx)
+	}()
+
+	if cfg.Backup.Enabled {
+		backupPolicy := backup.Policy{
+			KeepLast:    cfg.Backup.KeepLast,
+			KeepDaily:   cfg.Backup.KeepDaily,
+			KeepWeekly:  cfg.Backup.KeepWeekly,
+			KeepMonthly: cfg.Backup.KeepMonthly,
+		}
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			worker.NewBackupScheduler(backupService, cfg.Backup.Interval, backupPolicy, appLogger).Run(ctx)
+		}()
 	}
 
+	// The local driver's files are served by the API itself
+	var uploadsDir string
+	if cfg.Storage.Driver == storage.DriverLocal {
+		uploadsDir = cfg.Storage.LocalDir
+	}
+
+	// Initialize metrics
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(db, "chub")
+
 	// Initialize handlers
-	userHandler := handler.NewUserHandler(userUsecase, s3Service)
-	postHandler := handler.NewPostHandler(postUsecase, s3Service)
+	userHandler := handler.NewUserHandler(userUsecase, appMetrics, appLogger)
+	postHandler := handler.NewPostHandler(postUsecase, appMetrics, appLogger)
 	commentHandler := handler.NewCommentHandler(commentUsecase)
 	savedPostHandler := handler.NewSavedPostHandler(savedPostUsecase)
 	prayerRequestHandler := handler.NewPrayerRequestHandler(prayerRequestUsecase)
+	messageHandler := handler.NewMessageHandler(messageUsecase, messageHub)
+	userBlockHandler := handler.NewUserBlockHandler(userBlockUsecase)
+	userMuteHandler := handler.NewUserMuteHandler(userMuteUsecase)
+	trashHandler := handler.NewTrashHandler(trashUsecase)
+	healthHandler := handler.NewHealthHandler(appLogger,
+		handler.HealthCheck{Name: "database", Check: db.PingContext},
+		handler.HealthCheck{Name: "storage", Check: fileService.Ping},
+	)
 
 	// Initialize upload handler
-	uploadHandler := handler.NewUploadHandler(s3Service)
+	direct, _ := store.(storage.DirectUploader)
+	uploadHandler := handler.NewUploadHandler(uploadUsecase, direct)
+	linkPreviewHandler := handler.NewLinkPreviewHandler(linkPreviewUsecase)
+	mediaHandler := handler.NewMediaHandler(mediaUsecase)
+	auditHandler := handler.NewAuditHandler(auditUsecase)
+	accountHandler := handler.NewAccountHandler(accountUsecase)
 
 	// Setup router
 	router := httpDelivery.NewRouter(
@@ -77,51 +280,134 @@ func main() {
 		postHandler,
 		commentHandler,
 		savedPostHandler,
-		authMiddleware(jwtService),
+		authMiddleware(jwtService, userRepo),
+		streamAuthMiddleware(jwtService, userRepo),
 		prayerRequestHandler,
+		messageHandler,
+		userBlockHandler,
+		userMuteHandler,
+		trashHandler,
+		healthHandler,
+		uploadHandler,
+		linkPreviewHandler,
+		mediaHandler,
+		auditHandler,
+		accountHandler,
+		rateLimitStore,
+		httpDelivery.Config{
+			RateLimits:     httpDelivery.DefaultRateLimits(),
+			MaxBodyBytes:   cfg.Server.MaxBodyBytes,
+			CORSOrigins:    cfg.CORS.Origins(cfg.Env),
+			UploadsDir:     uploadsDir,
+			TrustedProxies: cfg.Server.TrustedProxies,
+		},
+		appMetrics,
+		appLogger,
 	)
 
-	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	if err := httpDelivery.CheckRoutes(router); err != nil {
+		log.Fatal(err)
+	}
 
-	// File upload endpoint
-	router.POST("/api/upload", uploadHandler.UploadFile)
+	// Start the server and block until a shutdown signal
+	srv := server.New(server.Config{
+		Addr:              cfg.Server.Addr,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ShutdownTimeout:   cfg.Server.ShutdownTimeout,
+	}, router, appLogger)
+	srv.RegisterOnShutdown(messageHub.Close)
+	if err := srv.Run(ctx); err != nil {
+		appLogger.Error("server stopped with error", "error", err)
+	}
 
-	// Start the server
-	if err := router.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	// Give the workers as long as the server had to drain, so a stuck job
+	// cannot keep the database open forever.
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	waitCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	select {
+	case <-done:
+		appLogger.Info("shutdown complete")
+	case <-waitCtx.Done():
+		appLogger.Warn("background workers did not stop in time", "timeout", cfg.Server.ShutdownTimeout)
 	}
 }
 
-func authMiddleware(jwtService *auth.JWTService) gin.HandlerFunc {
+// authMiddleware accepts tokens issued at the user's current token version,
+// so changing the password signs out every other session. Tokens of removed
+// accounts find no user, and anonymizing an account bumps its version, so
+// erased accounts are signed out too.
+func authMiddleware(jwtService *auth.JWTService, userRepo domain.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			handler.RespondError(c, domain.Unauthorized("authorization header is required"))
 			return
 		}
 
 		// Extract token from "Bearer <token>"
-		tokenString := authHeader[7:]
+		tokenString, ok := strings.CutPrefix(authHeader, "Bearer ")
+		if !ok {
+			handler.RespondError(c, domain.Unauthorized("authorization header must be a bearer token"))
+			return
+		}
 
-		userID, err := jwtService.ValidateToken(tokenString)
+		claims, err := jwtService.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+			handler.RespondError(c, domain.Unauthorized("invalid token"))
 			return
 		}
 
-		c.Set("user_id", userID)
-		c.Next()
+		authorize(c, userRepo, claims)
 	}
 }
+
+// streamAuthMiddleware accepts a stream ticket from the "ticket" query
+// parameter as well as a bearer token, for clients using EventSource
+func streamAuthMiddleware(jwtService *auth.JWTService, userRepo domain.UserRepository) gin.HandlerFunc {
+	bearer := authMiddleware(jwtService, userRepo)
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			bearer(c)
+			return
+		}
+
+		claims, err := jwtService.ValidateStreamTicket(ticket)
+		if err != nil {
+			handler.RespondError(c, domain.Unauthorized("invalid stream ticket"))
+			return
+		}
+
+		authorize(c, userRepo, claims)
+	}
+}
+
+// authorize lets the request through if the user still exists and the token
+// was issued after their sessions were last revoked
+func authorize(c *gin.Context, userRepo domain.UserRepository, claims *auth.Claims) {
+	version, err := userRepo.GetTokenVersion(c.Request.Context(), claims.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		handler.RespondError(c, domain.Unauthorized("invalid token"))
+		return
+	}
+	if err != nil {
+		handler.RespondError(c, err)
+		return
+	}
+	if claims.Version != version {
+		handler.RespondError(c, domain.Unauthorized("token has been revoked"))
+		return
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Next()
+}
@@ -0,0 +1,124 @@
+// Command restore lists the snapshots in the backup store and loads one back
+// into the database. The schema must already be migrated, and the API should
+// be stopped while it runs.
+//
+//	restore -list
+//	restore -snapshot latest
+//	restore -snapshot snapshots/chub-20250101T030000Z.jsonl.gz -force
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/config"
+	"github.com/ruth987/CHub.git/internal/services/backup"
+	"github.com/ruth987/CHub.git/pkg/database"
+	"github.com/ruth987/CHub.git/pkg/logger"
+	"github.com/ruth987/CHub.git/pkg/storage"
+)
+
+func main() {
+	var (
+		configFile string
+		list       bool
+		key        string
+		force      bool
+	)
+	flag.StringVar(&configFile, "config", "", "path to a YAML config file (default "+config.DefaultFile+" if present)")
+	flag.BoolVar(&list, "list", false, "list the available snapshots and exit")
+	flag.StringVar(&key, "snapshot", "", `key of the snapshot to restore, or "latest"`)
+	flag.BoolVar(&force, "force", false, "empty the tables first, along with every table that references them")
+	flag.Parse()
+	if !list && key == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(&config.Options{File: configFile})
+	if err != nil {
+		log.Fatal(err)
+	}
+	appLogger := logger.New(os.Stderr, logger.ConfigForEnv(cfg.Env))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	store, err := storage.New(ctx, &storage.Config{
+		Driver: cfg.Backup.Driver,
+		Bucket: cfg.Backup.Bucket,
+		Region: cfg.Backup.Region,
+		Dir:    cfg.Backup.LocalDir,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize backup storage: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:           cfg.Database.Host,
+		Port:           cfg.Database.Port,
+		User:           cfg.Database.User,
+		Password:       cfg.Database.Password,
+		DBName:         cfg.Database.Name,
+		SSLMode:        cfg.Database.SSLMode,
+		ConnectTimeout: cfg.Database.ConnectTimeout,
+		MaxOpenConns:   1,
+		MaxIdleConns:   1,
+	}, appLogger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	service := backup.NewService(db, store, appLogger)
+	snapshots, err := service.List(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if list {
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s\t%s\t%d bytes\n", snapshot.Key, snapshot.CreatedAt.Format("2006-01-02 15:04:05 MST"), snapshot.Size)
+		}
+		return
+	}
+
+	snapshot, ok := find(snapshots, key)
+	if !ok {
+		log.Fatalf("No snapshot %q; run with -list to see the available ones", key)
+	}
+
+	appLogger.Info("restoring backup", "key", snapshot.Key, "created_at", snapshot.CreatedAt, "force", force)
+	report, err := service.Restore(ctx, snapshot, backup.RestoreOptions{Force: force})
+	if err != nil {
+		appLogger.Error("restore failed; the database was left unchanged", "error", err)
+		os.Exit(1)
+	}
+	for _, table := range backup.Tables {
+		if rows, ok := report.Rows[table.Name]; ok {
+			fmt.Printf("%-20s %d rows\n", table.Name, rows)
+		}
+	}
+}
+
+// find returns the snapshot with the given key, or the newest for "latest"
+func find(snapshots []backup.Snapshot, key string) (backup.Snapshot, bool) {
+	if key == "latest" {
+		if len(snapshots) == 0 {
+			return backup.Snapshot{}, false
+		}
+		return snapshots[len(snapshots)-1], true
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Key == key {
+			return snapshot, true
+		}
+	}
+	return backup.Snapshot{}, false
+}
@@ -0,0 +1,341 @@
+// Package config loads the API's settings into one typed struct. Values come
+// from built-in defaults, then an optional YAML file, then environment
+// variables (including a .env file), then command-line flags; each source
+// overrides the ones before it.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/ruth987/CHub.git/pkg/database"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when no file is named with -config or CONFIG_FILE. It is
+// ignored when missing.
+const DefaultFile = "config/local.yaml"
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+type Config struct {
+	// Env is "development" or "production"
+	Env string `yaml:"env" env:"APP_ENV"`
+	// AppURL is the frontend's base URL, used in emailed links
+	AppURL string `yaml:"app_url" env:"APP_URL"`
+
+	Server    Server    `yaml:"server"`
+	Database  Database  `yaml:"database"`
+	Auth      Auth      `yaml:"auth"`
+	CORS      CORS      `yaml:"cors"`
+	Storage   Storage   `yaml:"storage"`
+	Mail      Mail      `yaml:"mail"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Images    Images    `yaml:"images"`
+	MediaGC   MediaGC   `yaml:"media_gc"`
+	Backup    Backup    `yaml:"backup"`
+	Audit     Audit     `yaml:"audit"`
+	Account   Account   `yaml:"account"`
+}
+
+type Server struct {
+	Addr              string        `yaml:"addr" env:"HTTP_ADDR"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// TrustedProxies are the IPs or CIDR ranges of the load balancers in front
+	// of the API. Only they may set the client IP with X-Forwarded-For; with
+	// none, the header is ignored and the client is the connecting peer.
+	TrustedProxies []string `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES"`
+}
+
+type Database struct {
+	Host             string        `yaml:"host" env:"DB_HOST"`
+	Port             string        `yaml:"port" env:"DB_PORT"`
+	User             string        `yaml:"user" env:"DB_USER"`
+	Password         string        `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name             string        `yaml:"name" env:"DB_NAME"`
+	SSLMode          string        `yaml:"ssl_mode" env:"DB_SSLMODE"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	MaxOpenConns     int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+type Auth struct {
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+}
+
+type CORS struct {
+	// AllowedOrigins are full origins such as "https://chub.example", allowed
+	// in every environment. A leftmost "*" label, as in
+	// "https://*.chub.example", matches any subdomain.
+	AllowedOrigins []string `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	// Environments adds origins for one APP_ENV only, such as local dev
+	// servers that production must not trust
+	Environments map[string][]string `yaml:"environments"`
+}
+
+// Origins returns the origins allowed in env.
+func (c CORS) Origins(env string) []string {
+	origins := append([]string(nil), c.AllowedOrigins...)
+	for _, origin := range c.Environments[env] {
+		if !slices.Contains(origins, origin) {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+type Storage struct {
+	// Driver is "s3" or "local"
+	Driver    string `yaml:"driver" env:"STORAGE_DRIVER"`
+	Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+	Region    string `yaml:"region" env:"S3_REGION"`
+	LocalDir  string `yaml:"local_dir" env:"STORAGE_LOCAL_DIR"`
+	PublicURL string `yaml:"public_url" env:"STORAGE_PUBLIC_URL"`
+	// UploadURL and SigningKey are for the local driver's presigned uploads
+	UploadURL  string `yaml:"upload_url" env:"STORAGE_UPLOAD_URL"`
+	SigningKey string `yaml:"signing_key" env:"STORAGE_SIGNING_KEY" secret:"true"`
+}
+
+type Mail struct {
+	// Driver is "smtp" or "log"
+	Driver       string `yaml:"driver" env:"MAIL_DRIVER"`
+	SMTPHost     string `yaml:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     string `yaml:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `yaml:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true"`
+	From         string `yaml:"from" env:"MAIL_FROM"`
+	LogDir       string `yaml:"log_dir" env:"MAIL_LOG_DIR"`
+}
+
+type RateLimit struct {
+	// Store is "memory" or "postgres"
+	Store string `yaml:"store" env:"RATE_LIMIT_STORE"`
+}
+
+type Images struct {
+	// Workers is how many images are resized at once
+	Workers int `yaml:"workers" env:"IMAGE_WORKERS"`
+	// QueueSize is how many uploads may wait for a worker before the rest are
+	// left for the periodic sweep
+	QueueSize int `yaml:"queue_size" env:"IMAGE_QUEUE_SIZE"`
+}
+
+// MediaGC controls the job that deletes uploads nothing uses any more.
+type MediaGC struct {
+	Interval time.Duration `yaml:"interval" env:"MEDIA_GC_INTERVAL"`
+	// Grace is how long an unused upload is kept, which also gives clients
+	// time to attach a file after uploading it
+	Grace time.Duration `yaml:"grace" env:"MEDIA_GC_GRACE"`
+	// DryRun logs what would be deleted without deleting it
+	DryRun bool `yaml:"dry_run" env:"MEDIA_GC_DRY_RUN"`
+}
+
+// Backup controls the periodic exports of users, posts and the rest of the
+// community's data. Snapshots hold password hashes and emails, so they go to
+// their own store rather than the public uploads one.
+type Backup struct {
+	Enabled bool `yaml:"enabled" env:"BACKUP_ENABLED"`
+	// Interval is how old the newest snapshot may get before another is made
+	Interval time.Duration `yaml:"interval" env:"BACKUP_INTERVAL"`
+	// Driver is "s3" or "local"
+	Driver   string `yaml:"driver" env:"BACKUP_STORAGE_DRIVER"`
+	Bucket   string `yaml:"bucket" env:"BACKUP_S3_BUCKET"`
+	Region   string `yaml:"region" env:"BACKUP_S3_REGION"`
+	LocalDir string `yaml:"local_dir" env:"BACKUP_LOCAL_DIR"`
+	// KeepLast, KeepDaily, KeepWeekly and KeepMonthly are the retention
+	// policy; see backup.Policy
+	KeepLast    int `yaml:"keep_last" env:"BACKUP_KEEP_LAST"`
+	KeepDaily   int `yaml:"keep_daily" env:"BACKUP_KEEP_DAILY"`
+	KeepWeekly  int `yaml:"keep_weekly" env:"BACKUP_KEEP_WEEKLY"`
+	KeepMonthly int `yaml:"keep_monthly" env:"BACKUP_KEEP_MONTHLY"`
+}
+
+// Audit controls how long the audit log is kept and whether each day of it is
+// exported. Exports go to the backup store, since events carry IP addresses.
+type Audit struct {
+	// Retention is how long events stay in the database; zero keeps them
+	// forever
+	Retention time.Duration `yaml:"retention" env:"AUDIT_RETENTION"`
+	Export    bool          `yaml:"export" env:"AUDIT_EXPORT"`
+}
+
+// Account controls data exports and account deletion. Exports are kept in
+// the backup store, since they hold emails and IP addresses.
+type Account struct {
+	// DeletionGrace is how long a deletion request waits before the account
+	// is erased, so it can be cancelled
+	DeletionGrace time.Duration `yaml:"deletion_grace" env:"ACCOUNT_DELETION_GRACE"`
+	// ExportTTL is how long a finished data export can be downloaded
+	ExportTTL time.Duration `yaml:"export_ttl" env:"DATA_EXPORT_TTL"`
+}
+
+// Default returns the settings used when nothing overrides them.
+func Default() *Config {
+	return &Config{
+		Env:    EnvDevelopment,
+		AppURL: "http://localhost:3000",
+		Server: Server{
+			Addr:              ":8080",
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      32 << 20,
+			ShutdownTimeout:   20 * time.Second,
+		},
+		Database: Database{
+			Host:             "localhost",
+			Port:             "5432",
+			SSLMode:          "disable",
+			StatementTimeout: database.DefaultStatementTimeout,
+			ConnectTimeout:   10 * time.Second,
+			MaxOpenConns:     25,
+			MaxIdleConns:     25,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+		},
+		CORS: CORS{
+			Environments: map[string][]string{
+				EnvDevelopment: {"http://localhost:3000", "http://127.0.0.1:3000"},
+			},
+		},
+		Storage: Storage{
+			Driver:   "s3",
+			Bucket:   "christian-hub-bucket",
+			Region:   "eu-north-1",
+			LocalDir: "./uploads",
+		},
+		Mail: Mail{
+			Driver: "log",
+		},
+		RateLimit: RateLimit{
+			Store: "memory",
+		},
+		Images: Images{
+			Workers:   2,
+			QueueSize: 256,
+		},
+		MediaGC: MediaGC{
+			Interval: 6 * time.Hour,
+			Grace:    24 * time.Hour,
+		},
+		Backup: Backup{
+			Enabled:     true,
+			Interval:    24 * time.Hour,
+			Driver:      "local",
+			Region:      "eu-north-1",
+			LocalDir:    "./backups",
+			KeepLast:    3,
+			KeepDaily:   7,
+			KeepWeekly:  4,
+			KeepMonthly: 6,
+		},
+		Audit: Audit{
+			Retention: 365 * 24 * time.Hour,
+		},
+		Account: Account{
+			DeletionGrace: 14 * 24 * time.Hour,
+			ExportTTL:     7 * 24 * time.Hour,
+		},
+	}
+}
+
+// Options are the command-line flags Load understands.
+type Options struct {
+	// File is the YAML file to read
+	File string
+	// Addr overrides the server's listen address
+	Addr string
+	// Print asks the caller to print the effective config and exit
+	Print bool
+}
+
+// ParseFlags reads -config, -addr and -print-config from args.
+func ParseFlags(name string, args []string) (*Options, error) {
+	opts := &Options{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&opts.File, "config", "", "path to a YAML config file (default "+DefaultFile+" if present)")
+	flags.StringVar(&opts.Addr, "addr", "", "listen address, overrides HTTP_ADDR")
+	flags.BoolVar(&opts.Print, "print-config", false, "print the effective config with secrets redacted and exit")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// Load builds the configuration from every source and validates it.
+func Load(opts *Options) (*Config, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	// A .env file is optional; real deployments set the variables directly
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	cfg := Default()
+
+	file, required := opts.File, true
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	if file == "" {
+		file, required = DefaultFile, false
+	}
+	if err := cfg.loadFile(file, required); err != nil {
+		return nil, err
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if opts.Addr != "" {
+		cfg.Server.Addr = opts.Addr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(name string, required bool) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", name, err)
+	}
+	return nil
+}
+
+// IsProduction reports whether the API runs in production.
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ruth987/CHub.git/pkg/mailer"
+	"github.com/ruth987/CHub.git/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// minProductionSecret is the shortest JWT secret accepted in production.
+const minProductionSecret = 32
+
+// Validate reports every problem with the configuration at once so a broken
+// deploy can be fixed in one go.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Env == EnvDevelopment || c.Env == EnvProduction,
+		"APP_ENV must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env)
+
+	// Auth
+	check(c.Auth.JWTSecret != "", "JWT_SECRET is required")
+	if c.IsProduction() {
+		check(len(c.Auth.JWTSecret) >= minProductionSecret,
+			"JWT_SECRET must be at least %d characters in production", minProductionSecret)
+	}
+
+	// Server
+	check(c.Server.Addr != "", "HTTP_ADDR is required")
+	check(c.Server.ReadTimeout > 0, "HTTP_READ_TIMEOUT must be positive")
+	check(c.Server.ReadHeaderTimeout > 0, "HTTP_READ_HEADER_TIMEOUT must be positive")
+	check(c.Server.WriteTimeout > 0, "HTTP_WRITE_TIMEOUT must be positive")
+	check(c.Server.IdleTimeout > 0, "HTTP_IDLE_TIMEOUT must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "HTTP_MAX_HEADER_BYTES must be positive")
+	check(c.Server.MaxBodyBytes > 0, "HTTP_MAX_BODY_BYTES must be positive")
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	for _, proxy := range c.Server.TrustedProxies {
+		if err := validateProxy(proxy); err != nil {
+			errs = append(errs, fmt.Errorf("HTTP_TRUSTED_PROXIES: %w", err))
+		}
+	}
+
+	// Database
+	check(c.Database.Host != "", "DB_HOST is required")
+	check(c.Database.Name != "", "DB_NAME is required")
+	check(c.Database.StatementTimeout >= 0, "DB_STATEMENT_TIMEOUT must not be negative")
+	check(c.Database.ConnectTimeout > 0, "DB_CONNECT_TIMEOUT must be positive")
+	check(c.Database.MaxOpenConns >= 0, "DB_MAX_OPEN_CONNS must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "DB_MAX_IDLE_CONNS must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS (%d) must not exceed DB_MAX_OPEN_CONNS (%d)", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	check(c.Database.ConnMaxLifetime >= 0, "DB_CONN_MAX_LIFETIME must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "DB_CONN_MAX_IDLE_TIME must not be negative")
+
+	// CORS
+	check(len(c.CORS.Origins(c.Env)) > 0, "CORS_ALLOWED_ORIGINS needs at least one origin for %s", c.Env)
+	for _, origin := range c.CORS.AllowedOrigins {
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err))
+		}
+	}
+	for env, origins := range c.CORS.Environments {
+		check(env == EnvDevelopment || env == EnvProduction,
+			"cors.environments: unknown environment %q", env)
+		for _, origin := range origins {
+			if err := validateOrigin(origin); err != nil {
+				errs = append(errs, fmt.Errorf("cors.environments.%s: %w", env, err))
+			}
+		}
+	}
+
+	// Storage
+	switch c.Storage.Driver {
+	case storage.DriverS3:
+		check(c.Storage.Bucket != "", "S3_BUCKET is required for the s3 storage driver")
+		check(c.Storage.Region != "", "S3_REGION is required for the s3 storage driver")
+	case storage.DriverLocal:
+		check(c.Storage.LocalDir != "", "STORAGE_LOCAL_DIR is required for the local storage driver")
+		if c.IsProduction() {
+			check(c.Storage.SigningKey != "", "STORAGE_SIGNING_KEY is required for the local storage driver in production")
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_DRIVER must be \"s3\" or \"local\", got %q", c.Storage.Driver))
+	}
+
+	// Mail
+	switch c.Mail.Driver {
+	case mailer.DriverSMTP:
+		check(c.Mail.SMTPHost != "", "SMTP_HOST is required for the smtp mail driver")
+		check(c.Mail.From != "", "MAIL_FROM is required for the smtp mail driver")
+	case mailer.DriverLog:
+		// Nothing reaches members, and the links they need end up on disk
+		check(!c.IsProduction(), "MAIL_DRIVER must be \"smtp\" in production")
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER must be \"smtp\" or \"log\", got %q", c.Mail.Driver))
+	}
+
+	check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "postgres",
+		"RATE_LIMIT_STORE must be \"memory\" or \"postgres\", got %q", c.RateLimit.Store)
+
+	check(c.Images.Workers > 0, "IMAGE_WORKERS must be positive")
+	check(c.Images.QueueSize > 0, "IMAGE_QUEUE_SIZE must be positive")
+	check(c.MediaGC.Interval > 0, "MEDIA_GC_INTERVAL must be positive")
+	check(c.MediaGC.Grace >= time.Hour, "MEDIA_GC_GRACE must be at least 1h, got %s", c.MediaGC.Grace)
+
+	// Backups
+	if c.Backup.Enabled {
+		check(c.Backup.Interval >= time.Hour, "BACKUP_INTERVAL must be at least 1h, got %s", c.Backup.Interval)
+		check(c.Backup.KeepLast >= 1, "BACKUP_KEEP_LAST must be at least 1")
+		check(c.Backup.KeepDaily >= 0 && c.Backup.KeepWeekly >= 0 && c.Backup.KeepMonthly >= 0,
+			"BACKUP_KEEP_DAILY, BACKUP_KEEP_WEEKLY and BACKUP_KEEP_MONTHLY must not be negative")
+	}
+	switch c.Backup.Driver {
+	case storage.DriverS3:
+		check(c.Backup.Bucket != "", "BACKUP_S3_BUCKET is required for the s3 backup driver")
+		check(c.Backup.Region != "", "BACKUP_S3_REGION is required for the s3 backup driver")
+		check(c.Storage.Driver != storage.DriverS3 || c.Backup.Bucket != c.Storage.Bucket,
+			"BACKUP_S3_BUCKET must not be the uploads bucket %q, whose files are public", c.Storage.Bucket)
+	case storage.DriverLocal:
+		check(c.Backup.LocalDir != "", "BACKUP_LOCAL_DIR is required for the local backup driver")
+		check(c.Storage.Driver != storage.DriverLocal || !within(c.Backup.LocalDir, c.Storage.LocalDir),
+			"BACKUP_LOCAL_DIR must not be inside STORAGE_LOCAL_DIR %q, which is served publicly", c.Storage.LocalDir)
+	default:
+		errs = append(errs, fmt.Errorf("BACKUP_STORAGE_DRIVER must be \"s3\" or \"local\", got %q", c.Backup.Driver))
+	}
+
+	// Audit log; exports cover the last week, so events must outlive it
+	check(c.Audit.Retention == 0 || c.Audit.Retention >= 7*24*time.Hour,
+		"AUDIT_RETENTION must be 0 (keep forever) or at least 168h, got %s", c.Audit.Retention)
+
+	// Accounts
+	check(c.Account.DeletionGrace >= 0, "ACCOUNT_DELETION_GRACE must not be negative, got %s", c.Account.DeletionGrace)
+	check(c.Account.ExportTTL >= time.Hour, "DATA_EXPORT_TTL must be at least 1h, got %s", c.Account.ExportTTL)
+
+	if c.AppURL != "" {
+		if u, err := url.Parse(c.AppURL); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("APP_URL must be an absolute URL, got %q", c.AppURL))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateProxy checks a trusted proxy is an IP address or CIDR range
+func validateProxy(proxy string) error {
+	if _, err := netip.ParsePrefix(proxy); err == nil {
+		return nil
+	}
+	if _, err := netip.ParseAddr(proxy); err != nil {
+		return fmt.Errorf("%q is not an IP address or CIDR range", proxy)
+	}
+	return nil
+}
+
+// validateOrigin accepts a scheme and host with an optional port, such as
+// "https://chub.example" or "http://localhost:3000". The host may start with
+// a "*." wildcard label as long as a registrable domain follows it.
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("%q is not an http(s) origin", origin)
+	}
+	if strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q must be an origin without a path", origin)
+	}
+	host := u.Hostname()
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		host = rest
+		if !strings.Contains(host, ".") {
+			return fmt.Errorf("%q: a wildcard needs at least two labels after it", origin)
+		}
+	}
+	if strings.Contains(host, "*") {
+		return fmt.Errorf("%q: only the leftmost label may be a wildcard", origin)
+	}
+	return nil
+}
+
+// within reports whether dir is parent or a directory under it.
+func within(dir, parent string) bool {
+	dir, parent = filepath.Clean(dir), filepath.Clean(parent)
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// redacted is shown in place of a secret that is set.
+const redacted = "[redacted]"
+
+// Redacted returns a copy with every field tagged `secret` masked, safe to log.
+func (c *Config) Redacted() *Config {
+	out := *c
+	out.CORS.AllowedOrigins = append([]string(nil), c.CORS.AllowedOrigins...)
+	out.CORS.Environments = maps.Clone(c.CORS.Environments)
+	_ = walk(reflect.ValueOf(&out).Elem(), func(field reflect.StructField, v reflect.Value) error {
+		if field.Tag.Get("secret") == "true" && v.String() != "" {
+			v.SetString(redacted)
+		}
+		return nil
+	})
+	return &out
+}
+
+// LogValue logs the redacted configuration as nested groups named after the
+// YAML keys.
+func (c *Config) LogValue() slog.Value {
+	return groupValue(reflect.ValueOf(c.Redacted()).Elem())
+}
+
+func groupValue(v reflect.Value) slog.Value {
+	t := v.Type()
+	attrs := make([]slog.Attr, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.Type.Kind() == reflect.Struct {
+			attrs = append(attrs, slog.Attr{Key: name, Value: groupValue(value)})
+			continue
+		}
+		attrs = append(attrs, slog.Any(name, value.Interface()))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// String renders the redacted configuration as YAML.
+func (c *Config) String() string {
+	data, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return fmt.Sprintf("config: %v", err)
+	}
+	return string(data)
+}
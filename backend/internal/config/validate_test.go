@@ -0,0 +1,162 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig returns the defaults plus the settings they leave out
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Auth.JWTSecret = strings.Repeat("s", minProductionSecret)
+	cfg.Database.Name = "chub"
+	return cfg
+}
+
+// production returns a valid production configuration
+func production() *Config {
+	cfg := validConfig()
+	cfg.Env = EnvProduction
+	cfg.CORS.AllowedOrigins = []string{"https://chub.example"}
+	cfg.Mail.Driver = "smtp"
+	cfg.Mail.SMTPHost = "smtp.example.com"
+	cfg.Mail.From = "CHub <no-reply@chub.example>"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func() *Config
+		// want are substrings of the error, none for a valid config
+		want []string
+	}{
+		{
+			name:   "defaults",
+			config: validConfig,
+		},
+		{
+			name:   "production",
+			config: production,
+		},
+		{
+			name: "unknown environment",
+			config: func() *Config {
+				cfg := validConfig()
+				cfg.Env = "staging"
+				return cfg
+			},
+			want: []string{"APP_ENV"},
+		},
+		{
+			name: "missing secret",
+			config: func() *Config {
+				cfg := validConfig()
+				cfg.Auth.JWTSecret = ""
+				return cfg
+			},
+			want: []string{"JWT_SECRET is required"},
+		},
+		{
+			name: "short secret in production",
+			config: func() *Config {
+				cfg := production()
+				cfg.Auth.JWTSecret = "short"
+				return cfg
+			},
+			want: []string{"JWT_SECRET must be at least 32 characters"},
+		},
+		{
+			name: "more idle than open connections",
+			config: func() *Config {
+				cfg := validConfig()
+				cfg.Database.MaxOpenConns = 5
+				cfg.Database.MaxIdleConns = 10
+				return cfg
+			},
+			want: []string{"DB_MAX_IDLE_CONNS (10) must not exceed DB_MAX_OPEN_CONNS (5)"},
+		},
+		{
+			name: "origin with a path",
+			config: func() *Config {
+				cfg := validConfig()
+				cfg.CORS.AllowedOrigins = []string{"https://chub.example/app"}
+				return cfg
+			},
+			want: []string{"must be an origin without a path"},
+		},
+		{
+			name: "log mail in production",
+			config: func() *Config {
+				cfg := production()
+				cfg.Mail.Driver = "log"
+				return cfg
+			},
+			want: []string{"MAIL_DRIVER must be \"smtp\" in production"},
+		},
+		{
+			name: "smtp without a host",
+			config: func() *Config {
+				cfg := validConfig()
+				cfg.Mail.Driver = "smtp"
+				return cfg
+			},
+			want: []string{"SMTP_HOST is required", "MAIL_FROM is required"},
+		},
+		{
+			name: "every problem at once",
+			config: func() *Config {
+				cfg := validConfig()
+				cfg.Server.Addr = ""
+				cfg.Storage.Driver = "ftp"
+				cfg.RateLimit.Store = "redis"
+				cfg.AppURL = "chub.example"
+				return cfg
+			},
+			want: []string{"HTTP_ADDR is required", "STORAGE_DRIVER", "RATE_LIMIT_STORE", "APP_URL must be an absolute URL"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config().Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("invalid configuration was accepted")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error does not mention %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := validConfig()
+	cfg.Database.Password = "db-password"
+	cfg.Mail.SMTPPassword = ""
+
+	out := cfg.Redacted()
+	if out.Auth.JWTSecret != redacted || out.Database.Password != redacted {
+		t.Errorf("secrets were not masked: %q, %q", out.Auth.JWTSecret, out.Database.Password)
+	}
+	if out.Mail.SMTPPassword != "" {
+		t.Errorf("unset secret shows as %q, want empty", out.Mail.SMTPPassword)
+	}
+	if out.Database.Name != "chub" {
+		t.Errorf("plain field changed to %q", out.Database.Name)
+	}
+	if cfg.Auth.JWTSecret == redacted || cfg.Database.Password != "db-password" {
+		t.Error("Redacted changed the original")
+	}
+	if strings.Contains(cfg.String(), "db-password") {
+		t.Error("String leaks a secret")
+	}
+}
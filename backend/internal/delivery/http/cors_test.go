@@ -0,0 +1,112 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	router := newTestRouter(t, Config{
+		CORSOrigins: []string{"https://chub.example", "https://*.chub.example"},
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		origin string
+		// preflight sends Access-Control-Request-Method
+		preflight  bool
+		wantStatus int
+		// wantOrigin is the Access-Control-Allow-Origin expected, empty for
+		// none
+		wantOrigin string
+	}{
+		{
+			name:       "exact origin",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			origin:     "https://chub.example",
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://chub.example",
+		},
+		{
+			name:       "wildcard subdomain",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			origin:     "https://app.chub.example",
+			wantStatus: http.StatusOK,
+			wantOrigin: "https://app.chub.example",
+		},
+		{
+			name:       "lookalike domain",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			origin:     "https://evilchub.example",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "disallowed origin",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			origin:     "https://evil.example",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "preflight from an allowed origin",
+			method:     http.MethodOptions,
+			path:       "/api/posts",
+			origin:     "https://chub.example",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			wantOrigin: "https://chub.example",
+		},
+		{
+			name:       "preflight from a disallowed origin",
+			method:     http.MethodOptions,
+			path:       "/api/posts",
+			origin:     "https://evil.example",
+			preflight:  true,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "preflight for a direct upload",
+			method:     http.MethodOptions,
+			path:       "/api/uploads/direct/images/1/photo.png",
+			origin:     "https://app.chub.example",
+			preflight:  true,
+			wantStatus: http.StatusNoContent,
+			wantOrigin: "https://app.chub.example",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+				req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if tt.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("Access-Control-Allow-Credentials is not set")
+			}
+			if tt.preflight && tt.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Methods") == "" {
+				t.Error("preflight answer has no Access-Control-Allow-Methods")
+			}
+			if rec.Header().Values("Vary") == nil {
+				t.Error("response does not vary by Origin")
+			}
+		})
+	}
+}
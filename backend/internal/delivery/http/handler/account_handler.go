@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// exportDownloadPath is where a ready data export is downloaded from
+const exportDownloadPath = "/api/me/export/download"
+
+type AccountHandler struct {
+	accountUsecase domain.AccountUsecase
+}
+
+func NewAccountHandler(au domain.AccountUsecase) *AccountHandler {
+	return &AccountHandler{
+		accountUsecase: au,
+	}
+}
+
+// RequestExport queues a fresh zip of everything stored about the user, even
+// if an earlier one is still ready to download
+func (h *AccountHandler) RequestExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	export, err := h.accountUsecase.RequestExport(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, export)
+}
+
+// GetExport reports on the user's data export, starting one if there is none
+// in progress or ready. It answers 202 until the export is ready, so clients
+// can poll it.
+func (h *AccountHandler) GetExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	export, err := h.accountUsecase.GetExport(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	if export.Status != domain.DataExportReady {
+		c.JSON(http.StatusAccepted, export)
+		return
+	}
+
+	export.DownloadURL = exportDownloadPath
+	c.JSON(http.StatusOK, export)
+}
+
+func (h *AccountHandler) DownloadExport(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	download, err := h.accountUsecase.OpenExport(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	defer download.Close()
+
+	name := fmt.Sprintf("chub-data-%s.zip", download.Export.CompletedAt.UTC().Format("2006-01-02"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Length", strconv.FormatInt(download.Export.Size, 10))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Header("Cache-Control", "private, no-store")
+	c.Status(http.StatusOK)
+	w := deadlineWriter{ResponseWriter: c.Writer, rc: http.NewResponseController(c.Writer)}
+	_, _ = io.Copy(w, download)
+}
+
+// RequestDeletion schedules the account's deletion after a grace period.
+// Asking again replaces the earlier request.
+func (h *AccountHandler) RequestDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	var req domain.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	deletion, err := h.accountUsecase.RequestDeletion(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+func (h *AccountHandler) GetDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	deletion, err := h.accountUsecase.GetDeletion(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
+
+func (h *AccountHandler) CancelDeletion(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	if err := h.accountUsecase.CancelDeletion(c.Request.Context(), userID.(uint)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
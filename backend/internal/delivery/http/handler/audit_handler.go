@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type AuditHandler struct {
+	auditUsecase domain.AuditUsecase
+}
+
+func NewAuditHandler(au domain.AuditUsecase) *AuditHandler {
+	return &AuditHandler{
+		auditUsecase: au,
+	}
+}
+
+// List pages backwards through the audit log (moderators only). Pass the last
+// event's ID as ?before= to get the next page.
+func (h *AuditHandler) List(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	var filter domain.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	events, err := h.auditUsecase.List(c.Request.Context(), userID.(uint), &filter)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
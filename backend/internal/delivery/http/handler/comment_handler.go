@@ -22,25 +22,25 @@ func NewCommentHandler(cu domain.CommentUsecase) *CommentHandler {
 func (h *CommentHandler) Create(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
 	var req domain.CreateCommentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	comment, err := h.commentUsecase.Create(userID.(uint), uint(postID), &req)
+	comment, err := h.commentUsecase.Create(c.Request.Context(), userID.(uint), uint(postID), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -51,16 +51,23 @@ func (h *CommentHandler) Create(c *gin.Context) {
 func (h *CommentHandler) GetByPostID(c *gin.Context) {
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
 
-	comments, err := h.commentUsecase.GetByPostID(uint(postID), page, limit)
+	var uid uint
+	if userID, exists := c.Get("user_id"); exists {
+		if id, ok := userID.(uint); ok {
+			uid = id
+		}
+	}
+
+	comments, err := h.commentUsecase.GetByPostID(c.Request.Context(), uint(postID), uid, page, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -71,25 +78,25 @@ func (h *CommentHandler) GetByPostID(c *gin.Context) {
 func (h *CommentHandler) Update(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment id"})
+		RespondError(c, domain.Validation("invalid comment id"))
 		return
 	}
 
 	var req domain.UpdateCommentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	comment, err := h.commentUsecase.Update(userID.(uint), uint(commentID), &req)
+	comment, err := h.commentUsecase.Update(c.Request.Context(), userID.(uint), uint(commentID), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -100,20 +107,72 @@ func (h *CommentHandler) Update(c *gin.Context) {
 func (h *CommentHandler) Delete(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid comment id"})
+		RespondError(c, domain.Validation("invalid comment id"))
 		return
 	}
 
-	if err := h.commentUsecase.Delete(userID.(uint), uint(commentID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.commentUsecase.Delete(c.Request.Context(), userID.(uint), uint(commentID)); err != nil {
+		RespondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "comment deleted successfully"})
 }
+
+// GetRevisions lists earlier versions of a comment for its author or a moderator
+func (h *CommentHandler) GetRevisions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid comment id"))
+		return
+	}
+
+	revisions, err := h.commentUsecase.GetRevisions(c.Request.Context(), userID.(uint), uint(commentID))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// RollbackRevision restores a comment to an earlier revision (moderators only)
+func (h *CommentHandler) RollbackRevision(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid comment id"))
+		return
+	}
+
+	revisionID, err := strconv.ParseUint(c.Param("revisionId"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid revision id"))
+		return
+	}
+
+	comment, err := h.commentUsecase.RollbackRevision(c.Request.Context(), userID.(uint), uint(commentID), uint(revisionID))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
+)
+
+// Error codes returned in the "code" field of every error response
+const (
+	CodeNotFound     = "not_found"
+	CodeForbidden    = "forbidden"
+	CodeConflict     = "conflict"
+	CodeValidation   = "validation_failed"
+	CodeUnauthorized = "unauthorized"
+	CodeRateLimited  = "rate_limited"
+	CodeTooLarge     = "too_large"
+	CodeUnavailable  = "unavailable"
+	CodeInternal     = "internal_error"
+)
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// RespondError writes err with the status that matches its domain kind.
+// Anything unrecognised is a 500 whose details stay in the logs rather than
+// the response.
+func RespondError(c *gin.Context, err error) {
+	var locked *ratelimit.LockedError
+	if errors.As(err, &locked) {
+		respondTooManyRequests(c, err, locked.RetryAfter)
+		return
+	}
+	var limited *ratelimit.LimitedError
+	if errors.As(err, &limited) {
+		respondTooManyRequests(c, err, limited.RetryAfter)
+		return
+	}
+
+	status, code := http.StatusInternalServerError, CodeInternal
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		status, code = http.StatusNotFound, CodeNotFound
+	case errors.Is(err, domain.ErrForbidden):
+		status, code = http.StatusForbidden, CodeForbidden
+	case errors.Is(err, domain.ErrConflict):
+		status, code = http.StatusConflict, CodeConflict
+	case errors.Is(err, domain.ErrValidation):
+		status, code = http.StatusBadRequest, CodeValidation
+	case errors.Is(err, domain.ErrUnauthorized):
+		status, code = http.StatusUnauthorized, CodeUnauthorized
+	case errors.Is(err, domain.ErrTooLarge):
+		status, code = http.StatusRequestEntityTooLarge, CodeTooLarge
+	case errors.Is(err, domain.ErrUnavailable):
+		status, code = http.StatusServiceUnavailable, CodeUnavailable
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		c.Error(err)
+		message = "internal server error"
+	}
+
+	c.AbortWithStatusJSON(status, ErrorResponse{Error: message, Code: code})
+}
+
+func respondTooManyRequests(c *gin.Context, err error, retryAfter time.Duration) {
+	retry := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retry))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrorResponse{Error: err.Error(), Code: CodeRateLimited})
+}
+
+// respondBindError reports a request body or query that failed to bind.
+func respondBindError(c *gin.Context, err error) {
+	RespondError(c, domain.Validation(err.Error()))
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds each dependency check so a hung backend can't hold
+// the probe open.
+const readinessTimeout = 2 * time.Second
+
+// HealthCheck reports whether a dependency such as the database is reachable.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// HealthResponse is the body of the liveness and readiness probes
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type HealthHandler struct {
+	checks []HealthCheck
+	logger *slog.Logger
+}
+
+func NewHealthHandler(logger *slog.Logger, checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{
+		checks: checks,
+		logger: logger,
+	}
+}
+
+// Live reports that the process is up and serving requests
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+}
+
+// Ready runs every dependency check and answers 503 if any of them fails
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]string, len(h.checks))
+		ready   = true
+	)
+	for _, check := range h.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := check.Check(ctx)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				h.logger.WarnContext(ctx, "readiness check failed", "check", check.Name, "error", err)
+				results[check.Name] = "unavailable"
+				ready = false
+				return
+			}
+			results[check.Name] = "ok"
+		}()
+	}
+	wg.Wait()
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{Status: "unavailable", Checks: results})
+		return
+	}
+	c.JSON(http.StatusOK, HealthResponse{Status: "ok", Checks: results})
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type LinkPreviewHandler struct {
+	previewUsecase domain.LinkPreviewUsecase
+}
+
+func NewLinkPreviewHandler(pu domain.LinkPreviewUsecase) *LinkPreviewHandler {
+	return &LinkPreviewHandler{
+		previewUsecase: pu,
+	}
+}
+
+// Get previews a link while a post is being written, before it is saved.
+func (h *LinkPreviewHandler) Get(c *gin.Context) {
+	link := c.Query("url")
+	if link == "" {
+		RespondError(c, domain.Validation("url is required"))
+		return
+	}
+
+	preview, err := h.previewUsecase.Preview(c.Request.Context(), link)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
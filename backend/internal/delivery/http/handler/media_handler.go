@@ -0,0 +1,267 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// streamWriteTimeout is how long one write of a media stream may take. A
+// whole recording can take far longer than the server's write timeout.
+const streamWriteTimeout = 30 * time.Second
+
+type MediaHandler struct {
+	mediaUsecase domain.MediaUsecase
+}
+
+func NewMediaHandler(mu domain.MediaUsecase) *MediaHandler {
+	return &MediaHandler{
+		mediaUsecase: mu,
+	}
+}
+
+// TranscriptForm is the multipart body of a transcript upload
+type TranscriptForm struct {
+	File *multipart.FileHeader `form:"file" binding:"required"`
+}
+
+// Create makes a media item from a sermon_media upload
+func (h *MediaHandler) Create(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	var req domain.CreateMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	item, err := h.mediaUsecase.Create(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+// List returns the caller's media items, newest first
+func (h *MediaHandler) List(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	items, err := h.mediaUsecase.GetByOwnerID(c.Request.Context(), userID.(uint), page, limit)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *MediaHandler) GetByID(c *gin.Context) {
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	item, err := h.mediaUsecase.GetByID(c.Request.Context(), id)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// Search finds media by title, description and transcript
+func (h *MediaHandler) Search(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "0"))
+
+	results, err := h.mediaUsecase.Search(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func (h *MediaHandler) Update(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdateMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	item, err := h.mediaUsecase.Update(c.Request.Context(), userID.(uint), id, &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+func (h *MediaHandler) Delete(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mediaUsecase.Delete(c.Request.Context(), userID.(uint), id); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// SetTranscript replaces a media item's transcript with an uploaded WebVTT
+// or SRT file
+func (h *MediaHandler) SetTranscript(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	var form TranscriptForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	src, err := form.File.Open()
+	if err != nil {
+		RespondError(c, fmt.Errorf("failed to open transcript: %w", err))
+		return
+	}
+	defer src.Close()
+
+	item, err := h.mediaUsecase.SetTranscript(c.Request.Context(), userID.(uint), id, src)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+func (h *MediaHandler) DeleteTranscript(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mediaUsecase.DeleteTranscript(c.Request.Context(), userID.(uint), id); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Transcript serves the transcript as WebVTT. It is public so a <track>
+// element, which can't send a token, can load it.
+func (h *MediaHandler) Transcript(c *gin.Context) {
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.mediaUsecase.Transcript(c.Request.Context(), id, &buf); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/vtt; charset=utf-8", buf.Bytes())
+}
+
+// Stream serves a media file with Range support so players can seek. It is
+// public because <audio> and <video> elements can't send a token.
+func (h *MediaHandler) Stream(c *gin.Context) {
+	id, ok := mediaID(c)
+	if !ok {
+		return
+	}
+
+	stream, err := h.mediaUsecase.Open(c.Request.Context(), id)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+	defer stream.Close()
+
+	// Setting the type stops ServeContent from reading the file to sniff it
+	c.Header("Content-Type", stream.ContentType)
+	c.Header("Cache-Control", "public, max-age=3600")
+	w := deadlineWriter{ResponseWriter: c.Writer, rc: http.NewResponseController(c.Writer)}
+	http.ServeContent(w, c.Request, "", stream.ModTime, stream)
+}
+
+// deadlineWriter pushes the write deadline forward before every write, so a
+// long recording can take as long as it needs while a stalled client is still
+// dropped.
+type deadlineWriter struct {
+	http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (w deadlineWriter) Write(p []byte) (int, error) {
+	_ = w.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	return w.ResponseWriter.Write(p)
+}
+
+func mediaID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid media id"))
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/internal/services/realtime"
+)
+
+// streamKeepAlive keeps idle SSE connections from being closed by proxies.
+const streamKeepAlive = 25 * time.Second
+
+type MessageHandler struct {
+	messageUsecase domain.MessageUsecase
+	hub            *realtime.Hub
+}
+
+func NewMessageHandler(mu domain.MessageUsecase, hub *realtime.Hub) *MessageHandler {
+	return &MessageHandler{
+		messageUsecase: mu,
+		hub:            hub,
+	}
+}
+
+// CreateConversation starts a direct or group conversation
+func (h *MessageHandler) CreateConversation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	var req domain.CreateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	conversation, err := h.messageUsecase.CreateConversation(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, conversation)
+}
+
+// GetConversations lists the caller's conversations, most recent first
+func (h *MessageHandler) GetConversations(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	conversations, err := h.messageUsecase.GetConversations(c.Request.Context(), userID.(uint), page, limit)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"conversations": conversations})
+}
+
+// GetConversation returns a single conversation with its participants
+func (h *MessageHandler) GetConversation(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid conversation id"))
+		return
+	}
+
+	conversation, err := h.messageUsecase.GetConversation(c.Request.Context(), userID.(uint), uint(conversationID))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, conversation)
+}
+
+// GetMessages pages through a conversation. Clients without a live stream
+// poll with ?after=<last seen id>.
+func (h *MessageHandler) GetMessages(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid conversation id"))
+		return
+	}
+
+	before, _ := strconv.ParseUint(c.DefaultQuery("before", "0"), 10, 32)
+	after, _ := strconv.ParseUint(c.DefaultQuery("after", "0"), 10, 32)
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	messages, err := h.messageUsecase.GetMessages(c.Request.Context(), userID.(uint), uint(conversationID), uint(before), uint(after), limit)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// SendMessage posts a message to a conversation
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid conversation id"))
+		return
+	}
+
+	var req domain.SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	message, err := h.messageUsecase.SendMessage(c.Request.Context(), userID.(uint), uint(conversationID), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
+// MarkRead records a read receipt up to the given message
+func (h *MessageHandler) MarkRead(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid conversation id"))
+		return
+	}
+
+	var req domain.MarkReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.messageUsecase.MarkRead(c.Request.Context(), userID.(uint), uint(conversationID), req.MessageID); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation marked as read"})
+}
+
+// Mute silences notifications for a conversation
+func (h *MessageHandler) Mute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid conversation id"))
+		return
+	}
+
+	if err := h.messageUsecase.Mute(c.Request.Context(), userID.(uint), uint(conversationID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation muted", "is_muted": true})
+}
+
+// Unmute restores notifications for a conversation
+func (h *MessageHandler) Unmute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid conversation id"))
+		return
+	}
+
+	if err := h.messageUsecase.Unmute(c.Request.Context(), userID.(uint), uint(conversationID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "conversation unmuted", "is_muted": false})
+}
+
+// StreamTicket issues a ticket for opening the stream with an EventSource,
+// which can't send an Authorization header
+func (h *MessageHandler) StreamTicket(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	ticket, err := h.messageUsecase.StreamTicket(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ticket)
+}
+
+// Stream delivers message and read events as Server-Sent Events
+func (h *MessageHandler) Stream(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	events, unsubscribe := h.hub.Subscribe(userID.(uint))
+	defer unsubscribe()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	// The server's write timeout is meant for ordinary responses; push the
+	// deadline forward before every write instead. Streams end when the hub is
+	// closed on shutdown.
+	rc := http.NewResponseController(c.Writer)
+	c.Stream(func(w io.Writer) bool {
+		_ = rc.SetWriteDeadline(time.Now().Add(2 * streamKeepAlive))
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-keepAlive.C:
+			c.SSEvent("ping", gin.H{"time": time.Now().Unix()})
+			return true
+		}
+	})
+}
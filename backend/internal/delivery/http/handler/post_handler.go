@@ -1,61 +1,49 @@
 package handler
 
 import (
-	"encoding/json"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ruth987/CHub.git/internal/domain"
-	"github.com/ruth987/CHub.git/internal/services/s3"
+	"github.com/ruth987/CHub.git/pkg/metrics"
 )
 
 type PostHandler struct {
 	postUsecase domain.PostUsecase
-	s3Service   *s3.Service
+	metrics     *metrics.Metrics
+	logger      *slog.Logger
 }
 
-func NewPostHandler(pu domain.PostUsecase, s3Service *s3.Service) *PostHandler {
+func NewPostHandler(pu domain.PostUsecase, metrics *metrics.Metrics, logger *slog.Logger) *PostHandler {
 	return &PostHandler{
 		postUsecase: pu,
-		s3Service:   s3Service,
+		metrics:     metrics,
+		logger:      logger,
 	}
 }
 
 // Create handles post creation
 func (h *PostHandler) Create(c *gin.Context) {
 	userID, exists := c.Get("user_id")
-	fmt.Println(" in create post handler userID", userID)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	var req domain.CreatePostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	post, err := h.postUsecase.Create(userID.(uint), &req)
+	post, err := h.postUsecase.Create(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
-
-	// Backup post to S3
-	postJSON, err := json.Marshal(post)
-	if err != nil {
-		fmt.Printf("Failed to marshal post for backup: %v\n", err)
-	} else {
-		backupURL, err := h.s3Service.UploadPostBackup(c.Request.Context(), postJSON, fmt.Sprintf("%d", post.ID))
-		if err != nil {
-			fmt.Printf("Failed to backup post to S3: %v\n", err)
-		} else {
-			fmt.Printf("Post backed up to S3: %s\n", backupURL)
-		}
-	}
+	h.metrics.PostsCreated.Inc()
 
 	c.JSON(http.StatusCreated, post)
 }
@@ -72,39 +60,39 @@ func (h *PostHandler) GetByID(c *gin.Context) {
 	// Parse post ID
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
 	// Get post
-	post, err := h.postUsecase.GetByID(uint(postID))
+	post, err := h.postUsecase.GetByID(c.Request.Context(), uint(postID), uid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
 	// If user is authenticated, check interaction statuses
 	if exists {
 		// Check if post is liked by user
-		isLiked, err := h.postUsecase.IsLikedByUser(uid, post.ID)
+		isLiked, err := h.postUsecase.IsLikedByUser(c.Request.Context(), uid, post.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			RespondError(c, err)
 			return
 		}
 		post.IsLiked = isLiked
 
 		// Check if post is saved by user
-		isSaved, err := h.postUsecase.IsSavedByUser(uid, post.ID)
+		isSaved, err := h.postUsecase.IsSavedByUser(c.Request.Context(), uid, post.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			RespondError(c, err)
 			return
 		}
 		post.IsSaved = isSaved
 
 		// Check if post is reported by user
-		isReported, err := h.postUsecase.IsReportedByUser(uid, post.ID)
+		isReported, err := h.postUsecase.IsReportedByUser(c.Request.Context(), uid, post.ID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			RespondError(c, err)
 			return
 		}
 		post.IsReported = isReported
@@ -125,11 +113,9 @@ func (h *PostHandler) GetAll(c *gin.Context) {
 		}
 	}
 
-	fmt.Println(" in post handler uid", uid)
-
-	posts, err := h.postUsecase.GetAll(page, limit, uid)
+	posts, err := h.postUsecase.GetAll(c.Request.Context(), page, limit, uid)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -140,25 +126,25 @@ func (h *PostHandler) GetAll(c *gin.Context) {
 func (h *PostHandler) Update(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
 	var req domain.UpdatePostRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	post, err := h.postUsecase.Update(userID.(uint), uint(postID), &req)
+	post, err := h.postUsecase.Update(c.Request.Context(), userID.(uint), uint(postID), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -169,18 +155,18 @@ func (h *PostHandler) Update(c *gin.Context) {
 func (h *PostHandler) Delete(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
-	if err := h.postUsecase.Delete(userID.(uint), uint(postID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.postUsecase.Delete(c.Request.Context(), userID.(uint), uint(postID)); err != nil {
+		RespondError(c, err)
 		return
 	}
 
@@ -191,25 +177,25 @@ func (h *PostHandler) Delete(c *gin.Context) {
 func (h *PostHandler) Like(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
-	if err := h.postUsecase.Like(userID.(uint), uint(postID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.postUsecase.Like(c.Request.Context(), userID.(uint), uint(postID)); err != nil {
+		RespondError(c, err)
 		return
 	}
 
 	// Get updated post to return current like count and status
-	post, err := h.postUsecase.GetByID(uint(postID))
+	post, err := h.postUsecase.GetByID(c.Request.Context(), uint(postID), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -227,25 +213,25 @@ func (h *PostHandler) Like(c *gin.Context) {
 func (h *PostHandler) Unlike(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
-	if err := h.postUsecase.Unlike(userID.(uint), uint(postID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.postUsecase.Unlike(c.Request.Context(), userID.(uint), uint(postID)); err != nil {
+		RespondError(c, err)
 		return
 	}
 
 	// Get updated post to return current like count
-	post, err := h.postUsecase.GetByID(uint(postID))
+	post, err := h.postUsecase.GetByID(c.Request.Context(), uint(postID), userID.(uint))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -258,3 +244,55 @@ func (h *PostHandler) Unlike(c *gin.Context) {
 		"is_liked": false,
 	})
 }
+
+// GetRevisions lists earlier versions of a post for its author or a moderator
+func (h *PostHandler) GetRevisions(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid post id"))
+		return
+	}
+
+	revisions, err := h.postUsecase.GetRevisions(c.Request.Context(), userID.(uint), uint(postID))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"revisions": revisions})
+}
+
+// RollbackRevision restores a post to an earlier revision (moderators only)
+func (h *PostHandler) RollbackRevision(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid post id"))
+		return
+	}
+
+	revisionID, err := strconv.ParseUint(c.Param("revisionId"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid revision id"))
+		return
+	}
+
+	post, err := h.postUsecase.RollbackRevision(c.Request.Context(), userID.(uint), uint(postID), uint(revisionID))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, post)
+}
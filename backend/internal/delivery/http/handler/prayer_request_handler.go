@@ -18,14 +18,10 @@ func NewPrayerRequestHandler(usecase domain.PrayerRequestUsecase) *PrayerRequest
 	}
 }
 
-type createPrayerRequestRequest struct {
-	Content string `json:"content" binding:"required"`
-}
-
 func (h *PrayerRequestHandler) Create(c *gin.Context) {
-	var req createPrayerRequestRequest
+	var req domain.PrayerRequestInput
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -35,7 +31,7 @@ func (h *PrayerRequestHandler) Create(c *gin.Context) {
 
 	err := h.prayerRequestUsecase.Create(c.Request.Context(), prayerRequest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -45,17 +41,13 @@ func (h *PrayerRequestHandler) Create(c *gin.Context) {
 func (h *PrayerRequestHandler) GetByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		RespondError(c, domain.Validation("invalid id"))
 		return
 	}
 
 	prayerRequest, err := h.prayerRequestUsecase.GetByID(c.Request.Context(), uint(id))
-	if err == domain.ErrNotFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "prayer request not found"})
-		return
-	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -71,7 +63,7 @@ func (h *PrayerRequestHandler) GetRandom(c *gin.Context) {
 
 	prayers, err := h.prayerRequestUsecase.GetRandomPrayers(c.Request.Context(), limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -81,13 +73,13 @@ func (h *PrayerRequestHandler) GetRandom(c *gin.Context) {
 func (h *PrayerRequestHandler) Update(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		RespondError(c, domain.Validation("invalid id"))
 		return
 	}
 
-	var req createPrayerRequestRequest
+	var req domain.PrayerRequestInput
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
@@ -97,12 +89,8 @@ func (h *PrayerRequestHandler) Update(c *gin.Context) {
 	}
 
 	err = h.prayerRequestUsecase.Update(c.Request.Context(), prayerRequest)
-	if err == domain.ErrNotFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "prayer request not found"})
-		return
-	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -112,17 +100,13 @@ func (h *PrayerRequestHandler) Update(c *gin.Context) {
 func (h *PrayerRequestHandler) Delete(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		RespondError(c, domain.Validation("invalid id"))
 		return
 	}
 
 	err = h.prayerRequestUsecase.Delete(c.Request.Context(), uint(id))
-	if err == domain.ErrNotFound {
-		c.JSON(http.StatusNotFound, gin.H{"error": "prayer request not found"})
-		return
-	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
 
@@ -29,19 +28,19 @@ func (h *SavedPostHandler) getUserIDFromContext(c *gin.Context) uint {
 func (h *SavedPostHandler) SavePost(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid post id"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
-	err = h.savedPostUsecase.SavePost(userID.(uint), uint(postID))
+	err = h.savedPostUsecase.SavePost(c.Request.Context(), userID.(uint), uint(postID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -52,13 +51,13 @@ func (h *SavedPostHandler) UnsavePost(c *gin.Context) {
 	userID := h.getUserIDFromContext(c)
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
-	err = h.savedPostUsecase.UnsavePost(uint(userID), uint(postID))
+	err = h.savedPostUsecase.UnsavePost(c.Request.Context(), uint(userID), uint(postID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -66,12 +65,11 @@ func (h *SavedPostHandler) UnsavePost(c *gin.Context) {
 }
 
 func (h *SavedPostHandler) GetSavedPosts(c *gin.Context) {
-	fmt.Println("GetSavedPosts handler")
 	userID := h.getUserIDFromContext(c)
 
-	savedPosts, err := h.savedPostUsecase.GetSavedPosts(uint(userID))
+	savedPosts, err := h.savedPostUsecase.GetSavedPosts(c.Request.Context(), uint(userID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
@@ -82,13 +80,13 @@ func (h *SavedPostHandler) IsSaved(c *gin.Context) {
 	userID := h.getUserIDFromContext(c)
 	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid post ID"})
+		RespondError(c, domain.Validation("invalid post id"))
 		return
 	}
 
-	isSaved, err := h.savedPostUsecase.IsSaved(uint(userID), uint(postID))
+	isSaved, err := h.savedPostUsecase.IsSaved(c.Request.Context(), uint(userID), uint(postID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
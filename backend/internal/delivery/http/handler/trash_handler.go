@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type TrashHandler struct {
+	trashUsecase domain.TrashUsecase
+}
+
+func NewTrashHandler(tu domain.TrashUsecase) *TrashHandler {
+	return &TrashHandler{
+		trashUsecase: tu,
+	}
+}
+
+// GetTrash lists the caller's deleted posts and comments that can still be restored
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	trash, err := h.trashUsecase.GetTrash(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// RestorePost brings a deleted post back out of the trash
+func (h *TrashHandler) RestorePost(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	postID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid post id"))
+		return
+	}
+
+	if err := h.trashUsecase.RestorePost(c.Request.Context(), userID.(uint), uint(postID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "post restored successfully"})
+}
+
+// RestoreComment brings a deleted comment back out of the trash
+func (h *TrashHandler) RestoreComment(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid comment id"))
+		return
+	}
+
+	if err := h.trashUsecase.RestoreComment(c.Request.Context(), userID.(uint), uint(commentID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "comment restored successfully"})
+}
@@ -1,47 +1,145 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/ruth987/CHub.git/internal/services/s3"
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/storage"
 )
 
 type UploadHandler struct {
-	s3Service *s3.Service
+	uploadUsecase domain.UploadUsecase
+	direct        storage.DirectUploader
 }
 
-func NewUploadHandler(s3Service *s3.Service) *UploadHandler {
+// NewUploadHandler returns the upload handler. direct takes the presigned
+// uploads of stores without their own upload endpoint and may be nil.
+func NewUploadHandler(uu domain.UploadUsecase, direct storage.DirectUploader) *UploadHandler {
 	return &UploadHandler{
-		s3Service: s3Service,
+		uploadUsecase: uu,
+		direct:        direct,
 	}
 }
 
-// UploadFile handles file uploads to S3
+// AcceptsDirectUploads reports whether presigned uploads are sent to the API
+// rather than to the store.
+func (h *UploadHandler) AcceptsDirectUploads() bool {
+	return h.direct != nil
+}
+
+// UploadForm is the multipart body of an upload
+type UploadForm struct {
+	File    *multipart.FileHeader `form:"file" binding:"required"`
+	Purpose domain.UploadPurpose  `form:"purpose" binding:"required,oneof=avatar post_image post_attachment devotional_image sermon_media"`
+}
+
+// UploadFile stores a file for the caller. Where it is stored is decided by
+// the server from the purpose.
 func (h *UploadHandler) UploadFile(c *gin.Context) {
-	// Get the file from the request
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No file provided",
-		})
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
 		return
 	}
 
-	// Get the folder from the request (default to "uploads")
-	folder := c.DefaultPostForm("folder", "uploads")
+	var form UploadForm
+	if err := c.ShouldBind(&form); err != nil {
+		respondBindError(c, err)
+		return
+	}
 
-	// Upload the file to S3
-	url, err := h.s3Service.UploadFile(c.Request.Context(), file, folder)
+	src, err := form.File.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("Failed to upload file: %v", err),
-		})
+		RespondError(c, fmt.Errorf("failed to open upload: %w", err))
 		return
 	}
+	defer src.Close()
 
-	c.JSON(http.StatusOK, gin.H{
-		"url": url,
+	upload, err := h.uploadUsecase.Upload(c.Request.Context(), userID.(uint), form.Purpose, domain.UploadFile{
+		Body: src,
+		Size: form.File.Size,
 	})
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, upload)
+}
+
+// CreateSession starts an upload straight to storage and returns the request
+// the client should make.
+func (h *UploadHandler) CreateSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	var req domain.CreateUploadSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	session, err := h.uploadUsecase.CreateSession(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// FinalizeSession records the file uploaded for a session once the client
+// has sent it.
+func (h *UploadHandler) FinalizeSession(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid upload session ID"))
+		return
+	}
+
+	var req domain.FinalizeUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	upload, err := h.uploadUsecase.FinalizeSession(c.Request.Context(), userID.(uint), uint(sessionID), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, upload)
+}
+
+// DirectUpload takes a presigned upload for a store that can't take it
+// itself. The signature in the URL stands in for authentication.
+func (h *UploadHandler) DirectUpload(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	err := h.direct.AcceptPut(c.Request.Context(), key, c.Request)
+	if errors.Is(err, storage.ErrInvalidSignature) {
+		RespondError(c, domain.Forbidden(err.Error()))
+		return
+	}
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
 }
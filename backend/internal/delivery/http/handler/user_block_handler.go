@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type UserBlockHandler struct {
+	blockUsecase domain.UserBlockUsecase
+}
+
+func NewUserBlockHandler(bu domain.UserBlockUsecase) *UserBlockHandler {
+	return &UserBlockHandler{
+		blockUsecase: bu,
+	}
+}
+
+// Block handles blocking another user
+func (h *UserBlockHandler) Block(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	blockedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid user id"))
+		return
+	}
+
+	if err := h.blockUsecase.Block(c.Request.Context(), userID.(uint), uint(blockedID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user blocked successfully"})
+}
+
+// Unblock handles removing a block
+func (h *UserBlockHandler) Unblock(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	blockedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid user id"))
+		return
+	}
+
+	if err := h.blockUsecase.Unblock(c.Request.Context(), userID.(uint), uint(blockedID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unblocked successfully"})
+}
+
+// GetBlocked handles listing the users the caller has blocked
+func (h *UserBlockHandler) GetBlocked(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	blocks, err := h.blockUsecase.GetBlockedUsers(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"blocks": blocks})
+}
@@ -1,79 +1,94 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/ruth987/CHub.git/internal/domain"
-	"github.com/ruth987/CHub.git/internal/services/s3"
+	"github.com/ruth987/CHub.git/pkg/metrics"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
 )
 
 type UserHandler struct {
 	userUsecase domain.UserUsecase
-	s3Service   *s3.Service
+	metrics     *metrics.Metrics
+	logger      *slog.Logger
 }
 
-func NewUserHandler(userUsecase domain.UserUsecase, s3Service *s3.Service) *UserHandler {
+func NewUserHandler(userUsecase domain.UserUsecase, metrics *metrics.Metrics, logger *slog.Logger) *UserHandler {
 	return &UserHandler{
 		userUsecase: userUsecase,
-		s3Service:   s3Service,
+		metrics:     metrics,
+		logger:      logger,
 	}
 }
 
 func (h *UserHandler) Register(c *gin.Context) {
 	var req domain.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	user, err := h.userUsecase.Register(&req)
+	user, err := h.userUsecase.Register(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
-	// Log signup activity to S3
-	details := fmt.Sprintf("New user registered with email: %s", req.Email)
-	_, err = h.s3Service.LogUserActivity(c.Request.Context(), user.Username, "signup", details)
-	if err != nil {
-		fmt.Printf("Failed to log signup activity to S3: %v\n", err)
-	}
-
 	c.JSON(http.StatusCreated, user)
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
 	var req domain.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
-	fmt.Printf("Login attempt for email: %s\n", req.Email)
 
-	response, err := h.userUsecase.Login(&req)
+	response, err := h.userUsecase.Login(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		var locked *ratelimit.LockedError
+		switch {
+		case errors.As(err, &locked):
+			h.metrics.Logins.WithLabelValues(metrics.LoginLocked).Inc()
+		case errors.Is(err, domain.ErrUnauthorized):
+			h.metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+		}
+		RespondError(c, err)
 		return
 	}
-
-	// Log login activity to S3
-	details := fmt.Sprintf("User logged in with email: %s", req.Email)
-	_, err = h.s3Service.LogUserActivity(c.Request.Context(), response.User.Username, "login", details)
-	if err != nil {
-		fmt.Printf("Failed to log login activity to S3: %v\n", err)
-	}
+	h.metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
 
 	c.JSON(http.StatusOK, response)
 }
 
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, _ := c.Get("user_id")
-	user, err := h.userUsecase.GetProfile(userID.(uint))
+	user, err := h.userUsecase.GetProfile(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) GetUserProfile(c *gin.Context) {
+	viewerID, _ := c.Get("user_id")
+
+	userID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		RespondError(c, domain.Validation("invalid user id"))
+		return
+	}
+
+	user, err := h.userUsecase.GetUserProfile(c.Request.Context(), viewerID.(uint), uint(userID))
+	if err != nil {
+		RespondError(c, err)
 		return
 	}
 
@@ -85,22 +100,24 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 
 	var req domain.UpdateProfileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindError(c, err)
 		return
 	}
 
-	user, err := h.userUsecase.UpdateProfile(userID.(uint), &req)
+	user, err := h.userUsecase.UpdateProfile(c.Request.Context(), userID.(uint), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, user)
 }
 func (h *UserHandler) GetUserPosts(c *gin.Context) {
+	viewerID, _ := c.Get("user_id")
+
 	userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		RespondError(c, domain.Validation("invalid user id"))
 		return
 	}
 
@@ -118,11 +135,123 @@ func (h *UserHandler) GetUserPosts(c *gin.Context) {
 		limit = l
 	}
 
-	posts, err := h.userUsecase.GetUserPosts(uint(userID), page, limit)
+	posts, err := h.userUsecase.GetUserPosts(c.Request.Context(), viewerID.(uint), uint(userID), page, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		RespondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, posts)
 }
+
+// VerifyEmail confirms the address a verification link was sent to
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req domain.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.userUsecase.VerifyEmail(c.Request.Context(), &req); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+}
+
+// ResendVerification sends a new verification link to the caller
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	if err := h.userUsecase.SendVerificationEmail(c.Request.Context(), userID.(uint)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "verification email sent"})
+}
+
+// ForgotPassword always answers the same way so it can't be used to probe
+// which emails have accounts
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req domain.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.userUsecase.ForgotPassword(c.Request.Context(), &req); err != nil {
+		h.logger.ErrorContext(c.Request.Context(), "failed to send password reset email", "error", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "if an account exists for that email, a reset link has been sent"})
+}
+
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req domain.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.userUsecase.ResetPassword(c.Request.Context(), &req); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req domain.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	token, err := h.userUsecase.ChangePassword(c.Request.Context(), userID.(uint), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	// Older tokens, including the one this request used, no longer work
+	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully", "token": token})
+}
+
+// ChangeEmail starts an email change; it completes once the new address is confirmed
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	var req domain.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	if err := h.userUsecase.ChangeEmail(c.Request.Context(), userID.(uint), &req); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "confirmation link sent to the new email address"})
+}
+
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var req domain.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondBindError(c, err)
+		return
+	}
+
+	user, err := h.userUsecase.ConfirmEmailChange(c.Request.Context(), &req)
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
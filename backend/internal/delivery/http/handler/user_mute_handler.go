@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type UserMuteHandler struct {
+	muteUsecase domain.UserMuteUsecase
+}
+
+func NewUserMuteHandler(mu domain.UserMuteUsecase) *UserMuteHandler {
+	return &UserMuteHandler{
+		muteUsecase: mu,
+	}
+}
+
+// Mute handles hiding another user's content from the caller's feed
+func (h *UserMuteHandler) Mute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	mutedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid user id"))
+		return
+	}
+
+	if err := h.muteUsecase.Mute(c.Request.Context(), userID.(uint), uint(mutedID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user muted successfully"})
+}
+
+// Unmute handles removing a mute
+func (h *UserMuteHandler) Unmute(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	mutedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		RespondError(c, domain.Validation("invalid user id"))
+		return
+	}
+
+	if err := h.muteUsecase.Unmute(c.Request.Context(), userID.(uint), uint(mutedID)); err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "user unmuted successfully"})
+}
+
+// GetMuted handles listing the users the caller has muted
+func (h *UserMuteHandler) GetMuted(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		RespondError(c, domain.Unauthorized("unauthorized"))
+		return
+	}
+
+	mutes, err := h.muteUsecase.GetMutedUsers(c.Request.Context(), userID.(uint))
+	if err != nil {
+		RespondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"mutes": mutes})
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// maxUserAgentLength keeps a padded User-Agent header out of the audit log
+const maxUserAgentLength = 512
+
+// Client stores the caller's IP and user agent on the request context, where
+// the audit log picks them up.
+func Client() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAgent := c.Request.UserAgent()
+		if len(userAgent) > maxUserAgentLength {
+			userAgent = userAgent[:maxUserAgentLength]
+		}
+		// Postgres refuses invalid UTF-8, including a rune cut in half above
+		userAgent = strings.ToValidUTF8(userAgent, "")
+
+		c.Request = c.Request.WithContext(domain.WithClient(c.Request.Context(), domain.Client{
+			IP:        c.ClientIP(),
+			UserAgent: userAgent,
+		}))
+		c.Next()
+	}
+}
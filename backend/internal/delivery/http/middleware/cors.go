@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	corsAllowMethods  = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	corsAllowHeaders  = []string{"Origin", "Content-Type", "Accept", "Authorization", RequestIDHeader}
+	corsExposeHeaders = []string{"Content-Length", "Retry-After", RequestIDHeader}
+)
+
+const corsMaxAge = 12 * time.Hour
+
+// CORS answers cross-origin requests from the allowed origins. An origin is
+// either exact, such as "https://chub.example", or has a wildcard leftmost
+// label, such as "https://*.chub.example", which matches any subdomain but
+// not the bare domain.
+//
+// Preflight requests are answered here, before routing, so every route
+// (uploads included) gets the same policy without registering OPTIONS
+// handlers. Preflights from other origins are refused; ordinary requests from
+// them go through without CORS headers and the browser withholds the response.
+func CORS(allowedOrigins []string) gin.HandlerFunc {
+	patterns := make([]originPattern, 0, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		if p, ok := parseOriginPattern(origin); ok {
+			patterns = append(patterns, p)
+		}
+	}
+
+	allowMethods := strings.Join(corsAllowMethods, ", ")
+	allowHeaders := strings.Join(corsAllowHeaders, ", ")
+	exposeHeaders := strings.Join(corsExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(corsMaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		// Responses differ by origin, so caches must key on it
+		c.Writer.Header().Add("Vary", "Origin")
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if !originAllowed(patterns, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		h := c.Writer.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+
+		if preflight {
+			h.Set("Access-Control-Allow-Methods", allowMethods)
+			h.Set("Access-Control-Allow-Headers", allowHeaders)
+			h.Set("Access-Control-Max-Age", maxAge)
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		h.Set("Access-Control-Expose-Headers", exposeHeaders)
+		c.Next()
+	}
+}
+
+type originPattern struct {
+	scheme string
+	// host is the exact host, or the suffix after "*." for wildcards
+	host     string
+	port     string
+	wildcard bool
+}
+
+func parseOriginPattern(origin string) (originPattern, bool) {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return originPattern{}, false
+	}
+	p := originPattern{scheme: u.Scheme, host: u.Hostname(), port: u.Port()}
+	if rest, ok := strings.CutPrefix(p.host, "*."); ok {
+		p.host, p.wildcard = rest, true
+	}
+	return p, true
+}
+
+func originAllowed(patterns []originPattern, origin string) bool {
+	u, err := url.Parse(strings.ToLower(origin))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	for _, p := range patterns {
+		if p.scheme != u.Scheme || p.port != u.Port() {
+			continue
+		}
+		if p.wildcard {
+			if strings.HasSuffix(host, "."+p.host) {
+				return true
+			}
+			continue
+		}
+		if host == p.host {
+			return true
+		}
+	}
+	return false
+}
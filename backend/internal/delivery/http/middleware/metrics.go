@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/pkg/metrics"
+)
+
+// Metrics records the latency of every request against its route template, so
+// /api/posts/1 and /api/posts/2 share a series.
+func Metrics(m *metrics.Metrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			// Unmatched paths are unbounded, keep them out of the labels
+			route = "unmatched"
+		}
+		m.RequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/delivery/http/handler"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
+)
+
+// RateLimit throttles a route group with two token buckets: one per client IP
+// and, once the auth middleware has run, one per account. name keeps the
+// buckets of different groups apart.
+func RateLimit(store ratelimit.Store, logger *slog.Logger, name string, limit ratelimit.Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := []string{fmt.Sprintf("%s:ip:%s", name, c.ClientIP())}
+		if userID, exists := c.Get("user_id"); exists {
+			keys = append(keys, fmt.Sprintf("%s:user:%d", name, userID.(uint)))
+		}
+
+		for _, key := range keys {
+			result, err := store.Take(c.Request.Context(), key, limit)
+			if err != nil {
+				// Fail open: a limiter outage shouldn't take the API down with it
+				logger.ErrorContext(c.Request.Context(), "rate limiter unavailable", "limit", name, "error", err)
+				c.Next()
+				return
+			}
+
+			if !result.Allowed {
+				retry := int(math.Ceil(result.RetryAfter.Seconds()))
+				c.Header("Retry-After", strconv.Itoa(retry))
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, handler.ErrorResponse{
+					Error: "too many requests",
+					Code:  handler.CodeRateLimited,
+				})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
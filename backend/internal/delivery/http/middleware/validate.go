@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/ruth987/CHub.git/internal/delivery/http/handler"
+	"github.com/ruth987/CHub.git/internal/delivery/http/openapi"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// MaxJSONBodyBytes caps the size of a JSON request body.
+const MaxJSONBodyBytes = 1 << 20
+
+// ValidateRequest checks JSON request bodies against the operation the spec
+// declares for the matched route, so malformed input is rejected before it
+// reaches a handler. The body is put back for the handler to bind.
+func ValidateRequest(spec *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		schema := spec.Operation(c.Request.Method, c.FullPath()).JSONBody()
+		if schema == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxJSONBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				handler.RespondError(c, domain.TooLarge("request body is too large"))
+				return
+			}
+			handler.RespondError(c, domain.Validation("could not read request body"))
+			return
+		}
+
+		if err := spec.ValidateJSON(schema, body); err != nil {
+			handler.RespondError(c, domain.Validation(err.Error()))
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
@@ -0,0 +1,282 @@
+// Package openapi builds the OpenAPI 3 document for the HTTP API from the
+// domain types and validates request bodies against it.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const Version = "3.0.3"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// operations indexes every operation by method and router path
+	// (e.g. "GET /api/posts/:id") so requests can be matched to it.
+	operations map[string]*Operation
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps a lower-case HTTP method to its operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	OperationID string                `json:"operationId,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Operation returns the operation registered for a router path such as
+// "/api/posts/:id", or nil when the spec does not describe it.
+func (d *Document) Operation(method, routePath string) *Operation {
+	return d.operations[method+" "+routePath]
+}
+
+// JSONBody returns the schema of the operation's JSON request body, if any.
+func (o *Operation) JSONBody() *Schema {
+	if o == nil || o.RequestBody == nil {
+		return nil
+	}
+	if media := o.RequestBody.Content[contentJSON]; media != nil {
+		return media.Schema
+	}
+	return nil
+}
+
+const (
+	contentJSON      = "application/json"
+	contentMultipart = "multipart/form-data"
+	bearerScheme     = "bearerAuth"
+)
+
+// Fields describes an ad-hoc JSON object, such as {"posts": [...]}, by mapping
+// each property to an example value of its type.
+type Fields map[string]any
+
+// Param is a query parameter.
+type Param struct {
+	Name string
+	// Type is a JSON schema type; "integer" when empty
+	Type string
+}
+
+// Op describes one route for Builder.Add.
+type Op struct {
+	Method  string
+	Path    string
+	Summary string
+	Tag     string
+	// Auth marks routes that need a bearer token
+	Auth  bool
+	Query []Param
+	// Body is a value of the JSON request body type, e.g. domain.LoginRequest{}
+	Body any
+	// Form is a value of the multipart form type, for uploads
+	Form any
+	// Response is a value of the success body type; nil for an empty body
+	Response any
+	// Status is the success status code; 200 when zero
+	Status int
+	// ContentType overrides the success media type, e.g. for event streams
+	ContentType string
+}
+
+// Builder accumulates operations into a Document.
+type Builder struct {
+	doc *Document
+	gen *generator
+	// errorSchema is attached as the default response of every operation
+	errorSchema *Schema
+}
+
+// NewBuilder starts a document. errorBody is a value of the type every error
+// response uses.
+func NewBuilder(info Info, errorBody any) *Builder {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]*PathItem{},
+		Components: Components{
+			Schemas: map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{
+				bearerScheme: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+		operations: map[string]*Operation{},
+	}
+	gen := &generator{schemas: doc.Components.Schemas}
+	return &Builder{
+		doc:         doc,
+		gen:         gen,
+		errorSchema: gen.schemaFor(reflect.TypeOf(errorBody)),
+	}
+}
+
+// Add registers an operation. Path uses the router's syntax (":id",
+// "*filepath"); it is converted to OpenAPI templating and each segment becomes
+// a required path parameter.
+func (b *Builder) Add(op Op) {
+	path, params := convertPath(op.Path)
+
+	operation := &Operation{
+		Summary:     op.Summary,
+		OperationID: operationID(op.Method, op.Path),
+		Parameters:  params,
+		Responses: map[string]*Response{
+			"default": {
+				Description: "Error",
+				Content:     map[string]*MediaType{contentJSON: {Schema: b.errorSchema}},
+			},
+		},
+	}
+	if op.Tag != "" {
+		operation.Tags = []string{op.Tag}
+	}
+	if op.Auth {
+		operation.Security = []map[string][]string{{bearerScheme: {}}}
+	}
+	for _, q := range op.Query {
+		typ := q.Type
+		if typ == "" {
+			typ = "integer"
+		}
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:   q.Name,
+			In:     "query",
+			Schema: &Schema{Type: typ},
+		})
+	}
+	if op.Body != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{contentJSON: {Schema: b.schemaOf(op.Body)}},
+		}
+	}
+	if op.Form != nil {
+		operation.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{contentMultipart: {Schema: b.schemaOf(op.Form)}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if op.Response != nil {
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = contentJSON
+		}
+		success.Content = map[string]*MediaType{contentType: {Schema: b.schemaOf(op.Response)}}
+	}
+	operation.Responses[strconv.Itoa(status)] = success
+
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+	(*item)[strings.ToLower(op.Method)] = operation
+	b.doc.operations[op.Method+" "+op.Path] = operation
+}
+
+// Document returns the built document.
+func (b *Builder) Document() *Document {
+	return b.doc
+}
+
+func (b *Builder) schemaOf(v any) *Schema {
+	if fields, ok := v.(Fields); ok {
+		schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for name, value := range fields {
+			schema.Properties[name] = b.schemaOf(value)
+			schema.Required = append(schema.Required, name)
+		}
+		sort.Strings(schema.Required)
+		return schema
+	}
+	return b.gen.schemaFor(reflect.TypeOf(v))
+}
+
+// convertPath turns "/api/posts/:id" into "/api/posts/{id}" and returns the
+// path parameters it found.
+func convertPath(routePath string) (string, []Parameter) {
+	segments := strings.Split(routePath, "/")
+	var params []Parameter
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+
+		schema := &Schema{Type: "integer", Minimum: ptr(0.0)}
+		if segment[0] == '*' {
+			schema = &Schema{Type: "string"}
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable identifier such as "getApiPostsId".
+func operationID(method, routePath string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(routePath, func(r rune) bool {
+		return r == '/' || r == ':' || r == '*' || r == '-' || r == '_'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,211 @@
+package openapi
+
+import (
+	"mime/multipart"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI schema object the API uses.
+type Schema struct {
+	Ref        string             `json:"$ref,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Format     string             `json:"format,omitempty"`
+	Nullable   bool               `json:"nullable,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+	Enum       []string           `json:"enum,omitempty"`
+	MinLength  *int               `json:"minLength,omitempty"`
+	MaxLength  *int               `json:"maxLength,omitempty"`
+	Minimum    *float64           `json:"minimum,omitempty"`
+	Maximum    *float64           `json:"maximum,omitempty"`
+	MinItems   *int               `json:"minItems,omitempty"`
+	MaxItems   *int               `json:"maxItems,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+	fileType = reflect.TypeOf(multipart.FileHeader{})
+)
+
+// generator reflects Go types into schemas. Named structs are registered once
+// under components and referenced from then on, which also covers recursive
+// types such as comment replies.
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) schemaFor(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case fileType:
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := g.schemaFor(t.Elem())
+		if elem.Ref != "" {
+			return elem
+		}
+		elem.Nullable = true
+		return elem
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Reserve the name before walking the fields so self references
+			// resolve to it.
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + name}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	}
+	return &Schema{}
+}
+
+func (g *generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, omitempty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		prop := g.schemaFor(field.Type)
+		if required := applyBinding(prop, field.Tag.Get("binding")); required {
+			schema.Required = append(schema.Required, name)
+		} else if !omitempty && field.Type.Kind() != reflect.Pointer && !isRequest(t) {
+			// Response fields without omitempty are always present
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// isRequest reports whether t is an input type, recognised by its binding
+// tags. Only their "required" rules decide which properties are required.
+func isRequest(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup("binding"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonName(field reflect.StructField) (name string, omitempty, skip bool) {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		// Multipart forms are described by their form tags
+		tag = field.Tag.Get("form")
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, strings.Contains(opts, "omitempty"), false
+}
+
+// applyBinding translates the validator rules in a binding tag into schema
+// constraints and reports whether the field is required.
+func applyBinding(s *Schema, tag string) (required bool) {
+	if tag == "" {
+		return false
+	}
+	rules := strings.Split(tag, ",")
+	// An omitempty field may be sent empty, which a minimum cannot express, so
+	// lower bounds are left to the binding itself.
+	omitempty := slices.Contains(rules, "omitempty")
+	for _, rule := range rules {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			s.Format = "email"
+		case "url":
+			s.Format = "uri"
+		case "oneof":
+			s.Enum = strings.Fields(value)
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil || (omitempty && key == "min") {
+				continue
+			}
+			setBound(s, key == "min", n)
+		}
+	}
+	// A required string must not be empty, as with the validator
+	if required && s.Type == "string" && s.MinLength == nil {
+		s.MinLength = ptr(1)
+	}
+	if required && s.Type == "integer" && s.Minimum != nil && *s.Minimum == 0 {
+		s.Minimum = ptr(1.0)
+	}
+	return required
+}
+
+func setBound(s *Schema, lower bool, n int) {
+	switch s.Type {
+	case "string":
+		if lower {
+			s.MinLength = ptr(n)
+		} else {
+			s.MaxLength = ptr(n)
+		}
+	case "array":
+		if lower {
+			s.MinItems = ptr(n)
+		} else {
+			s.MaxItems = ptr(n)
+		}
+	case "integer", "number":
+		if lower {
+			s.Minimum = ptr(float64(n))
+		} else {
+			s.Maximum = ptr(float64(n))
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,176 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationError describes the first part of a request body that does not
+// match its schema.
+type ValidationError struct {
+	// Field is the dotted path of the offending value; empty for the body itself
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// ValidateJSON checks a JSON document against schema. Properties the schema
+// does not declare are allowed so older clients keep working.
+func (d *Document) ValidateJSON(schema *Schema, data []byte) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return &ValidationError{Message: "request body is required"}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return &ValidationError{Message: "request body is not valid JSON"}
+	}
+	return d.validate(schema, value, "")
+}
+
+func (d *Document) validate(schema *Schema, value any, field string) error {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	if value == nil {
+		if schema.Nullable || schema.Type == "" {
+			return nil
+		}
+		return fail(field, "must not be null")
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fail(field, "must be an object")
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return fail(join(field, name), "is required")
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(schema.Properties)) {
+			v, ok := obj[name]
+			if !ok {
+				continue
+			}
+			if err := d.validate(schema.Properties[name], v, join(field, name)); err != nil {
+				return err
+			}
+		}
+
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fail(field, "must be an array")
+		}
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			return fail(field, fmt.Sprintf("must have at least %d items", *schema.MinItems))
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			return fail(field, fmt.Sprintf("must have at most %d items", *schema.MaxItems))
+		}
+		for i, item := range items {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+				return err
+			}
+		}
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fail(field, "must be a string")
+		}
+		return validateString(schema, s, field)
+
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return fail(field, "must be a number")
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fail(field, "must be a number")
+		}
+		if schema.Type == "integer" && strings.ContainsAny(n.String(), ".eE") {
+			return fail(field, "must be an integer")
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return fail(field, fmt.Sprintf("must be at least %v", *schema.Minimum))
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return fail(field, fmt.Sprintf("must be at most %v", *schema.Maximum))
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail(field, "must be a boolean")
+		}
+	}
+	return nil
+}
+
+func validateString(schema *Schema, s, field string) error {
+	length := utf8.RuneCountInString(s)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		if *schema.MinLength == 1 {
+			return fail(field, "must not be empty")
+		}
+		return fail(field, fmt.Sprintf("must be at least %d characters", *schema.MinLength))
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		return fail(field, fmt.Sprintf("must be at most %d characters", *schema.MaxLength))
+	}
+	if len(schema.Enum) > 0 && !slices.Contains(schema.Enum, s) {
+		return fail(field, "must be one of "+strings.Join(schema.Enum, ", "))
+	}
+
+	switch schema.Format {
+	case "email":
+		if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+			return fail(field, "must be a valid email address")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			return fail(field, "must be an RFC 3339 date-time")
+		}
+	}
+	return nil
+}
+
+// resolve follows a $ref to its component schema.
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, refPrefix)]
+	}
+	return schema
+}
+
+func fail(field, message string) error {
+	return &ValidationError{Field: field, Message: message}
+}
+
+func join(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
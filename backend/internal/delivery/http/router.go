@@ -1,59 +1,134 @@
 package http
 
 import (
-	"fmt"
+	"log/slog"
+	"net/http"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 
 	"github.com/ruth987/CHub.git/internal/delivery/http/handler"
+	"github.com/ruth987/CHub.git/internal/delivery/http/middleware"
+	"github.com/ruth987/CHub.git/pkg/metrics"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
 )
 
-func init() {
-	err := godotenv.Load()
-	if err != nil {
-		fmt.Println("⚠️ Warning: Could not load .env file, using system environment variables.")
+// RateLimits holds the token bucket applied to each throttled route group.
+type RateLimits struct {
+	// Auth covers login, registration and account recovery
+	Auth ratelimit.Limit
+	// Write covers creating posts, comments, prayer requests and messages
+	Write ratelimit.Limit
+}
+
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Auth:  ratelimit.PerMinute(10),
+		Write: ratelimit.PerMinute(30),
 	}
 }
 
+// Config holds the router settings that come from the app configuration.
+type Config struct {
+	RateLimits   RateLimits
+	MaxBodyBytes int64
+	// CORSOrigins are the origins browsers may call the API from; see
+	// middleware.CORS for the pattern syntax
+	CORSOrigins []string
+	// UploadsDir is served at /uploads when files are stored locally; empty
+	// leaves the route out
+	UploadsDir string
+	// TrustedProxies may set the client IP with X-Forwarded-For; empty
+	// ignores the header
+	TrustedProxies []string
+}
+
 func NewRouter(
 	userHandler *handler.UserHandler,
 	postHandler *handler.PostHandler,
 	commentHandler *handler.CommentHandler,
 	savedPostHandler *handler.SavedPostHandler,
 	authMiddleware gin.HandlerFunc,
+	streamAuthMiddleware gin.HandlerFunc,
 	prayerRequestHandler *handler.PrayerRequestHandler,
-
+	messageHandler *handler.MessageHandler,
+	userBlockHandler *handler.UserBlockHandler,
+	userMuteHandler *handler.UserMuteHandler,
+	trashHandler *handler.TrashHandler,
+	healthHandler *handler.HealthHandler,
+	uploadHandler *handler.UploadHandler,
+	linkPreviewHandler *handler.LinkPreviewHandler,
+	mediaHandler *handler.MediaHandler,
+	auditHandler *handler.AuditHandler,
+	accountHandler *handler.AccountHandler,
+	rateLimitStore ratelimit.Store,
+	cfg Config,
+	appMetrics *metrics.Metrics,
+	logger *slog.Logger,
 ) *gin.Engine {
-	router := gin.Default()
-
-	// CORS configuration
-	router.Use(cors.New(cors.Config{
-		AllowOrigins: []string{
-			"http://localhost:3000",
-			"http://51.21.236.223:3000",
-		},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * 60 * 60,
-	}))
-
-	// Serve static files
-	router.Static("/uploads", "./uploads")
+	router := gin.New()
+	// The client IP keys rate limits and lockouts and goes in the audit log,
+	// so forwarded headers count only from known proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Error("invalid trusted proxies; ignoring forwarded headers", "error", err)
+		_ = router.SetTrustedProxies(nil)
+	}
+	router.Use(middleware.RequestID(), middleware.Client(), middleware.RequestLogger(logger), middleware.Metrics(appMetrics), gin.Recovery())
+
+	// CORS goes first so preflights are answered before any other check and
+	// error responses still carry the headers the browser needs to read them
+	router.Use(middleware.CORS(cfg.CORSOrigins))
+	// Direct uploads are checked against the exact size that was signed, and
+	// sermon recordings run far past the usual limit
+	router.Use(middleware.MaxBodySize(cfg.MaxBodyBytes, "/api/uploads/direct/"))
+
+	writeLimit := middleware.RateLimit(rateLimitStore, logger, "write", cfg.RateLimits.Write)
+
+	// Reject request bodies that don't match the spec
+	router.Use(middleware.ValidateRequest(Spec()))
+
+	// Serve locally stored files
+	if cfg.UploadsDir != "" {
+		router.Static("/uploads", cfg.UploadsDir)
+	}
+
+	router.GET(SpecPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, Spec())
+	})
+
+	// Probes and metrics for the orchestrator
+	router.GET("/healthz", healthHandler.Live)
+	router.GET("/readyz", healthHandler.Ready)
+	router.GET("/metrics", gin.WrapH(appMetrics.Handler()))
 
 	api := router.Group("/api")
 	{
 		// Public routes
-		api.POST("/register", userHandler.Register)
-		api.POST("/login", userHandler.Login)
+		public := api.Group("")
+		public.Use(middleware.RateLimit(rateLimitStore, logger, "auth", cfg.RateLimits.Auth))
+		{
+			public.POST("/register", userHandler.Register)
+			public.POST("/login", userHandler.Login)
+			public.POST("/verify-email", userHandler.VerifyEmail)
+			public.POST("/forgot-password", userHandler.ForgotPassword)
+			public.POST("/reset-password", userHandler.ResetPassword)
+			public.POST("/confirm-email", userHandler.ConfirmEmailChange)
+		}
+
+		// Presigned uploads for the local store, authorized by their signature
+		if uploadHandler.AcceptsDirectUploads() {
+			api.PUT("/uploads/direct/*key", uploadHandler.DirectUpload)
+		}
+
+		// Media players can't send a token, so recordings and their
+		// transcripts are served without one
+		api.GET("/media/:id/stream", mediaHandler.Stream)
+		api.HEAD("/media/:id/stream", mediaHandler.Stream)
+		api.GET("/media/:id/transcript.vtt", mediaHandler.Transcript)
 
 		// Prayer Request routes
 		prayerRequests := api.Group("/prayer-requests")
 		{
-			prayerRequests.POST("", prayerRequestHandler.Create)
+			prayerRequests.POST("", writeLimit, prayerRequestHandler.Create)
 			prayerRequests.GET("/random", prayerRequestHandler.GetRandom)
 			prayerRequests.GET("/:id", prayerRequestHandler.GetByID)
 			prayerRequests.PUT("/:id", prayerRequestHandler.Update)
@@ -69,15 +144,22 @@ func NewRouter(
 				posts.GET("", postHandler.GetAll)
 				posts.GET("/:id", postHandler.GetByID)
 				posts.GET("/:id/comments", commentHandler.GetByPostID)
-				protected.POST("", postHandler.Create)
+				protected.POST("", writeLimit, postHandler.Create)
 				protected.PUT("/:id", postHandler.Update)
 				protected.DELETE("/:id", postHandler.Delete)
+				protected.POST("/:id/restore", trashHandler.RestorePost)
+				protected.GET("/:id/revisions", postHandler.GetRevisions)
+				protected.POST("/:id/revisions/:revisionId/restore", postHandler.RollbackRevision)
 				protected.POST("/:id/like", postHandler.Like)
 				protected.DELETE("/:id/like", postHandler.Unlike)
-				protected.POST("/:id/comments", commentHandler.Create)
+				protected.POST("/:id/comments", writeLimit, commentHandler.Create)
 			}
 		}
 
+		// The message stream also takes a ticket in the query string, since a
+		// browser EventSource can't send an Authorization header
+		api.GET("/conversations/stream", streamAuthMiddleware, messageHandler.Stream)
+
 		// Protected routes
 		protected := api.Group("")
 		protected.Use(authMiddleware)
@@ -85,13 +167,75 @@ func NewRouter(
 			// User routes
 			protected.GET("/profile", userHandler.GetProfile)
 			protected.PUT("/profile", userHandler.UpdateProfile)
+			protected.POST("/verify-email/resend", userHandler.ResendVerification)
+			protected.PUT("/password", userHandler.ChangePassword)
+			protected.PUT("/email", userHandler.ChangeEmail)
+			protected.DELETE("/me", accountHandler.RequestDeletion)
+			protected.GET("/me/deletion", accountHandler.GetDeletion)
+			protected.DELETE("/me/deletion", accountHandler.CancelDeletion)
+			protected.GET("/me/export", accountHandler.GetExport)
+			protected.POST("/me/export", writeLimit, accountHandler.RequestExport)
+			protected.GET("/me/export/download", accountHandler.DownloadExport)
+			protected.GET("/users/:id", userHandler.GetUserProfile)
 			protected.GET("/users/:id/posts", userHandler.GetUserPosts)
 
+			// Block and mute routes
+			protected.GET("/blocks", userBlockHandler.GetBlocked)
+			protected.POST("/users/:id/block", userBlockHandler.Block)
+			protected.DELETE("/users/:id/block", userBlockHandler.Unblock)
+			protected.GET("/mutes", userMuteHandler.GetMuted)
+			protected.POST("/users/:id/mute", userMuteHandler.Mute)
+			protected.DELETE("/users/:id/mute", userMuteHandler.Unmute)
+
+			// File uploads
+			protected.POST("/upload", writeLimit, uploadHandler.UploadFile)
+			protected.POST("/uploads/sessions", writeLimit, uploadHandler.CreateSession)
+			protected.POST("/uploads/sessions/:id/finalize", uploadHandler.FinalizeSession)
+
+			// Link previews fetch other sites, so they share the write limit
+			protected.GET("/link-preview", writeLimit, linkPreviewHandler.Get)
+
+			// Sermon recordings and other media
+			media := protected.Group("/media")
+			{
+				media.POST("", writeLimit, mediaHandler.Create)
+				media.GET("", mediaHandler.List)
+				media.GET("/search", mediaHandler.Search)
+				media.GET("/:id", mediaHandler.GetByID)
+				media.PUT("/:id", mediaHandler.Update)
+				media.DELETE("/:id", mediaHandler.Delete)
+				media.PUT("/:id/transcript", mediaHandler.SetTranscript)
+				media.DELETE("/:id/transcript", mediaHandler.DeleteTranscript)
+			}
+
 			// Comment routes
 			comments := protected.Group("/comments")
 			{
 				comments.PUT("/:id", commentHandler.Update)
 				comments.DELETE("/:id", commentHandler.Delete)
+				comments.POST("/:id/restore", trashHandler.RestoreComment)
+				comments.GET("/:id/revisions", commentHandler.GetRevisions)
+				comments.POST("/:id/revisions/:revisionId/restore", commentHandler.RollbackRevision)
+			}
+
+			// Trash routes
+			protected.GET("/trash", trashHandler.GetTrash)
+
+			// Admin routes
+			protected.GET("/admin/audit-events", auditHandler.List)
+
+			// Direct message routes
+			conversations := protected.Group("/conversations")
+			{
+				conversations.GET("", messageHandler.GetConversations)
+				conversations.POST("", messageHandler.CreateConversation)
+				conversations.POST("/stream/ticket", messageHandler.StreamTicket)
+				conversations.GET("/:id", messageHandler.GetConversation)
+				conversations.GET("/:id/messages", messageHandler.GetMessages)
+				conversations.POST("/:id/messages", writeLimit, messageHandler.SendMessage)
+				conversations.POST("/:id/read", messageHandler.MarkRead)
+				conversations.POST("/:id/mute", messageHandler.Mute)
+				conversations.DELETE("/:id/mute", messageHandler.Unmute)
 			}
 		}
 
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ruth987/CHub.git/internal/delivery/http/handler"
+	"github.com/ruth987/CHub.git/pkg/metrics"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
+)
+
+// acceptAll stands in for the local store, so the direct upload route is
+// registered
+type acceptAll struct{}
+
+func (acceptAll) AcceptPut(ctx context.Context, key string, r *http.Request) error { return nil }
+
+// newTestRouter builds the router around handlers with no usecases behind
+// them. Routes that reach a handler will panic, which is fine for tests of
+// the routing table and the middleware that runs before it.
+func newTestRouter(t *testing.T, cfg Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if cfg.RateLimits == (RateLimits{}) {
+		cfg.RateLimits = DefaultRateLimits()
+	}
+	if cfg.MaxBodyBytes == 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+
+	return NewRouter(
+		&handler.UserHandler{},
+		&handler.PostHandler{},
+		&handler.CommentHandler{},
+		&handler.SavedPostHandler{},
+		func(c *gin.Context) { c.Next() },
+		func(c *gin.Context) { c.Next() },
+		&handler.PrayerRequestHandler{},
+		&handler.MessageHandler{},
+		&handler.UserBlockHandler{},
+		&handler.UserMuteHandler{},
+		&handler.TrashHandler{},
+		&handler.HealthHandler{},
+		handler.NewUploadHandler(nil, acceptAll{}),
+		&handler.LinkPreviewHandler{},
+		&handler.MediaHandler{},
+		&handler.AuditHandler{},
+		&handler.AccountHandler{},
+		ratelimit.NewMemoryStore(),
+		cfg,
+		metrics.New(),
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+	)
+}
+
+func TestRoutesAreInSpec(t *testing.T) {
+	router := newTestRouter(t, Config{UploadsDir: t.TempDir()})
+
+	if err := CheckRoutes(router); err != nil {
+		t.Fatal(err)
+	}
+
+	// The optional routes must have been registered for the check to cover
+	// them
+	want := map[string]bool{
+		http.MethodPut + " /api/uploads/direct/*key": false,
+		http.MethodGet + " /uploads/*filepath":       false,
+	}
+	for _, route := range router.Routes() {
+		if _, ok := want[route.Method+" "+route.Path]; ok {
+			want[route.Method+" "+route.Path] = true
+		}
+	}
+	for route, found := range want {
+		if !found {
+			t.Errorf("%s is not registered", route)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/ruth987/CHub.git/internal/delivery/http/handler"
+	"github.com/ruth987/CHub.git/internal/delivery/http/openapi"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// SpecPath is where the OpenAPI document is served.
+const SpecPath = "/api/openapi.json"
+
+var pageQuery = []openapi.Param{{Name: "page"}, {Name: "limit"}}
+
+// Spec returns the OpenAPI document describing every route the API serves.
+// Request bodies are validated against it, and CheckRoutes fails startup when a
+// route is registered without an entry here.
+var Spec = sync.OnceValue(buildSpec)
+
+func buildSpec() *openapi.Document {
+	b := openapi.NewBuilder(openapi.Info{
+		Title:       "CHub API",
+		Description: "Posts, comments, prayer requests and direct messages for the CHub community.",
+		Version:     "1.0.0",
+	}, handler.ErrorResponse{})
+
+	message := openapi.Fields{"message": ""}
+
+	ops := []openapi.Op{
+		{Method: http.MethodGet, Path: SpecPath, Summary: "This OpenAPI document", Tag: "meta", Response: openapi.Fields{}},
+		{Method: http.MethodGet, Path: "/uploads/*filepath", Summary: "Download an uploaded file", Tag: "uploads", Response: "", ContentType: "application/octet-stream"},
+		{Method: http.MethodHead, Path: "/uploads/*filepath", Summary: "Check an uploaded file", Tag: "uploads"},
+		{Method: http.MethodGet, Path: "/healthz", Summary: "Liveness probe", Tag: "meta", Response: handler.HealthResponse{}},
+		{Method: http.MethodGet, Path: "/readyz", Summary: "Readiness probe; 503 when the database or storage is unreachable", Tag: "meta", Response: handler.HealthResponse{}},
+		{Method: http.MethodGet, Path: "/metrics", Summary: "Prometheus metrics", Tag: "meta", Response: "", ContentType: "text/plain"},
+		{Method: http.MethodPost, Path: "/api/upload", Summary: "Upload a file; JPEG, PNG or WebP images up to 2 MB for avatars and 10 MB otherwise, PDFs or MP3 or WAV audio up to 25 MB as post attachments, and MP3, WAV, MP4 or WebM up to 2 GB as sermon media (use an upload session for large files)", Tag: "uploads", Auth: true, Form: handler.UploadForm{}, Response: domain.Upload{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/uploads/sessions", Summary: "Start an upload straight to storage; returns the request to make", Tag: "uploads", Auth: true, Body: domain.CreateUploadSessionRequest{}, Response: domain.UploadSession{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/uploads/sessions/:id/finalize", Summary: "Check and record an uploaded file, optionally setting it as a post's image", Tag: "uploads", Auth: true, Body: domain.FinalizeUploadRequest{}, Response: domain.Upload{}},
+		{Method: http.MethodGet, Path: "/api/link-preview", Summary: "Preview a link from its Open Graph, Twitter card and title metadata", Tag: "posts", Auth: true, Query: []openapi.Param{{Name: "url", Type: "string"}}, Response: domain.LinkPreview{}},
+		{Method: http.MethodPut, Path: "/api/uploads/direct/*key", Summary: "Upload a file to a presigned URL of the local store", Tag: "uploads", Status: http.StatusNoContent},
+
+		// Accounts
+		{Method: http.MethodPost, Path: "/api/register", Summary: "Create an account", Tag: "users", Body: domain.RegisterRequest{}, Response: domain.User{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/login", Summary: "Log in", Tag: "users", Body: domain.LoginRequest{}, Response: domain.LoginResponse{}},
+		{Method: http.MethodPost, Path: "/api/verify-email", Summary: "Verify an email address", Tag: "users", Body: domain.VerifyEmailRequest{}, Response: message},
+		{Method: http.MethodPost, Path: "/api/forgot-password", Summary: "Send a password reset link", Tag: "users", Body: domain.ForgotPasswordRequest{}, Response: message},
+		{Method: http.MethodPost, Path: "/api/reset-password", Summary: "Reset a password", Tag: "users", Body: domain.ResetPasswordRequest{}, Response: message},
+		{Method: http.MethodPost, Path: "/api/confirm-email", Summary: "Confirm an email change", Tag: "users", Body: domain.VerifyEmailRequest{}, Response: domain.User{}},
+		{Method: http.MethodGet, Path: "/api/profile", Summary: "Get the current user", Tag: "users", Auth: true, Response: domain.User{}},
+		{Method: http.MethodPut, Path: "/api/profile", Summary: "Update the current user", Tag: "users", Auth: true, Body: domain.UpdateProfileRequest{}, Response: domain.User{}},
+		{Method: http.MethodPost, Path: "/api/verify-email/resend", Summary: "Resend the verification email", Tag: "users", Auth: true, Response: message},
+		{Method: http.MethodPut, Path: "/api/password", Summary: "Change password, signing out every session; the response carries a new token", Tag: "users", Auth: true, Body: domain.ChangePasswordRequest{}, Response: openapi.Fields{"message": "", "token": ""}},
+		{Method: http.MethodPut, Path: "/api/email", Summary: "Change email address", Tag: "users", Auth: true, Body: domain.ChangeEmailRequest{}, Response: message, Status: http.StatusAccepted},
+		{Method: http.MethodDelete, Path: "/api/me", Summary: "Schedule the account's deletion after the grace period, anonymizing or removing its posts and comments", Tag: "users", Auth: true, Body: domain.DeleteAccountRequest{}, Response: domain.AccountDeletion{}, Status: http.StatusAccepted},
+		{Method: http.MethodGet, Path: "/api/me/deletion", Summary: "Get the account's scheduled deletion", Tag: "users", Auth: true, Response: domain.AccountDeletion{}},
+		{Method: http.MethodDelete, Path: "/api/me/deletion", Summary: "Cancel the account's scheduled deletion", Tag: "users", Auth: true, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/me/export", Summary: "Get the data export, starting one if none is in progress or ready; 202 until it is ready", Tag: "users", Auth: true, Response: domain.DataExport{}},
+		{Method: http.MethodPost, Path: "/api/me/export", Summary: "Start a fresh data export", Tag: "users", Auth: true, Response: domain.DataExport{}, Status: http.StatusAccepted},
+		{Method: http.MethodGet, Path: "/api/me/export/download", Summary: "Download the ready data export as a zip", Tag: "users", Auth: true, Response: "", ContentType: "application/zip"},
+		{Method: http.MethodGet, Path: "/api/users/:id", Summary: "Get a user", Tag: "users", Auth: true, Response: domain.User{}},
+		{Method: http.MethodGet, Path: "/api/users/:id/posts", Summary: "List a user's posts", Tag: "users", Auth: true, Query: pageQuery, Response: []domain.Post{}},
+
+		// Blocks and mutes
+		{Method: http.MethodGet, Path: "/api/blocks", Summary: "List blocked users", Tag: "users", Auth: true, Response: openapi.Fields{"blocks": []domain.UserBlock{}}},
+		{Method: http.MethodPost, Path: "/api/users/:id/block", Summary: "Block a user", Tag: "users", Auth: true, Response: message},
+		{Method: http.MethodDelete, Path: "/api/users/:id/block", Summary: "Unblock a user", Tag: "users", Auth: true, Response: message},
+		{Method: http.MethodGet, Path: "/api/mutes", Summary: "List muted users", Tag: "users", Auth: true, Response: openapi.Fields{"mutes": []domain.UserMute{}}},
+		{Method: http.MethodPost, Path: "/api/users/:id/mute", Summary: "Mute a user", Tag: "users", Auth: true, Response: message},
+		{Method: http.MethodDelete, Path: "/api/users/:id/mute", Summary: "Unmute a user", Tag: "users", Auth: true, Response: message},
+
+		// Prayer requests
+		{Method: http.MethodPost, Path: "/api/prayer-requests", Summary: "Submit a prayer request", Tag: "prayer-requests", Body: domain.PrayerRequestInput{}, Response: domain.PrayerRequest{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/api/prayer-requests/random", Summary: "Get random prayer requests", Tag: "prayer-requests", Query: []openapi.Param{{Name: "limit"}}, Response: openapi.Fields{"prayer_requests": []domain.PrayerRequest{}}},
+		{Method: http.MethodGet, Path: "/api/prayer-requests/:id", Summary: "Get a prayer request", Tag: "prayer-requests", Response: domain.PrayerRequest{}},
+		{Method: http.MethodPut, Path: "/api/prayer-requests/:id", Summary: "Update a prayer request", Tag: "prayer-requests", Body: domain.PrayerRequestInput{}, Response: domain.PrayerRequest{}},
+		{Method: http.MethodDelete, Path: "/api/prayer-requests/:id", Summary: "Delete a prayer request", Tag: "prayer-requests", Status: http.StatusNoContent},
+
+		// Posts
+		{Method: http.MethodGet, Path: "/api/posts", Summary: "List posts", Tag: "posts", Auth: true, Query: pageQuery, Response: []domain.Post{}},
+		{Method: http.MethodPost, Path: "/api/posts", Summary: "Create a post", Tag: "posts", Auth: true, Body: domain.CreatePostRequest{}, Response: domain.Post{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/api/posts/:id", Summary: "Get a post", Tag: "posts", Auth: true, Response: domain.Post{}},
+		{Method: http.MethodPut, Path: "/api/posts/:id", Summary: "Update a post", Tag: "posts", Auth: true, Body: domain.UpdatePostRequest{}, Response: domain.Post{}},
+		{Method: http.MethodDelete, Path: "/api/posts/:id", Summary: "Delete a post", Tag: "posts", Auth: true, Response: message},
+		{Method: http.MethodPost, Path: "/api/posts/:id/restore", Summary: "Restore a deleted post", Tag: "posts", Auth: true, Response: message},
+		{Method: http.MethodGet, Path: "/api/posts/:id/revisions", Summary: "List a post's revisions", Tag: "posts", Auth: true, Response: openapi.Fields{"revisions": []domain.Revision{}}},
+		{Method: http.MethodPost, Path: "/api/posts/:id/revisions/:revisionId/restore", Summary: "Roll a post back to a revision", Tag: "posts", Auth: true, Response: domain.Post{}},
+		{Method: http.MethodPost, Path: "/api/posts/:id/like", Summary: "Like a post", Tag: "posts", Auth: true, Response: openapi.Fields{"message": "", "likes": 0, "is_liked": false}},
+		{Method: http.MethodDelete, Path: "/api/posts/:id/like", Summary: "Unlike a post", Tag: "posts", Auth: true, Response: openapi.Fields{"message": "", "likes": 0, "is_liked": false}},
+
+		// Media
+		{Method: http.MethodPost, Path: "/api/media", Summary: "Make a media item from a sermon_media upload; the duration is read from MP4 and WAV files", Tag: "media", Auth: true, Body: domain.CreateMediaRequest{}, Response: domain.MediaItem{}, Status: http.StatusCreated},
+		{Method: http.MethodGet, Path: "/api/media", Summary: "List your media items", Tag: "media", Auth: true, Query: pageQuery, Response: []domain.MediaItem{}},
+		{Method: http.MethodGet, Path: "/api/media/search", Summary: "Search media by title, description and transcript", Tag: "media", Auth: true, Query: []openapi.Param{{Name: "q", Type: "string"}, {Name: "limit"}}, Response: []domain.MediaSearchResult{}},
+		{Method: http.MethodGet, Path: "/api/media/:id", Summary: "Get a media item", Tag: "media", Auth: true, Response: domain.MediaItem{}},
+		{Method: http.MethodPut, Path: "/api/media/:id", Summary: "Update a media item's details and chapters", Tag: "media", Auth: true, Body: domain.UpdateMediaRequest{}, Response: domain.MediaItem{}},
+		{Method: http.MethodDelete, Path: "/api/media/:id", Summary: "Delete a media item", Tag: "media", Auth: true, Status: http.StatusNoContent},
+		{Method: http.MethodPut, Path: "/api/media/:id/transcript", Summary: "Upload a WebVTT or SRT transcript, replacing any earlier one", Tag: "media", Auth: true, Form: handler.TranscriptForm{}, Response: domain.MediaItem{}},
+		{Method: http.MethodDelete, Path: "/api/media/:id/transcript", Summary: "Remove a media item's transcript", Tag: "media", Auth: true, Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/media/:id/transcript.vtt", Summary: "Download a transcript as WebVTT", Tag: "media", Response: "", ContentType: "text/vtt"},
+		{Method: http.MethodGet, Path: "/api/media/:id/stream", Summary: "Stream a media file; supports Range requests", Tag: "media", Response: "", ContentType: "application/octet-stream"},
+		{Method: http.MethodHead, Path: "/api/media/:id/stream", Summary: "Check a media file's size and type", Tag: "media"},
+
+		// Comments
+		{Method: http.MethodGet, Path: "/api/posts/:id/comments", Summary: "List a post's comments", Tag: "comments", Auth: true, Query: pageQuery, Response: []domain.Comment{}},
+		{Method: http.MethodPost, Path: "/api/posts/:id/comments", Summary: "Comment on a post", Tag: "comments", Auth: true, Body: domain.CreateCommentRequest{}, Response: domain.Comment{}, Status: http.StatusCreated},
+		{Method: http.MethodPut, Path: "/api/comments/:id", Summary: "Update a comment", Tag: "comments", Auth: true, Body: domain.UpdateCommentRequest{}, Response: domain.Comment{}},
+		{Method: http.MethodDelete, Path: "/api/comments/:id", Summary: "Delete a comment", Tag: "comments", Auth: true, Response: message},
+		{Method: http.MethodPost, Path: "/api/comments/:id/restore", Summary: "Restore a deleted comment", Tag: "comments", Auth: true, Response: message},
+		{Method: http.MethodGet, Path: "/api/comments/:id/revisions", Summary: "List a comment's revisions", Tag: "comments", Auth: true, Response: openapi.Fields{"revisions": []domain.Revision{}}},
+		{Method: http.MethodPost, Path: "/api/comments/:id/revisions/:revisionId/restore", Summary: "Roll a comment back to a revision", Tag: "comments", Auth: true, Response: domain.Comment{}},
+
+		// Trash and saved posts
+		{Method: http.MethodGet, Path: "/api/trash", Summary: "List the current user's deleted posts and comments", Tag: "posts", Auth: true, Response: domain.Trash{}},
+		{Method: http.MethodGet, Path: "/api/admin/audit-events", Summary: "Search the audit log (moderators only)", Tag: "admin", Auth: true, Query: []openapi.Param{{Name: "actor_id"}, {Name: "action", Type: "string"}, {Name: "target_type", Type: "string"}, {Name: "target_id"}, {Name: "since", Type: "string"}, {Name: "until", Type: "string"}, {Name: "before"}, {Name: "limit"}}, Response: openapi.Fields{"events": []domain.AuditEvent{}}},
+		{Method: http.MethodGet, Path: "/api/saved-posts", Summary: "List saved posts", Tag: "saved-posts", Auth: true, Response: openapi.Fields{"saved_posts": []domain.Post{}}},
+		{Method: http.MethodPost, Path: "/api/saved-posts/:id", Summary: "Save a post", Tag: "saved-posts", Auth: true, Response: message},
+		{Method: http.MethodDelete, Path: "/api/saved-posts/:id", Summary: "Unsave a post", Tag: "saved-posts", Auth: true, Response: message},
+		{Method: http.MethodGet, Path: "/api/saved-posts/:id/check", Summary: "Check whether a post is saved", Tag: "saved-posts", Auth: true, Response: openapi.Fields{"is_saved": false}},
+
+		// Direct messages
+		{Method: http.MethodGet, Path: "/api/conversations", Summary: "List conversations", Tag: "messages", Auth: true, Query: pageQuery, Response: openapi.Fields{"conversations": []domain.Conversation{}}},
+		{Method: http.MethodPost, Path: "/api/conversations", Summary: "Start a conversation", Tag: "messages", Auth: true, Body: domain.CreateConversationRequest{}, Response: domain.Conversation{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/conversations/stream/ticket", Summary: "Get a short-lived ticket for opening the stream with EventSource", Tag: "messages", Auth: true, Response: domain.StreamTicket{}},
+		{Method: http.MethodGet, Path: "/api/conversations/stream", Summary: "Stream message events; send a bearer token or a stream ticket", Tag: "messages", Auth: true, Query: []openapi.Param{{Name: "ticket", Type: "string"}}, Response: domain.MessageEvent{}, ContentType: "text/event-stream"},
+		{Method: http.MethodGet, Path: "/api/conversations/:id", Summary: "Get a conversation", Tag: "messages", Auth: true, Response: domain.Conversation{}},
+		{Method: http.MethodGet, Path: "/api/conversations/:id/messages", Summary: "List messages", Tag: "messages", Auth: true, Query: []openapi.Param{{Name: "before"}, {Name: "after"}, {Name: "limit"}}, Response: openapi.Fields{"messages": []domain.Message{}}},
+		{Method: http.MethodPost, Path: "/api/conversations/:id/messages", Summary: "Send a message", Tag: "messages", Auth: true, Body: domain.SendMessageRequest{}, Response: domain.Message{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/api/conversations/:id/read", Summary: "Mark a conversation as read", Tag: "messages", Auth: true, Body: domain.MarkReadRequest{}, Response: message},
+		{Method: http.MethodPost, Path: "/api/conversations/:id/mute", Summary: "Mute a conversation", Tag: "messages", Auth: true, Response: openapi.Fields{"message": "", "is_muted": false}},
+		{Method: http.MethodDelete, Path: "/api/conversations/:id/mute", Summary: "Unmute a conversation", Tag: "messages", Auth: true, Response: openapi.Fields{"message": "", "is_muted": false}},
+	}
+	for _, op := range ops {
+		b.Add(op)
+	}
+	return b.Document()
+}
+
+// CheckRoutes reports every route registered on router that the spec does not
+// describe, so the document cannot drift from the API.
+func CheckRoutes(router *gin.Engine) error {
+	spec := Spec()
+	var missing []string
+	for _, route := range router.Routes() {
+		if spec.Operation(route.Method, route.Path) == nil {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("routes missing from the OpenAPI spec: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
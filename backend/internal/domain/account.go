@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Data export statuses
+const (
+	DataExportPending = "pending"
+	DataExportRunning = "running"
+	DataExportReady   = "ready"
+	DataExportFailed  = "failed"
+)
+
+// DataExport is a job that gathers everything stored about a user into a zip
+// they can download until it expires.
+type DataExport struct {
+	ID     uint   `json:"id"`
+	UserID uint   `json:"user_id"`
+	Status string `json:"status"`
+	Key    string `json:"-"`
+	Size   int64  `json:"size,omitempty"`
+	// Error says why a failed export failed
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	// DownloadURL is set once the export is ready
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// DataSection is one JSON file of a data export
+type DataSection struct {
+	Name string
+	Data json.RawMessage
+}
+
+// DataExportDownload is a ready export opened for reading
+type DataExportDownload struct {
+	io.ReadCloser
+	Export *DataExport
+}
+
+// Account deletion modes. Anonymizing keeps the user's posts and comments
+// under a placeholder name; removing deletes them along with the account.
+const (
+	DeletionAnonymize = "anonymize"
+	DeletionRemove    = "remove"
+)
+
+// AccountDeletion is a requested deletion waiting out its grace period
+type AccountDeletion struct {
+	UserID       uint      `json:"user_id"`
+	Mode         string    `json:"mode"`
+	RequestedAt  time.Time `json:"requested_at"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	Mode     string `json:"mode" binding:"required,oneof=anonymize remove"`
+}
+
+// AccountErasure records that an account was erased from the database. It is
+// kept until the copies held elsewhere, such as backups, have been scrubbed
+// too.
+type AccountErasure struct {
+	ID     uint
+	UserID uint
+	// Username is what the account was called, for finding objects named
+	// after it; it is cleared once scrubbing is done
+	Username   string
+	Mode       string
+	ErasedAt   time.Time
+	ScrubbedAt *time.Time
+}
+
+// Scrubber removes erased accounts from data kept outside the database.
+// Scrub is retried until it succeeds, so it must be safe to repeat.
+type Scrubber interface {
+	Scrub(ctx context.Context, erasures []AccountErasure) error
+}
+
+type AccountRepository interface {
+	// CreateExport fails with a conflict while the user has an export
+	// pending or running
+	CreateExport(ctx context.Context, export *DataExport) error
+	GetLatestExport(ctx context.Context, userID uint) (*DataExport, error)
+	GetExportsByUserID(ctx context.Context, userID uint) ([]DataExport, error)
+	// ClaimExport marks the oldest pending export running and returns it, or
+	// returns nil when there is none. Exports left running longer than
+	// stale are claimed again, as their worker is presumed dead.
+	ClaimExport(ctx context.Context, stale time.Duration) (*DataExport, error)
+	CompleteExport(ctx context.Context, export *DataExport) error
+	FailExport(ctx context.Context, id uint, message string) error
+	// GetExpiredExports returns up to limit exports that expired before the
+	// given time
+	GetExpiredExports(ctx context.Context, before time.Time, limit int) ([]DataExport, error)
+	DeleteExport(ctx context.Context, id uint) error
+	// PersonalData returns every section of a data export for the user
+	PersonalData(ctx context.Context, userID uint) ([]DataSection, error)
+
+	ScheduleDeletion(ctx context.Context, deletion *AccountDeletion) error
+	GetDeletion(ctx context.Context, userID uint) (*AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID uint) error
+	// GetDueDeletions returns up to limit deletions scheduled before the
+	// given time
+	GetDueDeletions(ctx context.Context, before time.Time, limit int) ([]AccountDeletion, error)
+	// DeleteInteractions removes what a user did to other people's content
+	// and accounts: likes, saves, reports, blocks and mutes, along with their
+	// tokens
+	DeleteInteractions(ctx context.Context, userID uint) error
+
+	CreateErasure(ctx context.Context, erasure *AccountErasure) error
+	// GetUnscrubbedErasures returns up to limit erasures made before the
+	// given time that are still to be scrubbed
+	GetUnscrubbedErasures(ctx context.Context, before time.Time, limit int) ([]AccountErasure, error)
+	MarkScrubbed(ctx context.Context, ids []uint) error
+}
+
+type AccountUsecase interface {
+	// RequestExport queues a data export for the user
+	RequestExport(ctx context.Context, userID uint) (*DataExport, error)
+	// GetExport returns the user's export in progress or ready to download,
+	// queuing a new one when there is neither
+	GetExport(ctx context.Context, userID uint) (*DataExport, error)
+	OpenExport(ctx context.Context, userID uint) (*DataExportDownload, error)
+	// ProcessExports builds queued exports until none are left
+	ProcessExports(ctx context.Context) (int, error)
+	// DeleteExpiredExports removes exports past their expiry
+	DeleteExpiredExports(ctx context.Context) (int, error)
+
+	// RequestDeletion schedules the account's deletion after the grace
+	// period. The password must be confirmed.
+	RequestDeletion(ctx context.Context, userID uint, req *DeleteAccountRequest) (*AccountDeletion, error)
+	GetDeletion(ctx context.Context, userID uint) (*AccountDeletion, error)
+	CancelDeletion(ctx context.Context, userID uint) error
+	// EraseDue erases the accounts whose grace period has run out
+	EraseDue(ctx context.Context) (int, error)
+	// ScrubErased removes erased accounts from backups and other copies
+	ScrubErased(ctx context.Context) (int, error)
+}
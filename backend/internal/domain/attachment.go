@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"context"
+	"strings"
+)
+
+// MaxAttachments is how many files one post may carry
+const MaxAttachments = 10
+
+// Attachment is a file shown with a post: an image, a PDF or an audio clip.
+// A post's attachments keep the order its author gave them.
+type Attachment struct {
+	// UploadID is the upload the file came from; attachments from before
+	// uploads were required may have none
+	UploadID uint   `json:"upload_id,omitempty"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	AltText  string `json:"alt_text,omitempty"`
+	// Width and Height are known for images
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Variants are resized copies of an image, once they are rendered
+	Variants *ImageSet `json:"variants,omitempty"`
+}
+
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// AttachmentInput is an attachment as a client sends it: one of the caller's
+// own uploads. Its URL, type and size come from the upload.
+type AttachmentInput struct {
+	UploadID uint   `json:"upload_id" binding:"required"`
+	AltText  string `json:"alt_text,omitempty" binding:"max=1000"`
+}
+
+type AttachmentRepository interface {
+	// GetByPostIDs returns the attachments of each post, in order
+	GetByPostIDs(ctx context.Context, postIDs []uint) (map[uint][]Attachment, error)
+	// Replace sets a post's attachments to exactly the given list
+	Replace(ctx context.Context, postID uint, attachments []Attachment) error
+}
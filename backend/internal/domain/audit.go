@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditSignup          = "user.signup"
+	AuditLogin           = "user.login"
+	AuditLoginFailed     = "user.login_failed"
+	AuditProfileUpdate   = "user.profile_update"
+	AuditPasswordChange  = "user.password_change"
+	AuditPasswordReset   = "user.password_reset"
+	AuditEmailChange     = "user.email_change"
+	AuditDataExport      = "user.data_export"
+	AuditDeletionRequest = "user.deletion_request"
+	AuditDeletionCancel  = "user.deletion_cancel"
+	AuditAccountErase    = "user.erase"
+	AuditPostDelete      = "post.delete"
+	AuditCommentDelete   = "comment.delete"
+	AuditPostRollback    = "post.rollback"
+	AuditCommentRollback = "comment.rollback"
+)
+
+// Audit target types
+const (
+	AuditTargetUser    = "user"
+	AuditTargetPost    = "post"
+	AuditTargetComment = "comment"
+)
+
+// AuditEvent records who did what to which object, and from where.
+type AuditEvent struct {
+	ID uint `json:"id"`
+	// ActorID is zero when nobody is signed in, e.g. for a failed login
+	ActorID    uint   `json:"actor_id,omitempty"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type,omitempty"`
+	TargetID   uint   `json:"target_id,omitempty"`
+	IP         string `json:"ip,omitempty"`
+	UserAgent  string `json:"user_agent,omitempty"`
+	// Diff holds the fields the action changed, by name
+	Diff      map[string]AuditChange `json:"diff,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditChange is one field's value before and after an action
+type AuditChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// AuditFilter narrows a listing of audit events. Zero fields match anything.
+type AuditFilter struct {
+	ActorID    uint      `form:"actor_id"`
+	Action     string    `form:"action"`
+	TargetType string    `form:"target_type"`
+	TargetID   uint      `form:"target_id"`
+	Since      time.Time `form:"since"`
+	Until      time.Time `form:"until"`
+	// Before pages backwards: only events with a smaller ID are listed
+	Before uint `form:"before"`
+	Limit  int  `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// AuditExport is a day of audit events written to storage
+type AuditExport struct {
+	Key    string    `json:"key"`
+	Day    time.Time `json:"day"`
+	Events int       `json:"events"`
+}
+
+// Client identifies where a request came from, for the audit log
+type Client struct {
+	IP        string
+	UserAgent string
+}
+
+type clientKey struct{}
+
+// WithClient returns a context carrying the request's client.
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client stored in ctx; it is empty outside
+// requests.
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(clientKey{}).(Client)
+	return client
+}
+
+type AuditRepository interface {
+	Create(ctx context.Context, event *AuditEvent) error
+	// List returns matching events newest first
+	List(ctx context.Context, filter *AuditFilter) ([]AuditEvent, error)
+	// GetBetween returns the events created in [from, to), oldest first
+	GetBetween(ctx context.Context, from, to time.Time) ([]AuditEvent, error)
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	// Forget strips the client and diff from the events a user was the
+	// actor or target of, leaving only what happened and when
+	Forget(ctx context.Context, userID uint) error
+}
+
+type AuditUsecase interface {
+	// Record stores an event, filling in the client and time. A failure is
+	// logged rather than returned, so it never undoes the action itself.
+	Record(ctx context.Context, event *AuditEvent)
+	// List is for moderators only
+	List(ctx context.Context, userID uint, filter *AuditFilter) ([]AuditEvent, error)
+	// Export writes the events of one UTC day to storage as compressed JSON
+	// Lines. It returns nil if that day was already exported.
+	Export(ctx context.Context, day time.Time) (*AuditExport, error)
+	// Prune deletes events older than the retention period.
+	Prune(ctx context.Context) (int64, error)
+	// Scrub redacts erased accounts from the daily exports
+	Scrub(ctx context.Context, erasures []AccountErasure) error
+}
@@ -1,21 +1,28 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Comment struct {
-	ID         uint      `json:"id"`
-	Content    string    `json:"content"`
-	UserID     uint      `json:"user_id"`
-	PostID     uint      `json:"post_id"`
-	ParentID   *uint     `json:"parent_id,omitempty"`
-	User       *User     `json:"user,omitempty"`
-	Replies    []Comment `json:"replies,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	Likes      int       `json:"likes"`
-	ReplyCount int       `json:"reply_count"`
-	IsLiked    bool      `json:"is_liked"`
-	IsReported bool      `json:"is_reported"`
+	ID         uint       `json:"id"`
+	Content    string     `json:"content"`
+	UserID     uint       `json:"user_id"`
+	PostID     uint       `json:"post_id"`
+	ParentID   *uint      `json:"parent_id,omitempty"`
+	User       *User      `json:"user,omitempty"`
+	Replies    []Comment  `json:"replies,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Likes      int        `json:"likes"`
+	ReplyCount int        `json:"reply_count"`
+	IsLiked    bool       `json:"is_liked"`
+	IsReported bool       `json:"is_reported"`
+	IsDeleted  bool       `json:"is_deleted"`
+	Edited     bool       `json:"edited"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
 }
 
 type CreateCommentRequest struct {
@@ -28,31 +35,39 @@ type UpdateCommentRequest struct {
 }
 
 type CommentRepository interface {
-	Create(comment *Comment) error
-	GetByID(id uint) (*Comment, error)
-	GetByPostID(postID uint, page, limit int) ([]Comment, error)
-	Update(comment *Comment) error
-	Delete(id uint) error
-	GetReplies(commentID uint) ([]Comment, error)
-	AddLike(commentID, userID uint) error
-	RemoveLike(commentID, userID uint) error
-	GetLikes(commentID uint) (int, error)
-	GetReplyCount(commentID uint) (int, error)
-	IsLikedByUser(commentID, userID uint) (bool, error)
-	AddReport(commentID, userID uint) error
-	RemoveReport(commentID, userID uint) error
-	IsReportedByUser(commentID, userID uint) (bool, error)
+	Create(ctx context.Context, comment *Comment) error
+	GetByID(ctx context.Context, id uint) (*Comment, error)
+	GetByPostID(ctx context.Context, postID, viewerID uint, page, limit int) ([]Comment, error)
+	Update(ctx context.Context, comment *Comment) error
+	Delete(ctx context.Context, id uint) error
+	// GetReplies hides replies from authors the viewer has blocked or muted,
+	// or who have blocked the viewer
+	GetReplies(ctx context.Context, commentID, viewerID uint) ([]Comment, error)
+	AddLike(ctx context.Context, commentID, userID uint) error
+	RemoveLike(ctx context.Context, commentID, userID uint) error
+	GetLikes(ctx context.Context, commentID uint) (int, error)
+	GetReplyCount(ctx context.Context, commentID uint) (int, error)
+	IsLikedByUser(ctx context.Context, commentID, userID uint) (bool, error)
+	AddReport(ctx context.Context, commentID, userID uint) error
+	RemoveReport(ctx context.Context, commentID, userID uint) error
+	IsReportedByUser(ctx context.Context, commentID, userID uint) (bool, error)
+	GetDeletedByID(ctx context.Context, id uint) (*Comment, error)
+	GetDeletedByUserID(ctx context.Context, userID uint, since time.Time) ([]Comment, error)
+	Restore(ctx context.Context, id uint) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
 }
 
 type CommentUsecase interface {
-	Create(userID, postID uint, req *CreateCommentRequest) (*Comment, error)
-	GetByID(id uint) (*Comment, error)
-	GetByPostID(postID uint, page, limit int) ([]Comment, error)
-	Update(userID, commentID uint, req *UpdateCommentRequest) (*Comment, error)
-	Delete(userID, commentID uint) error
-	Like(userID, commentID uint) error
-	Unlike(userID, commentID uint) error
-	GetReplies(commentID uint) ([]Comment, error)
-	Report(userID, commentID uint) error
-	Unreport(userID, commentID uint) error
+	Create(ctx context.Context, userID, postID uint, req *CreateCommentRequest) (*Comment, error)
+	GetByID(ctx context.Context, id, viewerID uint) (*Comment, error)
+	GetByPostID(ctx context.Context, postID, userID uint, page, limit int) ([]Comment, error)
+	Update(ctx context.Context, userID, commentID uint, req *UpdateCommentRequest) (*Comment, error)
+	Delete(ctx context.Context, userID, commentID uint) error
+	Like(ctx context.Context, userID, commentID uint) error
+	Unlike(ctx context.Context, userID, commentID uint) error
+	GetReplies(ctx context.Context, commentID, viewerID uint) ([]Comment, error)
+	Report(ctx context.Context, userID, commentID uint) error
+	Unreport(ctx context.Context, userID, commentID uint) error
+	GetRevisions(ctx context.Context, userID, commentID uint) ([]Revision, error)
+	RollbackRevision(ctx context.Context, userID, commentID, revisionID uint) (*Comment, error)
 }
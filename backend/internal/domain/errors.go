@@ -0,0 +1,58 @@
+package domain
+
+import "errors"
+
+// Error kinds. Use errors.Is to check which kind an error is; the delivery
+// layer maps each kind to an HTTP status.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrTooLarge     = errors.New("too large")
+	// ErrUnavailable is for features this deployment hasn't configured
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// Error is a failure with a message that is safe to show to the client.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func NotFound(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func Forbidden(message string) error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func Conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func Validation(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+func Unauthorized(message string) error {
+	return &Error{Kind: ErrUnauthorized, Message: message}
+}
+
+func TooLarge(message string) error {
+	return &Error{Kind: ErrTooLarge, Message: message}
+}
+
+func Unavailable(message string) error {
+	return &Error{Kind: ErrUnavailable, Message: message}
+}
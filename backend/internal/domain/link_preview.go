@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// LinkPreview is what a linked page says about itself, shown with a post's
+// link instead of the bare URL.
+type LinkPreview struct {
+	// URL is the link as it was posted
+	URL string `json:"url"`
+	// CanonicalURL is where the page says it lives, when that differs
+	CanonicalURL string `json:"canonical_url,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Description  string `json:"description,omitempty"`
+	ImageURL     string `json:"image_url,omitempty"`
+	SiteName     string `json:"site_name,omitempty"`
+	// Failed records a fetch that produced no preview, so the page isn't
+	// fetched again until the entry expires
+	Failed    bool      `json:"-"`
+	FetchedAt time.Time `json:"fetched_at"`
+	ExpiresAt time.Time `json:"-"`
+}
+
+type LinkPreviewRepository interface {
+	// GetByURLs returns the cached previews of any of urls, expired or not
+	GetByURLs(ctx context.Context, urls []string) ([]LinkPreview, error)
+	// Save stores a preview, replacing any earlier one for its URL
+	Save(ctx context.Context, preview *LinkPreview) error
+}
+
+// LinkPreviewQueue hands links to the background fetcher
+type LinkPreviewQueue interface {
+	// Enqueue schedules a link to be fetched. It never blocks; a link that
+	// doesn't fit is queued again the next time it is looked up.
+	Enqueue(url string)
+}
+
+type LinkPreviewUsecase interface {
+	// Preview returns the preview of a link, fetching it now unless a fresh
+	// one is cached
+	Preview(ctx context.Context, url string) (*LinkPreview, error)
+	// Lookup returns the cached previews of urls without waiting on the
+	// network. Missing and expired ones are queued for fetching.
+	Lookup(ctx context.Context, urls []string) (map[string]*LinkPreview, error)
+	// Refresh fetches and caches the preview of a link unless a fresh one is
+	// cached
+	Refresh(ctx context.Context, url string) error
+}
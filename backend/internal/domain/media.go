@@ -0,0 +1,132 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// MediaKind says whether a media item is listened to or watched
+type MediaKind string
+
+const (
+	MediaKindAudio MediaKind = "audio"
+	MediaKindVideo MediaKind = "video"
+)
+
+// MaxPostMedia is how many media items one post may carry
+const MaxPostMedia = 10
+
+// MediaItem is a sermon recording or other audio or video, stored as an
+// upload and streamed through the API so players can seek.
+type MediaItem struct {
+	ID          uint      `json:"id"`
+	OwnerID     uint      `json:"owner_id"`
+	UploadID    uint      `json:"upload_id"`
+	Kind        MediaKind `json:"kind"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	// Duration is in seconds, and 0 when it isn't known
+	Duration      float64   `json:"duration"`
+	Chapters      []Chapter `json:"chapters"`
+	HasTranscript bool      `json:"has_transcript"`
+	// StreamURL serves the file with support for Range requests
+	StreamURL string `json:"stream_url"`
+	// TranscriptURL serves the transcript as WebVTT, for a <track> element
+	TranscriptURL string `json:"transcript_url,omitempty"`
+	// Key is where the file is stored
+	Key       string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Chapter marks where a section of a recording starts
+type Chapter struct {
+	// Start is the offset in seconds
+	Start float64 `json:"start" binding:"min=0"`
+	Title string  `json:"title" binding:"required,max=200"`
+}
+
+// TranscriptCue is one timed line of a transcript. Times are in seconds.
+type TranscriptCue struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// MediaSearchResult is a media item found by a search, with the transcript
+// cues that matched so a player can jump to them
+type MediaSearchResult struct {
+	Media   MediaItem       `json:"media"`
+	Matches []TranscriptCue `json:"matches"`
+}
+
+type CreateMediaRequest struct {
+	// UploadID is a sermon_media upload of the caller's
+	UploadID    uint   `json:"upload_id" binding:"required"`
+	Title       string `json:"title" binding:"required,max=255"`
+	Description string `json:"description,omitempty" binding:"max=5000"`
+	// Duration is only used when it can't be read from the file
+	Duration float64   `json:"duration,omitempty" binding:"min=0"`
+	Chapters []Chapter `json:"chapters,omitempty" binding:"max=100,dive"`
+}
+
+type UpdateMediaRequest struct {
+	Title       string  `json:"title,omitempty" binding:"max=255"`
+	Description string  `json:"description,omitempty" binding:"max=5000"`
+	Duration    float64 `json:"duration,omitempty" binding:"min=0"`
+	// Chapters replaces the whole list when present; an empty list removes
+	// them all
+	Chapters []Chapter `json:"chapters,omitempty" binding:"max=100,dive"`
+}
+
+// MediaStream is an open media file, ready to be served with Range support
+type MediaStream struct {
+	io.ReadSeekCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+type MediaRepository interface {
+	Create(ctx context.Context, item *MediaItem) error
+	GetByID(ctx context.Context, id uint) (*MediaItem, error)
+	// GetByIDs returns whichever of ids exist, in no particular order
+	GetByIDs(ctx context.Context, ids []uint) ([]MediaItem, error)
+	GetByOwnerID(ctx context.Context, ownerID uint, page, limit int) ([]MediaItem, error)
+	Update(ctx context.Context, item *MediaItem) error
+	Delete(ctx context.Context, id uint) error
+	// ReplaceTranscript sets a media item's transcript to exactly cues; none
+	// removes it
+	ReplaceTranscript(ctx context.Context, mediaID uint, cues []TranscriptCue) error
+	GetTranscript(ctx context.Context, mediaID uint) ([]TranscriptCue, error)
+	// Search returns up to limit media items whose title, description or
+	// transcript match query, best first, with up to matchLimit matching cues
+	// each
+	Search(ctx context.Context, query string, limit, matchLimit int) ([]MediaSearchResult, error)
+	// GetByPostIDs returns the media items of each post, in order
+	GetByPostIDs(ctx context.Context, postIDs []uint) (map[uint][]MediaItem, error)
+	// ReplacePostMedia sets a post's media items to exactly the given list
+	ReplacePostMedia(ctx context.Context, postID uint, mediaIDs []uint) error
+}
+
+type MediaUsecase interface {
+	Create(ctx context.Context, ownerID uint, req *CreateMediaRequest) (*MediaItem, error)
+	GetByID(ctx context.Context, id uint) (*MediaItem, error)
+	GetByOwnerID(ctx context.Context, ownerID uint, page, limit int) ([]MediaItem, error)
+	Update(ctx context.Context, userID, id uint, req *UpdateMediaRequest) (*MediaItem, error)
+	// Delete removes a media item and takes it off any posts. The recording
+	// is left for garbage collection.
+	Delete(ctx context.Context, userID, id uint) error
+	// SetTranscript parses a WebVTT or SRT file and replaces the item's
+	// transcript with it
+	SetTranscript(ctx context.Context, userID, id uint, file io.Reader) (*MediaItem, error)
+	DeleteTranscript(ctx context.Context, userID, id uint) error
+	// Transcript writes the item's transcript as WebVTT
+	Transcript(ctx context.Context, id uint, w io.Writer) error
+	Search(ctx context.Context, query string, limit int) ([]MediaSearchResult, error)
+	// Open returns the item's file for streaming
+	Open(ctx context.Context, id uint) (*MediaStream, error)
+}
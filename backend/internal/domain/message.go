@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// MaxGroupParticipants caps the size of a group conversation, creator included.
+const MaxGroupParticipants = 10
+
+// Message event types delivered over the live stream
+const (
+	MessageEventNew  = "message"
+	MessageEventRead = "read"
+)
+
+type Conversation struct {
+	ID           uint                      `json:"id"`
+	Title        string                    `json:"title,omitempty"`
+	IsGroup      bool                      `json:"is_group"`
+	CreatedBy    uint                      `json:"created_by"`
+	Participants []ConversationParticipant `json:"participants,omitempty"`
+	LastMessage  *Message                  `json:"last_message,omitempty"`
+	UnreadCount  int                       `json:"unread_count"`
+	IsMuted      bool                      `json:"is_muted"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	UpdatedAt    time.Time                 `json:"updated_at"`
+}
+
+type ConversationParticipant struct {
+	UserID            uint       `json:"user_id"`
+	User              *User      `json:"user,omitempty"`
+	LastReadMessageID uint       `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+	JoinedAt          time.Time  `json:"joined_at"`
+}
+
+type Message struct {
+	ID             uint      `json:"id"`
+	ConversationID uint      `json:"conversation_id"`
+	SenderID       uint      `json:"sender_id"`
+	Sender         *User     `json:"sender,omitempty"`
+	Content        string    `json:"content"`
+	ReadBy         []uint    `json:"read_by"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// MessageEvent is pushed to connected participants when a conversation changes.
+type MessageEvent struct {
+	Type              string   `json:"type"`
+	ConversationID    uint     `json:"conversation_id"`
+	Message           *Message `json:"message,omitempty"`
+	UserID            uint     `json:"user_id,omitempty"`
+	LastReadMessageID uint     `json:"last_read_message_id,omitempty"`
+	Muted             bool     `json:"muted"`
+}
+
+type CreateConversationRequest struct {
+	ParticipantIDs []uint `json:"participant_ids" binding:"required,min=1"`
+	Title          string `json:"title,omitempty" binding:"omitempty,max=100"`
+}
+
+type SendMessageRequest struct {
+	Content string `json:"content" binding:"required,max=5000"`
+}
+
+type MarkReadRequest struct {
+	MessageID uint `json:"message_id" binding:"required"`
+}
+
+// StreamTicket opens the message stream from clients that can't send an
+// Authorization header, such as a browser EventSource.
+type StreamTicket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type ConversationRepository interface {
+	Create(ctx context.Context, conversation *Conversation, participantIDs []uint) error
+	GetByID(ctx context.Context, id uint) (*Conversation, error)
+	FindDirect(ctx context.Context, userID, otherUserID uint) (*Conversation, error)
+	GetByUserID(ctx context.Context, userID uint, page, limit int) ([]Conversation, error)
+	GetParticipants(ctx context.Context, conversationID uint) ([]ConversationParticipant, error)
+	IsParticipant(ctx context.Context, conversationID, userID uint) (bool, error)
+	SetMuted(ctx context.Context, conversationID, userID uint, muted bool) error
+	IsMuted(ctx context.Context, conversationID, userID uint) (bool, error)
+	CreateMessage(ctx context.Context, message *Message) error
+	GetMessages(ctx context.Context, conversationID, beforeID, afterID uint, limit int) ([]Message, error)
+	MarkRead(ctx context.Context, conversationID, userID, messageID uint) (uint, error)
+}
+
+type MessageUsecase interface {
+	CreateConversation(ctx context.Context, userID uint, req *CreateConversationRequest) (*Conversation, error)
+	GetConversations(ctx context.Context, userID uint, page, limit int) ([]Conversation, error)
+	GetConversation(ctx context.Context, userID, conversationID uint) (*Conversation, error)
+	GetMessages(ctx context.Context, userID, conversationID, beforeID, afterID uint, limit int) ([]Message, error)
+	SendMessage(ctx context.Context, userID, conversationID uint, req *SendMessageRequest) (*Message, error)
+	MarkRead(ctx context.Context, userID, conversationID, messageID uint) error
+	Mute(ctx context.Context, userID, conversationID uint) error
+	Unmute(ctx context.Context, userID, conversationID uint) error
+	StreamTicket(ctx context.Context, userID uint) (*StreamTicket, error)
+}
+
+// MessagePublisher delivers message events to a user's live connections.
+type MessagePublisher interface {
+	Publish(userID uint, event MessageEvent)
+}
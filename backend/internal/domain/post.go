@@ -1,79 +1,110 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type Post struct {
-	ID           uint      `json:"id"`
-	Title        string    `json:"title"`
-	Content      string    `json:"content"`
-	ImageURL     string    `json:"image_url,omitempty"`
-	LinkURL      string    `json:"link_url,omitempty"`
-	Likes        int       `json:"likes"`
-	CommentCount int       `json:"comment_count"`
-	User         *User     `json:"user,omitempty"`
-	Tags         []string  `json:"tags,omitempty"`
-	Comments     []Comment `json:"comments,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	IsLiked      bool      `json:"is_liked"`
-	IsReported   bool      `json:"is_reported"`
-	IsSaved      bool      `json:"is_saved"`
+	ID      uint   `json:"id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	// ImageURL is the first image attachment, kept for clients that predate
+	// attachments
+	ImageURL string `json:"image_url,omitempty"`
+	// ImageVariants are resized copies of ImageURL, once they are rendered
+	ImageVariants *ImageSet    `json:"image_variants,omitempty"`
+	Attachments   []Attachment `json:"attachments"`
+	LinkURL       string       `json:"link_url,omitempty"`
+	// LinkPreview describes the page at LinkURL, once it has been fetched
+	LinkPreview *LinkPreview `json:"link_preview,omitempty"`
+	// Media are the recordings shown with the post, in order
+	Media        []MediaItem `json:"media"`
+	Likes        int         `json:"likes"`
+	CommentCount int         `json:"comment_count"`
+	User         *User       `json:"user,omitempty"`
+	Tags         []string    `json:"tags,omitempty"`
+	Comments     []Comment   `json:"comments,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	IsLiked      bool        `json:"is_liked"`
+	IsReported   bool        `json:"is_reported"`
+	IsSaved      bool        `json:"is_saved"`
+	Edited       bool        `json:"edited"`
+	EditedAt     *time.Time  `json:"edited_at,omitempty"`
+	DeletedAt    *time.Time  `json:"deleted_at,omitempty"`
 }
 
 type CreatePostRequest struct {
-	Title    string   `json:"title" binding:"required,min=3,max=255"`
-	Content  string   `json:"content" binding:"required"`
-	ImageURL string   `json:"image_url,omitempty"`
-	LinkURL  string   `json:"link_url,omitempty"`
-	Tags     []string `json:"tags,omitempty"`
+	Title   string `json:"title" binding:"required,min=3,max=255"`
+	Content string `json:"content" binding:"required"`
+	// ImageURL adds a single image when Attachments is empty. It must be the
+	// URL of one of the caller's uploads.
+	ImageURL    string            `json:"image_url,omitempty"`
+	Attachments []AttachmentInput `json:"attachments,omitempty" binding:"max=10,dive"`
+	LinkURL     string            `json:"link_url,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	MediaIDs    []uint            `json:"media_ids,omitempty" binding:"max=10"`
 }
 
 type UpdatePostRequest struct {
-	Title    string   `json:"title,omitempty" binding:"omitempty,min=3,max=255"`
-	Content  string   `json:"content,omitempty"`
-	ImageURL string   `json:"image_url,omitempty"`
-	LinkURL  string   `json:"link_url,omitempty"`
-	Tags     []string `json:"tags,omitempty"`
+	Title   string `json:"title,omitempty" binding:"omitempty,min=3,max=255"`
+	Content string `json:"content,omitempty"`
+	// ImageURL replaces the post's first image, like CreatePostRequest's
+	ImageURL string `json:"image_url,omitempty"`
+	// Attachments replaces the whole list when present; an empty list
+	// removes them all
+	Attachments []AttachmentInput `json:"attachments,omitempty" binding:"max=10,dive"`
+	LinkURL     string            `json:"link_url,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	// MediaIDs replaces the post's media items when present
+	MediaIDs []uint `json:"media_ids,omitempty" binding:"max=10"`
 }
 
 type PostRepository interface {
-	Create(post *Post) error
-	GetByID(id uint) (*Post, error)
-	GetAll(page, limit int, userID uint) ([]Post, error)
-	GetByUserID(userID uint) ([]Post, error)
-	Update(post *Post) error
-	Delete(id uint) error
-	AddTags(postID uint, tags []string) error
-	GetTags(postID uint) ([]string, error)
-	AddLike(postID, userID uint) error
-	RemoveLike(postID, userID uint) error
-	GetLikes(postID uint) (int, error)
-	GetCommentCount(postID uint) (int, error)
-	AddSave(postID, userID uint) error
-	RemoveSave(postID, userID uint) error
-	IsSavedByUser(postID, userID uint) (bool, error)
-	GetSavedPosts(userID uint) ([]Post, error)
-	IsLikedByUser(postID, userID uint) (bool, error)
-	AddReport(postID, userID uint) error
-	RemoveReport(postID, userID uint) error
-	IsReportedByUser(postID, userID uint) (bool, error)
+	Create(ctx context.Context, post *Post) error
+	GetByID(ctx context.Context, id uint) (*Post, error)
+	GetAll(ctx context.Context, page, limit int, userID uint) ([]Post, error)
+	GetByUserID(ctx context.Context, userID uint) ([]Post, error)
+	Update(ctx context.Context, post *Post) error
+	Delete(ctx context.Context, id uint) error
+	AddTags(ctx context.Context, postID uint, tags []string) error
+	GetTags(ctx context.Context, postID uint) ([]string, error)
+	AddLike(ctx context.Context, postID, userID uint) error
+	RemoveLike(ctx context.Context, postID, userID uint) error
+	GetLikes(ctx context.Context, postID uint) (int, error)
+	GetCommentCount(ctx context.Context, postID uint) (int, error)
+	AddSave(ctx context.Context, postID, userID uint) error
+	RemoveSave(ctx context.Context, postID, userID uint) error
+	IsSavedByUser(ctx context.Context, postID, userID uint) (bool, error)
+	GetSavedPosts(ctx context.Context, userID uint) ([]Post, error)
+	IsLikedByUser(ctx context.Context, postID, userID uint) (bool, error)
+	AddReport(ctx context.Context, postID, userID uint) error
+	RemoveReport(ctx context.Context, postID, userID uint) error
+	IsReportedByUser(ctx context.Context, postID, userID uint) (bool, error)
+	GetDeletedByID(ctx context.Context, id uint) (*Post, error)
+	GetDeletedByUserID(ctx context.Context, userID uint, since time.Time) ([]Post, error)
+	Restore(ctx context.Context, id uint) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
 }
 
 type PostUsecase interface {
-	Create(userID uint, req *CreatePostRequest) (*Post, error)
-	GetByID(id uint) (*Post, error)
-	GetAll(page, limit int, userID uint) ([]Post, error)
-	GetByUserID(userID uint) ([]Post, error)
-	Update(userID uint, postID uint, req *UpdatePostRequest) (*Post, error)
-	Delete(userID uint, postID uint) error
-	Like(userID uint, postID uint) error
-	Unlike(userID uint, postID uint) error
-	SavePost(userID, postID uint) error
-	UnsavePost(userID, postID uint) error
-	GetSavedPosts(userID uint) ([]Post, error)
-	Report(userID, postID uint) error
-	Unreport(userID, postID uint) error
-	IsLikedByUser(userID uint, postID uint) (bool, error)
-	IsSavedByUser(userID uint, postID uint) (bool, error)
-	IsReportedByUser(userID uint, postID uint) (bool, error)
+	Create(ctx context.Context, userID uint, req *CreatePostRequest) (*Post, error)
+	GetByID(ctx context.Context, id, userID uint) (*Post, error)
+	GetAll(ctx context.Context, page, limit int, userID uint) ([]Post, error)
+	GetByUserID(ctx context.Context, userID uint) ([]Post, error)
+	Update(ctx context.Context, userID uint, postID uint, req *UpdatePostRequest) (*Post, error)
+	Delete(ctx context.Context, userID uint, postID uint) error
+	Like(ctx context.Context, userID uint, postID uint) error
+	Unlike(ctx context.Context, userID uint, postID uint) error
+	SavePost(ctx context.Context, userID, postID uint) error
+	UnsavePost(ctx context.Context, userID, postID uint) error
+	GetSavedPosts(ctx context.Context, userID uint) ([]Post, error)
+	Report(ctx context.Context, userID, postID uint) error
+	Unreport(ctx context.Context, userID, postID uint) error
+	IsLikedByUser(ctx context.Context, userID uint, postID uint) (bool, error)
+	IsSavedByUser(ctx context.Context, userID uint, postID uint) (bool, error)
+	IsReportedByUser(ctx context.Context, userID uint, postID uint) (bool, error)
+	GetRevisions(ctx context.Context, userID, postID uint) ([]Revision, error)
+	RollbackRevision(ctx context.Context, userID, postID, revisionID uint) (*Post, error)
 }
@@ -2,15 +2,9 @@ package domain
 
 import (
 	"context"
-	"errors"
 	"time"
 )
 
-// Error definitions
-var (
-	ErrNotFound = errors.New("not found")
-)
-
 // PrayerRequest represents the prayer request entity
 type PrayerRequest struct {
 	ID        uint      `json:"id"`
@@ -19,6 +13,11 @@ type PrayerRequest struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// PrayerRequestInput is the body for creating or updating a prayer request
+type PrayerRequestInput struct {
+	Content string `json:"content" binding:"required"`
+}
+
 // PrayerRequestRepository represents the prayer request repository contract
 type PrayerRequestRepository interface {
 	Create(ctx context.Context, prayerRequest *PrayerRequest) error
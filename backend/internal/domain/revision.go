@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Revision target types
+const (
+	RevisionTargetPost    = "post"
+	RevisionTargetComment = "comment"
+)
+
+// Revision is a snapshot of a post or comment as it was before an edit.
+type Revision struct {
+	ID         uint     `json:"id"`
+	TargetType string   `json:"target_type"`
+	TargetID   uint     `json:"target_id"`
+	EditorID   uint     `json:"editor_id"`
+	Editor     *User    `json:"editor,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Content    string   `json:"content"`
+	ImageURL   string   `json:"image_url,omitempty"`
+	LinkURL    string   `json:"link_url,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	// Attachments are only kept for posts
+	Attachments []Attachment `json:"attachments,omitempty"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+type RevisionRepository interface {
+	Create(ctx context.Context, revision *Revision) error
+	GetByID(ctx context.Context, id uint) (*Revision, error)
+	GetByTarget(ctx context.Context, targetType string, targetID uint) ([]Revision, error)
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type SavedPost struct {
 	ID        uint      `json:"id"`
@@ -13,15 +16,15 @@ type SavedPost struct {
 }
 
 type SavedPostRepository interface {
-	Create(savedPost *SavedPost) error
-	Delete(userID, postID uint) error
-	GetByUserID(userID uint) ([]SavedPost, error)
-	IsSaved(userID, postID uint) (bool, error)
+	Create(ctx context.Context, savedPost *SavedPost) error
+	Delete(ctx context.Context, userID, postID uint) error
+	GetByUserID(ctx context.Context, userID uint) ([]SavedPost, error)
+	IsSaved(ctx context.Context, userID, postID uint) (bool, error)
 }
 
 type SavedPostUsecase interface {
-	SavePost(userID, postID uint) error
-	UnsavePost(userID, postID uint) error
-	GetSavedPosts(userID uint) ([]SavedPost, error)
-	IsSaved(userID, postID uint) (bool, error)
+	SavePost(ctx context.Context, userID, postID uint) error
+	UnsavePost(ctx context.Context, userID, postID uint) error
+	GetSavedPosts(ctx context.Context, userID uint) ([]SavedPost, error)
+	IsSaved(ctx context.Context, userID, postID uint) (bool, error)
 }
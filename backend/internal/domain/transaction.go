@@ -0,0 +1,10 @@
+package domain
+
+import "context"
+
+// Transactor runs a group of repository calls atomically. Repositories called
+// with the ctx passed to fn join the transaction; if fn returns an error
+// everything it wrote is rolled back.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// TrashRetention is how long soft-deleted posts and comments can be restored
+// before the purge job removes them for good.
+const TrashRetention = 30 * 24 * time.Hour
+
+// DeletedCommentPlaceholder replaces the content of a deleted comment that is
+// kept in the tree because it still has replies.
+const DeletedCommentPlaceholder = "[deleted]"
+
+type Trash struct {
+	Posts    []Post    `json:"posts"`
+	Comments []Comment `json:"comments"`
+}
+
+type TrashUsecase interface {
+	GetTrash(ctx context.Context, userID uint) (*Trash, error)
+	RestorePost(ctx context.Context, userID, postID uint) error
+	RestoreComment(ctx context.Context, userID, commentID uint) error
+	// Purge hard-deletes everything that has been in the trash longer than
+	// TrashRetention.
+	Purge(ctx context.Context) (posts int64, comments int64, err error)
+}
@@ -0,0 +1,209 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// UploadPurpose says what an uploaded file is for. Each purpose has its own
+// allowed types and size cap, and its own prefix in the object store.
+type UploadPurpose string
+
+const (
+	UploadPurposeAvatar    UploadPurpose = "avatar"
+	UploadPurposePostImage UploadPurpose = "post_image"
+	// UploadPurposePostAttachment is any file attached to a post: an image,
+	// a PDF or an audio clip
+	UploadPurposePostAttachment  UploadPurpose = "post_attachment"
+	UploadPurposeDevotionalImage UploadPurpose = "devotional_image"
+	// UploadPurposeSermonMedia is the recording behind a media item
+	UploadPurposeSermonMedia UploadPurpose = "sermon_media"
+)
+
+// UploadStatus tracks an image through the processing pipeline
+type UploadStatus string
+
+const (
+	// UploadStatusProcessing means the variants are still being rendered;
+	// the original is already stored and usable
+	UploadStatusProcessing UploadStatus = "processing"
+	UploadStatusReady      UploadStatus = "ready"
+	// UploadStatusFailed means the file could not be decoded as an image
+	UploadStatusFailed UploadStatus = "failed"
+)
+
+// Upload is a stored object and the user who uploaded it
+type Upload struct {
+	ID          uint          `json:"id"`
+	OwnerID     uint          `json:"owner_id"`
+	Purpose     UploadPurpose `json:"purpose"`
+	Key         string        `json:"-"`
+	URL         string        `json:"url"`
+	ContentType string        `json:"content_type"`
+	Size        int64         `json:"size"`
+	Status      UploadStatus  `json:"status"`
+	// Width and Height are known once processing has finished
+	Width     int            `json:"width,omitempty"`
+	Height    int            `json:"height,omitempty"`
+	Variants  []ImageVariant `json:"variants,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// ImageVariant is a resized copy of an uploaded image
+type ImageVariant struct {
+	// Name is "thumbnail", "feed" or "full"
+	Name        string `json:"name"`
+	Key         string `json:"-"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// ImageSet lists the sizes an image is available in, with a srcset value
+// that can be handed straight to an <img> element.
+type ImageSet struct {
+	Srcset   string         `json:"srcset"`
+	Variants []ImageVariant `json:"variants"`
+}
+
+// NewImageSet returns the set for an upload's variants, or nil if it has none
+// yet.
+func NewImageSet(variants []ImageVariant) *ImageSet {
+	if len(variants) == 0 {
+		return nil
+	}
+	sources := make([]string, len(variants))
+	for i, v := range variants {
+		sources[i] = fmt.Sprintf("%s %dw", v.URL, v.Width)
+	}
+	return &ImageSet{Srcset: strings.Join(sources, ", "), Variants: variants}
+}
+
+// Kinds of things that can use an upload, as they appear in the
+// upload_references view
+const (
+	UploadReferencePost         = "post"
+	UploadReferencePostRevision = "post_revision"
+	UploadReferenceUser         = "user"
+	UploadReferenceMedia        = "media"
+)
+
+// MediaGCReport describes one garbage collection run over uploads that
+// nothing references any more.
+type MediaGCReport struct {
+	DryRun bool `json:"dry_run"`
+	// Orphans are the unreferenced uploads found; they are left alone in a
+	// dry run
+	Orphans []Upload `json:"orphans"`
+	Deleted int      `json:"deleted"`
+	// Bytes is the size of the deleted originals, not counting variants
+	Bytes int64 `json:"bytes"`
+}
+
+// UploadSession is a slot for the client to upload one file straight to
+// storage. Once the file is there the client finalizes the session, which
+// checks the file and turns it into an Upload.
+type UploadSession struct {
+	ID          uint          `json:"id"`
+	OwnerID     uint          `json:"owner_id"`
+	Purpose     UploadPurpose `json:"purpose"`
+	Key         string        `json:"-"`
+	ContentType string        `json:"content_type"`
+	Size        int64         `json:"size"`
+	// Request is how to send the file. It is only returned when the session
+	// is created.
+	Request     *PresignedUpload `json:"request,omitempty"`
+	UploadID    *uint            `json:"upload_id,omitempty"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+	FinalizedAt *time.Time       `json:"finalized_at,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// PresignedUpload is the HTTP request that uploads a session's file. Headers
+// must be sent exactly as given.
+type PresignedUpload struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+type CreateUploadSessionRequest struct {
+	Purpose     UploadPurpose `json:"purpose" binding:"required,oneof=avatar post_image post_attachment devotional_image sermon_media"`
+	ContentType string        `json:"content_type" binding:"required,oneof=image/jpeg image/png image/webp application/pdf audio/mpeg audio/wave video/mp4 video/webm"`
+	Size        int64         `json:"size" binding:"required,min=1"`
+}
+
+type FinalizeUploadRequest struct {
+	// PostID sets a post image as the image of one of the caller's posts.
+	// Avatars always become the caller's avatar.
+	PostID uint `json:"post_id,omitempty"`
+}
+
+// UploadFile is a file received from a client. Size is the length the client
+// declared; the body is still checked against the purpose's cap as it is read.
+type UploadFile struct {
+	Body io.Reader
+	Size int64
+}
+
+type UploadRepository interface {
+	Create(ctx context.Context, upload *Upload) error
+	GetByID(ctx context.Context, id uint) (*Upload, error)
+	// GetByIDs returns the uploads with any of ids; missing ones are left out
+	GetByIDs(ctx context.Context, ids []uint) ([]Upload, error)
+	// GetByURLs returns the uploads stored at any of urls
+	GetByURLs(ctx context.Context, urls []string) ([]Upload, error)
+	GetByOwnerID(ctx context.Context, ownerID uint) ([]Upload, error)
+	// GetProcessing returns up to limit uploads still processing that were
+	// created before the given time, oldest first
+	GetProcessing(ctx context.Context, before time.Time, limit int) ([]Upload, error)
+	UpdateProcessed(ctx context.Context, upload *Upload) error
+	// GetUnreferenced returns up to limit uploads of the given purposes,
+	// created before the given time, that no post, revision or user uses
+	GetUnreferenced(ctx context.Context, purposes []UploadPurpose, before time.Time, limit int) ([]Upload, error)
+	// DeleteUnreferenced deletes an upload's row if it is still unused and
+	// reports whether it did
+	DeleteUnreferenced(ctx context.Context, id uint) (bool, error)
+}
+
+type UploadSessionRepository interface {
+	Create(ctx context.Context, session *UploadSession) error
+	GetByID(ctx context.Context, id uint) (*UploadSession, error)
+	// MarkFinalized records the upload a session produced. It fails with a
+	// conflict if the session was already finalized.
+	MarkFinalized(ctx context.Context, id, uploadID uint) error
+	// GetAbandoned returns up to limit unfinalized sessions that expired
+	// before the given time
+	GetAbandoned(ctx context.Context, before time.Time, limit int) ([]UploadSession, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+// ImageQueue hands uploaded images to the background processor
+type ImageQueue interface {
+	// Enqueue schedules an upload for processing. It never blocks; an upload
+	// that doesn't fit in the queue is picked up by the next sweep.
+	Enqueue(uploadID uint)
+}
+
+type UploadUsecase interface {
+	Upload(ctx context.Context, ownerID uint, purpose UploadPurpose, file UploadFile) (*Upload, error)
+	// CreateSession reserves a key and returns a presigned request for it
+	CreateSession(ctx context.Context, ownerID uint, req *CreateUploadSessionRequest) (*UploadSession, error)
+	// FinalizeSession checks the uploaded file, records it and attaches it
+	// to a post or the caller's avatar
+	FinalizeSession(ctx context.Context, ownerID, sessionID uint, req *FinalizeUploadRequest) (*Upload, error)
+	// CleanupSessions deletes sessions that were never finalized, and any
+	// file uploaded for them, returning how many were removed
+	CleanupSessions(ctx context.Context) (int, error)
+	// ProcessImage renders an upload's variants and marks it ready
+	ProcessImage(ctx context.Context, uploadID uint) error
+	// Stalled returns uploads that have been processing for longer than age
+	Stalled(ctx context.Context, age time.Duration) ([]uint, error)
+	// CollectGarbage deletes uploads older than grace that nothing uses,
+	// with their variants. A dry run only reports what would go.
+	CollectGarbage(ctx context.Context, grace time.Duration, dryRun bool) (*MediaGCReport, error)
+}
@@ -1,17 +1,41 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// User roles
+const (
+	RoleMember    = "member"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
 
 type User struct {
-	ID        uint      `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	Bio       string    `json:"bio,omitempty"`
-	AvatarURL string    `json:"avatar_url,omitempty"`
-	PostCount int       `json:"post_count"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint   `json:"id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Password  string `json:"-"`
+	Bio       string `json:"bio,omitempty"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+	// AvatarVariants are resized copies of AvatarURL, once they are rendered
+	AvatarVariants *ImageSet `json:"avatar_variants,omitempty"`
+	PostCount      int       `json:"post_count"`
+	Role           string    `json:"role,omitempty"`
+	// EmailVerifiedAt is nil until the user follows their verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	EmailVerified   bool       `json:"email_verified"`
+	// TokenVersion is the version login tokens are issued at; tokens from an
+	// older one are rejected
+	TokenVersion int       `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// IsModerator reports whether the user may moderate other members' content.
+func (u *User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
 }
 
 type RegisterRequest struct {
@@ -25,6 +49,29 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
 type UpdateProfileRequest struct {
 	Bio       string `json:"bio,omitempty"`
 	AvatarURL string `json:"avatar_url,omitempty"`
@@ -36,19 +83,41 @@ type LoginResponse struct {
 }
 
 type UserRepository interface {
-	Create(user *User) error
-	GetByID(id uint) (*User, error)
-	GetByEmail(email string) (*User, error)
-	GetByUsername(username string) (*User, error)
-	Update(user *User) error
-	GetUserPosts(userID uint, page, limit int) ([]Post, error)
-	UpdatePostCount(userID uint) error
+	Create(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id uint) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetByUsername(ctx context.Context, username string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	GetUserPosts(ctx context.Context, userID uint, page, limit int) ([]Post, error)
+	UpdatePostCount(ctx context.Context, userID uint) error
+	SetEmailVerified(ctx context.Context, userID uint, email string) error
+	// SetPassword stores a password hash and bumps the token version, signing
+	// the account out everywhere; it returns the new version
+	SetPassword(ctx context.Context, userID uint, hash string) (int, error)
+	// GetTokenVersion returns the version current login tokens must carry
+	GetTokenVersion(ctx context.Context, userID uint) (int, error)
+	// Anonymize strips the account of everything that identifies its owner,
+	// leaving a placeholder that their posts and comments can still point at.
+	// It bumps the token version, so the owner's tokens stop working.
+	Anonymize(ctx context.Context, id uint) error
+	// Delete removes the account along with everything it wrote
+	Delete(ctx context.Context, id uint) error
 }
 
 type UserUsecase interface {
-	Register(req *RegisterRequest) (*User, error)
-	Login(req *LoginRequest) (*LoginResponse, error)
-	GetProfile(id uint) (*User, error)
-	UpdateProfile(userID uint, req *UpdateProfileRequest) (*User, error)
-	GetUserPosts(userID uint, page, limit int) ([]Post, error)
+	Register(ctx context.Context, req *RegisterRequest) (*User, error)
+	Login(ctx context.Context, req *LoginRequest) (*LoginResponse, error)
+	GetProfile(ctx context.Context, id uint) (*User, error)
+	GetUserProfile(ctx context.Context, viewerID, userID uint) (*User, error)
+	UpdateProfile(ctx context.Context, userID uint, req *UpdateProfileRequest) (*User, error)
+	GetUserPosts(ctx context.Context, viewerID, userID uint, page, limit int) ([]Post, error)
+	SendVerificationEmail(ctx context.Context, userID uint) error
+	VerifyEmail(ctx context.Context, req *VerifyEmailRequest) error
+	ForgotPassword(ctx context.Context, req *ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req *ResetPasswordRequest) error
+	// ChangePassword signs the account out everywhere and returns a fresh
+	// token for the caller
+	ChangePassword(ctx context.Context, userID uint, req *ChangePasswordRequest) (string, error)
+	ChangeEmail(ctx context.Context, userID uint, req *ChangeEmailRequest) error
+	ConfirmEmailChange(ctx context.Context, req *VerifyEmailRequest) (*User, error)
 }
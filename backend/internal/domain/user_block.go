@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type UserBlock struct {
+	BlockerID uint      `json:"blocker_id"`
+	BlockedID uint      `json:"blocked_id"`
+	User      *User     `json:"user,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserBlockRepository interface {
+	Create(ctx context.Context, blockerID, blockedID uint) error
+	Delete(ctx context.Context, blockerID, blockedID uint) error
+	GetByBlockerID(ctx context.Context, blockerID uint) ([]UserBlock, error)
+	// ExistsBetween reports whether either user has blocked the other.
+	ExistsBetween(ctx context.Context, userID, otherUserID uint) (bool, error)
+}
+
+type UserBlockUsecase interface {
+	Block(ctx context.Context, blockerID, blockedID uint) error
+	Unblock(ctx context.Context, blockerID, blockedID uint) error
+	GetBlockedUsers(ctx context.Context, blockerID uint) ([]UserBlock, error)
+	IsBlockedBetween(ctx context.Context, userID, otherUserID uint) (bool, error)
+}
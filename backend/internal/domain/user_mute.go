@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type UserMute struct {
+	MuterID   uint      `json:"muter_id"`
+	MutedID   uint      `json:"muted_id"`
+	User      *User     `json:"user,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserMuteRepository interface {
+	Create(ctx context.Context, muterID, mutedID uint) error
+	Delete(ctx context.Context, muterID, mutedID uint) error
+	GetByMuterID(ctx context.Context, muterID uint) ([]UserMute, error)
+	IsMuted(ctx context.Context, muterID, mutedID uint) (bool, error)
+}
+
+type UserMuteUsecase interface {
+	Mute(ctx context.Context, muterID, mutedID uint) error
+	Unmute(ctx context.Context, muterID, mutedID uint) error
+	GetMutedUsers(ctx context.Context, muterID uint) ([]UserMute, error)
+}
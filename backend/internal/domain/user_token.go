@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Token purposes
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+	TokenPurposeChangeEmail   = "change_email"
+)
+
+// Token lifetimes
+const (
+	VerifyEmailTokenTTL   = 48 * time.Hour
+	ResetPasswordTokenTTL = time.Hour
+	ChangeEmailTokenTTL   = 24 * time.Hour
+)
+
+// UserToken is a single-use, expiring token sent to a user by email. Only a
+// hash of the token is stored.
+type UserToken struct {
+	ID        uint
+	UserID    uint
+	Purpose   string
+	TokenHash string
+	NewEmail  string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+type UserTokenRepository interface {
+	Create(ctx context.Context, token *UserToken) error
+	GetByHash(ctx context.Context, purpose, tokenHash string) (*UserToken, error)
+	// MarkUsed consumes the token; it fails if the token was already used.
+	MarkUsed(ctx context.Context, id uint) error
+	// InvalidateForUser consumes every outstanding token of the given purpose.
+	InvalidateForUser(ctx context.Context, userID uint, purpose string) error
+}
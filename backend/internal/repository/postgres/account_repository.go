@@ -0,0 +1,413 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type accountRepository struct {
+	db *sql.DB
+}
+
+func NewAccountRepository(db *sql.DB) domain.AccountRepository {
+	return &accountRepository{db: db}
+}
+
+const dataExportColumns = `
+            id, user_id, status, key, size, error, created_at,
+            started_at, completed_at, expires_at`
+
+func scanDataExport(row interface{ Scan(...any) error }) (*domain.DataExport, error) {
+	export := &domain.DataExport{}
+	err := row.Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.Key,
+		&export.Size,
+		&export.Error,
+		&export.CreatedAt,
+		&export.StartedAt,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+func (r *accountRepository) CreateExport(ctx context.Context, export *domain.DataExport) error {
+	query := `
+        INSERT INTO data_exports (user_id, status, created_at)
+        VALUES ($1, $2, $3)
+        RETURNING id`
+
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, export.UserID, export.Status, export.CreatedAt).Scan(&export.ID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("an export is already in progress")
+	}
+	return err
+}
+
+func (r *accountRepository) GetLatestExport(ctx context.Context, userID uint) (*domain.DataExport, error) {
+	query := `
+        SELECT` + dataExportColumns + `
+        FROM data_exports
+        WHERE user_id = $1
+        ORDER BY id DESC
+        LIMIT 1`
+
+	export, err := scanDataExport(conn(ctx, r.db).QueryRowContext(ctx, query, userID))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("no data export has been requested")
+	}
+	return export, err
+}
+
+func (r *accountRepository) GetExportsByUserID(ctx context.Context, userID uint) ([]domain.DataExport, error) {
+	query := `
+        SELECT` + dataExportColumns + `
+        FROM data_exports
+        WHERE user_id = $1
+        ORDER BY id`
+
+	return r.queryExports(ctx, query, userID)
+}
+
+// ClaimExport locks the row it claims, so workers on several instances never
+// build the same export.
+func (r *accountRepository) ClaimExport(ctx context.Context, stale time.Duration) (*domain.DataExport, error) {
+	query := `
+        UPDATE data_exports
+        SET status = 'running', started_at = NOW()
+        WHERE id = (
+            SELECT id FROM data_exports
+            WHERE status = 'pending'
+               OR (status = 'running' AND started_at < NOW() - $1 * INTERVAL '1 second')
+            ORDER BY id
+            LIMIT 1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING` + dataExportColumns
+
+	export, err := scanDataExport(conn(ctx, r.db).QueryRowContext(ctx, query, stale.Seconds()))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return export, err
+}
+
+func (r *accountRepository) CompleteExport(ctx context.Context, export *domain.DataExport) error {
+	query := `
+        UPDATE data_exports
+        SET status = $1, key = $2, size = $3, error = '', completed_at = $4, expires_at = $5
+        WHERE id = $6`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query,
+		export.Status,
+		export.Key,
+		export.Size,
+		export.CompletedAt,
+		export.ExpiresAt,
+		export.ID,
+	)
+	return err
+}
+
+func (r *accountRepository) FailExport(ctx context.Context, id uint, message string) error {
+	query := `
+        UPDATE data_exports
+        SET status = 'failed', error = $1, completed_at = NOW()
+        WHERE id = $2`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, message, id)
+	return err
+}
+
+func (r *accountRepository) GetExpiredExports(ctx context.Context, before time.Time, limit int) ([]domain.DataExport, error) {
+	query := `
+        SELECT` + dataExportColumns + `
+        FROM data_exports
+        WHERE expires_at < $1
+        ORDER BY id
+        LIMIT $2`
+
+	return r.queryExports(ctx, query, before, limit)
+}
+
+func (r *accountRepository) DeleteExport(ctx context.Context, id uint) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM data_exports WHERE id = $1`, id)
+	return err
+}
+
+func (r *accountRepository) queryExports(ctx context.Context, query string, args ...any) ([]domain.DataExport, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []domain.DataExport
+	for rows.Next() {
+		export, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *export)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// personalDataSections are the files of a data export and the queries that
+// fill them. Each returns a single JSON value for user $1.
+var personalDataSections = []struct {
+	name  string
+	query string
+}{
+	{"profile.json", `
+        SELECT to_jsonb(u) - 'password' FROM users u WHERE u.id = $1`},
+	{"posts.json", `
+        SELECT COALESCE(jsonb_agg(
+            to_jsonb(p) || jsonb_build_object('tags', ARRAY(SELECT tag FROM post_tags WHERE post_id = p.id ORDER BY tag))
+            ORDER BY p.id), '[]')
+        FROM posts p WHERE p.user_id = $1`},
+	{"comments.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(c) ORDER BY c.id), '[]')
+        FROM comments c WHERE c.user_id = $1`},
+	{"post_likes.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(l) - 'user_id' ORDER BY l.created_at), '[]')
+        FROM post_likes l WHERE l.user_id = $1`},
+	{"comment_likes.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(l) - 'user_id' ORDER BY l.created_at), '[]')
+        FROM comment_likes l WHERE l.user_id = $1`},
+	{"saved_posts.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(s) - 'user_id' ORDER BY s.created_at), '[]')
+        FROM saved_posts s WHERE s.user_id = $1`},
+	{"messages.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(m) ORDER BY m.id), '[]')
+        FROM messages m WHERE m.sender_id = $1`},
+	{"blocks.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(b) - 'blocker_id' ORDER BY b.created_at), '[]')
+        FROM user_blocks b WHERE b.blocker_id = $1`},
+	{"mutes.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(m) - 'muter_id' ORDER BY m.created_at), '[]')
+        FROM user_mutes m WHERE m.muter_id = $1`},
+	{"uploads.json", `
+        SELECT COALESCE(jsonb_agg(to_jsonb(u) - 'owner_id' ORDER BY u.id), '[]')
+        FROM uploads u WHERE u.owner_id = $1`},
+	{"media.json", `
+        SELECT COALESCE(jsonb_agg(
+            to_jsonb(m) - 'owner_id' - 'search' || jsonb_build_object('transcript', (
+                SELECT COALESCE(jsonb_agg(jsonb_build_object('start_ms', c.start_ms, 'end_ms', c.end_ms, 'text', c.text) ORDER BY c.position), '[]')
+                FROM media_transcript_cues c WHERE c.media_id = m.id))
+            ORDER BY m.id), '[]')
+        FROM media_items m WHERE m.owner_id = $1`},
+	{"account_activity.json", `
+        SELECT COALESCE(jsonb_agg(jsonb_build_object(
+            'action', a.action, 'target_type', a.target_type, 'target_id', a.target_id,
+            'ip', host(a.ip), 'user_agent', a.user_agent, 'diff', a.diff, 'created_at', a.created_at)
+            ORDER BY a.id), '[]')
+        FROM audit_events a WHERE a.actor_id = $1`},
+}
+
+// PersonalData reads every section in one repeatable-read transaction, so
+// the files agree with each other.
+func (r *accountRepository) PersonalData(ctx context.Context, userID uint) ([]domain.DataSection, error) {
+	var sections []domain.DataSection
+	err := withTx(ctx, r.db, func(ctx context.Context) error {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY`); err != nil {
+			return err
+		}
+		for _, section := range personalDataSections {
+			var data []byte
+			err := conn(ctx, r.db).QueryRowContext(ctx, section.query, userID).Scan(&data)
+			if err == sql.ErrNoRows {
+				return domain.NotFound("user not found")
+			}
+			if err != nil {
+				return err
+			}
+			sections = append(sections, domain.DataSection{Name: section.name, Data: json.RawMessage(data)})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+func (r *accountRepository) ScheduleDeletion(ctx context.Context, deletion *domain.AccountDeletion) error {
+	query := `
+        INSERT INTO account_deletions (user_id, mode, requested_at, scheduled_for)
+        VALUES ($1, $2, $3, $4)
+        ON CONFLICT (user_id) DO UPDATE
+        SET mode = EXCLUDED.mode, requested_at = EXCLUDED.requested_at, scheduled_for = EXCLUDED.scheduled_for`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query,
+		deletion.UserID,
+		deletion.Mode,
+		deletion.RequestedAt,
+		deletion.ScheduledFor,
+	)
+	return err
+}
+
+func (r *accountRepository) GetDeletion(ctx context.Context, userID uint) (*domain.AccountDeletion, error) {
+	query := `
+        SELECT user_id, mode, requested_at, scheduled_for
+        FROM account_deletions
+        WHERE user_id = $1`
+
+	deletion := &domain.AccountDeletion{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID).Scan(
+		&deletion.UserID,
+		&deletion.Mode,
+		&deletion.RequestedAt,
+		&deletion.ScheduledFor,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("no account deletion is scheduled")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+func (r *accountRepository) CancelDeletion(ctx context.Context, userID uint) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM account_deletions WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("no account deletion is scheduled")
+	}
+	return nil
+}
+
+func (r *accountRepository) GetDueDeletions(ctx context.Context, before time.Time, limit int) ([]domain.AccountDeletion, error) {
+	query := `
+        SELECT user_id, mode, requested_at, scheduled_for
+        FROM account_deletions
+        WHERE scheduled_for <= $1
+        ORDER BY scheduled_for
+        LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deletions []domain.AccountDeletion
+	for rows.Next() {
+		var deletion domain.AccountDeletion
+		err := rows.Scan(&deletion.UserID, &deletion.Mode, &deletion.RequestedAt, &deletion.ScheduledFor)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, deletion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deletions, nil
+}
+
+// DeleteInteractions keeps the like counters in step with the likes it
+// removes.
+func (r *accountRepository) DeleteInteractions(ctx context.Context, userID uint) error {
+	queries := []string{
+		`WITH removed AS (DELETE FROM post_likes WHERE user_id = $1 RETURNING post_id)
+         UPDATE posts SET likes = GREATEST(likes - 1, 0) WHERE id IN (SELECT post_id FROM removed)`,
+		`WITH removed AS (DELETE FROM comment_likes WHERE user_id = $1 RETURNING comment_id)
+         UPDATE comments SET likes = GREATEST(likes - 1, 0) WHERE id IN (SELECT comment_id FROM removed)`,
+		`DELETE FROM saved_posts WHERE user_id = $1`,
+		`DELETE FROM post_reports WHERE user_id = $1`,
+		`DELETE FROM comment_reports WHERE user_id = $1`,
+		`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1`,
+		`DELETE FROM user_mutes WHERE muter_id = $1 OR muted_id = $1`,
+		`DELETE FROM user_tokens WHERE user_id = $1`,
+	}
+	for _, query := range queries {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *accountRepository) CreateErasure(ctx context.Context, erasure *domain.AccountErasure) error {
+	query := `
+        INSERT INTO account_erasures (user_id, username, mode, erased_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id`
+
+	return conn(ctx, r.db).QueryRowContext(ctx, query,
+		erasure.UserID,
+		erasure.Username,
+		erasure.Mode,
+		erasure.ErasedAt,
+	).Scan(&erasure.ID)
+}
+
+func (r *accountRepository) GetUnscrubbedErasures(ctx context.Context, before time.Time, limit int) ([]domain.AccountErasure, error) {
+	query := `
+        SELECT id, user_id, COALESCE(username, ''), mode, erased_at
+        FROM account_erasures
+        WHERE scrubbed_at IS NULL AND erased_at < $1
+        ORDER BY id
+        LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var erasures []domain.AccountErasure
+	for rows.Next() {
+		var erasure domain.AccountErasure
+		err := rows.Scan(&erasure.ID, &erasure.UserID, &erasure.Username, &erasure.Mode, &erasure.ErasedAt)
+		if err != nil {
+			return nil, err
+		}
+		erasures = append(erasures, erasure)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return erasures, nil
+}
+
+// MarkScrubbed also forgets the usernames, which were only kept for scrubbing
+func (r *accountRepository) MarkScrubbed(ctx context.Context, ids []uint) error {
+	query := `
+        UPDATE account_erasures
+        SET scrubbed_at = NOW(), username = NULL
+        WHERE id = ANY($1)`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, pq.Array(int64s(ids)))
+	return err
+}
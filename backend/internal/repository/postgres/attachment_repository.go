@@ -0,0 +1,120 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) domain.AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+func (r *attachmentRepository) GetByPostIDs(ctx context.Context, postIDs []uint) (map[uint][]domain.Attachment, error) {
+	attachments := make(map[uint][]domain.Attachment)
+	if len(postIDs) == 0 {
+		return attachments, nil
+	}
+
+	ids := make([]int64, len(postIDs))
+	for i, id := range postIDs {
+		ids[i] = int64(id)
+	}
+
+	query := `
+        SELECT post_id, url, mime_type, alt_text, COALESCE(width, 0), COALESCE(height, 0)
+        FROM post_attachments
+        WHERE post_id = ANY($1)
+        ORDER BY post_id, position`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID uint
+		var a domain.Attachment
+		if err := rows.Scan(&postID, &a.URL, &a.MimeType, &a.AltText, &a.Width, &a.Height); err != nil {
+			return nil, err
+		}
+		attachments[postID] = append(attachments[postID], a)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return attachments, nil
+}
+
+func (r *attachmentRepository) Replace(ctx context.Context, postID uint, attachments []domain.Attachment) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		deleteQuery := `DELETE FROM post_attachments WHERE post_id = $1`
+		if _, err := conn(ctx, r.db).ExecContext(ctx, deleteQuery, postID); err != nil {
+			return err
+		}
+
+		insertQuery := `
+            INSERT INTO post_attachments (post_id, position, url, mime_type, alt_text, width, height)
+            VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0))`
+		for i, a := range attachments {
+			_, err := conn(ctx, r.db).ExecContext(ctx, insertQuery, postID, i, a.URL, a.MimeType, a.AltText, a.Width, a.Height)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// attachmentRecord is how an attachment is stored in a revision's
+// attachments column. Variants are looked up when the post is read, so they
+// aren't kept.
+type attachmentRecord struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	AltText  string `json:"alt_text,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+}
+
+func marshalAttachments(attachments []domain.Attachment) ([]byte, error) {
+	records := make([]attachmentRecord, len(attachments))
+	for i, a := range attachments {
+		records[i] = attachmentRecord{
+			URL:      a.URL,
+			MimeType: a.MimeType,
+			AltText:  a.AltText,
+			Width:    a.Width,
+			Height:   a.Height,
+		}
+	}
+	return json.Marshal(records)
+}
+
+func unmarshalAttachments(data []byte) ([]domain.Attachment, error) {
+	var records []attachmentRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	var attachments []domain.Attachment
+	for _, r := range records {
+		attachments = append(attachments, domain.Attachment{
+			URL:      r.URL,
+			MimeType: r.MimeType,
+			AltText:  r.AltText,
+			Width:    r.Width,
+			Height:   r.Height,
+		})
+	}
+	return attachments, nil
+}
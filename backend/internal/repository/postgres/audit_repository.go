@@ -0,0 +1,168 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// defaultAuditLimit is the page size when a listing doesn't ask for one
+const defaultAuditLimit = 100
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) domain.AuditRepository {
+	return &auditRepository{db: db}
+}
+
+const auditColumns = `
+            id, COALESCE(actor_id, 0), action, target_type, COALESCE(target_id, 0),
+            COALESCE(host(ip), ''), user_agent, diff, created_at`
+
+func scanAuditEvent(row interface{ Scan(...any) error }) (*domain.AuditEvent, error) {
+	event := &domain.AuditEvent{}
+	var diff []byte
+	err := row.Scan(
+		&event.ID,
+		&event.ActorID,
+		&event.Action,
+		&event.TargetType,
+		&event.TargetID,
+		&event.IP,
+		&event.UserAgent,
+		&diff,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if diff != nil {
+		if err := json.Unmarshal(diff, &event.Diff); err != nil {
+			return nil, err
+		}
+	}
+	return event, nil
+}
+
+func (r *auditRepository) Create(ctx context.Context, event *domain.AuditEvent) error {
+	var diff []byte
+	if len(event.Diff) > 0 {
+		var err error
+		if diff, err = json.Marshal(event.Diff); err != nil {
+			return err
+		}
+	}
+
+	query := `
+        INSERT INTO audit_events (actor_id, action, target_type, target_id, ip, user_agent, diff, created_at)
+        VALUES (NULLIF($1, 0), $2, $3, NULLIF($4, 0), NULLIF($5, '')::inet, $6, $7, $8)
+        RETURNING id`
+
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		int64(event.ActorID),
+		event.Action,
+		event.TargetType,
+		int64(event.TargetID),
+		event.IP,
+		event.UserAgent,
+		diff,
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+func (r *auditRepository) List(ctx context.Context, filter *domain.AuditFilter) ([]domain.AuditEvent, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+
+	query := `
+        SELECT` + auditColumns + `
+        FROM audit_events
+        WHERE ($1::int = 0 OR actor_id = $1)
+          AND ($2 = '' OR action = $2)
+          AND ($3 = '' OR target_type = $3)
+          AND ($4::int = 0 OR target_id = $4)
+          AND ($5::timestamptz IS NULL OR created_at >= $5)
+          AND ($6::timestamptz IS NULL OR created_at < $6)
+          AND ($7::bigint = 0 OR id < $7)
+        ORDER BY id DESC
+        LIMIT $8`
+
+	return r.query(ctx, query,
+		int64(filter.ActorID),
+		filter.Action,
+		filter.TargetType,
+		int64(filter.TargetID),
+		nullTime(filter.Since),
+		nullTime(filter.Until),
+		int64(filter.Before),
+		limit,
+	)
+}
+
+func (r *auditRepository) GetBetween(ctx context.Context, from, to time.Time) ([]domain.AuditEvent, error) {
+	query := `
+        SELECT` + auditColumns + `
+        FROM audit_events
+        WHERE created_at >= $1 AND created_at < $2
+        ORDER BY id`
+
+	return r.query(ctx, query, from, to)
+}
+
+func (r *auditRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM audit_events WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (r *auditRepository) Forget(ctx context.Context, userID uint) error {
+	query := `
+        UPDATE audit_events
+        SET ip = NULL, user_agent = '', diff = NULL
+        WHERE actor_id = $1 OR (target_type = $2 AND target_id = $1)`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, int64(userID), domain.AuditTargetUser)
+	return err
+}
+
+func (r *auditRepository) query(ctx context.Context, query string, args ...any) ([]domain.AuditEvent, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []domain.AuditEvent{}
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// nullTime passes a zero time as NULL
+func nullTime(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
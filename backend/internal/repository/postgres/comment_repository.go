@@ -1,8 +1,9 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"errors"
+	"time"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 )
@@ -11,24 +12,24 @@ type commentRepository struct {
 	db *sql.DB
 }
 
-func (r *commentRepository) AddReport(commentID, userID uint) error {
+func (r *commentRepository) AddReport(ctx context.Context, commentID, userID uint) error {
 	query := `
         INSERT INTO comment_reports (comment_id, user_id, created_at)
         VALUES ($1, $2, NOW())
         ON CONFLICT (comment_id, user_id) DO NOTHING
     `
-	_, err := r.db.Exec(query, commentID, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, commentID, userID)
 	return err
 }
 
-func (r *commentRepository) GetReplyCount(commentID uint) (int, error) {
+func (r *commentRepository) GetReplyCount(ctx context.Context, commentID uint) (int, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM comments WHERE parent_id = $1`
-	err := r.db.QueryRow(query, commentID).Scan(&count)
+	query := `SELECT COUNT(*) FROM comments WHERE parent_id = $1 AND deleted_at IS NULL`
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, commentID).Scan(&count)
 	return count, err
 }
 
-func (r *commentRepository) IsLikedByUser(commentID, userID uint) (bool, error) {
+func (r *commentRepository) IsLikedByUser(ctx context.Context, commentID, userID uint) (bool, error) {
 	query := `
         SELECT EXISTS(
             SELECT 1 FROM comment_likes
@@ -36,17 +37,17 @@ func (r *commentRepository) IsLikedByUser(commentID, userID uint) (bool, error)
         )
     `
 	var exists bool
-	err := r.db.QueryRow(query, commentID, userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, commentID, userID).Scan(&exists)
 	return exists, err
 }
 
-func (r *commentRepository) RemoveReport(commentID, userID uint) error {
+func (r *commentRepository) RemoveReport(ctx context.Context, commentID, userID uint) error {
 	query := `DELETE FROM comment_reports WHERE comment_id = $1 AND user_id = $2`
-	_, err := r.db.Exec(query, commentID, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, commentID, userID)
 	return err
 }
 
-func (r *commentRepository) IsReportedByUser(commentID, userID uint) (bool, error) {
+func (r *commentRepository) IsReportedByUser(ctx context.Context, commentID, userID uint) (bool, error) {
 	query := `
         SELECT EXISTS(
             SELECT 1 FROM comment_reports
@@ -54,7 +55,7 @@ func (r *commentRepository) IsReportedByUser(commentID, userID uint) (bool, erro
         )
     `
 	var exists bool
-	err := r.db.QueryRow(query, commentID, userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, commentID, userID).Scan(&exists)
 	return exists, err
 }
 
@@ -62,13 +63,14 @@ func NewCommentRepository(db *sql.DB) domain.CommentRepository {
 	return &commentRepository{db: db}
 }
 
-func (r *commentRepository) Create(comment *domain.Comment) error {
+func (r *commentRepository) Create(ctx context.Context, comment *domain.Comment) error {
 	query := `
         INSERT INTO comments (content, user_id, post_id, parent_id, created_at, updated_at)
         VALUES ($1, $2, $3, $4, $5, $6)
         RETURNING id`
 
-	return r.db.QueryRow(
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
 		query,
 		comment.Content,
 		comment.UserID,
@@ -79,30 +81,31 @@ func (r *commentRepository) Create(comment *domain.Comment) error {
 	).Scan(&comment.ID)
 }
 
-func (r *commentRepository) GetByID(id uint) (*domain.Comment, error) {
+func (r *commentRepository) GetByID(ctx context.Context, id uint) (*domain.Comment, error) {
 	query := `
         SELECT 
             c.id, c.content, c.user_id, c.post_id, c.parent_id, 
             c.created_at, c.updated_at,
+            c.edited_at, c.edited_at IS NOT NULL as edited,
             u.id as user_id,
             u.username, 
-            u.email, 
             COALESCE(u.bio, '') as bio,
             COALESCE(u.avatar_url, '') as avatar_url,
             COALESCE(u.post_count, 0) as post_count,
             u.created_at as user_created_at,
             u.updated_at as user_updated_at,
             (SELECT COUNT(*) FROM comment_likes WHERE comment_id = c.id) as likes,
-            COALESCE((SELECT COUNT(*) FROM comments WHERE parent_id = c.id), 0) as reply_count
+            COALESCE((SELECT COUNT(*) FROM comments WHERE parent_id = c.id AND deleted_at IS NULL), 0) as reply_count
         FROM comments c
         JOIN users u ON c.user_id = u.id
-        WHERE c.id = $1`
+        JOIN posts p ON c.post_id = p.id AND p.deleted_at IS NULL
+        WHERE c.id = $1 AND c.deleted_at IS NULL`
 
 	comment := &domain.Comment{
 		User: &domain.User{},
 	}
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&comment.ID,
 		&comment.Content,
 		&comment.UserID,
@@ -110,9 +113,10 @@ func (r *commentRepository) GetByID(id uint) (*domain.Comment, error) {
 		&comment.ParentID,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
+		&comment.EditedAt,
+		&comment.Edited,
 		&comment.User.ID,
 		&comment.User.Username,
-		&comment.User.Email,
 		&comment.User.Bio,
 		&comment.User.AvatarURL,
 		&comment.User.PostCount,
@@ -123,7 +127,7 @@ func (r *commentRepository) GetByID(id uint) (*domain.Comment, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("comment not found")
+		return nil, domain.NotFound("comment not found")
 	}
 	if err != nil {
 		return nil, err
@@ -132,29 +136,31 @@ func (r *commentRepository) GetByID(id uint) (*domain.Comment, error) {
 	return comment, nil
 }
 
-func (r *commentRepository) GetByPostID(postID uint, page, limit int) ([]domain.Comment, error) {
+func (r *commentRepository) GetByPostID(ctx context.Context, postID, viewerID uint, page, limit int) ([]domain.Comment, error) {
 	offset := (page - 1) * limit
 	query := `
        SELECT 
             c.id, c.content, c.user_id, c.post_id, c.parent_id, 
-            c.created_at, c.updated_at,
+            c.created_at, c.updated_at, c.deleted_at,
+            c.edited_at, c.edited_at IS NOT NULL as edited,
             u.id as user_id,
             u.username, 
-            u.email, 
             COALESCE(u.bio, '') as bio,
             COALESCE(u.avatar_url, '') as avatar_url,
             COALESCE(u.post_count, 0) as post_count,
             u.created_at as user_created_at,
             u.updated_at as user_updated_at,
             (SELECT COUNT(*) FROM comment_likes WHERE comment_id = c.id) as likes,
-            COALESCE((SELECT COUNT(*) FROM comments WHERE parent_id = c.id), 0) as reply_count
+            COALESCE((SELECT COUNT(*) FROM comments WHERE parent_id = c.id AND deleted_at IS NULL), 0) as reply_count
         FROM comments c
         JOIN users u ON c.user_id = u.id
+        JOIN posts p ON c.post_id = p.id AND p.deleted_at IS NULL
         WHERE c.post_id = $1
+          AND ` + visibleAuthorFilter("c.user_id", "$4") + `
         ORDER BY c.created_at DESC
         LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.Query(query, postID, limit, offset)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, postID, limit, offset, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -172,9 +178,11 @@ func (r *commentRepository) GetByPostID(postID uint, page, limit int) ([]domain.
 			&comment.ParentID,
 			&comment.CreatedAt,
 			&comment.UpdatedAt,
+			&comment.DeletedAt,
+			&comment.EditedAt,
+			&comment.Edited,
 			&comment.User.ID,
 			&comment.User.Username,
-			&comment.User.Email,
 			&comment.User.Bio,
 			&comment.User.AvatarURL,
 			&comment.User.PostCount,
@@ -186,22 +194,35 @@ func (r *commentRepository) GetByPostID(postID uint, page, limit int) ([]domain.
 		if err != nil {
 			return nil, err
 		}
+
+		// Deleted comments stay in the listing as tombstones so their
+		// replies keep a parent; the usecase drops the ones nobody replied to
+		if comment.DeletedAt != nil {
+			comment.IsDeleted = true
+			comment.Content = domain.DeletedCommentPlaceholder
+			comment.UserID = 0
+			comment.User = nil
+			comment.Likes = 0
+		}
 		comments = append(comments, comment)
 	}
 
 	return comments, nil
 }
 
-func (r *commentRepository) GetReplies(commentID uint) ([]domain.Comment, error) {
+func (r *commentRepository) GetReplies(ctx context.Context, commentID, viewerID uint) ([]domain.Comment, error) {
 	query := `
         SELECT c.id, c.content, c.user_id, c.post_id, c.parent_id, c.created_at, c.updated_at,
-               u.username, u.email
+               c.edited_at, c.edited_at IS NOT NULL as edited,
+               u.username
         FROM comments c
         JOIN users u ON c.user_id = u.id
-        WHERE c.parent_id = $1
+        JOIN posts p ON c.post_id = p.id AND p.deleted_at IS NULL
+        WHERE c.parent_id = $1 AND c.deleted_at IS NULL
+          AND ` + visibleAuthorFilter("c.user_id", "$2") + `
         ORDER BY c.created_at ASC`
 
-	rows, err := r.db.Query(query, commentID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, commentID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -219,8 +240,9 @@ func (r *commentRepository) GetReplies(commentID uint) ([]domain.Comment, error)
 			&comment.ParentID,
 			&comment.CreatedAt,
 			&comment.UpdatedAt,
+			&comment.EditedAt,
+			&comment.Edited,
 			&user.Username,
-			&user.Email,
 		)
 		if err != nil {
 			return nil, err
@@ -233,13 +255,13 @@ func (r *commentRepository) GetReplies(commentID uint) ([]domain.Comment, error)
 	return replies, nil
 }
 
-func (r *commentRepository) Update(comment *domain.Comment) error {
+func (r *commentRepository) Update(ctx context.Context, comment *domain.Comment) error {
 	query := `
         UPDATE comments 
-        SET content = $1, updated_at = $2
-        WHERE id = $3 AND user_id = $4`
+        SET content = $1, updated_at = $2, edited_at = $2
+        WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL`
 
-	result, err := r.db.Exec(query, comment.Content, comment.UpdatedAt, comment.ID, comment.UserID)
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, comment.Content, comment.UpdatedAt, comment.ID, comment.UserID)
 	if err != nil {
 		return err
 	}
@@ -249,15 +271,16 @@ func (r *commentRepository) Update(comment *domain.Comment) error {
 		return err
 	}
 	if rows == 0 {
-		return errors.New("comment not found or unauthorized")
+		return domain.NotFound("comment not found")
 	}
 
 	return nil
 }
 
-func (r *commentRepository) Delete(id uint) error {
-	query := `DELETE FROM comments WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// Delete moves a comment to its author's trash. Replies are left untouched.
+func (r *commentRepository) Delete(ctx context.Context, id uint) error {
+	query := `UPDATE comments SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -267,52 +290,210 @@ func (r *commentRepository) Delete(id uint) error {
 		return err
 	}
 	if rows == 0 {
-		return errors.New("comment not found")
+		return domain.NotFound("comment not found")
 	}
 
 	return nil
 }
-func (r *commentRepository) AddLike(commentID, userID uint) error {
-	query := `
+func (r *commentRepository) AddLike(ctx context.Context, commentID, userID uint) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
         INSERT INTO comment_likes (comment_id, user_id, created_at)
         VALUES ($1, $2, NOW())
         ON CONFLICT (comment_id, user_id) DO NOTHING`
 
-	_, err := r.db.Exec(query, commentID, userID)
-	if err != nil {
+		result, err := conn(ctx, r.db).ExecContext(ctx, query, commentID, userID)
+		if err != nil {
+			return err
+		}
+
+		// Only touch the counter when a like was actually added, so it can't
+		// drift from comment_likes under concurrent requests
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+
+		updateQuery := `UPDATE comments SET likes = likes + 1 WHERE id = $1`
+		_, err = conn(ctx, r.db).ExecContext(ctx, updateQuery, commentID)
 		return err
+	})
+}
+
+func (r *commentRepository) RemoveLike(ctx context.Context, commentID, userID uint) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		query := `DELETE FROM comment_likes WHERE comment_id = $1 AND user_id = $2`
+		result, err := conn(ctx, r.db).ExecContext(ctx, query, commentID, userID)
+		if err != nil {
+			return err
+		}
+
+		// Only touch the counter when a like was actually removed, so it can't
+		// drift from comment_likes under concurrent requests
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+
+		updateQuery := `UPDATE comments SET likes = GREATEST(likes - 1, 0) WHERE id = $1`
+		_, err = conn(ctx, r.db).ExecContext(ctx, updateQuery, commentID)
+		return err
+	})
+}
+
+func (r *commentRepository) GetLikes(ctx context.Context, commentID uint) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM comment_likes WHERE comment_id = $1`
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, commentID).Scan(&count)
+	return count, err
+}
+
+func (r *commentRepository) GetDeletedByID(ctx context.Context, id uint) (*domain.Comment, error) {
+	query := `
+        SELECT id, content, user_id, post_id, parent_id, created_at, updated_at, deleted_at
+        FROM comments
+        WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	comment := &domain.Comment{IsDeleted: true}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&comment.ID,
+		&comment.Content,
+		&comment.UserID,
+		&comment.PostID,
+		&comment.ParentID,
+		&comment.CreatedAt,
+		&comment.UpdatedAt,
+		&comment.DeletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("comment not found")
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Update likes count
-	updateQuery := `
-        UPDATE comments 
-        SET likes = (SELECT COUNT(*) FROM comment_likes WHERE comment_id = $1)
-        WHERE id = $1`
+	return comment, nil
+}
 
-	_, err = r.db.Exec(updateQuery, commentID)
-	return err
+func (r *commentRepository) GetDeletedByUserID(ctx context.Context, userID uint, since time.Time) ([]domain.Comment, error) {
+	query := `
+        SELECT c.id, c.content, c.user_id, c.post_id, c.parent_id, c.created_at, c.updated_at, c.deleted_at
+        FROM comments c
+        JOIN posts p ON c.post_id = p.id
+        WHERE c.user_id = $1 AND c.deleted_at IS NOT NULL AND c.deleted_at > $2
+          AND p.deleted_at IS NULL
+        ORDER BY c.deleted_at DESC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []domain.Comment{}
+	for rows.Next() {
+		comment := domain.Comment{IsDeleted: true}
+		err := rows.Scan(
+			&comment.ID,
+			&comment.Content,
+			&comment.UserID,
+			&comment.PostID,
+			&comment.ParentID,
+			&comment.CreatedAt,
+			&comment.UpdatedAt,
+			&comment.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
 }
 
-func (r *commentRepository) RemoveLike(commentID, userID uint) error {
-	query := `DELETE FROM comment_likes WHERE comment_id = $1 AND user_id = $2`
-	_, err := r.db.Exec(query, commentID, userID)
+func (r *commentRepository) Restore(ctx context.Context, id uint) error {
+	query := `UPDATE comments SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
 
-	// Update likes count
-	updateQuery := `
-        UPDATE comments 
-        SET likes = (SELECT COUNT(*) FROM comment_likes WHERE comment_id = $1)
-        WHERE id = $1`
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("comment not found")
+	}
 
-	_, err = r.db.Exec(updateQuery, commentID)
-	return err
+	return nil
 }
 
-func (r *commentRepository) GetLikes(commentID uint) (int, error) {
-	var count int
-	query := `SELECT COUNT(*) FROM comment_likes WHERE comment_id = $1`
-	err := r.db.QueryRow(query, commentID).Scan(&count)
-	return count, err
+// PurgeDeleted hard-deletes comments that were moved to the trash before the
+// given time. A comment would take its replies with it through the parent_id
+// cascade, so one with a reply that isn't going too is kept as a content-less
+// tombstone instead. A thread that is all expired goes in one run.
+func (r *commentRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := withTx(ctx, r.db, func(ctx context.Context) error {
+		var err error
+		purged, err = r.purgeDeleted(ctx, before)
+		return err
+	})
+	return purged, err
+}
+
+func (r *commentRepository) purgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	// Tombstones keep no content, so no history either
+	revisionsQuery := `
+        DELETE FROM content_revisions
+        WHERE target_type = 'comment' AND target_id IN (
+            SELECT id FROM comments WHERE deleted_at IS NOT NULL AND deleted_at < $1)`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, revisionsQuery, before); err != nil {
+		return 0, err
+	}
+
+	// kept starts at expired comments with a reply that stays, and climbs
+	// through their expired ancestors. A live ancestor stops the climb; its
+	// own expired parent is caught by the first half.
+	query := `
+        WITH RECURSIVE kept AS (
+            SELECT p.id, p.parent_id
+            FROM comments p
+            JOIN comments r ON r.parent_id = p.id
+            WHERE p.deleted_at < $1
+              AND (r.deleted_at IS NULL OR r.deleted_at >= $1)
+            UNION
+            SELECT p.id, p.parent_id
+            FROM comments p
+            JOIN kept k ON p.id = k.parent_id
+            WHERE p.deleted_at < $1
+        )
+        DELETE FROM comments c
+        WHERE c.deleted_at IS NOT NULL AND c.deleted_at < $1
+          AND c.id NOT IN (SELECT id FROM kept)`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	scrubQuery := `
+        UPDATE comments SET content = ''
+        WHERE deleted_at IS NOT NULL AND deleted_at < $1 AND content <> ''`
+	if _, err := conn(ctx, r.db).ExecContext(ctx, scrubQuery, before); err != nil {
+		return purged, err
+	}
+
+	return purged, nil
 }
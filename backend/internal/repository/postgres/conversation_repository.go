@@ -0,0 +1,344 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type conversationRepository struct {
+	db *sql.DB
+}
+
+func NewConversationRepository(db *sql.DB) domain.ConversationRepository {
+	return &conversationRepository{db: db}
+}
+
+func (r *conversationRepository) Create(ctx context.Context, conversation *domain.Conversation, participantIDs []uint) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+        INSERT INTO conversations (title, is_group, created_by, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id`
+
+		err := conn(ctx, r.db).QueryRowContext(
+			ctx,
+			query,
+			conversation.Title,
+			conversation.IsGroup,
+			conversation.CreatedBy,
+			conversation.CreatedAt,
+			conversation.UpdatedAt,
+		).Scan(&conversation.ID)
+		if err != nil {
+			return err
+		}
+
+		participantQuery := `
+        INSERT INTO conversation_participants (conversation_id, user_id, joined_at)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (conversation_id, user_id) DO NOTHING`
+		for _, userID := range participantIDs {
+			if _, err := conn(ctx, r.db).ExecContext(ctx, participantQuery, conversation.ID, userID, conversation.CreatedAt); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *conversationRepository) GetByID(ctx context.Context, id uint) (*domain.Conversation, error) {
+	query := `
+        SELECT id, COALESCE(title, '') as title, is_group, COALESCE(created_by, 0) as created_by, created_at, updated_at
+        FROM conversations
+        WHERE id = $1`
+
+	conversation := &domain.Conversation{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&conversation.ID,
+		&conversation.Title,
+		&conversation.IsGroup,
+		&conversation.CreatedBy,
+		&conversation.CreatedAt,
+		&conversation.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("conversation not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation, nil
+}
+
+func (r *conversationRepository) FindDirect(ctx context.Context, userID, otherUserID uint) (*domain.Conversation, error) {
+	query := `
+        SELECT c.id
+        FROM conversations c
+        JOIN conversation_participants a ON a.conversation_id = c.id AND a.user_id = $1
+        JOIN conversation_participants b ON b.conversation_id = c.id AND b.user_id = $2
+        WHERE c.is_group = false
+        LIMIT 1`
+
+	var id uint
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID, otherUserID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+func (r *conversationRepository) GetByUserID(ctx context.Context, userID uint, page, limit int) ([]domain.Conversation, error) {
+	offset := (page - 1) * limit
+	query := `
+        SELECT
+            c.id, COALESCE(c.title, '') as title, c.is_group, COALESCE(c.created_by, 0) as created_by,
+            c.created_at, c.updated_at, cp.muted,
+            (SELECT COUNT(*) FROM messages m
+             WHERE m.conversation_id = c.id
+               AND m.id > cp.last_read_message_id
+               AND m.sender_id <> $1) as unread_count
+        FROM conversations c
+        JOIN conversation_participants cp ON cp.conversation_id = c.id AND cp.user_id = $1
+        ORDER BY c.updated_at DESC
+        LIMIT $2 OFFSET $3`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversations []domain.Conversation
+	for rows.Next() {
+		var conversation domain.Conversation
+		err := rows.Scan(
+			&conversation.ID,
+			&conversation.Title,
+			&conversation.IsGroup,
+			&conversation.CreatedBy,
+			&conversation.CreatedAt,
+			&conversation.UpdatedAt,
+			&conversation.IsMuted,
+			&conversation.UnreadCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, conversation)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return conversations, nil
+}
+
+func (r *conversationRepository) GetParticipants(ctx context.Context, conversationID uint) ([]domain.ConversationParticipant, error) {
+	query := `
+        SELECT
+            cp.user_id, cp.last_read_message_id, cp.last_read_at, cp.joined_at,
+            u.id, u.username, COALESCE(u.avatar_url, '') as avatar_url
+        FROM conversation_participants cp
+        JOIN users u ON cp.user_id = u.id
+        WHERE cp.conversation_id = $1
+        ORDER BY cp.joined_at ASC, cp.user_id ASC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []domain.ConversationParticipant
+	for rows.Next() {
+		participant := domain.ConversationParticipant{
+			User: &domain.User{},
+		}
+		var lastReadAt sql.NullTime
+		err := rows.Scan(
+			&participant.UserID,
+			&participant.LastReadMessageID,
+			&lastReadAt,
+			&participant.JoinedAt,
+			&participant.User.ID,
+			&participant.User.Username,
+			&participant.User.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if lastReadAt.Valid {
+			participant.LastReadAt = &lastReadAt.Time
+		}
+		participants = append(participants, participant)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return participants, nil
+}
+
+func (r *conversationRepository) IsParticipant(ctx context.Context, conversationID, userID uint) (bool, error) {
+	query := `
+        SELECT EXISTS(
+            SELECT 1 FROM conversation_participants
+            WHERE conversation_id = $1 AND user_id = $2
+        )
+    `
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, conversationID, userID).Scan(&exists)
+	return exists, err
+}
+
+func (r *conversationRepository) SetMuted(ctx context.Context, conversationID, userID uint, muted bool) error {
+	query := `
+        UPDATE conversation_participants
+        SET muted = $1
+        WHERE conversation_id = $2 AND user_id = $3`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, muted, conversationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("conversation not found")
+	}
+
+	return nil
+}
+
+func (r *conversationRepository) IsMuted(ctx context.Context, conversationID, userID uint) (bool, error) {
+	query := `
+        SELECT COALESCE((
+            SELECT muted FROM conversation_participants
+            WHERE conversation_id = $1 AND user_id = $2
+        ), false)
+    `
+	var muted bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, conversationID, userID).Scan(&muted)
+	return muted, err
+}
+
+func (r *conversationRepository) CreateMessage(ctx context.Context, message *domain.Message) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		query := `
+        INSERT INTO messages (conversation_id, sender_id, content, created_at)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id`
+
+		err := conn(ctx, r.db).QueryRowContext(
+			ctx,
+			query,
+			message.ConversationID,
+			message.SenderID,
+			message.Content,
+			message.CreatedAt,
+		).Scan(&message.ID)
+		if err != nil {
+			return err
+		}
+
+		// Bump the conversation so it sorts first in the inbox
+		updateQuery := `UPDATE conversations SET updated_at = $1 WHERE id = $2`
+		_, err = conn(ctx, r.db).ExecContext(ctx, updateQuery, message.CreatedAt, message.ConversationID)
+		return err
+	})
+}
+
+// GetMessages returns up to limit messages in chronological order. A non-zero
+// beforeID pages backwards through history; a non-zero afterID returns the
+// messages that arrived since the client last polled.
+func (r *conversationRepository) GetMessages(ctx context.Context, conversationID, beforeID, afterID uint, limit int) ([]domain.Message, error) {
+	order := "DESC"
+	if afterID > 0 {
+		order = "ASC"
+	}
+
+	query := `
+        SELECT
+            m.id, m.conversation_id, m.sender_id, m.content, m.created_at,
+            u.id, u.username, COALESCE(u.avatar_url, '') as avatar_url
+        FROM messages m
+        JOIN users u ON m.sender_id = u.id
+        WHERE m.conversation_id = $1
+          AND ($2::int = 0 OR m.id < $2::int)
+          AND m.id > $3::int
+        ORDER BY m.id ` + order + `
+        LIMIT $4`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, conversationID, beforeID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []domain.Message
+	for rows.Next() {
+		message := domain.Message{
+			Sender: &domain.User{},
+		}
+		err := rows.Scan(
+			&message.ID,
+			&message.ConversationID,
+			&message.SenderID,
+			&message.Content,
+			&message.CreatedAt,
+			&message.Sender.ID,
+			&message.Sender.Username,
+			&message.Sender.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if order == "DESC" {
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	}
+
+	return messages, nil
+}
+
+// MarkRead moves the participant's read marker forward to messageID, clamped
+// to the newest message in the conversation, and returns the stored marker.
+func (r *conversationRepository) MarkRead(ctx context.Context, conversationID, userID, messageID uint) (uint, error) {
+	query := `
+        UPDATE conversation_participants
+        SET last_read_message_id = GREATEST(
+                last_read_message_id,
+                LEAST($3::int, (SELECT COALESCE(MAX(id), 0) FROM messages WHERE conversation_id = $1))
+            ),
+            last_read_at = NOW()
+        WHERE conversation_id = $1 AND user_id = $2
+        RETURNING last_read_message_id`
+
+	var lastRead uint
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, conversationID, userID, messageID).Scan(&lastRead)
+	if err == sql.ErrNoRows {
+		return 0, domain.NotFound("conversation not found")
+	}
+	return lastRead, err
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type linkPreviewRepository struct {
+	db *sql.DB
+}
+
+func NewLinkPreviewRepository(db *sql.DB) domain.LinkPreviewRepository {
+	return &linkPreviewRepository{db: db}
+}
+
+func (r *linkPreviewRepository) GetByURLs(ctx context.Context, urls []string) ([]domain.LinkPreview, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	query := `
+        SELECT url, canonical_url, title, description, image_url, site_name,
+            failed, fetched_at, expires_at
+        FROM link_previews
+        WHERE url = ANY($1)`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(urls))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var previews []domain.LinkPreview
+	for rows.Next() {
+		var p domain.LinkPreview
+		err := rows.Scan(
+			&p.URL,
+			&p.CanonicalURL,
+			&p.Title,
+			&p.Description,
+			&p.ImageURL,
+			&p.SiteName,
+			&p.Failed,
+			&p.FetchedAt,
+			&p.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		previews = append(previews, p)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return previews, nil
+}
+
+func (r *linkPreviewRepository) Save(ctx context.Context, preview *domain.LinkPreview) error {
+	query := `
+        INSERT INTO link_previews
+            (url, canonical_url, title, description, image_url, site_name, failed, fetched_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (url) DO UPDATE SET
+            canonical_url = EXCLUDED.canonical_url,
+            title = EXCLUDED.title,
+            description = EXCLUDED.description,
+            image_url = EXCLUDED.image_url,
+            site_name = EXCLUDED.site_name,
+            failed = EXCLUDED.failed,
+            fetched_at = EXCLUDED.fetched_at,
+            expires_at = EXCLUDED.expires_at`
+
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		preview.URL,
+		preview.CanonicalURL,
+		preview.Title,
+		preview.Description,
+		preview.ImageURL,
+		preview.SiteName,
+		preview.Failed,
+		preview.FetchedAt,
+		preview.ExpiresAt,
+	)
+	return err
+}
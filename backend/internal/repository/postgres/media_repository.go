@@ -0,0 +1,382 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type mediaRepository struct {
+	db *sql.DB
+}
+
+func NewMediaRepository(db *sql.DB) domain.MediaRepository {
+	return &mediaRepository{db: db}
+}
+
+// The file's type, size and key come from its upload
+const mediaColumns = `
+        m.id, m.owner_id, m.upload_id, m.kind, m.title, m.description,
+        up.content_type, up.size, m.duration, m.chapters, m.has_transcript,
+        up.key, m.created_at, m.updated_at
+        FROM media_items m
+        JOIN uploads up ON up.id = m.upload_id`
+
+func scanMedia(row interface{ Scan(...any) error }) (*domain.MediaItem, error) {
+	item := &domain.MediaItem{}
+	var chapters []byte
+	err := row.Scan(
+		&item.ID,
+		&item.OwnerID,
+		&item.UploadID,
+		&item.Kind,
+		&item.Title,
+		&item.Description,
+		&item.ContentType,
+		&item.Size,
+		&item.Duration,
+		&chapters,
+		&item.HasTranscript,
+		&item.Key,
+		&item.CreatedAt,
+		&item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(chapters, &item.Chapters); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func marshalChapters(chapters []domain.Chapter) ([]byte, error) {
+	if chapters == nil {
+		chapters = []domain.Chapter{}
+	}
+	return json.Marshal(chapters)
+}
+
+func (r *mediaRepository) Create(ctx context.Context, item *domain.MediaItem) error {
+	chapters, err := marshalChapters(item.Chapters)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO media_items (owner_id, upload_id, kind, title, description, duration, chapters, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING id`
+
+	err = conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		item.OwnerID,
+		item.UploadID,
+		item.Kind,
+		item.Title,
+		item.Description,
+		item.Duration,
+		chapters,
+		item.CreatedAt,
+		item.UpdatedAt,
+	).Scan(&item.ID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("upload already belongs to a media item")
+	}
+	return err
+}
+
+func (r *mediaRepository) GetByID(ctx context.Context, id uint) (*domain.MediaItem, error) {
+	query := `SELECT` + mediaColumns + `
+        WHERE m.id = $1`
+
+	item, err := scanMedia(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("media not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func (r *mediaRepository) GetByIDs(ctx context.Context, ids []uint) ([]domain.MediaItem, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := `SELECT` + mediaColumns + `
+        WHERE m.id = ANY($1)`
+
+	return r.list(ctx, query, pq.Array(int64s(ids)))
+}
+
+func (r *mediaRepository) GetByOwnerID(ctx context.Context, ownerID uint, page, limit int) ([]domain.MediaItem, error) {
+	query := `SELECT` + mediaColumns + `
+        WHERE m.owner_id = $1
+        ORDER BY m.created_at DESC, m.id DESC
+        LIMIT $2 OFFSET $3`
+
+	return r.list(ctx, query, ownerID, limit, (page-1)*limit)
+}
+
+func (r *mediaRepository) Update(ctx context.Context, item *domain.MediaItem) error {
+	chapters, err := marshalChapters(item.Chapters)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE media_items
+        SET title = $2, description = $3, duration = $4, chapters = $5, updated_at = $6
+        WHERE id = $1`
+
+	_, err = conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		item.ID,
+		item.Title,
+		item.Description,
+		item.Duration,
+		chapters,
+		item.UpdatedAt,
+	)
+	return err
+}
+
+func (r *mediaRepository) Delete(ctx context.Context, id uint) error {
+	query := `DELETE FROM media_items WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *mediaRepository) ReplaceTranscript(ctx context.Context, mediaID uint, cues []domain.TranscriptCue) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		deleteQuery := `DELETE FROM media_transcript_cues WHERE media_id = $1`
+		if _, err := conn(ctx, r.db).ExecContext(ctx, deleteQuery, mediaID); err != nil {
+			return err
+		}
+
+		if len(cues) > 0 {
+			starts := make([]int64, len(cues))
+			ends := make([]int64, len(cues))
+			texts := make([]string, len(cues))
+			for i, cue := range cues {
+				starts[i], ends[i], texts[i] = toMillis(cue.Start), toMillis(cue.End), cue.Text
+			}
+
+			// Transcripts run to thousands of cues, so they go in one statement
+			insertQuery := `
+                INSERT INTO media_transcript_cues (media_id, position, start_ms, end_ms, text)
+                SELECT $1, t.position - 1, t.start_ms, t.end_ms, t.text
+                FROM unnest($2::bigint[], $3::bigint[], $4::text[])
+                    WITH ORDINALITY AS t(start_ms, end_ms, text, position)`
+			_, err := conn(ctx, r.db).ExecContext(ctx, insertQuery, mediaID, pq.Array(starts), pq.Array(ends), pq.Array(texts))
+			if err != nil {
+				return err
+			}
+		}
+
+		updateQuery := `UPDATE media_items SET has_transcript = $2, updated_at = NOW() WHERE id = $1`
+		_, err := conn(ctx, r.db).ExecContext(ctx, updateQuery, mediaID, len(cues) > 0)
+		return err
+	})
+}
+
+func (r *mediaRepository) GetTranscript(ctx context.Context, mediaID uint) ([]domain.TranscriptCue, error) {
+	query := `
+        SELECT start_ms, end_ms, text
+        FROM media_transcript_cues
+        WHERE media_id = $1
+        ORDER BY position`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, mediaID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cues []domain.TranscriptCue
+	for rows.Next() {
+		var start, end int64
+		var cue domain.TranscriptCue
+		if err := rows.Scan(&start, &end, &cue.Text); err != nil {
+			return nil, err
+		}
+		cue.Start, cue.End = fromMillis(start), fromMillis(end)
+		cues = append(cues, cue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cues, nil
+}
+
+func (r *mediaRepository) Search(ctx context.Context, query string, limit, matchLimit int) ([]domain.MediaSearchResult, error) {
+	itemQuery := `SELECT` + mediaColumns + `
+        CROSS JOIN websearch_to_tsquery('english', $1) q
+        WHERE m.search @@ q
+            OR EXISTS (SELECT 1 FROM media_transcript_cues c WHERE c.media_id = m.id AND c.search @@ q)
+        ORDER BY ts_rank(m.search, q) DESC, m.created_at DESC
+        LIMIT $2`
+
+	items, err := r.list(ctx, itemQuery, query, limit)
+	if err != nil || len(items) == 0 {
+		return []domain.MediaSearchResult{}, err
+	}
+
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+
+	// The first few matching cues of each item, in the order they are spoken
+	cueQuery := `
+        SELECT media_id, start_ms, end_ms, text
+        FROM (
+            SELECT c.media_id, c.position, c.start_ms, c.end_ms, c.text,
+                row_number() OVER (PARTITION BY c.media_id ORDER BY c.position) AS n
+            FROM media_transcript_cues c
+            CROSS JOIN websearch_to_tsquery('english', $1) q
+            WHERE c.media_id = ANY($2) AND c.search @@ q
+        ) matched
+        WHERE n <= $3
+        ORDER BY media_id, position`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, cueQuery, query, pq.Array(int64s(ids)), matchLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matches := make(map[uint][]domain.TranscriptCue)
+	for rows.Next() {
+		var mediaID uint
+		var start, end int64
+		var cue domain.TranscriptCue
+		if err := rows.Scan(&mediaID, &start, &end, &cue.Text); err != nil {
+			return nil, err
+		}
+		cue.Start, cue.End = fromMillis(start), fromMillis(end)
+		matches[mediaID] = append(matches[mediaID], cue)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.MediaSearchResult, len(items))
+	for i, item := range items {
+		results[i] = domain.MediaSearchResult{Media: item, Matches: matches[item.ID]}
+		if results[i].Matches == nil {
+			results[i].Matches = []domain.TranscriptCue{}
+		}
+	}
+	return results, nil
+}
+
+func (r *mediaRepository) GetByPostIDs(ctx context.Context, postIDs []uint) (map[uint][]domain.MediaItem, error) {
+	media := make(map[uint][]domain.MediaItem)
+	if len(postIDs) == 0 {
+		return media, nil
+	}
+
+	query := `SELECT pm.post_id,` + mediaColumns + `
+        JOIN post_media pm ON pm.media_id = m.id
+        WHERE pm.post_id = ANY($1)
+        ORDER BY pm.post_id, pm.position`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, pq.Array(int64s(postIDs)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID uint
+		item, err := scanMedia(prefixedRow{rows, &postID})
+		if err != nil {
+			return nil, err
+		}
+		media[postID] = append(media[postID], *item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+func (r *mediaRepository) ReplacePostMedia(ctx context.Context, postID uint, mediaIDs []uint) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		deleteQuery := `DELETE FROM post_media WHERE post_id = $1`
+		if _, err := conn(ctx, r.db).ExecContext(ctx, deleteQuery, postID); err != nil {
+			return err
+		}
+
+		insertQuery := `INSERT INTO post_media (post_id, media_id, position) VALUES ($1, $2, $3)`
+		for i, id := range mediaIDs {
+			if _, err := conn(ctx, r.db).ExecContext(ctx, insertQuery, postID, id, i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *mediaRepository) list(ctx context.Context, query string, args ...any) ([]domain.MediaItem, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.MediaItem{}
+	for rows.Next() {
+		item, err := scanMedia(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, *item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// prefixedRow scans a leading column into dest before handing the rest of
+// the row to a scanner that doesn't know about it
+type prefixedRow struct {
+	rows *sql.Rows
+	dest any
+}
+
+func (p prefixedRow) Scan(dest ...any) error {
+	return p.rows.Scan(append([]any{p.dest}, dest...)...)
+}
+
+func int64s(ids []uint) []int64 {
+	out := make([]int64, len(ids))
+	for i, id := range ids {
+		out[i] = int64(id)
+	}
+	return out
+}
+
+func toMillis(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
+
+func fromMillis(ms int64) float64 {
+	return float64(ms) / 1000
+}
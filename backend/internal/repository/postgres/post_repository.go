@@ -1,9 +1,9 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"errors"
-	"fmt"
+	"time"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 )
@@ -12,62 +12,79 @@ type postRepository struct {
 	db *sql.DB
 }
 
-func (r *postRepository) AddReport(postID, userID uint) error {
+func (r *postRepository) AddReport(ctx context.Context, postID, userID uint) error {
+	if err := r.ensureLive(ctx, postID); err != nil {
+		return err
+	}
+
 	query := `
         INSERT INTO post_reports (post_id, user_id, created_at)
         VALUES ($1, $2, NOW())
         ON CONFLICT (post_id, user_id) DO NOTHING
     `
-	_, err := r.db.Exec(query, postID, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, postID, userID)
 	return err
 }
 
-func (r *postRepository) AddSave(postID, userID uint) error {
+func (r *postRepository) AddSave(ctx context.Context, postID, userID uint) error {
 	// First verify the user and post exist
 	var exists bool
-	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return errors.New("user not found")
+		return domain.NotFound("user not found")
 	}
 
-	err = r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1)", postID).Scan(&exists)
-	if err != nil {
+	if err := r.ensureLive(ctx, postID); err != nil {
 		return err
 	}
-	if !exists {
-		return errors.New("post not found")
-	}
 
 	query := `
         INSERT INTO saved_posts (user_id, post_id, created_at, updated_at)
         VALUES ($1, $2, NOW(), NOW())
         ON CONFLICT (user_id, post_id) DO NOTHING
     `
-	_, err = r.db.Exec(query, userID, postID)
+	_, err = conn(ctx, r.db).ExecContext(ctx, query, userID, postID)
 	return err
 }
 
+// ensureLive returns NotFound unless the post exists and isn't in the trash,
+// so trashed posts can't collect likes, reports or saves.
+func (r *postRepository) ensureLive(ctx context.Context, postID uint) error {
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM posts WHERE id = $1 AND deleted_at IS NULL)", postID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return domain.NotFound("post not found")
+	}
+	return nil
+}
+
 // GetSavedPosts implements domain.PostRepository.
-func (r *postRepository) GetSavedPosts(userID uint) ([]domain.Post, error) {
+func (r *postRepository) GetSavedPosts(ctx context.Context, userID uint) ([]domain.Post, error) {
 	query := `
         SELECT 
             p.id, p.title, p.content, p.image_url, p.link_url,
             p.likes, p.created_at, p.updated_at,
+            p.edited_at, p.edited_at IS NOT NULL as edited,
             u.id, u.username, u.email, COALESCE(u.bio, '') as bio,
             COALESCE(u.avatar_url, '') as avatar_url,
             COALESCE(u.post_count, 0) as post_count,
             u.created_at, u.updated_at,
-            (SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count
+            (SELECT COUNT(*) FROM comments WHERE post_id = p.id AND deleted_at IS NULL) as comment_count
         FROM posts p
         JOIN saved_posts sp ON sp.post_id = p.id
         JOIN users u ON p.user_id = u.id
         WHERE sp.user_id = $1
+          AND p.deleted_at IS NULL
+          AND ` + visibleAuthorFilter("p.user_id", "$1") + `
         ORDER BY sp.created_at DESC
     `
-	rows, err := r.db.Query(query, userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +106,8 @@ func (r *postRepository) GetSavedPosts(userID uint) ([]domain.Post, error) {
 			&post.Likes,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.EditedAt,
+			&post.Edited,
 			&post.User.ID,
 			&post.User.Username,
 			&post.User.Email,
@@ -102,7 +121,7 @@ func (r *postRepository) GetSavedPosts(userID uint) ([]domain.Post, error) {
 		if err != nil {
 			return nil, err
 		}
-		isLiked, _ := r.IsLikedByUser(post.ID, userID)
+		isLiked, _ := r.IsLikedByUser(ctx, post.ID, userID)
 		post.IsLiked = isLiked
 
 		posts = append(posts, post)
@@ -111,7 +130,7 @@ func (r *postRepository) GetSavedPosts(userID uint) ([]domain.Post, error) {
 	return posts, nil
 }
 
-func (r *postRepository) IsLikedByUser(postID, userID uint) (bool, error) {
+func (r *postRepository) IsLikedByUser(ctx context.Context, postID, userID uint) (bool, error) {
 	var exists bool
 	query := `
         SELECT EXISTS(
@@ -119,14 +138,14 @@ func (r *postRepository) IsLikedByUser(postID, userID uint) (bool, error) {
             WHERE post_id = $1 AND user_id = $2
         )
     `
-	err := r.db.QueryRow(query, postID, userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, postID, userID).Scan(&exists)
 	if err != nil {
 		return false, err
 	}
 	return exists, nil
 }
 
-func (r *postRepository) IsReportedByUser(postID, userID uint) (bool, error) {
+func (r *postRepository) IsReportedByUser(ctx context.Context, postID, userID uint) (bool, error) {
 	query := `
         SELECT EXISTS(
             SELECT 1 FROM post_reports
@@ -134,11 +153,11 @@ func (r *postRepository) IsReportedByUser(postID, userID uint) (bool, error) {
         )
     `
 	var exists bool
-	err := r.db.QueryRow(query, postID, userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, postID, userID).Scan(&exists)
 	return exists, err
 }
 
-func (r *postRepository) IsSavedByUser(postID, userID uint) (bool, error) {
+func (r *postRepository) IsSavedByUser(ctx context.Context, postID, userID uint) (bool, error) {
 	query := `
         SELECT EXISTS(
             SELECT 1 FROM saved_posts
@@ -146,19 +165,19 @@ func (r *postRepository) IsSavedByUser(postID, userID uint) (bool, error) {
         )
     `
 	var exists bool
-	err := r.db.QueryRow(query, postID, userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, postID, userID).Scan(&exists)
 	return exists, err
 }
 
-func (r *postRepository) RemoveReport(postID, userID uint) error {
+func (r *postRepository) RemoveReport(ctx context.Context, postID, userID uint) error {
 	query := `DELETE FROM post_reports WHERE post_id = $1 AND user_id = $2`
-	_, err := r.db.Exec(query, postID, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, postID, userID)
 	return err
 }
 
-func (r *postRepository) RemoveSave(postID, userID uint) error {
+func (r *postRepository) RemoveSave(ctx context.Context, postID, userID uint) error {
 	query := `DELETE FROM saved_posts WHERE post_id = $1 AND user_id = $2`
-	_, err := r.db.Exec(query, postID, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, postID, userID)
 	return err
 }
 
@@ -166,13 +185,14 @@ func NewPostRepository(db *sql.DB) domain.PostRepository {
 	return &postRepository{db: db}
 }
 
-func (r *postRepository) Create(post *domain.Post) error {
+func (r *postRepository) Create(ctx context.Context, post *domain.Post) error {
 	query := `
         INSERT INTO posts (title, content, image_url, link_url, user_id, created_at, updated_at)
         VALUES ($1, $2, $3, $4, $5, $6, $7)
         RETURNING id`
 
-	return r.db.QueryRow(
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
 		query,
 		post.Title,
 		post.Content,
@@ -184,25 +204,26 @@ func (r *postRepository) Create(post *domain.Post) error {
 	).Scan(&post.ID)
 }
 
-func (r *postRepository) GetByID(id uint) (*domain.Post, error) {
+func (r *postRepository) GetByID(ctx context.Context, id uint) (*domain.Post, error) {
 	query := `
         SELECT 
             p.id, p.title, p.content, p.image_url, p.link_url, p.likes,
             p.created_at, p.updated_at,
+            p.edited_at, p.edited_at IS NOT NULL as edited,
             u.id, u.username, u.email, COALESCE(u.bio, '') as bio,
             COALESCE(u.avatar_url, '') as avatar_url,
             COALESCE(u.post_count, 0) as post_count,
             u.created_at, u.updated_at,
-			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count
+			(SELECT COUNT(*) FROM comments WHERE post_id = p.id AND deleted_at IS NULL) as comment_count
         FROM posts p
         JOIN users u ON p.user_id = u.id
-        WHERE p.id = $1`
+        WHERE p.id = $1 AND p.deleted_at IS NULL`
 
 	post := &domain.Post{
 		User: &domain.User{},
 	}
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&post.ID,
 		&post.Title,
 		&post.Content,
@@ -211,6 +232,8 @@ func (r *postRepository) GetByID(id uint) (*domain.Post, error) {
 		&post.Likes,
 		&post.CreatedAt,
 		&post.UpdatedAt,
+		&post.EditedAt,
+		&post.Edited,
 		&post.User.ID,
 		&post.User.Username,
 		&post.User.Email,
@@ -223,7 +246,7 @@ func (r *postRepository) GetByID(id uint) (*domain.Post, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("post not found")
+		return nil, domain.NotFound("post not found")
 	}
 	if err != nil {
 		return nil, err
@@ -232,7 +255,7 @@ func (r *postRepository) GetByID(id uint) (*domain.Post, error) {
 	return post, nil
 }
 
-func (r *postRepository) GetAll(page, limit int, userID uint) ([]domain.Post, error) {
+func (r *postRepository) GetAll(ctx context.Context, page, limit int, userID uint) ([]domain.Post, error) {
 	offset := (page - 1) * limit
 	var query string
 	var rows *sql.Rows
@@ -242,11 +265,12 @@ func (r *postRepository) GetAll(page, limit int, userID uint) ([]domain.Post, er
 			SELECT 
 				p.id, p.title, p.content, p.image_url, p.link_url, p.likes,
 				p.created_at, p.updated_at,
+				p.edited_at, p.edited_at IS NOT NULL as edited,
 				u.id, u.username, u.email, COALESCE(u.bio, '') as bio,
 				COALESCE(u.avatar_url, '') as avatar_url,
 				COALESCE(u.post_count, 0) as post_count,
 				u.created_at, u.updated_at,
-				(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count,
+				(SELECT COUNT(*) FROM comments WHERE post_id = p.id AND deleted_at IS NULL) as comment_count,
 				CASE WHEN pl.user_id IS NOT NULL THEN true ELSE false END as is_liked,
 				CASE WHEN sp.user_id IS NOT NULL THEN true ELSE false END as is_saved,
 				CASE WHEN pr.user_id IS NOT NULL THEN true ELSE false END as is_reported
@@ -255,30 +279,32 @@ func (r *postRepository) GetAll(page, limit int, userID uint) ([]domain.Post, er
 			LEFT JOIN post_likes pl ON pl.post_id = p.id AND pl.user_id = $3
 			LEFT JOIN saved_posts sp ON sp.post_id = p.id AND sp.user_id = $3
 			LEFT JOIN post_reports pr ON pr.post_id = p.id AND pr.user_id = $3
+			WHERE p.deleted_at IS NULL
+			  AND ` + visibleAuthorFilter("p.user_id", "$3") + `
 			ORDER BY p.created_at DESC
 			LIMIT $1 OFFSET $2`
-		rows, err = r.db.Query(query, limit, offset, userID)
+		rows, err = conn(ctx, r.db).QueryContext(ctx, query, limit, offset, userID)
 	} else {
 		query = `
 		SELECT 
 			p.id, p.title, p.content, p.image_url, p.link_url, p.likes,
 			p.created_at, p.updated_at,
+			p.edited_at, p.edited_at IS NOT NULL as edited,
 			u.id, u.username, u.email, COALESCE(u.bio, '') as bio,
 			COALESCE(u.avatar_url, '') as avatar_url,
 			COALESCE(u.post_count, 0) as post_count,
 			u.created_at, u.updated_at,
-			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count,
+			(SELECT COUNT(*) FROM comments WHERE post_id = p.id AND deleted_at IS NULL) as comment_count,
 			false as is_liked,
 			false as is_saved,
 			false as is_reported
 		FROM posts p
 		JOIN users u ON p.user_id = u.id
+		WHERE p.deleted_at IS NULL
 		ORDER BY p.created_at DESC
 		LIMIT $1 OFFSET $2`
-		rows, err = r.db.Query(query, limit, offset)
+		rows, err = conn(ctx, r.db).QueryContext(ctx, query, limit, offset)
 	}
-	fmt.Println("userid", userID)
-
 	if err != nil {
 		return nil, err
 	}
@@ -298,6 +324,8 @@ func (r *postRepository) GetAll(page, limit int, userID uint) ([]domain.Post, er
 			&post.Likes,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.EditedAt,
+			&post.Edited,
 			&post.User.ID,
 			&post.User.Username,
 			&post.User.Email,
@@ -316,7 +344,7 @@ func (r *postRepository) GetAll(page, limit int, userID uint) ([]domain.Post, er
 		}
 
 		// Get tags for the post
-		tags, err := r.GetTags(post.ID)
+		tags, err := r.GetTags(ctx, post.ID)
 		if err == nil {
 			post.Tags = tags
 		}
@@ -327,23 +355,23 @@ func (r *postRepository) GetAll(page, limit int, userID uint) ([]domain.Post, er
 	return posts, nil
 }
 
-func (r *postRepository) GetByUserID(userID uint) ([]domain.Post, error) {
+func (r *postRepository) GetByUserID(ctx context.Context, userID uint) ([]domain.Post, error) {
 	query := `
         SELECT 
             p.id, p.title, p.content, p.image_url, p.link_url, p.likes,
             p.created_at, p.updated_at,
+            p.edited_at, p.edited_at IS NOT NULL as edited,
             u.id, u.username, u.email, COALESCE(u.bio, '') as bio,
             COALESCE(u.avatar_url, '') as avatar_url,
             COALESCE(u.post_count, 0) as post_count,
-            u.created_at, u.updated_at
-			(SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count
-
+            u.created_at, u.updated_at,
+            (SELECT COUNT(*) FROM comments WHERE post_id = p.id AND deleted_at IS NULL) as comment_count
         FROM posts p
         JOIN users u ON p.user_id = u.id
-        WHERE p.user_id = $1
+        WHERE p.user_id = $1 AND p.deleted_at IS NULL
         ORDER BY p.created_at DESC`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -363,6 +391,8 @@ func (r *postRepository) GetByUserID(userID uint) ([]domain.Post, error) {
 			&post.Likes,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.EditedAt,
+			&post.Edited,
 			&post.User.ID,
 			&post.User.Username,
 			&post.User.Email,
@@ -382,13 +412,14 @@ func (r *postRepository) GetByUserID(userID uint) ([]domain.Post, error) {
 	return posts, nil
 }
 
-func (r *postRepository) Update(post *domain.Post) error {
+func (r *postRepository) Update(ctx context.Context, post *domain.Post) error {
 	query := `
         UPDATE posts 
-        SET title = $1, content = $2, image_url = $3, link_url = $4, updated_at = $5
-        WHERE id = $6 AND user_id = $7`
+        SET title = $1, content = $2, image_url = $3, link_url = $4, updated_at = $5, edited_at = $5
+        WHERE id = $6 AND user_id = $7 AND deleted_at IS NULL`
 
-	result, err := r.db.Exec(
+	result, err := conn(ctx, r.db).ExecContext(
+		ctx,
 		query,
 		post.Title,
 		post.Content,
@@ -407,15 +438,17 @@ func (r *postRepository) Update(post *domain.Post) error {
 		return err
 	}
 	if rows == 0 {
-		return errors.New("post not found or unauthorized")
+		return domain.NotFound("post not found")
 	}
 
 	return nil
 }
 
-func (r *postRepository) Delete(id uint) error {
-	query := `DELETE FROM posts WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// Delete moves a post to its owner's trash. The row is removed for good by
+// PurgeDeleted once the retention window has passed.
+func (r *postRepository) Delete(ctx context.Context, id uint) error {
+	query := `UPDATE posts SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -425,35 +458,37 @@ func (r *postRepository) Delete(id uint) error {
 		return err
 	}
 	if rows == 0 {
-		return errors.New("post not found")
+		return domain.NotFound("post not found")
 	}
 
 	return nil
 }
 
-func (r *postRepository) AddTags(postID uint, tags []string) error {
-	// First, delete existing tags
-	deleteQuery := `DELETE FROM post_tags WHERE post_id = $1`
-	_, err := r.db.Exec(deleteQuery, postID)
-	if err != nil {
-		return err
-	}
-
-	// Insert new tags
-	insertQuery := `INSERT INTO post_tags (post_id, tag) VALUES ($1, $2)`
-	for _, tag := range tags {
-		_, err := r.db.Exec(insertQuery, postID, tag)
+func (r *postRepository) AddTags(ctx context.Context, postID uint, tags []string) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		// First, delete existing tags
+		deleteQuery := `DELETE FROM post_tags WHERE post_id = $1`
+		_, err := conn(ctx, r.db).ExecContext(ctx, deleteQuery, postID)
 		if err != nil {
 			return err
 		}
-	}
 
-	return nil
+		// Insert new tags
+		insertQuery := `INSERT INTO post_tags (post_id, tag) VALUES ($1, $2)`
+		for _, tag := range tags {
+			_, err := conn(ctx, r.db).ExecContext(ctx, insertQuery, postID, tag)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
-func (r *postRepository) GetTags(postID uint) ([]string, error) {
+func (r *postRepository) GetTags(ctx context.Context, postID uint) ([]string, error) {
 	query := `SELECT tag FROM post_tags WHERE post_id = $1`
-	rows, err := r.db.Query(query, postID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, postID)
 	if err != nil {
 		return nil, err
 	}
@@ -471,57 +506,193 @@ func (r *postRepository) GetTags(postID uint) ([]string, error) {
 	return tags, nil
 }
 
-func (r *postRepository) AddLike(postID, userID uint) error {
-	query := `
+func (r *postRepository) AddLike(ctx context.Context, postID, userID uint) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		if err := r.ensureLive(ctx, postID); err != nil {
+			return err
+		}
+
+		query := `
         INSERT INTO post_likes (post_id, user_id, created_at)
         VALUES ($1, $2, NOW())
         ON CONFLICT (post_id, user_id) DO NOTHING`
 
-	_, err := r.db.Exec(query, postID, userID)
-	if err != nil {
-		return err
-	}
+		result, err := conn(ctx, r.db).ExecContext(ctx, query, postID, userID)
+		if err != nil {
+			return err
+		}
 
-	// Update likes count in posts table
-	updateQuery := `
-        UPDATE posts 
-        SET likes = (SELECT COUNT(*) FROM post_likes WHERE post_id = $1)
-        WHERE id = $1`
+		// Only touch the counter when a like was actually added, so it can't
+		// drift from post_likes under concurrent requests
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
 
-	_, err = r.db.Exec(updateQuery, postID)
-	return err
+		updateQuery := `UPDATE posts SET likes = likes + 1 WHERE id = $1`
+		_, err = conn(ctx, r.db).ExecContext(ctx, updateQuery, postID)
+		return err
+	})
 }
 
-func (r *postRepository) RemoveLike(postID, userID uint) error {
-	query := `DELETE FROM post_likes WHERE post_id = $1 AND user_id = $2`
-	_, err := r.db.Exec(query, postID, userID)
-	if err != nil {
-		return err
-	}
+func (r *postRepository) RemoveLike(ctx context.Context, postID, userID uint) error {
+	return withTx(ctx, r.db, func(ctx context.Context) error {
+		query := `DELETE FROM post_likes WHERE post_id = $1 AND user_id = $2`
+		result, err := conn(ctx, r.db).ExecContext(ctx, query, postID, userID)
+		if err != nil {
+			return err
+		}
 
-	// Update likes count in posts table
-	updateQuery := `
-        UPDATE posts 
-        SET likes = (SELECT COUNT(*) FROM post_likes WHERE post_id = $1)
-        WHERE id = $1`
+		// Only touch the counter when a like was actually removed, so it can't
+		// drift from post_likes under concurrent requests
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
 
-	_, err = r.db.Exec(updateQuery, postID)
-	return err
+		updateQuery := `UPDATE posts SET likes = GREATEST(likes - 1, 0) WHERE id = $1`
+		_, err = conn(ctx, r.db).ExecContext(ctx, updateQuery, postID)
+		return err
+	})
 }
 
-func (r *postRepository) GetLikes(postID uint) (int, error) {
+func (r *postRepository) GetLikes(ctx context.Context, postID uint) (int, error) {
 	var count int
 	query := `SELECT COUNT(*) FROM post_likes WHERE post_id = $1`
-	err := r.db.QueryRow(query, postID).Scan(&count)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, postID).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
-func (r *postRepository) GetCommentCount(postID uint) (int, error) {
+func (r *postRepository) GetCommentCount(ctx context.Context, postID uint) (int, error) {
 	var count int
-	query := `SELECT COUNT(*) FROM comments WHERE post_id = $1`
-	err := r.db.QueryRow(query, postID).Scan(&count)
+	query := `SELECT COUNT(*) FROM comments WHERE post_id = $1 AND deleted_at IS NULL`
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, postID).Scan(&count)
 	return count, err
 }
+
+func (r *postRepository) GetDeletedByID(ctx context.Context, id uint) (*domain.Post, error) {
+	query := `
+        SELECT p.id, p.title, p.content, p.image_url, p.link_url, p.likes,
+               p.user_id, p.created_at, p.updated_at, p.deleted_at
+        FROM posts p
+        WHERE p.id = $1 AND p.deleted_at IS NOT NULL`
+
+	post := &domain.Post{
+		User: &domain.User{},
+	}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&post.ID,
+		&post.Title,
+		&post.Content,
+		&post.ImageURL,
+		&post.LinkURL,
+		&post.Likes,
+		&post.User.ID,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&post.DeletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("post not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (r *postRepository) GetDeletedByUserID(ctx context.Context, userID uint, since time.Time) ([]domain.Post, error) {
+	query := `
+        SELECT p.id, p.title, p.content, p.image_url, p.link_url, p.likes,
+               p.user_id, p.created_at, p.updated_at, p.deleted_at
+        FROM posts p
+        WHERE p.user_id = $1 AND p.deleted_at IS NOT NULL AND p.deleted_at > $2
+        ORDER BY p.deleted_at DESC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []domain.Post{}
+	for rows.Next() {
+		post := domain.Post{
+			User: &domain.User{},
+		}
+		err := rows.Scan(
+			&post.ID,
+			&post.Title,
+			&post.Content,
+			&post.ImageURL,
+			&post.LinkURL,
+			&post.Likes,
+			&post.User.ID,
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func (r *postRepository) Restore(ctx context.Context, id uint) error {
+	query := `UPDATE posts SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("post not found")
+	}
+
+	return nil
+}
+
+// PurgeDeleted hard-deletes posts that were moved to the trash before the
+// given time. Their comments, likes and tags go with them via ON DELETE CASCADE;
+// the edit history of the posts and their comments, which has no foreign key,
+// is deleted first.
+func (r *postRepository) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	var purged int64
+	err := withTx(ctx, r.db, func(ctx context.Context) error {
+		revisionsQuery := `
+            WITH doomed AS (
+                SELECT id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < $1
+            )
+            DELETE FROM content_revisions
+            WHERE (target_type = 'post' AND target_id IN (SELECT id FROM doomed))
+               OR (target_type = 'comment' AND target_id IN (
+                    SELECT id FROM comments WHERE post_id IN (SELECT id FROM doomed)))`
+		if _, err := conn(ctx, r.db).ExecContext(ctx, revisionsQuery, before); err != nil {
+			return err
+		}
+
+		query := `DELETE FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < $1`
+		result, err := conn(ctx, r.db).ExecContext(ctx, query, before)
+		if err != nil {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+	return purged, err
+}
@@ -26,7 +26,7 @@ func (r *prayerRequestRepository) Create(ctx context.Context, pr *domain.PrayerR
 		RETURNING id`
 
 	now := time.Now()
-	err := r.db.QueryRowContext(ctx, query, pr.Content, now).Scan(&pr.ID)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, pr.Content, now).Scan(&pr.ID)
 	if err != nil {
 		return err
 	}
@@ -43,14 +43,14 @@ func (r *prayerRequestRepository) GetByID(ctx context.Context, id uint) (*domain
 		WHERE id = $1`
 
 	pr := &domain.PrayerRequest{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&pr.ID,
 		&pr.Content,
 		&pr.CreatedAt,
 		&pr.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return nil, domain.ErrNotFound
+		return nil, domain.NotFound("prayer request not found")
 	}
 	if err != nil {
 		return nil, err
@@ -66,7 +66,7 @@ func (r *prayerRequestRepository) GetRandom(ctx context.Context, limit int) ([]*
 		ORDER BY RANDOM()
 		LIMIT $1`
 
-	rows, err := r.db.QueryContext(ctx, query, limit)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +101,7 @@ func (r *prayerRequestRepository) Update(ctx context.Context, pr *domain.PrayerR
 		WHERE id = $3`
 
 	now := time.Now()
-	result, err := r.db.ExecContext(ctx, query, pr.Content, now, pr.ID)
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, pr.Content, now, pr.ID)
 	if err != nil {
 		return err
 	}
@@ -111,7 +111,7 @@ func (r *prayerRequestRepository) Update(ctx context.Context, pr *domain.PrayerR
 		return err
 	}
 	if rowsAffected == 0 {
-		return domain.ErrNotFound
+		return domain.NotFound("prayer request not found")
 	}
 
 	pr.UpdatedAt = now
@@ -121,7 +121,7 @@ func (r *prayerRequestRepository) Update(ctx context.Context, pr *domain.PrayerR
 func (r *prayerRequestRepository) Delete(ctx context.Context, id uint) error {
 	query := `DELETE FROM prayer_requests WHERE id = $1`
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return err
 	}
@@ -131,7 +131,7 @@ func (r *prayerRequestRepository) Delete(ctx context.Context, id uint) error {
 		return err
 	}
 	if rowsAffected == 0 {
-		return domain.ErrNotFound
+		return domain.NotFound("prayer request not found")
 	}
 
 	return nil
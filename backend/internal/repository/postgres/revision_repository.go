@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type revisionRepository struct {
+	db *sql.DB
+}
+
+func NewRevisionRepository(db *sql.DB) domain.RevisionRepository {
+	return &revisionRepository{db: db}
+}
+
+func (r *revisionRepository) Create(ctx context.Context, revision *domain.Revision) error {
+	attachments, err := marshalAttachments(revision.Attachments)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        INSERT INTO content_revisions
+            (target_type, target_id, editor_id, title, content, image_url, link_url, tags, attachments, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id`
+
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		revision.TargetType,
+		revision.TargetID,
+		revision.EditorID,
+		revision.Title,
+		revision.Content,
+		revision.ImageURL,
+		revision.LinkURL,
+		pq.Array(revision.Tags),
+		attachments,
+		revision.CreatedAt,
+	).Scan(&revision.ID)
+}
+
+func (r *revisionRepository) GetByID(ctx context.Context, id uint) (*domain.Revision, error) {
+	query := `
+        SELECT
+            rv.id, rv.target_type, rv.target_id, COALESCE(rv.editor_id, 0),
+            COALESCE(rv.title, ''), rv.content,
+            COALESCE(rv.image_url, ''), COALESCE(rv.link_url, ''),
+            rv.tags, rv.attachments, rv.created_at
+        FROM content_revisions rv
+        WHERE rv.id = $1`
+
+	revision := &domain.Revision{}
+	var attachments []byte
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
+		&revision.ID,
+		&revision.TargetType,
+		&revision.TargetID,
+		&revision.EditorID,
+		&revision.Title,
+		&revision.Content,
+		&revision.ImageURL,
+		&revision.LinkURL,
+		pq.Array(&revision.Tags),
+		&attachments,
+		&revision.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("revision not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if revision.Attachments, err = unmarshalAttachments(attachments); err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+func (r *revisionRepository) GetByTarget(ctx context.Context, targetType string, targetID uint) ([]domain.Revision, error) {
+	query := `
+        SELECT
+            rv.id, rv.target_type, rv.target_id, COALESCE(rv.editor_id, 0),
+            COALESCE(rv.title, ''), rv.content,
+            COALESCE(rv.image_url, ''), COALESCE(rv.link_url, ''),
+            rv.tags, rv.attachments, rv.created_at,
+            COALESCE(u.username, '') as editor_username
+        FROM content_revisions rv
+        LEFT JOIN users u ON rv.editor_id = u.id
+        WHERE rv.target_type = $1 AND rv.target_id = $2
+        ORDER BY rv.id DESC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []domain.Revision{}
+	for rows.Next() {
+		var revision domain.Revision
+		var editorUsername string
+		var attachments []byte
+		err := rows.Scan(
+			&revision.ID,
+			&revision.TargetType,
+			&revision.TargetID,
+			&revision.EditorID,
+			&revision.Title,
+			&revision.Content,
+			&revision.ImageURL,
+			&revision.LinkURL,
+			pq.Array(&revision.Tags),
+			&attachments,
+			&revision.CreatedAt,
+			&editorUsername,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if revision.Attachments, err = unmarshalAttachments(attachments); err != nil {
+			return nil, err
+		}
+		if revision.EditorID != 0 {
+			revision.Editor = &domain.User{ID: revision.EditorID, Username: editorUsername}
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -1,8 +1,8 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"log"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 )
@@ -15,29 +15,30 @@ func NewSavedPostRepository(db *sql.DB) domain.SavedPostRepository {
 	return &savedPostRepository{db}
 }
 
-func (r *savedPostRepository) Create(savedPost *domain.SavedPost) error {
+func (r *savedPostRepository) Create(ctx context.Context, savedPost *domain.SavedPost) error {
 	query := `
         INSERT INTO saved_posts (user_id, post_id)
         VALUES ($1, $2)
         RETURNING id, created_at, updated_at
     `
-	return r.db.QueryRow(
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
 		query,
 		savedPost.UserID,
 		savedPost.PostID,
 	).Scan(&savedPost.ID, &savedPost.CreatedAt, &savedPost.UpdatedAt)
 }
 
-func (r *savedPostRepository) Delete(userID, postID uint) error {
+func (r *savedPostRepository) Delete(ctx context.Context, userID, postID uint) error {
 	query := `
         DELETE FROM saved_posts
         WHERE user_id = $1 AND post_id = $2
     `
-	_, err := r.db.Exec(query, userID, postID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID, postID)
 	return err
 }
 
-func (r *savedPostRepository) GetByUserID(userID uint) ([]domain.SavedPost, error) {
+func (r *savedPostRepository) GetByUserID(ctx context.Context, userID uint) ([]domain.SavedPost, error) {
 	query := `
         SELECT 
             sp.id as saved_post_id,
@@ -52,6 +53,7 @@ func (r *savedPostRepository) GetByUserID(userID uint) ([]domain.SavedPost, erro
             p.likes, 
             p.created_at, 
             p.updated_at,
+            p.edited_at, p.edited_at IS NOT NULL as edited,
             u.id as author_id, 
             u.username, 
             u.email, 
@@ -60,17 +62,18 @@ func (r *savedPostRepository) GetByUserID(userID uint) ([]domain.SavedPost, erro
             COALESCE(u.post_count, 0) as post_count,
             u.created_at as user_created_at,
             u.updated_at as user_updated_at,
-            (SELECT COUNT(*) FROM comments WHERE post_id = p.id) as comment_count
+            (SELECT COUNT(*) FROM comments WHERE post_id = p.id AND deleted_at IS NULL) as comment_count
         FROM saved_posts sp
         JOIN posts p ON sp.post_id = p.id
         JOIN users u ON p.user_id = u.id
         WHERE sp.user_id = $1
+          AND p.deleted_at IS NULL
+          AND ` + visibleAuthorFilter("p.user_id", "$1") + `
         ORDER BY sp.created_at DESC
     `
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID)
 	if err != nil {
-		log.Printf("Query error: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -95,6 +98,8 @@ func (r *savedPostRepository) GetByUserID(userID uint) ([]domain.SavedPost, erro
 			&sp.Post.Likes,
 			&sp.Post.CreatedAt,
 			&sp.Post.UpdatedAt,
+			&sp.Post.EditedAt,
+			&sp.Post.Edited,
 			&sp.Post.User.ID,
 			&sp.Post.User.Username,
 			&sp.Post.User.Email,
@@ -106,15 +111,13 @@ func (r *savedPostRepository) GetByUserID(userID uint) ([]domain.SavedPost, erro
 			&sp.Post.CommentCount,
 		)
 		if err != nil {
-			log.Printf("Scan error: %v", err)
 			return nil, err
 		}
 
 		sp.Post.IsSaved = true
 
-		isLiked, err := r.IsLikedByUser(sp.Post.ID, userID)
+		isLiked, err := r.IsLikedByUser(ctx, sp.Post.ID, userID)
 		if err != nil {
-			log.Printf("Error checking like status: %v", err)
 			return nil, err
 		}
 		sp.Post.IsLiked = isLiked
@@ -123,15 +126,13 @@ func (r *savedPostRepository) GetByUserID(userID uint) ([]domain.SavedPost, erro
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Printf("Rows error: %v", err)
 		return nil, err
 	}
 
-	log.Printf("Found %d saved posts for user_id: %d", len(savedPosts), userID)
 	return savedPosts, nil
 }
 
-func (r *savedPostRepository) IsLikedByUser(postID, userID uint) (bool, error) {
+func (r *savedPostRepository) IsLikedByUser(ctx context.Context, postID, userID uint) (bool, error) {
 	var exists bool
 	query := `
         SELECT EXISTS(
@@ -139,11 +140,11 @@ func (r *savedPostRepository) IsLikedByUser(postID, userID uint) (bool, error) {
             WHERE post_id = $1 AND user_id = $2
         )
     `
-	err := r.db.QueryRow(query, postID, userID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, postID, userID).Scan(&exists)
 	return exists, err
 }
 
-func (r *savedPostRepository) IsSaved(userID, postID uint) (bool, error) {
+func (r *savedPostRepository) IsSaved(ctx context.Context, userID, postID uint) (bool, error) {
 	query := `
         SELECT EXISTS(
             SELECT 1 FROM saved_posts
@@ -151,6 +152,6 @@ func (r *savedPostRepository) IsSaved(userID, postID uint) (bool, error) {
         )
     `
 	var exists bool
-	err := r.db.QueryRow(query, userID, postID).Scan(&exists)
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID, postID).Scan(&exists)
 	return exists, err
 }
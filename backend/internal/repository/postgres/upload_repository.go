@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type uploadRepository struct {
+	db *sql.DB
+}
+
+func NewUploadRepository(db *sql.DB) domain.UploadRepository {
+	return &uploadRepository{db: db}
+}
+
+// variantRecord is how an image variant is stored in the variants column.
+// Unlike the domain type it keeps the key, which is needed to delete it.
+type variantRecord struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+const uploadColumns = `
+        id, owner_id, purpose, key, COALESCE(url, ''), content_type, size,
+        status, COALESCE(width, 0), COALESCE(height, 0), variants, created_at`
+
+func scanUpload(row interface{ Scan(...any) error }) (*domain.Upload, error) {
+	upload := &domain.Upload{}
+	var variants []byte
+	err := row.Scan(
+		&upload.ID,
+		&upload.OwnerID,
+		&upload.Purpose,
+		&upload.Key,
+		&upload.URL,
+		&upload.ContentType,
+		&upload.Size,
+		&upload.Status,
+		&upload.Width,
+		&upload.Height,
+		&variants,
+		&upload.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []variantRecord
+	if err := json.Unmarshal(variants, &records); err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		upload.Variants = append(upload.Variants, domain.ImageVariant(r))
+	}
+	return upload, nil
+}
+
+func (r *uploadRepository) Create(ctx context.Context, upload *domain.Upload) error {
+	query := `
+        INSERT INTO uploads (owner_id, purpose, key, url, content_type, size, status, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id`
+
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		upload.OwnerID,
+		upload.Purpose,
+		upload.Key,
+		upload.URL,
+		upload.ContentType,
+		upload.Size,
+		upload.Status,
+		upload.CreatedAt,
+	).Scan(&upload.ID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("file has already been recorded")
+	}
+	return err
+}
+
+func (r *uploadRepository) GetByID(ctx context.Context, id uint) (*domain.Upload, error) {
+	query := `SELECT` + uploadColumns + `
+        FROM uploads
+        WHERE id = $1`
+
+	upload, err := scanUpload(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("upload not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+func (r *uploadRepository) GetByIDs(ctx context.Context, ids []uint) ([]domain.Upload, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query := `SELECT` + uploadColumns + `
+        FROM uploads
+        WHERE id = ANY($1)`
+
+	return r.list(ctx, query, pq.Array(int64s(ids)))
+}
+
+func (r *uploadRepository) GetByURLs(ctx context.Context, urls []string) ([]domain.Upload, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	query := `SELECT` + uploadColumns + `
+        FROM uploads
+        WHERE url = ANY($1)`
+
+	return r.list(ctx, query, pq.Array(urls))
+}
+
+func (r *uploadRepository) GetByOwnerID(ctx context.Context, ownerID uint) ([]domain.Upload, error) {
+	query := `SELECT` + uploadColumns + `
+        FROM uploads
+        WHERE owner_id = $1
+        ORDER BY id`
+
+	return r.list(ctx, query, ownerID)
+}
+
+func (r *uploadRepository) GetProcessing(ctx context.Context, before time.Time, limit int) ([]domain.Upload, error) {
+	query := `SELECT` + uploadColumns + `
+        FROM uploads
+        WHERE status = $1 AND created_at < $2
+        ORDER BY created_at
+        LIMIT $3`
+
+	return r.list(ctx, query, domain.UploadStatusProcessing, before, limit)
+}
+
+func (r *uploadRepository) GetUnreferenced(ctx context.Context, purposes []domain.UploadPurpose, before time.Time, limit int) ([]domain.Upload, error) {
+	names := make([]string, len(purposes))
+	for i, purpose := range purposes {
+		names[i] = string(purpose)
+	}
+
+	query := `SELECT` + uploadColumns + `
+        FROM uploads
+        WHERE purpose = ANY($1) AND created_at < $2
+            AND NOT EXISTS (SELECT 1 FROM upload_references ref WHERE ref.upload_id = uploads.id)
+        ORDER BY created_at
+        LIMIT $3`
+
+	return r.list(ctx, query, pq.Array(names), before, limit)
+}
+
+func (r *uploadRepository) DeleteUnreferenced(ctx context.Context, id uint) (bool, error) {
+	query := `
+        DELETE FROM uploads
+        WHERE id = $1
+            AND NOT EXISTS (SELECT 1 FROM upload_references ref WHERE ref.upload_id = $1)`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+func (r *uploadRepository) list(ctx context.Context, query string, args ...any) ([]domain.Upload, error) {
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []domain.Upload
+	for rows.Next() {
+		upload, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, *upload)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return uploads, nil
+}
+
+func (r *uploadRepository) UpdateProcessed(ctx context.Context, upload *domain.Upload) error {
+	records := make([]variantRecord, len(upload.Variants))
+	for i, v := range upload.Variants {
+		records[i] = variantRecord(v)
+	}
+	variants, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+
+	query := `
+        UPDATE uploads
+        SET url = $2, status = $3, width = NULLIF($4, 0), height = NULLIF($5, 0), variants = $6
+        WHERE id = $1`
+
+	_, err = conn(ctx, r.db).ExecContext(
+		ctx,
+		query,
+		upload.ID,
+		upload.URL,
+		upload.Status,
+		upload.Width,
+		upload.Height,
+		variants,
+	)
+	return err
+}
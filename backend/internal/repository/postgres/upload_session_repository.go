@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type uploadSessionRepository struct {
+	db *sql.DB
+}
+
+func NewUploadSessionRepository(db *sql.DB) domain.UploadSessionRepository {
+	return &uploadSessionRepository{db: db}
+}
+
+func (r *uploadSessionRepository) Create(ctx context.Context, session *domain.UploadSession) error {
+	query := `
+        INSERT INTO upload_sessions (owner_id, purpose, key, content_type, size, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`
+
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		session.OwnerID,
+		session.Purpose,
+		session.Key,
+		session.ContentType,
+		session.Size,
+		session.ExpiresAt,
+		session.CreatedAt,
+	).Scan(&session.ID)
+}
+
+const uploadSessionColumns = `
+        id, owner_id, purpose, key, content_type, size, upload_id,
+        expires_at, finalized_at, created_at`
+
+func scanUploadSession(row interface{ Scan(...any) error }) (*domain.UploadSession, error) {
+	session := &domain.UploadSession{}
+	var uploadID sql.NullInt64
+	err := row.Scan(
+		&session.ID,
+		&session.OwnerID,
+		&session.Purpose,
+		&session.Key,
+		&session.ContentType,
+		&session.Size,
+		&uploadID,
+		&session.ExpiresAt,
+		&session.FinalizedAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if uploadID.Valid {
+		id := uint(uploadID.Int64)
+		session.UploadID = &id
+	}
+	return session, nil
+}
+
+func (r *uploadSessionRepository) GetByID(ctx context.Context, id uint) (*domain.UploadSession, error) {
+	query := `SELECT` + uploadSessionColumns + `
+        FROM upload_sessions
+        WHERE id = $1`
+
+	session, err := scanUploadSession(conn(ctx, r.db).QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("upload session not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (r *uploadSessionRepository) MarkFinalized(ctx context.Context, id, uploadID uint) error {
+	query := `
+        UPDATE upload_sessions
+        SET upload_id = $2, finalized_at = NOW()
+        WHERE id = $1 AND finalized_at IS NULL`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id, uploadID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.Conflict("upload session has already been finalized")
+	}
+
+	return nil
+}
+
+func (r *uploadSessionRepository) GetAbandoned(ctx context.Context, before time.Time, limit int) ([]domain.UploadSession, error) {
+	query := `SELECT` + uploadSessionColumns + `
+        FROM upload_sessions
+        WHERE finalized_at IS NULL AND expires_at < $1
+        ORDER BY expires_at
+        LIMIT $2`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []domain.UploadSession
+	for rows.Next() {
+		session, err := scanUploadSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *uploadSessionRepository) Delete(ctx context.Context, id uint) error {
+	query := `DELETE FROM upload_sessions WHERE id = $1`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	return err
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type userBlockRepository struct {
+	db *sql.DB
+}
+
+func NewUserBlockRepository(db *sql.DB) domain.UserBlockRepository {
+	return &userBlockRepository{db: db}
+}
+
+func (r *userBlockRepository) Create(ctx context.Context, blockerID, blockedID uint) error {
+	query := `
+        INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (blocker_id, blocked_id) DO NOTHING`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+func (r *userBlockRepository) Delete(ctx context.Context, blockerID, blockedID uint) error {
+	query := `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, blockerID, blockedID)
+	return err
+}
+
+func (r *userBlockRepository) GetByBlockerID(ctx context.Context, blockerID uint) ([]domain.UserBlock, error) {
+	query := `
+        SELECT
+            b.blocker_id, b.blocked_id, b.created_at,
+            u.id, u.username, COALESCE(u.avatar_url, '') as avatar_url
+        FROM user_blocks b
+        JOIN users u ON b.blocked_id = u.id
+        WHERE b.blocker_id = $1
+        ORDER BY b.created_at DESC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []domain.UserBlock
+	for rows.Next() {
+		block := domain.UserBlock{
+			User: &domain.User{},
+		}
+		err := rows.Scan(
+			&block.BlockerID,
+			&block.BlockedID,
+			&block.CreatedAt,
+			&block.User.ID,
+			&block.User.Username,
+			&block.User.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+func (r *userBlockRepository) ExistsBetween(ctx context.Context, userID, otherUserID uint) (bool, error) {
+	query := `
+        SELECT EXISTS(
+            SELECT 1 FROM user_blocks
+            WHERE (blocker_id = $1 AND blocked_id = $2)
+               OR (blocker_id = $2 AND blocked_id = $1)
+        )
+    `
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, userID, otherUserID).Scan(&exists)
+	return exists, err
+}
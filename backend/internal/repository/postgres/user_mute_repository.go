@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type userMuteRepository struct {
+	db *sql.DB
+}
+
+func NewUserMuteRepository(db *sql.DB) domain.UserMuteRepository {
+	return &userMuteRepository{db: db}
+}
+
+func (r *userMuteRepository) Create(ctx context.Context, muterID, mutedID uint) error {
+	query := `
+        INSERT INTO user_mutes (muter_id, muted_id, created_at)
+        VALUES ($1, $2, NOW())
+        ON CONFLICT (muter_id, muted_id) DO NOTHING`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (r *userMuteRepository) Delete(ctx context.Context, muterID, mutedID uint) error {
+	query := `DELETE FROM user_mutes WHERE muter_id = $1 AND muted_id = $2`
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, muterID, mutedID)
+	return err
+}
+
+func (r *userMuteRepository) GetByMuterID(ctx context.Context, muterID uint) ([]domain.UserMute, error) {
+	query := `
+        SELECT
+            m.muter_id, m.muted_id, m.created_at,
+            u.id, u.username, COALESCE(u.avatar_url, '') as avatar_url
+        FROM user_mutes m
+        JOIN users u ON m.muted_id = u.id
+        WHERE m.muter_id = $1
+        ORDER BY m.created_at DESC`
+
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, muterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mutes []domain.UserMute
+	for rows.Next() {
+		mute := domain.UserMute{
+			User: &domain.User{},
+		}
+		err := rows.Scan(
+			&mute.MuterID,
+			&mute.MutedID,
+			&mute.CreatedAt,
+			&mute.User.ID,
+			&mute.User.Username,
+			&mute.User.AvatarURL,
+		)
+		if err != nil {
+			return nil, err
+		}
+		mutes = append(mutes, mute)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mutes, nil
+}
+
+func (r *userMuteRepository) IsMuted(ctx context.Context, muterID, mutedID uint) (bool, error) {
+	query := `
+        SELECT EXISTS(
+            SELECT 1 FROM user_mutes
+            WHERE muter_id = $1 AND muted_id = $2
+        )
+    `
+	var exists bool
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, muterID, mutedID).Scan(&exists)
+	return exists, err
+}
@@ -1,8 +1,8 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"errors"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 )
@@ -15,13 +15,14 @@ func NewUserRepository(db *sql.DB) domain.UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *domain.User) error {
+func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
 	query := `
         INSERT INTO users (username, email, password, created_at, updated_at)
         VALUES ($1, $2, $3, $4, $5)
         RETURNING id`
 
-	return r.db.QueryRow(
+	err := conn(ctx, r.db).QueryRowContext(
+		ctx,
 		query,
 		user.Username,
 		user.Email,
@@ -29,19 +30,23 @@ func (r *userRepository) Create(user *domain.User) error {
 		user.CreatedAt,
 		user.UpdatedAt,
 	).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("username or email is already taken")
+	}
+	return err
 }
 
-func (r *userRepository) GetByID(id uint) (*domain.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*domain.User, error) {
 	user := &domain.User{}
 	query := `
         SELECT id, username, email, password, 
                COALESCE(bio, '') as bio,
                COALESCE(avatar_url, '') as avatar_url,
                COALESCE(post_count, 0) as post_count,
-               created_at, updated_at 
+               role, email_verified_at, token_version, created_at, updated_at 
         FROM users WHERE id = $1`
 
-	err := r.db.QueryRow(query, id).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -49,31 +54,35 @@ func (r *userRepository) GetByID(id uint) (*domain.User, error) {
 		&user.Bio,
 		&user.AvatarURL,
 		&user.PostCount,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.TokenVersion,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, domain.NotFound("user not found")
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	user.EmailVerified = user.EmailVerifiedAt != nil
 	return user, nil
 }
 
-func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.User, error) {
 	user := &domain.User{}
 	query := `
         SELECT id, username, email, password, 
                COALESCE(bio, '') as bio,
                COALESCE(avatar_url, '') as avatar_url,
                COALESCE(post_count, 0) as post_count,
-               created_at, updated_at 
+               role, email_verified_at, token_version, created_at, updated_at 
         FROM users WHERE email = $1`
 
-	err := r.db.QueryRow(query, email).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, email).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -81,27 +90,31 @@ func (r *userRepository) GetByEmail(email string) (*domain.User, error) {
 		&user.Bio,
 		&user.AvatarURL,
 		&user.PostCount,
+		&user.Role,
+		&user.EmailVerifiedAt,
+		&user.TokenVersion,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, domain.NotFound("user not found")
 	}
 	if err != nil {
 		return nil, err
 	}
 
+	user.EmailVerified = user.EmailVerifiedAt != nil
 	return user, nil
 }
 
-func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*domain.User, error) {
 	user := &domain.User{}
 	query := `
-        SELECT id, username, email, password, bio, avatar_url, post_count, created_at, updated_at 
+        SELECT id, username, email, password, bio, avatar_url, post_count, role, email_verified_at, created_at, updated_at 
         FROM users WHERE username = $1`
 
-	err := r.db.QueryRow(query, username).Scan(
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, username).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
@@ -109,23 +122,27 @@ func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
 		&user.Bio,
 		&user.AvatarURL,
 		&user.PostCount,
+		&user.Role,
+		&user.EmailVerifiedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, errors.New("user not found")
+		return nil, domain.NotFound("user not found")
 	}
+	user.EmailVerified = user.EmailVerifiedAt != nil
 	return user, err
 }
 
-func (r *userRepository) Update(user *domain.User) error {
+func (r *userRepository) Update(ctx context.Context, user *domain.User) error {
 	query := `
         UPDATE users 
         SET username = $1, email = $2, password = $3, bio = $4, avatar_url = $5, updated_at = $6
         WHERE id = $7`
 
-	result, err := r.db.Exec(
+	result, err := conn(ctx, r.db).ExecContext(
+		ctx,
 		query,
 		user.Username,
 		user.Email,
@@ -136,6 +153,9 @@ func (r *userRepository) Update(user *domain.User) error {
 		user.ID,
 	)
 
+	if isUniqueViolation(err) {
+		return domain.Conflict("username or email is already taken")
+	}
 	if err != nil {
 		return err
 	}
@@ -146,12 +166,12 @@ func (r *userRepository) Update(user *domain.User) error {
 	}
 
 	if rows == 0 {
-		return errors.New("user not found")
+		return domain.NotFound("user not found")
 	}
 	return nil
 }
 
-func (r *userRepository) GetUserPosts(userID uint, page, limit int) ([]domain.Post, error) {
+func (r *userRepository) GetUserPosts(ctx context.Context, userID uint, page, limit int) ([]domain.Post, error) {
 	offset := (page - 1) * limit
 	query := `
         SELECT 
@@ -159,17 +179,18 @@ func (r *userRepository) GetUserPosts(userID uint, page, limit int) ([]domain.Po
             COALESCE(p.image_url, '') as image_url, 
             COALESCE(p.link_url, '') as link_url, 
             p.likes, p.created_at, p.updated_at,
+            p.edited_at, p.edited_at IS NOT NULL as edited,
             u.id, u.username, u.email, COALESCE(u.bio, '') as bio,
             COALESCE(u.avatar_url, '') as avatar_url,
             COALESCE(u.post_count, 0) as post_count,
             u.created_at, u.updated_at
         FROM posts p
         JOIN users u ON p.user_id = u.id
-        WHERE p.user_id = $1
+        WHERE p.user_id = $1 AND p.deleted_at IS NULL
         ORDER BY p.created_at DESC
         LIMIT $2 OFFSET $3`
 
-	rows, err := r.db.Query(query, userID, limit, offset)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +210,8 @@ func (r *userRepository) GetUserPosts(userID uint, page, limit int) ([]domain.Po
 			&post.Likes,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&post.EditedAt,
+			&post.Edited,
 			&post.User.ID,
 			&post.User.Username,
 			&post.User.Email,
@@ -203,7 +226,7 @@ func (r *userRepository) GetUserPosts(userID uint, page, limit int) ([]domain.Po
 		}
 
 		// Get tags for each post
-		tags, err := r.GetPostTags(post.ID)
+		tags, err := r.GetPostTags(ctx, post.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -220,9 +243,9 @@ func (r *userRepository) GetUserPosts(userID uint, page, limit int) ([]domain.Po
 }
 
 // Helper function to get tags for a post
-func (r *userRepository) GetPostTags(postID uint) ([]string, error) {
+func (r *userRepository) GetPostTags(ctx context.Context, postID uint) ([]string, error) {
 	query := `SELECT tag FROM post_tags WHERE post_id = $1`
-	rows, err := r.db.Query(query, postID)
+	rows, err := conn(ctx, r.db).QueryContext(ctx, query, postID)
 	if err != nil {
 		return nil, err
 	}
@@ -240,12 +263,136 @@ func (r *userRepository) GetPostTags(postID uint) ([]string, error) {
 	return tags, nil
 }
 
-func (r *userRepository) UpdatePostCount(userID uint) error {
+func (r *userRepository) UpdatePostCount(ctx context.Context, userID uint) error {
 	query := `
         UPDATE users 
-        SET post_count = (SELECT COUNT(*) FROM posts WHERE user_id = $1)
+        SET post_count = (SELECT COUNT(*) FROM posts WHERE user_id = $1 AND deleted_at IS NULL)
         WHERE id = $1`
 
-	_, err := r.db.Exec(query, userID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID)
 	return err
 }
+
+// SetPassword stores a new password hash and bumps the token version,
+// returning the new version.
+func (r *userRepository) SetPassword(ctx context.Context, userID uint, hash string) (int, error) {
+	query := `
+        UPDATE users
+        SET password = $1, token_version = token_version + 1, updated_at = NOW()
+        WHERE id = $2
+        RETURNING token_version`
+
+	var version int
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, hash, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, domain.NotFound("user not found")
+	}
+	return version, err
+}
+
+func (r *userRepository) GetTokenVersion(ctx context.Context, userID uint) (int, error) {
+	var version int
+	err := conn(ctx, r.db).QueryRowContext(ctx, `SELECT token_version FROM users WHERE id = $1`, userID).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, domain.NotFound("user not found")
+	}
+	return version, err
+}
+
+// SetEmailVerified stores email as the user's address and marks it verified.
+func (r *userRepository) SetEmailVerified(ctx context.Context, userID uint, email string) error {
+	query := `
+        UPDATE users
+        SET email = $1, email_verified_at = NOW(), updated_at = NOW()
+        WHERE id = $2`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, email, userID)
+	if isUniqueViolation(err) {
+		return domain.Conflict("email is already in use")
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("user not found")
+	}
+	return nil
+}
+
+func (r *userRepository) Anonymize(ctx context.Context, id uint) error {
+	query := `
+        UPDATE users
+        SET username = 'deleted-' || id,
+            email = 'deleted-' || id || '@deleted.invalid',
+            password = '',
+            bio = NULL,
+            avatar_url = NULL,
+            role = 'member',
+            email_verified_at = NULL,
+            token_version = token_version + 1,
+            updated_at = NOW()
+        WHERE id = $1`
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("user not found")
+	}
+	return nil
+}
+
+// Delete leans on the foreign keys to cascade from users, posts and comments.
+// Databases that predate the baseline schema may lack them on the likes,
+// reports and saves tables, so those are cleared first, along with the edit
+// history of the content that is going away. Run it in a transaction.
+func (r *userRepository) Delete(ctx context.Context, id uint) error {
+	const doomed = `
+        WITH RECURSIVE doomed_posts AS (
+            SELECT id FROM posts WHERE user_id = $1
+        ), doomed_comments AS (
+            SELECT id FROM comments WHERE user_id = $1 OR post_id IN (SELECT id FROM doomed_posts)
+            UNION
+            SELECT c.id FROM comments c JOIN doomed_comments d ON c.parent_id = d.id
+        )`
+
+	queries := []string{
+		doomed + ` DELETE FROM comment_likes WHERE user_id = $1 OR comment_id IN (SELECT id FROM doomed_comments)`,
+		doomed + ` DELETE FROM comment_reports WHERE user_id = $1 OR comment_id IN (SELECT id FROM doomed_comments)`,
+		doomed + ` DELETE FROM saved_posts WHERE user_id = $1 OR post_id IN (SELECT id FROM doomed_posts)`,
+		doomed + ` DELETE FROM post_reports WHERE user_id = $1 OR post_id IN (SELECT id FROM doomed_posts)`,
+		doomed + ` DELETE FROM content_revisions
+            WHERE (target_type = 'post' AND target_id IN (SELECT id FROM doomed_posts))
+               OR (target_type = 'comment' AND target_id IN (SELECT id FROM doomed_comments))`,
+	}
+	for _, query := range queries {
+		if _, err := conn(ctx, r.db).ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := conn(ctx, r.db).ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.NotFound("user not found")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type userTokenRepository struct {
+	db *sql.DB
+}
+
+func NewUserTokenRepository(db *sql.DB) domain.UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Create(ctx context.Context, token *domain.UserToken) error {
+	query := `
+        INSERT INTO user_tokens (user_id, purpose, token_hash, new_email, expires_at, created_at)
+        VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+        RETURNING id`
+
+	return conn(ctx, r.db).QueryRowContext(
+		ctx,
+		query,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.NewEmail,
+		token.ExpiresAt,
+		token.CreatedAt,
+	).Scan(&token.ID)
+}
+
+func (r *userTokenRepository) GetByHash(ctx context.Context, purpose, tokenHash string) (*domain.UserToken, error) {
+	query := `
+        SELECT id, user_id, purpose, token_hash, COALESCE(new_email, ''),
+               expires_at, used_at, created_at
+        FROM user_tokens
+        WHERE purpose = $1 AND token_hash = $2`
+
+	token := &domain.UserToken{}
+	err := conn(ctx, r.db).QueryRowContext(ctx, query, purpose, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.NewEmail,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.NotFound("token not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (r *userTokenRepository) MarkUsed(ctx context.Context, id uint) error {
+	query := `UPDATE user_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`
+	result, err := conn(ctx, r.db).ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return domain.Conflict("token has already been used")
+	}
+
+	return nil
+}
+
+func (r *userTokenRepository) InvalidateForUser(ctx context.Context, userID uint, purpose string) error {
+	query := `
+        UPDATE user_tokens SET used_at = NOW()
+        WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, userID, purpose)
+	return err
+}
@@ -0,0 +1,19 @@
+package postgres
+
+import "fmt"
+
+// visibleAuthorFilter returns a SQL predicate that is true when content written
+// by authorColumn should be shown to the viewer bound at viewerParam: neither
+// user has blocked the other and the viewer has not muted the author. A zero
+// viewer matches no block or mute rows, so anonymous reads are unaffected.
+func visibleAuthorFilter(authorColumn, viewerParam string) string {
+	return fmt.Sprintf(`NOT EXISTS (
+            SELECT 1 FROM user_blocks b
+            WHERE (b.blocker_id = %[2]s AND b.blocked_id = %[1]s)
+               OR (b.blocker_id = %[1]s AND b.blocked_id = %[2]s)
+        )
+        AND NOT EXISTS (
+            SELECT 1 FROM user_mutes m
+            WHERE m.muter_id = %[2]s AND m.muted_id = %[1]s
+        )`, authorColumn, viewerParam)
+}
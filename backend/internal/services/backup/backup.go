@@ -0,0 +1,481 @@
+// Package backup exports the community's data to an object store as
+// versioned, compressed snapshots, prunes old snapshots and restores a
+// database from one.
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ruth987/CHub.git/pkg/storage"
+)
+
+// Table is a table included in snapshots
+type Table struct {
+	Name string
+	// OrderBy puts rows that others point at first, such as parent comments
+	// before their replies
+	OrderBy string
+}
+
+// Tables are exported and restored in this order, so every foreign key
+// points at a row that is already there
+var Tables = []Table{
+	{Name: "users", OrderBy: "id"},
+	{Name: "posts", OrderBy: "id"},
+	{Name: "post_tags", OrderBy: "post_id, tag"},
+	{Name: "post_likes", OrderBy: "post_id, user_id"},
+	{Name: "post_attachments", OrderBy: "post_id, position"},
+	{Name: "comments", OrderBy: "id"},
+	{Name: "prayer_requests", OrderBy: "id"},
+}
+
+const (
+	// keyPrefix is where snapshots are kept in the store
+	keyPrefix = "snapshots/"
+	// keyTime is the timestamp layout in snapshot keys, which makes key
+	// order and time order the same
+	keyTime = "20060102T150405Z"
+	keyExt  = ".jsonl.gz"
+
+	// lockID keeps two API instances from exporting at once
+	lockID = 0x43487562_00000001
+	// restoreBatch is how many rows are inserted per statement
+	restoreBatch = 500
+)
+
+// ErrInProgress is returned by Create while another instance is exporting
+var ErrInProgress = errors.New("another backup is in progress")
+
+// Snapshot is one stored export
+type Snapshot struct {
+	Key       string    `json:"key"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+type Service struct {
+	db     *sql.DB
+	store  storage.ObjectStore
+	logger *slog.Logger
+}
+
+// NewService returns a service keeping snapshots in store. The store must not
+// be the public uploads store: snapshots hold password hashes and emails.
+func NewService(db *sql.DB, store storage.ObjectStore, logger *slog.Logger) *Service {
+	return &Service{
+		db:     db,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Create exports every table from one consistent view of the database and
+// stores the snapshot. Tables that don't exist in this database are left out.
+func (s *Service) Create(ctx context.Context) (*Snapshot, error) {
+	tmp, err := os.CreateTemp("", "chub-backup-*"+keyExt)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	if err := s.export(ctx, tmp, createdAt); err != nil {
+		return nil, err
+	}
+
+	// The store gets a file of known length rather than a stream, which S3
+	// needs to sign the upload
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{
+		Key:       keyPrefix + "chub-" + createdAt.Format(keyTime) + keyExt,
+		CreatedAt: createdAt,
+		Size:      size,
+	}
+	if err := s.store.Put(ctx, snapshot.Key, tmp, "application/gzip"); err != nil {
+		return nil, fmt.Errorf("failed to store backup: %w", err)
+	}
+	return snapshot, nil
+}
+
+func (s *Service) export(ctx context.Context, w io.Writer, createdAt time.Time) error {
+	// Repeatable read gives every table the same view of the data
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked bool
+	if err := tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, int64(lockID)).Scan(&locked); err != nil {
+		return err
+	}
+	if !locked {
+		return ErrInProgress
+	}
+	// A large table takes longer to read than the API's statement timeout
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return err
+	}
+
+	var tables []Table
+	for _, table := range Tables {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, table.Name).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			s.logger.WarnContext(ctx, "backup skipping missing table", "table", table.Name)
+			continue
+		}
+		tables = append(tables, table)
+	}
+
+	gz := gzip.NewWriter(w)
+	out := newWriter(gz)
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	if err := out.write(line{Type: lineHeader, Format: FormatName, Version: FormatVersion, CreatedAt: &createdAt, Tables: names}); err != nil {
+		return err
+	}
+
+	var total int64
+	for _, table := range tables {
+		rows, err := exportTable(ctx, tx, out, table)
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %w", table.Name, err)
+		}
+		total += rows
+	}
+
+	if err := out.write(line{Type: lineTrailer, Rows: total}); err != nil {
+		return err
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func exportTable(ctx context.Context, tx *sql.Tx, out *writer, table Table) (int64, error) {
+	columns, err := tableColumns(ctx, tx, table.Name)
+	if err != nil {
+		return 0, err
+	}
+	if err := out.write(line{Type: lineTable, Name: table.Name, Columns: columns}); err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf(`SELECT to_jsonb(t) FROM (SELECT %s FROM %s ORDER BY %s) t`,
+		quoteAll(columns), pq.QuoteIdentifier(table.Name), table.OrderBy)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var count int64
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return 0, err
+		}
+		if err := out.row(data); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	return count, out.write(line{Type: lineTableEnd, Name: table.Name, Rows: count})
+}
+
+// List returns the stored snapshots, oldest first
+func (s *Service) List(ctx context.Context) ([]Snapshot, error) {
+	objects, err := s.store.List(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	for _, object := range objects {
+		name, ok := strings.CutPrefix(object.Key, keyPrefix+"chub-")
+		if !ok || !strings.HasSuffix(name, keyExt) {
+			continue
+		}
+		createdAt, err := time.Parse(keyTime, strings.TrimSuffix(name, keyExt))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, Snapshot{Key: object.Key, CreatedAt: createdAt, Size: object.Size})
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return snapshots, nil
+}
+
+// Prune deletes the snapshots policy doesn't keep and returns them
+func (s *Service) Prune(ctx context.Context, policy Policy) ([]Snapshot, error) {
+	snapshots, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []Snapshot
+	for _, snapshot := range policy.Expired(snapshots) {
+		if err := s.store.Delete(ctx, snapshot.Key); err != nil {
+			return deleted, fmt.Errorf("failed to delete backup %s: %w", snapshot.Key, err)
+		}
+		deleted = append(deleted, snapshot)
+	}
+	return deleted, nil
+}
+
+// RestoreOptions control Restore
+type RestoreOptions struct {
+	// Force empties the tables first. Without it Restore refuses to write
+	// into a table that has rows. Emptying cascades to every table that
+	// references them, such as messages and saved posts.
+	Force bool
+}
+
+// RestoreReport counts what Restore wrote
+type RestoreReport struct {
+	Snapshot Snapshot
+	Rows     map[string]int64
+}
+
+// Restore loads a snapshot into the database, whose schema must already be
+// migrated. Everything happens in one transaction, so a bad or truncated
+// snapshot leaves the database as it was. Columns the snapshot has but the
+// schema dropped are skipped; columns added since take their defaults.
+func (s *Service) Restore(ctx context.Context, snapshot Snapshot, opts RestoreOptions) (*RestoreReport, error) {
+	object, err := s.store.Get(ctx, snapshot.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer object.Close()
+
+	gz, err := gzip.NewReader(object)
+	if err != nil {
+		return nil, fmt.Errorf("backup is not gzip-compressed: %w", err)
+	}
+	defer gz.Close()
+	in := newReader(gz)
+
+	header, err := in.expect(lineHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup header: %w", err)
+	}
+	if header.Format != FormatName {
+		return nil, fmt.Errorf("not a %s snapshot", FormatName)
+	}
+	if header.Version < 1 || header.Version > FormatVersion {
+		return nil, fmt.Errorf("snapshot format version %d is not supported; this build reads up to %d", header.Version, FormatVersion)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return nil, err
+	}
+	if err := prepareTables(ctx, tx, header.Tables, opts.Force); err != nil {
+		return nil, err
+	}
+
+	report := &RestoreReport{Snapshot: snapshot, Rows: make(map[string]int64)}
+	var total int64
+	for range header.Tables {
+		table, err := in.expect(lineTable)
+		if err != nil {
+			return nil, err
+		}
+		count, err := s.restoreTable(ctx, tx, in, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", table.Name, err)
+		}
+		report.Rows[table.Name] = count
+		total += count
+	}
+
+	trailer, err := in.expect(lineTrailer)
+	if err != nil {
+		return nil, err
+	}
+	if trailer.Rows != total {
+		return nil, fmt.Errorf("snapshot is inconsistent: trailer counts %d rows, found %d", trailer.Rows, total)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// prepareTables checks that every table exists and is empty, or with force
+// empties them
+func prepareTables(ctx context.Context, tx *sql.Tx, tables []string, force bool) error {
+	for _, name := range tables {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("table %s does not exist; run the migrations first", name)
+		}
+	}
+
+	if force {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(`TRUNCATE %s RESTART IDENTITY CASCADE`, quoteAll(tables)))
+		return err
+	}
+	for _, name := range tables {
+		var hasRows bool
+		query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s)`, pq.QuoteIdentifier(name))
+		if err := tx.QueryRowContext(ctx, query).Scan(&hasRows); err != nil {
+			return err
+		}
+		if hasRows {
+			return fmt.Errorf("table %s is not empty; restore into an empty database or force it", name)
+		}
+	}
+	return nil
+}
+
+func (s *Service) restoreTable(ctx context.Context, tx *sql.Tx, in *reader, table *line) (int64, error) {
+	current, err := tableColumns(ctx, tx, table.Name)
+	if err != nil {
+		return 0, err
+	}
+	var columns []string
+	for _, column := range table.Columns {
+		if slices.Contains(current, column) {
+			columns = append(columns, column)
+		} else {
+			s.logger.WarnContext(ctx, "restore skipping dropped column", "table", table.Name, "column", column)
+		}
+	}
+
+	// Rows go in as a JSON array that Postgres turns back into typed rows
+	query := fmt.Sprintf(`INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM jsonb_populate_recordset(NULL::%[1]s, $1::jsonb)`,
+		pq.QuoteIdentifier(table.Name), quoteAll(columns))
+
+	var count int64
+	var batch [][]byte
+	insert := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		data := "[" + string(bytes.Join(batch, []byte(","))) + "]"
+		_, err := tx.ExecContext(ctx, query, data)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		l, err := in.next()
+		if err != nil {
+			return 0, err
+		}
+		if l.Type == lineTableEnd {
+			if l.Name != table.Name || l.Rows != count {
+				return 0, fmt.Errorf("table ends after %d rows, snapshot says %d", count, l.Rows)
+			}
+			break
+		}
+		if l.Type != lineRow {
+			return 0, fmt.Errorf("unexpected %s line", l.Type)
+		}
+		batch = append(batch, l.Data)
+		count++
+		if len(batch) == restoreBatch {
+			if err := insert(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := insert(); err != nil {
+		return 0, err
+	}
+
+	return count, resetSequence(ctx, tx, table.Name)
+}
+
+// resetSequence moves a table's id sequence past the restored rows so new
+// rows don't collide with them
+func resetSequence(ctx context.Context, tx *sql.Tx, table string) error {
+	var sequence sql.NullString
+	err := tx.QueryRowContext(ctx, `
+        SELECT pg_get_serial_sequence($1, 'id')
+        WHERE EXISTS (
+            SELECT 1 FROM information_schema.columns
+            WHERE table_schema = current_schema() AND table_name = $1 AND column_name = 'id'
+        )`, table).Scan(&sequence)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !sequence.Valid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`SELECT setval($1, COALESCE(MAX(id), 0) + 1, false) FROM %s`, pq.QuoteIdentifier(table))
+	_, err = tx.ExecContext(ctx, query, sequence.String)
+	return err
+}
+
+// tableColumns lists the columns of a table that can be written, in order.
+// Generated columns are recomputed on restore.
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+        SELECT column_name
+        FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = $1 AND is_generated = 'NEVER'
+        ORDER BY ordinal_position`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pq.QuoteIdentifier(name)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,120 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// A snapshot is gzip-compressed JSON Lines. It opens with a header, then each
+// table in restore order: a line naming its columns, one line per row holding
+// the row as Postgres' to_jsonb renders it, and an end line with the row
+// count. A trailer closes the file, so a truncated upload is refused rather
+// than half restored.
+const (
+	FormatName = "chub-backup"
+	// FormatVersion changes whenever a reader of the previous version would
+	// misread a snapshot. Restore accepts this version and older ones.
+	FormatVersion = 1
+)
+
+// Kinds of line in a snapshot
+const (
+	lineHeader   = "header"
+	lineTable    = "table"
+	lineRow      = "row"
+	lineTableEnd = "table_end"
+	lineTrailer  = "trailer"
+)
+
+// line is any line of a snapshot; which fields are set depends on Type
+type line struct {
+	Type string `json:"type"`
+
+	// header
+	Format    string     `json:"format,omitempty"`
+	Version   int        `json:"version,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Tables    []string   `json:"tables,omitempty"`
+
+	// table and table_end
+	Name    string   `json:"name,omitempty"`
+	Columns []string `json:"columns,omitempty"`
+	Rows    int64    `json:"rows,omitempty"`
+
+	// row
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// writer writes the lines of a snapshot
+type writer struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newWriter(w io.Writer) *writer {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	return &writer{w: bw, enc: json.NewEncoder(bw)}
+}
+
+func (w *writer) write(l line) error {
+	return w.enc.Encode(l)
+}
+
+// row writes a row without decoding it; data is already JSON
+func (w *writer) row(data []byte) error {
+	w.w.WriteString(`{"type":"row","data":`)
+	w.w.Write(data)
+	_, err := w.w.WriteString("}\n")
+	return err
+}
+
+func (w *writer) flush() error {
+	return w.w.Flush()
+}
+
+// reader reads the lines of a snapshot
+type reader struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newReader(r io.Reader) *reader {
+	return &reader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// next returns the next line, or io.ErrUnexpectedEOF if the file ends before
+// its trailer
+func (r *reader) next() (*line, error) {
+	data, err := r.r.ReadBytes('\n')
+	if errors.Is(err, io.EOF) {
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	r.line++
+
+	var l line
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("line %d: %w", r.line, err)
+	}
+	return &l, nil
+}
+
+// expect returns the next line, failing unless it is of type want
+func (r *reader) expect(want string) (*line, error) {
+	l, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if l.Type != want {
+		return nil, fmt.Errorf("line %d: expected %s, got %s", r.line, want, l.Type)
+	}
+	return l, nil
+}
@@ -0,0 +1,61 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy decides which snapshots to keep, in the style of restic's forget:
+// the newest KeepLast, plus the newest snapshot of each of the last
+// KeepDaily days, KeepWeekly ISO weeks and KeepMonthly months that have one.
+// A snapshot kept by any rule stays.
+type Policy struct {
+	KeepLast    int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+}
+
+// Expired returns the snapshots the policy doesn't keep, given them oldest
+// first as List returns them. The newest snapshot is always kept.
+func (p Policy) Expired(snapshots []Snapshot) []Snapshot {
+	rules := []struct {
+		keep   int
+		bucket func(time.Time) string
+	}{
+		{p.KeepLast, func(t time.Time) string { return t.String() }},
+		{p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+
+	kept := make(map[string]bool)
+	for _, rule := range rules {
+		count := 0
+		last := ""
+		// Newest first, so each bucket is represented by its latest snapshot
+		for i := len(snapshots) - 1; i >= 0 && count < rule.keep; i-- {
+			bucket := rule.bucket(snapshots[i].CreatedAt.UTC())
+			if bucket == last {
+				continue
+			}
+			last = bucket
+			kept[snapshots[i].Key] = true
+			count++
+		}
+	}
+	if len(snapshots) > 0 {
+		kept[snapshots[len(snapshots)-1].Key] = true
+	}
+
+	var expired []Snapshot
+	for _, snapshot := range snapshots {
+		if !kept[snapshot.Key] {
+			expired = append(expired, snapshot)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,299 @@
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// Scrub rewrites the stored snapshots without the erased accounts, so that
+// restoring an old snapshot doesn't bring them back. Anonymized accounts are
+// anonymized in the snapshot as they were in the database; removed ones are
+// dropped along with their posts and comments and everything hanging off
+// them. Snapshots that don't mention the accounts are left alone.
+func (s *Service) Scrub(ctx context.Context, erasures []domain.AccountErasure) error {
+	if len(erasures) == 0 {
+		return nil
+	}
+	modes := make(map[uint]string, len(erasures))
+	for _, erasure := range erasures {
+		modes[erasure.UserID] = erasure.Mode
+	}
+
+	snapshots, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		if err := s.scrubSnapshot(ctx, snapshot, modes); err != nil {
+			return fmt.Errorf("failed to scrub backup %s: %w", snapshot.Key, err)
+		}
+	}
+	return nil
+}
+
+// scrubRow holds the fields of a row that decide whether it is scrubbed.
+// Tables without a field leave it zero.
+type scrubRow struct {
+	ID       uint   `json:"id"`
+	UserID   uint   `json:"user_id"`
+	PostID   uint   `json:"post_id"`
+	ParentID uint   `json:"parent_id"`
+	Username string `json:"username"`
+}
+
+// scrubber filters the rows of one snapshot
+type scrubber struct {
+	// modes maps erased users to how they were erased
+	modes map[uint]string
+	// likes counts the likes erased users gave each post, which come off its
+	// like count
+	likes map[uint]int64
+	// posts and comments are those dropped so far; rows pointing at them go
+	// too
+	posts    map[uint]bool
+	comments map[uint]bool
+}
+
+// affected reports whether a row still holds an erased account's data
+func (sc *scrubber) affected(table string, row *scrubRow) bool {
+	switch table {
+	case "users":
+		mode, ok := sc.modes[row.ID]
+		return ok && (mode == domain.DeletionRemove || row.Username != anonymousName(row.ID))
+	case "post_likes":
+		_, ok := sc.modes[row.UserID]
+		return ok
+	}
+	return false
+}
+
+// filter returns the row as it should be written, or nil to drop it. Tables
+// come in restore order, so a row's parents have been seen before it.
+func (sc *scrubber) filter(table string, data []byte) ([]byte, error) {
+	var row scrubRow
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+
+	switch table {
+	case "users":
+		switch sc.modes[row.ID] {
+		case domain.DeletionRemove:
+			return nil, nil
+		case domain.DeletionAnonymize:
+			return anonymizeRow(data, row.ID)
+		}
+	case "posts":
+		if sc.modes[row.UserID] == domain.DeletionRemove {
+			sc.posts[row.ID] = true
+			return nil, nil
+		}
+		if n := sc.likes[row.ID]; n > 0 {
+			return adjustLikes(data, n)
+		}
+	case "post_tags", "post_attachments":
+		if sc.posts[row.PostID] {
+			return nil, nil
+		}
+	case "post_likes":
+		if _, ok := sc.modes[row.UserID]; ok || sc.posts[row.PostID] {
+			return nil, nil
+		}
+	case "comments":
+		if sc.modes[row.UserID] == domain.DeletionRemove || sc.posts[row.PostID] || sc.comments[row.ParentID] {
+			sc.comments[row.ID] = true
+			return nil, nil
+		}
+	}
+	return data, nil
+}
+
+// scrubSnapshot reads the snapshot once to see whether it needs scrubbing
+// and how like counts change, then writes the scrubbed copy over it
+func (s *Service) scrubSnapshot(ctx context.Context, snapshot Snapshot, modes map[uint]string) error {
+	sc := &scrubber{
+		modes:    modes,
+		likes:    make(map[uint]int64),
+		posts:    make(map[uint]bool),
+		comments: make(map[uint]bool),
+	}
+
+	affected := false
+	err := s.readSnapshot(ctx, snapshot.Key, func(table string, l *line) error {
+		if l.Type != lineRow {
+			return nil
+		}
+		var row scrubRow
+		if err := json.Unmarshal(l.Data, &row); err != nil {
+			return err
+		}
+		if sc.affected(table, &row) {
+			affected = true
+			if table == "post_likes" {
+				sc.likes[row.PostID]++
+			}
+		}
+		return nil
+	})
+	if err != nil || !affected {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "chub-backup-*"+keyExt)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	gz := gzip.NewWriter(tmp)
+	out := newWriter(gz)
+	var rows, total int64
+	err = s.readSnapshot(ctx, snapshot.Key, func(table string, l *line) error {
+		switch l.Type {
+		case lineRow:
+			data, err := sc.filter(table, l.Data)
+			if err != nil || data == nil {
+				return err
+			}
+			rows++
+			return out.row(data)
+		case lineTable:
+			rows = 0
+		case lineTableEnd:
+			l.Rows = rows
+			total += rows
+		case lineTrailer:
+			l.Rows = total
+		}
+		return out.write(*l)
+	})
+	if err != nil {
+		return err
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.store.Put(ctx, snapshot.Key, tmp, "application/gzip"); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "scrubbed backup", "key", snapshot.Key)
+	return nil
+}
+
+// readSnapshot calls fn with every line of a snapshot and the table it
+// belongs to, checking the file is whole
+func (s *Service) readSnapshot(ctx context.Context, key string, fn func(table string, l *line) error) error {
+	object, err := s.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer object.Close()
+
+	gz, err := gzip.NewReader(object)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	in := newReader(gz)
+
+	header, err := in.expect(lineHeader)
+	if err != nil {
+		return err
+	}
+	if header.Format != FormatName || header.Version < 1 || header.Version > FormatVersion {
+		return fmt.Errorf("not a %s snapshot this build can read", FormatName)
+	}
+	if err := fn("", header); err != nil {
+		return err
+	}
+
+	table := ""
+	for {
+		l, err := in.next()
+		if err != nil {
+			return err
+		}
+		switch l.Type {
+		case lineTable:
+			table = l.Name
+		case lineTrailer:
+			return fn("", l)
+		}
+		if err := fn(table, l); err != nil {
+			return err
+		}
+	}
+}
+
+// anonymousName is the username Anonymize gives an account
+func anonymousName(id uint) string {
+	return "deleted-" + strconv.FormatUint(uint64(id), 10)
+}
+
+// anonymizeRow clears a users row the way the user repository's Anonymize
+// does, bumping the token version so a restore doesn't revive old tokens.
+// Columns the snapshot lacks stay missing.
+func anonymizeRow(data []byte, id uint) ([]byte, error) {
+	var row struct {
+		TokenVersion int `json:"token_version"`
+	}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	name := anonymousName(id)
+	return setFields(data, map[string]any{
+		"token_version":     row.TokenVersion + 1,
+		"username":          name,
+		"email":             name + "@deleted.invalid",
+		"password":          "",
+		"bio":               nil,
+		"avatar_url":        nil,
+		"role":              domain.RoleMember,
+		"email_verified_at": nil,
+	})
+}
+
+// adjustLikes takes n off a posts row's like count
+func adjustLikes(data []byte, n int64) ([]byte, error) {
+	var row struct {
+		Likes int64 `json:"likes"`
+	}
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	return setFields(data, map[string]any{"likes": max(row.Likes-n, 0)})
+}
+
+// setFields replaces the fields of a JSON object that it already has
+func setFields(data []byte, fields map[string]any) ([]byte, error) {
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data, &row); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		if _, ok := row[name]; !ok {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		row[name] = encoded
+	}
+	return json.Marshal(row)
+}
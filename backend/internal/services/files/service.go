@@ -0,0 +1,71 @@
+package files
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/storage"
+)
+
+// activityLogPrefix is where activity logs were written before the audit log
+// moved into the database. Old objects are named
+// logs/<activity>/<username>_<unix time>.txt.
+const activityLogPrefix = "logs/"
+
+// Service gives handlers access to the configured object store.
+type Service struct {
+	store storage.ObjectStore
+}
+
+func NewService(store storage.ObjectStore) *Service {
+	return &Service{
+		store: store,
+	}
+}
+
+// Ping checks that the object store is reachable
+func (s *Service) Ping(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
+// DeleteFile deletes a stored file
+func (s *Service) DeleteFile(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// GetFile retrieves a stored file
+func (s *Service) GetFile(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.store.Get(ctx, key)
+}
+
+// Scrub deletes the activity logs left behind by erased accounts
+func (s *Service) Scrub(ctx context.Context, erasures []domain.AccountErasure) error {
+	erased := make(map[string]bool, len(erasures))
+	for _, erasure := range erasures {
+		if erasure.Username != "" {
+			erased[erasure.Username] = true
+		}
+	}
+	if len(erased) == 0 {
+		return nil
+	}
+
+	objects, err := s.store.List(ctx, activityLogPrefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		name := strings.TrimSuffix(path.Base(object.Key), ".txt")
+		i := strings.LastIndexByte(name, '_')
+		if i < 0 || !erased[name[:i]] {
+			continue
+		}
+		if err := s.store.Delete(ctx, object.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
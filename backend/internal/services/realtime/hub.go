@@ -0,0 +1,87 @@
+package realtime
+
+import (
+	"sync"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// subscriberBuffer is how many events a slow connection may fall behind
+// before further events are dropped; clients recover by polling.
+const subscriberBuffer = 32
+
+// Hub fans message events out to every live connection a user has open.
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[uint]map[chan domain.MessageEvent]struct{}
+	closed      bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[uint]map[chan domain.MessageEvent]struct{}),
+	}
+}
+
+// Subscribe registers a connection for userID. The returned function must be
+// called once the connection closes.
+func (h *Hub) Subscribe(userID uint) (<-chan domain.MessageEvent, func()) {
+	ch := make(chan domain.MessageEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan domain.MessageEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		// Close may already have ended the subscription
+		if _, ok := h.subscribers[userID][ch]; !ok {
+			return
+		}
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+		close(ch)
+	}
+
+	return ch, unsubscribe
+}
+
+// Close ends every subscription so open streams return, and turns away new
+// ones. It is called when the server shuts down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userID, channels := range h.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+		delete(h.subscribers, userID)
+	}
+}
+
+// Publish implements domain.MessagePublisher. It never blocks the caller.
+func (h *Hub) Publish(userID uint, event domain.MessageEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/mailer"
+	"github.com/ruth987/CHub.git/pkg/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// exportStale is how long an export may run before another worker takes
+	// it over
+	exportStale = time.Hour
+	// exportBatch bounds how many expired exports one sweep removes
+	exportBatch = 100
+	// erasureBatch bounds how many accounts one run erases or scrubs
+	erasureBatch = 50
+	// scrubDelay holds back scrubbing after an erasure. A backup that began
+	// before the erasure is only stored after it, and must be there to be
+	// scrubbed.
+	scrubDelay = 6 * time.Hour
+)
+
+// exportReadme opens every data export
+const exportReadme = `This archive holds the data CHub stored about your account as of %s.
+
+Each .json file is one kind of record: your profile, your posts and
+comments, the likes, saves, blocks and mutes you made, the messages you
+sent, your uploads and sermon media, and the account activity recorded in
+the audit log. The media folder holds the files you uploaded.
+
+Prayer requests are not included: they are posted anonymously and are not
+linked to any account.
+`
+
+type accountUsecase struct {
+	accountRepo domain.AccountRepository
+	userRepo    domain.UserRepository
+	uploadRepo  domain.UploadRepository
+	auditRepo   domain.AuditRepository
+	audit       domain.AuditUsecase
+	transactor  domain.Transactor
+	// uploads holds the files users uploaded; exports go to the private
+	// store, as they hold emails and IP addresses
+	uploads storage.ObjectStore
+	private storage.ObjectStore
+	// scrubbers remove erased accounts from copies kept outside the database
+	scrubbers []domain.Scrubber
+	mailer    mailer.Mailer
+	// grace is how long a requested deletion waits before it is carried out
+	grace time.Duration
+	// exportTTL is how long a finished export can be downloaded
+	exportTTL time.Duration
+	logger    *slog.Logger
+}
+
+func NewAccountUsecase(
+	accountRepo domain.AccountRepository,
+	userRepo domain.UserRepository,
+	uploadRepo domain.UploadRepository,
+	auditRepo domain.AuditRepository,
+	audit domain.AuditUsecase,
+	tx domain.Transactor,
+	uploads storage.ObjectStore,
+	private storage.ObjectStore,
+	scrubbers []domain.Scrubber,
+	m mailer.Mailer,
+	grace time.Duration,
+	exportTTL time.Duration,
+	logger *slog.Logger,
+) domain.AccountUsecase {
+	return &accountUsecase{
+		accountRepo: accountRepo,
+		userRepo:    userRepo,
+		uploadRepo:  uploadRepo,
+		auditRepo:   auditRepo,
+		audit:       audit,
+		transactor:  tx,
+		uploads:     uploads,
+		private:     private,
+		scrubbers:   scrubbers,
+		mailer:      m,
+		grace:       grace,
+		exportTTL:   exportTTL,
+		logger:      logger,
+	}
+}
+
+func (u *accountUsecase) RequestExport(ctx context.Context, userID uint) (*domain.DataExport, error) {
+	export := &domain.DataExport{
+		UserID:    userID,
+		Status:    domain.DataExportPending,
+		CreatedAt: time.Now(),
+	}
+	if err := u.accountRepo.CreateExport(ctx, export); err != nil {
+		return nil, err
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditDataExport,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+	})
+	return export, nil
+}
+
+func (u *accountUsecase) GetExport(ctx context.Context, userID uint) (*domain.DataExport, error) {
+	export, err := u.accountRepo.GetLatestExport(ctx, userID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if export != nil && (export.Status == domain.DataExportPending || export.Status == domain.DataExportRunning || downloadable(export)) {
+		return export, nil
+	}
+
+	export, err = u.RequestExport(ctx, userID)
+	if errors.Is(err, domain.ErrConflict) {
+		// Another request queued one first
+		return u.accountRepo.GetLatestExport(ctx, userID)
+	}
+	return export, err
+}
+
+func (u *accountUsecase) OpenExport(ctx context.Context, userID uint) (*domain.DataExportDownload, error) {
+	export, err := u.accountRepo.GetLatestExport(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !downloadable(export) {
+		return nil, domain.NotFound("no data export is ready to download")
+	}
+
+	body, err := u.private.Get(ctx, export.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, domain.NotFound("no data export is ready to download")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &domain.DataExportDownload{ReadCloser: body, Export: export}, nil
+}
+
+// downloadable reports whether an export is ready and not yet expired
+func downloadable(export *domain.DataExport) bool {
+	return export.Status == domain.DataExportReady && export.ExpiresAt != nil && export.ExpiresAt.After(time.Now())
+}
+
+// ProcessExports builds one export at a time. A failed export is marked so
+// and the user can ask again; the error is only logged.
+func (u *accountUsecase) ProcessExports(ctx context.Context) (int, error) {
+	built := 0
+	for {
+		export, err := u.accountRepo.ClaimExport(ctx, exportStale)
+		if err != nil {
+			return built, err
+		}
+		if export == nil {
+			return built, nil
+		}
+
+		if err := u.buildExport(ctx, export); err != nil {
+			u.logger.ErrorContext(ctx, "data export failed", "export_id", export.ID, "user_id", export.UserID, "error", err)
+			if err := u.accountRepo.FailExport(context.WithoutCancel(ctx), export.ID, "the export could not be built; please request a new one"); err != nil {
+				return built, err
+			}
+			continue
+		}
+		built++
+	}
+}
+
+func (u *accountUsecase) buildExport(ctx context.Context, export *domain.DataExport) error {
+	user, err := u.userRepo.GetByID(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+	sections, err := u.accountRepo.PersonalData(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+	uploads, err := u.uploadRepo.GetByOwnerID(ctx, export.UserID)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "chub-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	now := time.Now()
+	zw := zip.NewWriter(tmp)
+	readme, err := zw.Create("README.txt")
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(readme, exportReadme, now.UTC().Format(time.DateOnly)); err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		w, err := zw.Create(section.Name)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, section.Data, "", "  "); err != nil {
+			return fmt.Errorf("section %s: %w", section.Name, err)
+		}
+		buf.WriteByte('\n')
+		if _, err := buf.WriteTo(w); err != nil {
+			return err
+		}
+	}
+
+	for _, upload := range uploads {
+		if err := u.addUpload(ctx, zw, &upload); err != nil {
+			return fmt.Errorf("upload %d: %w", upload.ID, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("exports/%d/%d.zip", export.UserID, export.ID)
+	if err := u.private.Put(ctx, key, tmp, "application/zip"); err != nil {
+		return fmt.Errorf("failed to store export: %w", err)
+	}
+
+	expiresAt := now.Add(u.exportTTL)
+	export.Status = domain.DataExportReady
+	export.Key = key
+	export.Size = size
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+	if err := u.accountRepo.CompleteExport(ctx, export); err != nil {
+		return err
+	}
+
+	err = u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your data export is ready",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe copy of your data you asked for is ready. Download it from your account settings before %s, when it will be deleted.\n",
+			user.Username, expiresAt.UTC().Format("2 January 2006 15:04 MST"),
+		),
+	})
+	if err != nil {
+		u.logger.WarnContext(ctx, "failed to send data export email", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
+
+// addUpload copies an uploaded file into the export. Media files are
+// already compressed, so they are stored as they are. Files missing from the
+// store are left out.
+func (u *accountUsecase) addUpload(ctx context.Context, zw *zip.Writer, upload *domain.Upload) error {
+	body, err := u.uploads.Get(ctx, upload.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		u.logger.WarnContext(ctx, "data export skipping missing upload", "upload_id", upload.ID, "key", upload.Key)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("media/%d-%s", upload.ID, path.Base(upload.Key)),
+		Method:   zip.Store,
+		Modified: upload.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, body)
+	return err
+}
+
+func (u *accountUsecase) DeleteExpiredExports(ctx context.Context) (int, error) {
+	exports, err := u.accountRepo.GetExpiredExports(ctx, time.Now(), exportBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, export := range exports {
+		if err := u.deleteExportObject(ctx, export.Key); err != nil {
+			return removed, err
+		}
+		if err := u.accountRepo.DeleteExport(ctx, export.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (u *accountUsecase) deleteExportObject(ctx context.Context, key string) error {
+	if key == "" {
+		return nil
+	}
+	if err := u.private.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("failed to delete data export: %w", err)
+	}
+	return nil
+}
+
+// RequestDeletion schedules the account's deletion, replacing any earlier
+// request, so the mode can be changed during the grace period
+func (u *accountUsecase) RequestDeletion(ctx context.Context, userID uint, req *domain.DeleteAccountRequest) (*domain.AccountDeletion, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, domain.Validation("password is incorrect")
+	}
+
+	now := time.Now()
+	deletion := &domain.AccountDeletion{
+		UserID:       userID,
+		Mode:         req.Mode,
+		RequestedAt:  now,
+		ScheduledFor: now.Add(u.grace),
+	}
+	if err := u.accountRepo.ScheduleDeletion(ctx, deletion); err != nil {
+		return nil, err
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditDeletionRequest,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+		Diff:       map[string]domain.AuditChange{"mode": {Old: nil, New: req.Mode}},
+	})
+
+	what := "Your posts and comments will stay up under an anonymous name."
+	if req.Mode == domain.DeletionRemove {
+		what = "Your posts and comments will be deleted with it."
+	}
+	err = u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your account is scheduled for deletion",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour account will be deleted on %s. %s\n\nIf you change your mind, sign in and cancel the deletion before then.\n",
+			user.Username, deletion.ScheduledFor.UTC().Format("2 January 2006 15:04 MST"), what,
+		),
+	})
+	if err != nil {
+		u.logger.WarnContext(ctx, "failed to send account deletion email", "user_id", user.ID, "error", err)
+	}
+	return deletion, nil
+}
+
+func (u *accountUsecase) GetDeletion(ctx context.Context, userID uint) (*domain.AccountDeletion, error) {
+	return u.accountRepo.GetDeletion(ctx, userID)
+}
+
+func (u *accountUsecase) CancelDeletion(ctx context.Context, userID uint) error {
+	if err := u.accountRepo.CancelDeletion(ctx, userID); err != nil {
+		return err
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditDeletionCancel,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+	})
+	return nil
+}
+
+// EraseDue erases accounts one at a time, so one that fails doesn't hold up
+// the rest. It is retried on the next run.
+func (u *accountUsecase) EraseDue(ctx context.Context) (int, error) {
+	deletions, err := u.accountRepo.GetDueDeletions(ctx, time.Now(), erasureBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	erased := 0
+	var errs []error
+	for _, deletion := range deletions {
+		if err := u.erase(ctx, &deletion); err != nil {
+			errs = append(errs, fmt.Errorf("user %d: %w", deletion.UserID, err))
+			continue
+		}
+		erased++
+	}
+	return erased, errors.Join(errs...)
+}
+
+// erase carries out a deletion in one transaction. Stored files are deleted
+// once it commits; one that fails to go is logged and left behind.
+func (u *accountUsecase) erase(ctx context.Context, deletion *domain.AccountDeletion) error {
+	user, err := u.userRepo.GetByID(ctx, deletion.UserID)
+	if err != nil {
+		return err
+	}
+	exports, err := u.accountRepo.GetExportsByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	var uploads []domain.Upload
+	if deletion.Mode == domain.DeletionRemove {
+		if uploads, err = u.uploadRepo.GetByOwnerID(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	erasure := &domain.AccountErasure{
+		UserID:   user.ID,
+		Username: user.Username,
+		Mode:     deletion.Mode,
+		ErasedAt: time.Now(),
+	}
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.accountRepo.CancelDeletion(ctx, user.ID); err != nil {
+			return err
+		}
+		for _, export := range exports {
+			if err := u.accountRepo.DeleteExport(ctx, export.ID); err != nil {
+				return err
+			}
+		}
+		if err := u.accountRepo.DeleteInteractions(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := u.auditRepo.Forget(ctx, user.ID); err != nil {
+			return err
+		}
+
+		switch deletion.Mode {
+		case domain.DeletionAnonymize:
+			if err := u.userRepo.Anonymize(ctx, user.ID); err != nil {
+				return err
+			}
+		case domain.DeletionRemove:
+			if err := u.userRepo.Delete(ctx, user.ID); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown deletion mode %q", deletion.Mode)
+		}
+		return u.accountRepo.CreateErasure(ctx, erasure)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, export := range exports {
+		if err := u.deleteExportObject(ctx, export.Key); err != nil {
+			u.logger.WarnContext(ctx, "failed to delete erased account's export", "user_id", user.ID, "key", export.Key, "error", err)
+		}
+	}
+	for _, upload := range uploads {
+		for _, key := range uploadObjectKeys(&upload) {
+			if err := u.uploads.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				u.logger.WarnContext(ctx, "failed to delete erased account's upload", "user_id", user.ID, "key", key, "error", err)
+			}
+		}
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		Action:     domain.AuditAccountErase,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+		Diff:       map[string]domain.AuditChange{"mode": {Old: nil, New: deletion.Mode}},
+	})
+	u.logger.InfoContext(ctx, "erased account", "user_id", user.ID, "mode", deletion.Mode)
+	return nil
+}
+
+// ScrubErased hands the same batch to every scrubber and only marks it done
+// once they have all succeeded, so a failure retries the whole batch.
+func (u *accountUsecase) ScrubErased(ctx context.Context) (int, error) {
+	erasures, err := u.accountRepo.GetUnscrubbedErasures(ctx, time.Now().Add(-scrubDelay), erasureBatch)
+	if err != nil || len(erasures) == 0 {
+		return 0, err
+	}
+
+	for _, scrubber := range u.scrubbers {
+		if err := scrubber.Scrub(ctx, erasures); err != nil {
+			return 0, err
+		}
+	}
+
+	ids := make([]uint, len(erasures))
+	for i, erasure := range erasures {
+		ids[i] = erasure.ID
+	}
+	if err := u.accountRepo.MarkScrubbed(ctx, ids); err != nil {
+		return 0, err
+	}
+	return len(erasures), nil
+}
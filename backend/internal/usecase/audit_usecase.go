@@ -0,0 +1,201 @@
+package usecase
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/storage"
+)
+
+// auditExportPrefix is where daily exports go in the export store
+const auditExportPrefix = "audit/"
+
+type auditUsecase struct {
+	auditRepo domain.AuditRepository
+	userRepo  domain.UserRepository
+	// store holds the daily exports, which the archiver only writes when
+	// exporting is on
+	store storage.ObjectStore
+	// retention is how long events are kept; zero keeps them forever
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+func NewAuditUsecase(ar domain.AuditRepository, ur domain.UserRepository, store storage.ObjectStore, retention time.Duration, logger *slog.Logger) domain.AuditUsecase {
+	return &auditUsecase{
+		auditRepo: ar,
+		userRepo:  ur,
+		store:     store,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+func (u *auditUsecase) Record(ctx context.Context, event *domain.AuditEvent) {
+	client := domain.ClientFromContext(ctx)
+	event.IP = client.IP
+	event.UserAgent = client.UserAgent
+	event.CreatedAt = time.Now()
+
+	if err := u.auditRepo.Create(ctx, event); err != nil {
+		u.logger.ErrorContext(ctx, "failed to record audit event",
+			"action", event.Action, "actor_id", event.ActorID,
+			"target_type", event.TargetType, "target_id", event.TargetID, "error", err)
+	}
+}
+
+func (u *auditUsecase) List(ctx context.Context, userID uint, filter *domain.AuditFilter) ([]domain.AuditEvent, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsModerator() {
+		return nil, domain.Forbidden("only moderators can view the audit log")
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return nil, domain.Validation("since must be before until")
+	}
+
+	return u.auditRepo.List(ctx, filter)
+}
+
+// Export is idempotent: a day whose object exists is left alone, so the
+// exporter can retry days freely. Days without events are skipped.
+func (u *auditUsecase) Export(ctx context.Context, day time.Time) (*domain.AuditExport, error) {
+	day = day.UTC()
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	if to.After(time.Now()) {
+		return nil, domain.Validation(fmt.Sprintf("%s is not over yet", from.Format(time.DateOnly)))
+	}
+	key := auditExportPrefix + from.Format("2006/01/02") + ".jsonl.gz"
+
+	_, err := u.store.Stat(ctx, key)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+
+	events, err := u.auditRepo.GetBetween(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, nil
+	}
+
+	data, err := encodeAuditEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.store.Put(ctx, key, bytes.NewReader(data), "application/gzip"); err != nil {
+		return nil, fmt.Errorf("failed to store audit export: %w", err)
+	}
+	return &domain.AuditExport{Key: key, Day: from, Events: len(events)}, nil
+}
+
+func (u *auditUsecase) Prune(ctx context.Context) (int64, error) {
+	if u.retention == 0 {
+		return 0, nil
+	}
+	return u.auditRepo.DeleteBefore(ctx, time.Now().Add(-u.retention))
+}
+
+// Scrub strips the client and diff from exported events that involve an
+// erased account, as Forget does for the table. Only exports that change are
+// rewritten.
+func (u *auditUsecase) Scrub(ctx context.Context, erasures []domain.AccountErasure) error {
+	if len(erasures) == 0 {
+		return nil
+	}
+	erased := make(map[uint]bool, len(erasures))
+	for _, erasure := range erasures {
+		erased[erasure.UserID] = true
+	}
+
+	objects, err := u.store.List(ctx, auditExportPrefix)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if err := u.scrubExport(ctx, object.Key, erased); err != nil {
+			return fmt.Errorf("failed to scrub %s: %w", object.Key, err)
+		}
+	}
+	return nil
+}
+
+func (u *auditUsecase) scrubExport(ctx context.Context, key string, erased map[uint]bool) error {
+	body, err := u.store.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		return err
+	}
+
+	var events []domain.AuditEvent
+	changed := false
+	dec := json.NewDecoder(gz)
+	for dec.More() {
+		var event domain.AuditEvent
+		if err := dec.Decode(&event); err != nil {
+			return err
+		}
+		if erased[event.ActorID] || (event.TargetType == domain.AuditTargetUser && erased[event.TargetID]) {
+			if event.IP != "" || event.UserAgent != "" || event.Diff != nil {
+				event.IP, event.UserAgent, event.Diff = "", "", nil
+				changed = true
+			}
+		}
+		events = append(events, event)
+	}
+	if !changed {
+		return nil
+	}
+
+	data, err := encodeAuditEvents(events)
+	if err != nil {
+		return err
+	}
+	return u.store.Put(ctx, key, bytes.NewReader(data), "application/gzip")
+}
+
+// encodeAuditEvents renders events as gzipped JSON Lines
+func encodeAuditEvents(events []domain.AuditEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for i := range events {
+		if err := enc.Encode(&events[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// auditDiff turns fields' old and new values into an audit diff, leaving out
+// the ones that didn't change
+func auditDiff(fields map[string][2]string) map[string]domain.AuditChange {
+	diff := make(map[string]domain.AuditChange)
+	for name, values := range fields {
+		if values[0] != values[1] {
+			diff[name] = domain.AuditChange{Old: values[0], New: values[1]}
+		}
+	}
+	return diff
+}
@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -8,32 +9,63 @@ import (
 )
 
 type commentUsecase struct {
-	commentRepo domain.CommentRepository
-	postRepo    domain.PostRepository
+	commentRepo  domain.CommentRepository
+	postRepo     domain.PostRepository
+	blockRepo    domain.UserBlockRepository
+	revisionRepo domain.RevisionRepository
+	userRepo     domain.UserRepository
+	transactor   domain.Transactor
+	audit        domain.AuditUsecase
 }
 
-func NewCommentUsecase(cr domain.CommentRepository, pr domain.PostRepository) domain.CommentUsecase {
+func NewCommentUsecase(
+	cr domain.CommentRepository,
+	pr domain.PostRepository,
+	br domain.UserBlockRepository,
+	rr domain.RevisionRepository,
+	ur domain.UserRepository,
+	au domain.AuditUsecase,
+	tx domain.Transactor,
+) domain.CommentUsecase {
 	return &commentUsecase{
-		commentRepo: cr,
-		postRepo:    pr,
+		commentRepo:  cr,
+		postRepo:     pr,
+		blockRepo:    br,
+		revisionRepo: rr,
+		userRepo:     ur,
+		transactor:   tx,
+		audit:        au,
 	}
 }
 
-func (u *commentUsecase) Create(userID, postID uint, req *domain.CreateCommentRequest) (*domain.Comment, error) {
-	// Verify p  ost exists
-	_, err := u.postRepo.GetByID(postID)
+func (u *commentUsecase) Create(ctx context.Context, userID, postID uint, req *domain.CreateCommentRequest) (*domain.Comment, error) {
+	// Verify post exists
+	post, err := u.postRepo.GetByID(ctx, postID)
 	if err != nil {
-		return nil, errors.New("post not found")
+		return nil, err
+	}
+
+	// Blocked users cannot comment on each other's posts
+	if err := u.ensureNotBlocked(ctx, userID, post.User.ID); err != nil {
+		return nil, err
 	}
 
 	// If it's a reply, verify parent comment exists and belongs to the same post
 	if req.ParentID != nil {
-		parentComment, err := u.commentRepo.GetByID(*req.ParentID)
+		parentComment, err := u.commentRepo.GetByID(ctx, *req.ParentID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return nil, domain.NotFound("parent comment not found")
+		}
 		if err != nil {
-			return nil, errors.New("parent comment not found")
+			return nil, err
 		}
 		if parentComment.PostID != postID {
-			return nil, errors.New("parent comment does not belong to this post")
+			return nil, domain.Validation("parent comment does not belong to this post")
+		}
+
+		// ...or reply to each other
+		if err := u.ensureNotBlocked(ctx, userID, parentComment.UserID); err != nil {
+			return nil, err
 		}
 	}
 
@@ -47,23 +79,37 @@ func (u *commentUsecase) Create(userID, postID uint, req *domain.CreateCommentRe
 		UpdatedAt: now,
 	}
 
-	err = u.commentRepo.Create(comment)
+	err = u.commentRepo.Create(ctx, comment)
 	if err != nil {
 		return nil, err
 	}
 
 	// Fetch the complete comment with user information
-	return u.commentRepo.GetByID(comment.ID)
+	return u.commentRepo.GetByID(ctx, comment.ID)
 }
 
-func (u *commentUsecase) GetByID(id uint) (*domain.Comment, error) {
-	comment, err := u.commentRepo.GetByID(id)
+func (u *commentUsecase) ensureNotBlocked(ctx context.Context, userID, otherUserID uint) error {
+	if userID == otherUserID {
+		return nil
+	}
+	blocked, err := u.blockRepo.ExistsBetween(ctx, userID, otherUserID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return domain.Forbidden("you cannot interact with this user")
+	}
+	return nil
+}
+
+func (u *commentUsecase) GetByID(ctx context.Context, id, viewerID uint) (*domain.Comment, error) {
+	comment, err := u.commentRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get replies if any
-	replies, err := u.commentRepo.GetReplies(comment.ID)
+	replies, err := u.commentRepo.GetReplies(ctx, comment.ID, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +118,7 @@ func (u *commentUsecase) GetByID(id uint) (*domain.Comment, error) {
 	return comment, nil
 }
 
-func (u *commentUsecase) GetByPostID(postID uint, page, limit int) ([]domain.Comment, error) {
+func (u *commentUsecase) GetByPostID(ctx context.Context, postID, userID uint, page, limit int) ([]domain.Comment, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -80,7 +126,12 @@ func (u *commentUsecase) GetByPostID(postID uint, page, limit int) ([]domain.Com
 		limit = 10
 	}
 
-	comments, err := u.commentRepo.GetByPostID(postID, page, limit)
+	// Comments go into the trash along with their post
+	if _, err := u.postRepo.GetByID(ctx, postID); err != nil {
+		return nil, err
+	}
+
+	comments, err := u.commentRepo.GetByPostID(ctx, postID, userID, page, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +154,21 @@ func (u *commentUsecase) GetByPostID(postID uint, page, limit int) ([]domain.Com
 		attachReplies(&rootComments[i], commentMap)
 	}
 
-	return rootComments, nil
+	return pruneTombstones(rootComments), nil
+}
+
+// pruneTombstones drops deleted comments that no longer have any visible
+// replies, so tombstones only appear where they hold a thread together.
+func pruneTombstones(comments []domain.Comment) []domain.Comment {
+	var kept []domain.Comment
+	for _, comment := range comments {
+		comment.Replies = pruneTombstones(comment.Replies)
+		if comment.IsDeleted && len(comment.Replies) == 0 {
+			continue
+		}
+		kept = append(kept, comment)
+	}
+	return kept
 }
 
 func attachReplies(comment *domain.Comment, commentMap map[uint][]domain.Comment) {
@@ -116,20 +181,35 @@ func attachReplies(comment *domain.Comment, commentMap map[uint][]domain.Comment
 	}
 }
 
-func (u *commentUsecase) Update(userID, commentID uint, req *domain.UpdateCommentRequest) (*domain.Comment, error) {
-	comment, err := u.commentRepo.GetByID(commentID)
+func (u *commentUsecase) Update(ctx context.Context, userID, commentID uint, req *domain.UpdateCommentRequest) (*domain.Comment, error) {
+	comment, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return nil, err
 	}
 
 	if comment.UserID != userID {
-		return nil, errors.New("unauthorized to update this comment")
+		return nil, domain.Forbidden("unauthorized to update this comment")
 	}
 
+	// Resending the same text isn't an edit: no revision, no edited mark
+	if req.Content == comment.Content {
+		return comment, nil
+	}
+
+	previous := *comment
+
 	comment.Content = req.Content
 	comment.UpdatedAt = time.Now()
+	comment.Edited = true
+	comment.EditedAt = &comment.UpdatedAt
 
-	err = u.commentRepo.Update(comment)
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		// Keep the version being replaced
+		if err := u.saveRevision(ctx, userID, &previous); err != nil {
+			return err
+		}
+		return u.commentRepo.Update(ctx, comment)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -137,54 +217,63 @@ func (u *commentUsecase) Update(userID, commentID uint, req *domain.UpdateCommen
 	return comment, nil
 }
 
-func (u *commentUsecase) Delete(userID, commentID uint) error {
-	comment, err := u.commentRepo.GetByID(commentID)
+func (u *commentUsecase) Delete(ctx context.Context, userID, commentID uint) error {
+	comment, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return err
 	}
 
 	if comment.UserID != userID {
-		return errors.New("unauthorized to delete this comment")
+		return domain.Forbidden("unauthorized to delete this comment")
 	}
 
-	return u.commentRepo.Delete(commentID)
+	if err := u.commentRepo.Delete(ctx, commentID); err != nil {
+		return err
+	}
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditCommentDelete,
+		TargetType: domain.AuditTargetComment,
+		TargetID:   commentID,
+	})
+	return nil
 }
 
-func (u *commentUsecase) Like(userID, commentID uint) error {
+func (u *commentUsecase) Like(ctx context.Context, userID, commentID uint) error {
 	// Verify comment exists
-	_, err := u.commentRepo.GetByID(commentID)
+	_, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return err
 	}
-	return u.commentRepo.AddLike(commentID, userID)
+	return u.commentRepo.AddLike(ctx, commentID, userID)
 }
 
-func (u *commentUsecase) Unlike(userID, commentID uint) error {
+func (u *commentUsecase) Unlike(ctx context.Context, userID, commentID uint) error {
 	// Verify comment exists
-	_, err := u.commentRepo.GetByID(commentID)
+	_, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return err
 	}
-	return u.commentRepo.RemoveLike(commentID, userID)
+	return u.commentRepo.RemoveLike(ctx, commentID, userID)
 }
 
-func (u *commentUsecase) GetReplies(commentID uint) ([]domain.Comment, error) {
+func (u *commentUsecase) GetReplies(ctx context.Context, commentID, viewerID uint) ([]domain.Comment, error) {
 	// First verify the comment exists
-	_, err := u.commentRepo.GetByID(commentID)
+	_, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return nil, err
 	}
 
-	return u.commentRepo.GetReplies(commentID)
+	return u.commentRepo.GetReplies(ctx, commentID, viewerID)
 }
 
-func (u *commentUsecase) Report(userID, commentID uint) error {
-	_, err := u.commentRepo.GetByID(commentID)
+func (u *commentUsecase) Report(ctx context.Context, userID, commentID uint) error {
+	_, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return err
 	}
 
-	isReported, err := u.commentRepo.IsReportedByUser(commentID, userID)
+	isReported, err := u.commentRepo.IsReportedByUser(ctx, commentID, userID)
 	if err != nil {
 		return err
 	}
@@ -192,14 +281,107 @@ func (u *commentUsecase) Report(userID, commentID uint) error {
 		return nil
 	}
 
-	return u.commentRepo.AddReport(commentID, userID)
+	return u.commentRepo.AddReport(ctx, commentID, userID)
 }
 
-func (u *commentUsecase) Unreport(userID, commentID uint) error {
-	_, err := u.commentRepo.GetByID(commentID)
+func (u *commentUsecase) Unreport(ctx context.Context, userID, commentID uint) error {
+	_, err := u.commentRepo.GetByID(ctx, commentID)
 	if err != nil {
 		return err
 	}
 
-	return u.commentRepo.RemoveReport(commentID, userID)
+	return u.commentRepo.RemoveReport(ctx, commentID, userID)
+}
+
+// GetRevisions lists earlier versions of a comment, newest first. Only the
+// author and moderators can see them.
+func (u *commentUsecase) GetRevisions(ctx context.Context, userID, commentID uint) ([]domain.Revision, error) {
+	comment, err := u.commentRepo.GetByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.UserID != userID {
+		isModerator, err := u.isModerator(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isModerator {
+			return nil, domain.Forbidden("unauthorized to view revisions of this comment")
+		}
+	}
+
+	return u.revisionRepo.GetByTarget(ctx, domain.RevisionTargetComment, commentID)
+}
+
+// RollbackRevision lets a moderator put a comment back to an earlier version.
+// The version being replaced is itself kept as a revision.
+func (u *commentUsecase) RollbackRevision(ctx context.Context, userID, commentID, revisionID uint) (*domain.Comment, error) {
+	isModerator, err := u.isModerator(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isModerator {
+		return nil, domain.Forbidden("only moderators can roll back comments")
+	}
+
+	comment, err := u.commentRepo.GetByID(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := u.revisionRepo.GetByID(ctx, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if revision.TargetType != domain.RevisionTargetComment || revision.TargetID != commentID {
+		return nil, domain.NotFound("revision not found")
+	}
+
+	previous := *comment
+
+	comment.Content = revision.Content
+	comment.UpdatedAt = time.Now()
+	comment.Edited = true
+	comment.EditedAt = &comment.UpdatedAt
+
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.saveRevision(ctx, userID, &previous); err != nil {
+			return err
+		}
+		return u.commentRepo.Update(ctx, comment)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditCommentRollback,
+		TargetType: domain.AuditTargetComment,
+		TargetID:   commentID,
+		Diff: auditDiff(map[string][2]string{
+			"content": {previous.Content, comment.Content},
+		}),
+	})
+
+	return comment, nil
+}
+
+func (u *commentUsecase) saveRevision(ctx context.Context, editorID uint, comment *domain.Comment) error {
+	return u.revisionRepo.Create(ctx, &domain.Revision{
+		TargetType: domain.RevisionTargetComment,
+		TargetID:   comment.ID,
+		EditorID:   editorID,
+		Content:    comment.Content,
+		CreatedAt:  time.Now(),
+	})
+}
+
+func (u *commentUsecase) isModerator(ctx context.Context, userID uint) (bool, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsModerator(), nil
 }
@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/unfurl"
+)
+
+const (
+	// previewTTL is how long a fetched preview is used before the page is
+	// fetched again
+	previewTTL = 24 * time.Hour
+	// failedPreviewTTL is how long a failed fetch stops the page from being
+	// requested again
+	failedPreviewTTL = time.Hour
+)
+
+type linkPreviewUsecase struct {
+	previewRepo domain.LinkPreviewRepository
+	fetcher     *unfurl.Fetcher
+	queue       domain.LinkPreviewQueue
+	logger      *slog.Logger
+}
+
+func NewLinkPreviewUsecase(pr domain.LinkPreviewRepository, fetcher *unfurl.Fetcher, queue domain.LinkPreviewQueue, logger *slog.Logger) domain.LinkPreviewUsecase {
+	return &linkPreviewUsecase{
+		previewRepo: pr,
+		fetcher:     fetcher,
+		queue:       queue,
+		logger:      logger,
+	}
+}
+
+func (u *linkPreviewUsecase) Preview(ctx context.Context, link string) (*domain.LinkPreview, error) {
+	if !previewable(link) {
+		return nil, domain.Validation("link must be an absolute http or https URL")
+	}
+
+	preview, err := u.cached(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	if preview == nil || time.Now().After(preview.ExpiresAt) {
+		if preview, err = u.fetch(ctx, link); err != nil {
+			return nil, err
+		}
+	}
+	if preview.Failed {
+		return nil, domain.NotFound("no preview is available for this link")
+	}
+	return preview, nil
+}
+
+func (u *linkPreviewUsecase) Lookup(ctx context.Context, links []string) (map[string]*domain.LinkPreview, error) {
+	var wanted []string
+	for _, link := range links {
+		if previewable(link) {
+			wanted = append(wanted, link)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil, nil
+	}
+
+	cached, err := u.previewRepo.GetByURLs(ctx, wanted)
+	if err != nil {
+		return nil, err
+	}
+	byURL := make(map[string]*domain.LinkPreview, len(cached))
+	for i := range cached {
+		byURL[cached[i].URL] = &cached[i]
+	}
+
+	now := time.Now()
+	previews := make(map[string]*domain.LinkPreview, len(cached))
+	for _, link := range wanted {
+		preview, ok := byURL[link]
+		if !ok || now.After(preview.ExpiresAt) {
+			u.queue.Enqueue(link)
+		}
+		// A stale preview is still better than none while it is refreshed
+		if ok && !preview.Failed {
+			previews[link] = preview
+		}
+	}
+	return previews, nil
+}
+
+func (u *linkPreviewUsecase) Refresh(ctx context.Context, link string) error {
+	preview, err := u.cached(ctx, link)
+	if err != nil {
+		return err
+	}
+	if preview != nil && time.Now().Before(preview.ExpiresAt) {
+		return nil
+	}
+	_, err = u.fetch(ctx, link)
+	return err
+}
+
+func (u *linkPreviewUsecase) cached(ctx context.Context, link string) (*domain.LinkPreview, error) {
+	previews, err := u.previewRepo.GetByURLs(ctx, []string{link})
+	if err != nil || len(previews) == 0 {
+		return nil, err
+	}
+	return &previews[0], nil
+}
+
+// fetch requests the page and caches the result. A page that can't be
+// previewed is cached as failed rather than returned as an error, since the
+// link is the poster's, not the server's, problem.
+func (u *linkPreviewUsecase) fetch(ctx context.Context, link string) (*domain.LinkPreview, error) {
+	now := time.Now()
+	preview := &domain.LinkPreview{URL: link, FetchedAt: now, ExpiresAt: now.Add(previewTTL)}
+
+	md, err := u.fetcher.Fetch(ctx, link)
+	if err != nil {
+		u.logger.InfoContext(ctx, "link preview failed", "url", link, "error", err)
+		preview.Failed = true
+		preview.ExpiresAt = now.Add(failedPreviewTTL)
+	} else {
+		preview.Title = md.Title
+		preview.Description = md.Description
+		preview.ImageURL = md.Image
+		preview.SiteName = md.SiteName
+		if md.URL != link {
+			preview.CanonicalURL = md.URL
+		}
+	}
+
+	if err := u.previewRepo.Save(ctx, preview); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// previewable reports whether link is an absolute http or https URL.
+func previewable(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,164 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// media fills in the attachments, media items and link previews of posts and
+// the resized variants of images in responses. Posts and profiles store plain
+// URLs, so variants are found by matching those URLs to uploads; images
+// hosted elsewhere are left without.
+type media struct {
+	uploadRepo     domain.UploadRepository
+	attachmentRepo domain.AttachmentRepository
+	mediaRepo      domain.MediaRepository
+	previews       domain.LinkPreviewUsecase
+}
+
+// attach loads the attachments and link previews of posts and looks up the
+// uploads behind every attachment and image in posts and users, with one
+// query each. Comment authors on the posts are included.
+func (m media) attach(ctx context.Context, posts []*domain.Post, users []*domain.User) error {
+	if len(posts) > 0 {
+		if err := m.linkPreviews(ctx, posts); err != nil {
+			return err
+		}
+
+		ids := make([]uint, len(posts))
+		for i, post := range posts {
+			ids[i] = post.ID
+		}
+		attachments, err := m.attachmentRepo.GetByPostIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		items, err := m.mediaRepo.GetByPostIDs(ctx, ids)
+		if err != nil {
+			return err
+		}
+		for _, post := range posts {
+			post.Attachments = attachments[post.ID]
+			if post.Attachments == nil {
+				post.Attachments = []domain.Attachment{}
+			}
+			post.Media = items[post.ID]
+			if post.Media == nil {
+				post.Media = []domain.MediaItem{}
+			}
+			for i := range post.Media {
+				setMediaURLs(&post.Media[i])
+			}
+		}
+	}
+
+	for _, post := range posts {
+		if post.User != nil {
+			users = append(users, post.User)
+		}
+		for i := range post.Comments {
+			if post.Comments[i].User != nil {
+				users = append(users, post.Comments[i].User)
+			}
+		}
+	}
+
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(url string) {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	for _, post := range posts {
+		add(post.ImageURL)
+		for _, a := range post.Attachments {
+			add(a.URL)
+		}
+	}
+	for _, user := range users {
+		add(user.AvatarURL)
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	uploads, err := m.uploadRepo.GetByURLs(ctx, urls)
+	if err != nil {
+		return err
+	}
+	byURL := make(map[string]*domain.Upload, len(uploads))
+	for i := range uploads {
+		byURL[uploads[i].URL] = &uploads[i]
+	}
+	set := func(url string) *domain.ImageSet {
+		if upload, ok := byURL[url]; ok {
+			return domain.NewImageSet(upload.Variants)
+		}
+		return nil
+	}
+
+	for _, post := range posts {
+		post.ImageVariants = set(post.ImageURL)
+		for i := range post.Attachments {
+			a := &post.Attachments[i]
+			// Clients send the upload back to keep the attachment on edit
+			if upload, ok := byURL[a.URL]; ok {
+				a.UploadID = upload.ID
+			}
+			if !a.IsImage() {
+				continue
+			}
+			a.Variants = set(a.URL)
+			// Dimensions of uploads are only known once processing finishes
+			if upload, ok := byURL[a.URL]; ok && a.Width == 0 {
+				a.Width, a.Height = upload.Width, upload.Height
+			}
+		}
+	}
+	for _, user := range users {
+		user.AvatarVariants = set(user.AvatarURL)
+	}
+	return nil
+}
+
+// linkPreviews only uses what is cached; previews that are missing are
+// fetched in the background and show up on a later read.
+func (m media) linkPreviews(ctx context.Context, posts []*domain.Post) error {
+	var links []string
+	for _, post := range posts {
+		if post.LinkURL != "" {
+			links = append(links, post.LinkURL)
+		}
+	}
+	if len(links) == 0 {
+		return nil
+	}
+
+	previews, err := m.previews.Lookup(ctx, links)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		post.LinkPreview = previews[post.LinkURL]
+	}
+	return nil
+}
+
+func (m media) posts(ctx context.Context, posts []domain.Post) error {
+	ptrs := make([]*domain.Post, len(posts))
+	for i := range posts {
+		ptrs[i] = &posts[i]
+	}
+	return m.attach(ctx, ptrs, nil)
+}
+
+func (m media) post(ctx context.Context, post *domain.Post) error {
+	return m.attach(ctx, []*domain.Post{post}, nil)
+}
+
+func (m media) users(ctx context.Context, users ...*domain.User) error {
+	return m.attach(ctx, nil, users)
+}
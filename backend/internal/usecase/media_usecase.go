@@ -0,0 +1,321 @@
+package usecase
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/captions"
+	"github.com/ruth987/CHub.git/pkg/mediainfo"
+	"github.com/ruth987/CHub.git/pkg/storage"
+)
+
+const (
+	// maxTranscriptSize bounds an uploaded WebVTT or SRT file
+	maxTranscriptSize = 5 << 20
+	// searchLimit is how many media items a search returns, and
+	// searchMatches how many matching cues it shows for each
+	searchLimit   = 20
+	searchMatches = 5
+)
+
+type mediaUsecase struct {
+	mediaRepo  domain.MediaRepository
+	uploadRepo domain.UploadRepository
+	store      storage.ObjectStore
+	logger     *slog.Logger
+}
+
+func NewMediaUsecase(mr domain.MediaRepository, ur domain.UploadRepository, store storage.ObjectStore, logger *slog.Logger) domain.MediaUsecase {
+	return &mediaUsecase{
+		mediaRepo:  mr,
+		uploadRepo: ur,
+		store:      store,
+		logger:     logger,
+	}
+}
+
+// Create turns one of the caller's sermon_media uploads into a media item.
+// The duration is read from the file where the format allows, and taken from
+// the request otherwise.
+func (u *mediaUsecase) Create(ctx context.Context, ownerID uint, req *domain.CreateMediaRequest) (*domain.MediaItem, error) {
+	upload, err := u.uploadRepo.GetByID(ctx, req.UploadID)
+	if err != nil {
+		return nil, err
+	}
+	if upload.OwnerID != ownerID {
+		return nil, domain.NotFound("upload not found")
+	}
+	if upload.Purpose != domain.UploadPurposeSermonMedia {
+		return nil, domain.Validation(fmt.Sprintf("media must be a %s upload", domain.UploadPurposeSermonMedia))
+	}
+
+	item := &domain.MediaItem{
+		OwnerID:     ownerID,
+		UploadID:    upload.ID,
+		Kind:        mediaKind(upload.ContentType),
+		Title:       strings.TrimSpace(req.Title),
+		Description: strings.TrimSpace(req.Description),
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+		Duration:    u.probeDuration(ctx, upload),
+		Key:         upload.Key,
+		CreatedAt:   time.Now(),
+	}
+	item.UpdatedAt = item.CreatedAt
+	if item.Duration == 0 {
+		item.Duration = req.Duration
+	}
+	if item.Title == "" {
+		return nil, domain.Validation("title is required")
+	}
+	if item.Chapters, err = normalizeChapters(req.Chapters, item.Duration); err != nil {
+		return nil, err
+	}
+
+	if err := u.mediaRepo.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	setMediaURLs(item)
+	return item, nil
+}
+
+// probeDuration reads the duration from the stored file's headers, fetching
+// only the ranges it needs. It returns 0 when the format doesn't say.
+func (u *mediaUsecase) probeDuration(ctx context.Context, upload *domain.Upload) float64 {
+	r := storage.NewReader(ctx, u.store, upload.Key, upload.Size)
+	defer r.Close()
+
+	duration, err := mediainfo.Duration(r, upload.Size, upload.ContentType)
+	if err != nil {
+		if !errors.Is(err, mediainfo.ErrUnsupported) {
+			u.logger.WarnContext(ctx, "failed to read media duration", "upload_id", upload.ID, "error", err)
+		}
+		return 0
+	}
+	return duration.Seconds()
+}
+
+func (u *mediaUsecase) GetByID(ctx context.Context, id uint) (*domain.MediaItem, error) {
+	item, err := u.mediaRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	setMediaURLs(item)
+	return item, nil
+}
+
+func (u *mediaUsecase) GetByOwnerID(ctx context.Context, ownerID uint, page, limit int) ([]domain.MediaItem, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	items, err := u.mediaRepo.GetByOwnerID(ctx, ownerID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		setMediaURLs(&items[i])
+	}
+	return items, nil
+}
+
+func (u *mediaUsecase) Update(ctx context.Context, userID, id uint, req *domain.UpdateMediaRequest) (*domain.MediaItem, error) {
+	item, err := u.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if title := strings.TrimSpace(req.Title); title != "" {
+		item.Title = title
+	}
+	if req.Description != "" {
+		item.Description = strings.TrimSpace(req.Description)
+	}
+	if req.Duration > 0 {
+		item.Duration = req.Duration
+	}
+	chapters := item.Chapters
+	if req.Chapters != nil {
+		chapters = req.Chapters
+	}
+	// A shorter duration can leave existing chapters out of range
+	if item.Chapters, err = normalizeChapters(chapters, item.Duration); err != nil {
+		return nil, err
+	}
+	item.UpdatedAt = time.Now()
+
+	if err := u.mediaRepo.Update(ctx, item); err != nil {
+		return nil, err
+	}
+	setMediaURLs(item)
+	return item, nil
+}
+
+func (u *mediaUsecase) Delete(ctx context.Context, userID, id uint) error {
+	if _, err := u.owned(ctx, userID, id); err != nil {
+		return err
+	}
+	return u.mediaRepo.Delete(ctx, id)
+}
+
+// SetTranscript replaces the transcript with a parsed WebVTT or SRT file.
+// Cues are stored as plain text; the formatting of the original is dropped.
+func (u *mediaUsecase) SetTranscript(ctx context.Context, userID, id uint, file io.Reader) (*domain.MediaItem, error) {
+	item, err := u.owned(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxTranscriptSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcript: %w", err)
+	}
+	if len(data) > maxTranscriptSize {
+		return nil, domain.TooLarge(fmt.Sprintf("transcripts are limited to %d bytes", maxTranscriptSize))
+	}
+
+	parsed, err := captions.Parse(data)
+	if err != nil {
+		return nil, domain.Validation(fmt.Sprintf("transcript must be WebVTT or SRT: %v", err))
+	}
+	cues := make([]domain.TranscriptCue, len(parsed))
+	for i, cue := range parsed {
+		cues[i] = domain.TranscriptCue{Start: cue.Start.Seconds(), End: cue.End.Seconds(), Text: cue.Text}
+	}
+
+	if err := u.mediaRepo.ReplaceTranscript(ctx, id, cues); err != nil {
+		return nil, err
+	}
+	item.HasTranscript = true
+	setMediaURLs(item)
+	return item, nil
+}
+
+func (u *mediaUsecase) DeleteTranscript(ctx context.Context, userID, id uint) error {
+	if _, err := u.owned(ctx, userID, id); err != nil {
+		return err
+	}
+	return u.mediaRepo.ReplaceTranscript(ctx, id, nil)
+}
+
+func (u *mediaUsecase) Transcript(ctx context.Context, id uint, w io.Writer) error {
+	item, err := u.mediaRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !item.HasTranscript {
+		return domain.NotFound("media has no transcript")
+	}
+
+	cues, err := u.mediaRepo.GetTranscript(ctx, id)
+	if err != nil {
+		return err
+	}
+	out := make([]captions.Cue, len(cues))
+	for i, cue := range cues {
+		out[i] = captions.Cue{Start: seconds(cue.Start), End: seconds(cue.End), Text: cue.Text}
+	}
+	return captions.WriteVTT(w, out)
+}
+
+func (u *mediaUsecase) Search(ctx context.Context, query string, limit int) ([]domain.MediaSearchResult, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, domain.Validation("search query is required")
+	}
+	if limit < 1 || limit > searchLimit {
+		limit = searchLimit
+	}
+
+	results, err := u.mediaRepo.Search(ctx, query, limit, searchMatches)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		setMediaURLs(&results[i].Media)
+	}
+	return results, nil
+}
+
+// Open returns a reader over the stored file that fetches only the ranges
+// read from it, so seeking in a long recording doesn't download all of it.
+func (u *mediaUsecase) Open(ctx context.Context, id uint) (*domain.MediaStream, error) {
+	item, err := u.mediaRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MediaStream{
+		ReadSeekCloser: storage.NewReader(ctx, u.store, item.Key, item.Size),
+		ContentType:    item.ContentType,
+		Size:           item.Size,
+		ModTime:        item.CreatedAt,
+	}, nil
+}
+
+func (u *mediaUsecase) owned(ctx context.Context, userID, id uint) (*domain.MediaItem, error) {
+	item, err := u.mediaRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.OwnerID != userID {
+		return nil, domain.Forbidden("unauthorized to change this media")
+	}
+	return item, nil
+}
+
+// normalizeChapters trims chapter titles and orders chapters by start time.
+// Chapters must start at distinct times within the recording, when its length
+// is known.
+func normalizeChapters(chapters []domain.Chapter, duration float64) ([]domain.Chapter, error) {
+	out := make([]domain.Chapter, len(chapters))
+	for i, ch := range chapters {
+		ch.Title = strings.TrimSpace(ch.Title)
+		if ch.Title == "" {
+			return nil, domain.Validation(fmt.Sprintf("chapter %d needs a title", i+1))
+		}
+		if ch.Start < 0 || (duration > 0 && ch.Start >= duration) {
+			return nil, domain.Validation(fmt.Sprintf("chapter %q starts outside the recording", ch.Title))
+		}
+		out[i] = ch
+	}
+	slices.SortStableFunc(out, func(a, b domain.Chapter) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	for i := 1; i < len(out); i++ {
+		if out[i].Start == out[i-1].Start {
+			return nil, domain.Validation(fmt.Sprintf("chapters %q and %q start at the same time", out[i-1].Title, out[i].Title))
+		}
+	}
+	return out, nil
+}
+
+func mediaKind(contentType string) domain.MediaKind {
+	if strings.HasPrefix(contentType, "video/") {
+		return domain.MediaKindVideo
+	}
+	return domain.MediaKindAudio
+}
+
+// setMediaURLs points a media item at the API routes that serve it
+func setMediaURLs(item *domain.MediaItem) {
+	item.StreamURL = fmt.Sprintf("/api/media/%d/stream", item.ID)
+	item.TranscriptURL = ""
+	if item.HasTranscript {
+		item.TranscriptURL = fmt.Sprintf("/api/media/%d/transcript.vtt", item.ID)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,334 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/auth"
+)
+
+type messageUsecase struct {
+	conversationRepo domain.ConversationRepository
+	userRepo         domain.UserRepository
+	blockRepo        domain.UserBlockRepository
+	publisher        domain.MessagePublisher
+	transactor       domain.Transactor
+	jwtService       *auth.JWTService
+}
+
+func NewMessageUsecase(
+	conversationRepo domain.ConversationRepository,
+	userRepo domain.UserRepository,
+	blockRepo domain.UserBlockRepository,
+	publisher domain.MessagePublisher,
+	transactor domain.Transactor,
+	jwtService *auth.JWTService,
+) domain.MessageUsecase {
+	return &messageUsecase{
+		conversationRepo: conversationRepo,
+		userRepo:         userRepo,
+		blockRepo:        blockRepo,
+		publisher:        publisher,
+		transactor:       transactor,
+		jwtService:       jwtService,
+	}
+}
+
+func (u *messageUsecase) CreateConversation(ctx context.Context, userID uint, req *domain.CreateConversationRequest) (*domain.Conversation, error) {
+	// Deduplicate participants and drop the creator if they listed themselves
+	seen := map[uint]bool{userID: true}
+	var others []uint
+	for _, id := range req.ParticipantIDs {
+		if !seen[id] {
+			seen[id] = true
+			others = append(others, id)
+		}
+	}
+
+	if len(others) == 0 {
+		return nil, domain.Validation("a conversation needs at least one other participant")
+	}
+	if len(others)+1 > domain.MaxGroupParticipants {
+		return nil, domain.Validation("too many participants for a group conversation")
+	}
+
+	for _, id := range others {
+		if _, err := u.userRepo.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		blocked, err := u.blockRepo.ExistsBetween(ctx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, domain.Forbidden("you cannot message this user")
+		}
+	}
+
+	isGroup := len(others) > 1
+
+	// One-to-one conversations are reused rather than duplicated
+	if !isGroup {
+		existing, err := u.conversationRepo.FindDirect(ctx, userID, others[0])
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return u.GetConversation(ctx, userID, existing.ID)
+		}
+	}
+
+	now := time.Now()
+	conversation := &domain.Conversation{
+		IsGroup:   isGroup,
+		CreatedBy: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if isGroup {
+		conversation.Title = strings.TrimSpace(req.Title)
+	}
+
+	err := u.conversationRepo.Create(ctx, conversation, append([]uint{userID}, others...))
+	if err != nil {
+		return nil, err
+	}
+
+	return u.GetConversation(ctx, userID, conversation.ID)
+}
+
+func (u *messageUsecase) GetConversations(ctx context.Context, userID uint, page, limit int) ([]domain.Conversation, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	conversations, err := u.conversationRepo.GetByUserID(ctx, userID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range conversations {
+		participants, err := u.conversationRepo.GetParticipants(ctx, conversations[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		conversations[i].Participants = participants
+
+		latest, err := u.conversationRepo.GetMessages(ctx, conversations[i].ID, 0, 0, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(latest) > 0 {
+			attachReadReceipts(latest, participants)
+			conversations[i].LastMessage = &latest[0]
+		}
+	}
+
+	return conversations, nil
+}
+
+func (u *messageUsecase) GetConversation(ctx context.Context, userID, conversationID uint) (*domain.Conversation, error) {
+	if err := u.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	conversation, err := u.conversationRepo.GetByID(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := u.conversationRepo.GetParticipants(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	conversation.Participants = participants
+
+	muted, err := u.conversationRepo.IsMuted(ctx, conversationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	conversation.IsMuted = muted
+
+	return conversation, nil
+}
+
+func (u *messageUsecase) GetMessages(ctx context.Context, userID, conversationID, beforeID, afterID uint, limit int) ([]domain.Message, error) {
+	if limit < 1 || limit > 100 {
+		limit = 50
+	}
+
+	if err := u.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	messages, err := u.conversationRepo.GetMessages(ctx, conversationID, beforeID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := u.conversationRepo.GetParticipants(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	attachReadReceipts(messages, participants)
+
+	return messages, nil
+}
+
+func (u *messageUsecase) SendMessage(ctx context.Context, userID, conversationID uint, req *domain.SendMessageRequest) (*domain.Message, error) {
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return nil, domain.Validation("message content cannot be empty")
+	}
+
+	conversation, err := u.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocks between the two members of a direct conversation stop delivery
+	if !conversation.IsGroup {
+		for _, p := range conversation.Participants {
+			if p.UserID == userID {
+				continue
+			}
+			blocked, err := u.blockRepo.ExistsBetween(ctx, userID, p.UserID)
+			if err != nil {
+				return nil, err
+			}
+			if blocked {
+				return nil, domain.Forbidden("you cannot message this user")
+			}
+		}
+	}
+
+	message := &domain.Message{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Content:        content,
+		CreatedAt:      time.Now(),
+	}
+	// The message, the conversation's updated_at and the sender's read marker
+	// are saved together; events only go out once they are committed
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.conversationRepo.CreateMessage(ctx, message); err != nil {
+			return err
+		}
+
+		// The sender has implicitly read their own message
+		_, err := u.conversationRepo.MarkRead(ctx, conversationID, userID, message.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range conversation.Participants {
+		if p.UserID == userID {
+			message.Sender = p.User
+			break
+		}
+	}
+	message.ReadBy = []uint{}
+
+	for _, p := range conversation.Participants {
+		muted := false
+		if p.UserID != userID {
+			muted, err = u.conversationRepo.IsMuted(ctx, conversationID, p.UserID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		u.publisher.Publish(p.UserID, domain.MessageEvent{
+			Type:           domain.MessageEventNew,
+			ConversationID: conversationID,
+			Message:        message,
+			Muted:          muted,
+		})
+	}
+
+	return message, nil
+}
+
+func (u *messageUsecase) MarkRead(ctx context.Context, userID, conversationID, messageID uint) error {
+	if err := u.ensureParticipant(ctx, conversationID, userID); err != nil {
+		return err
+	}
+
+	lastRead, err := u.conversationRepo.MarkRead(ctx, conversationID, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	participants, err := u.conversationRepo.GetParticipants(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, p := range participants {
+		if p.UserID == userID {
+			continue
+		}
+		u.publisher.Publish(p.UserID, domain.MessageEvent{
+			Type:              domain.MessageEventRead,
+			ConversationID:    conversationID,
+			UserID:            userID,
+			LastReadMessageID: lastRead,
+		})
+	}
+
+	return nil
+}
+
+func (u *messageUsecase) Mute(ctx context.Context, userID, conversationID uint) error {
+	return u.conversationRepo.SetMuted(ctx, conversationID, userID, true)
+}
+
+func (u *messageUsecase) Unmute(ctx context.Context, userID, conversationID uint) error {
+	return u.conversationRepo.SetMuted(ctx, conversationID, userID, false)
+}
+
+// StreamTicket issues a short-lived ticket for opening the message stream
+// without an Authorization header. Like a token, it stops working once the
+// user's sessions are revoked.
+func (u *messageUsecase) StreamTicket(ctx context.Context, userID uint) (*domain.StreamTicket, error) {
+	version, err := u.userRepo.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, expiresAt, err := u.jwtService.GenerateStreamTicket(userID, version)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.StreamTicket{Ticket: ticket, ExpiresAt: expiresAt}, nil
+}
+
+func (u *messageUsecase) ensureParticipant(ctx context.Context, conversationID, userID uint) error {
+	isParticipant, err := u.conversationRepo.IsParticipant(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	if !isParticipant {
+		return domain.NotFound("conversation not found")
+	}
+	return nil
+}
+
+// attachReadReceipts fills ReadBy with the other participants whose read
+// marker has reached each message.
+func attachReadReceipts(messages []domain.Message, participants []domain.ConversationParticipant) {
+	for i := range messages {
+		readBy := []uint{}
+		for _, p := range participants {
+			if p.UserID != messages[i].SenderID && p.LastReadMessageID >= messages[i].ID {
+				readBy = append(readBy, p.UserID)
+			}
+		}
+		messages[i].ReadBy = readBy
+	}
+}
@@ -1,193 +1,639 @@
 package usecase
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 )
 
 type postUsecase struct {
-	postRepo    domain.PostRepository
-	commentRepo domain.CommentRepository
+	postRepo       domain.PostRepository
+	commentRepo    domain.CommentRepository
+	revisionRepo   domain.RevisionRepository
+	userRepo       domain.UserRepository
+	uploadRepo     domain.UploadRepository
+	attachmentRepo domain.AttachmentRepository
+	mediaRepo      domain.MediaRepository
+	transactor     domain.Transactor
+	media          media
+	audit          domain.AuditUsecase
 }
 
-func NewPostUsecase(pr domain.PostRepository, cr domain.CommentRepository) domain.PostUsecase {
+func NewPostUsecase(
+	pr domain.PostRepository,
+	cr domain.CommentRepository,
+	rr domain.RevisionRepository,
+	ur domain.UserRepository,
+	upr domain.UploadRepository,
+	ar domain.AttachmentRepository,
+	mr domain.MediaRepository,
+	lpu domain.LinkPreviewUsecase,
+	au domain.AuditUsecase,
+	tx domain.Transactor,
+) domain.PostUsecase {
 	return &postUsecase{
-		postRepo:    pr,
-		commentRepo: cr,
+		postRepo:       pr,
+		commentRepo:    cr,
+		revisionRepo:   rr,
+		userRepo:       ur,
+		uploadRepo:     upr,
+		attachmentRepo: ar,
+		mediaRepo:      mr,
+		transactor:     tx,
+		media:          media{uploadRepo: upr, attachmentRepo: ar, mediaRepo: mr, previews: lpu},
+		audit:          au,
 	}
 }
 
-func (u *postUsecase) Create(userID uint, req *domain.CreatePostRequest) (*domain.Post, error) {
+func (u *postUsecase) Create(ctx context.Context, userID uint, req *domain.CreatePostRequest) (*domain.Post, error) {
+	inputs := req.Attachments
+	if len(inputs) == 0 && req.ImageURL != "" {
+		image, err := u.legacyImage(ctx, req.ImageURL)
+		if err != nil {
+			return nil, err
+		}
+		inputs = []domain.AttachmentInput{image}
+	}
+	attachments, err := u.resolveAttachments(ctx, userID, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.checkMedia(ctx, req.MediaIDs); err != nil {
+		return nil, err
+	}
+
 	now := time.Now()
 	post := &domain.Post{
-		Title:     req.Title,
-		Content:   req.Content,
-		ImageURL:  req.ImageURL,
-		LinkURL:   req.LinkURL,
-		User:      &domain.User{ID: userID},
-		CreatedAt: now,
-		UpdatedAt: now,
+		Title:       req.Title,
+		Content:     req.Content,
+		ImageURL:    coverImage(attachments),
+		Attachments: attachments,
+		LinkURL:     req.LinkURL,
+		User:        &domain.User{ID: userID},
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
-	err := u.postRepo.Create(post)
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.postRepo.Create(ctx, post); err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			if err := u.attachmentRepo.Replace(ctx, post.ID, attachments); err != nil {
+				return err
+			}
+		}
+		if len(req.MediaIDs) > 0 {
+			if err := u.mediaRepo.ReplacePostMedia(ctx, post.ID, req.MediaIDs); err != nil {
+				return err
+			}
+		}
+
+		// Add tags if provided
+		if len(req.Tags) > 0 {
+			return u.postRepo.AddTags(ctx, post.ID, req.Tags)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Add tags if provided
 	if len(req.Tags) > 0 {
-		err = u.postRepo.AddTags(post.ID, req.Tags)
-		if err != nil {
-			return nil, err
-		}
 		post.Tags = req.Tags
 	}
-
+	if err := u.media.post(ctx, post); err != nil {
+		return nil, err
+	}
 	return post, nil
 }
 
-func (u *postUsecase) GetByID(id uint) (*domain.Post, error) {
-	post, err := u.postRepo.GetByID(id)
+func (u *postUsecase) GetByID(ctx context.Context, id, userID uint) (*domain.Post, error) {
+	post, err := u.postRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Get tags
-	tags, err := u.postRepo.GetTags(id)
+	tags, err := u.postRepo.GetTags(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	post.Tags = tags
 
 	// Get likes count
-	likes, err := u.postRepo.GetLikes(id)
+	likes, err := u.postRepo.GetLikes(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	post.Likes = likes
 
 	// Get comments
-	comments, err := u.commentRepo.GetByPostID(id, 1, 100)
+	comments, err := u.commentRepo.GetByPostID(ctx, id, userID, 1, 100)
 	if err != nil {
 		return nil, err
 	}
 	post.Comments = comments
 
+	if err := u.media.post(ctx, post); err != nil {
+		return nil, err
+	}
+
 	return post, nil
 }
 
-func (u *postUsecase) GetAll(page, limit int, userID uint) ([]domain.Post, error) {
+func (u *postUsecase) GetAll(ctx context.Context, page, limit int, userID uint) ([]domain.Post, error) {
 	if page < 1 {
 		page = 1
 	}
 	if limit < 1 || limit > 100 {
 		limit = 10
 	}
-	fmt.Println(" in post usecase userID", userID)
 
-	posts, err := u.postRepo.GetAll(page, limit, userID)
+	posts, err := u.postRepo.GetAll(ctx, page, limit, userID)
 	if err != nil {
 		return nil, err
 	}
+	if err := u.media.posts(ctx, posts); err != nil {
+		return nil, err
+	}
 
 	return posts, nil
 }
 
-func (u *postUsecase) GetByUserID(userID uint) ([]domain.Post, error) {
-	return u.postRepo.GetByUserID(userID)
+func (u *postUsecase) GetByUserID(ctx context.Context, userID uint) ([]domain.Post, error) {
+	posts, err := u.postRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.media.posts(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
 }
 
-func (u *postUsecase) Update(userID uint, postID uint, req *domain.UpdatePostRequest) (*domain.Post, error) {
-	post, err := u.postRepo.GetByID(postID)
+func (u *postUsecase) Update(ctx context.Context, userID uint, postID uint, req *domain.UpdatePostRequest) (*domain.Post, error) {
+	post, err := u.postRepo.GetByID(ctx, postID)
 	if err != nil {
 		return nil, err
 	}
 
 	if post.User.ID != userID {
-		return nil, errors.New("unauthorized to update this post")
+		return nil, domain.Forbidden("unauthorized to update this post")
+	}
+	if err := u.loadAttachments(ctx, post); err != nil {
+		return nil, err
 	}
 
+	previous := *post
+
 	if req.Title != "" {
 		post.Title = req.Title
 	}
 	if req.Content != "" {
 		post.Content = req.Content
 	}
-	if req.ImageURL != "" {
-		post.ImageURL = req.ImageURL
+
+	// A list replaces the attachments; a lone image URL replaces the first image
+	attachmentsChanged := req.Attachments != nil || req.ImageURL != ""
+	if req.Attachments != nil {
+		if post.Attachments, err = u.resolveAttachments(ctx, userID, req.Attachments); err != nil {
+			return nil, err
+		}
+	} else if req.ImageURL != "" {
+		image, err := u.legacyImage(ctx, req.ImageURL)
+		if err != nil {
+			return nil, err
+		}
+		images, err := u.resolveAttachments(ctx, userID, []domain.AttachmentInput{image})
+		if err != nil {
+			return nil, err
+		}
+		if post.Attachments, err = replaceCover(post.Attachments, images[0]); err != nil {
+			return nil, err
+		}
+	}
+	post.ImageURL = coverImage(post.Attachments)
+	if err := u.checkMedia(ctx, req.MediaIDs); err != nil {
+		return nil, err
 	}
+
 	if req.LinkURL != "" {
 		post.LinkURL = req.LinkURL
 	}
-	post.UpdatedAt = time.Now()
 
-	err = u.postRepo.Update(post)
+	tagsChanged := false
+	if len(req.Tags) > 0 {
+		tags, err := u.postRepo.GetTags(ctx, post.ID)
+		if err != nil {
+			return nil, err
+		}
+		tagsChanged = !sameTags(tags, req.Tags)
+	}
+	attachmentsChanged = attachmentsChanged && !sameAttachments(previous.Attachments, post.Attachments)
+
+	// Resending the post as it is isn't an edit: it leaves no revision and
+	// doesn't mark the post edited. Media items aren't kept in revisions, so
+	// changing only them isn't one either.
+	edited := post.Title != previous.Title || post.Content != previous.Content ||
+		post.LinkURL != previous.LinkURL || attachmentsChanged || tagsChanged
+	if edited {
+		post.UpdatedAt = time.Now()
+		post.Edited = true
+		post.EditedAt = &post.UpdatedAt
+	}
+
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if edited {
+			// Keep the version being replaced
+			if err := u.saveRevision(ctx, userID, &previous); err != nil {
+				return err
+			}
+			if err := u.postRepo.Update(ctx, post); err != nil {
+				return err
+			}
+		}
+		if attachmentsChanged {
+			if err := u.attachmentRepo.Replace(ctx, post.ID, post.Attachments); err != nil {
+				return err
+			}
+		}
+		if req.MediaIDs != nil {
+			if err := u.mediaRepo.ReplacePostMedia(ctx, post.ID, req.MediaIDs); err != nil {
+				return err
+			}
+		}
+
+		if tagsChanged {
+			return u.postRepo.AddTags(ctx, post.ID, req.Tags)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	if len(req.Tags) > 0 {
-		err = u.postRepo.AddTags(post.ID, req.Tags)
-		if err != nil {
-			return nil, err
-		}
 		post.Tags = req.Tags
 	}
-
+	if err := u.media.post(ctx, post); err != nil {
+		return nil, err
+	}
 	return post, nil
 }
 
-func (u *postUsecase) Delete(userID uint, postID uint) error {
-	post, err := u.postRepo.GetByID(postID)
+func (u *postUsecase) Delete(ctx context.Context, userID uint, postID uint) error {
+	post, err := u.postRepo.GetByID(ctx, postID)
 	if err != nil {
 		return err
 	}
 
 	if post.User.ID != userID {
-		return errors.New("unauthorized to delete this post")
+		return domain.Forbidden("unauthorized to delete this post")
 	}
 
-	return u.postRepo.Delete(postID)
+	if err := u.postRepo.Delete(ctx, postID); err != nil {
+		return err
+	}
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditPostDelete,
+		TargetType: domain.AuditTargetPost,
+		TargetID:   postID,
+	})
+	return nil
 }
 
-func (u *postUsecase) Like(userID uint, postID uint) error {
-	return u.postRepo.AddLike(postID, userID)
+func (u *postUsecase) Like(ctx context.Context, userID uint, postID uint) error {
+	return u.postRepo.AddLike(ctx, postID, userID)
 }
 
-func (u *postUsecase) Unlike(userID uint, postID uint) error {
-	return u.postRepo.RemoveLike(postID, userID)
+func (u *postUsecase) Unlike(ctx context.Context, userID uint, postID uint) error {
+	return u.postRepo.RemoveLike(ctx, postID, userID)
 }
 
-func (u *postUsecase) SavePost(userID, postID uint) error {
-	return u.postRepo.AddSave(postID, userID)
+func (u *postUsecase) SavePost(ctx context.Context, userID, postID uint) error {
+	return u.postRepo.AddSave(ctx, postID, userID)
 }
 
-func (u *postUsecase) UnsavePost(userID, postID uint) error {
-	return u.postRepo.RemoveSave(postID, userID)
+func (u *postUsecase) UnsavePost(ctx context.Context, userID, postID uint) error {
+	return u.postRepo.RemoveSave(ctx, postID, userID)
 }
 
-func (u *postUsecase) GetSavedPosts(userID uint) ([]domain.Post, error) {
-	return u.postRepo.GetSavedPosts(userID)
+func (u *postUsecase) GetSavedPosts(ctx context.Context, userID uint) ([]domain.Post, error) {
+	posts, err := u.postRepo.GetSavedPosts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.media.posts(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
 }
 
-func (u *postUsecase) Report(userID, postID uint) error {
-	return u.postRepo.AddReport(postID, userID)
+func (u *postUsecase) Report(ctx context.Context, userID, postID uint) error {
+	return u.postRepo.AddReport(ctx, postID, userID)
 }
 
-func (u *postUsecase) Unreport(userID, postID uint) error {
-	return u.postRepo.RemoveReport(postID, userID)
+func (u *postUsecase) Unreport(ctx context.Context, userID, postID uint) error {
+	return u.postRepo.RemoveReport(ctx, postID, userID)
+}
+func (u *postUsecase) IsLikedByUser(ctx context.Context, userID uint, postID uint) (bool, error) {
+	return u.postRepo.IsLikedByUser(ctx, postID, userID)
 }
-func (u *postUsecase) IsLikedByUser(userID uint, postID uint) (bool, error) {
-	return u.postRepo.IsLikedByUser(postID, userID)
+
+func (u *postUsecase) IsSavedByUser(ctx context.Context, userID uint, postID uint) (bool, error) {
+	return u.postRepo.IsSavedByUser(ctx, postID, userID)
 }
 
-func (u *postUsecase) IsSavedByUser(userID uint, postID uint) (bool, error) {
-	return u.postRepo.IsSavedByUser(postID, userID)
+func (u *postUsecase) IsReportedByUser(ctx context.Context, userID uint, postID uint) (bool, error) {
+	return u.postRepo.IsReportedByUser(ctx, postID, userID)
 }
 
-func (u *postUsecase) IsReportedByUser(userID uint, postID uint) (bool, error) {
-	return u.postRepo.IsReportedByUser(postID, userID)
+// GetRevisions lists earlier versions of a post, newest first. Only the author
+// and moderators can see them.
+func (u *postUsecase) GetRevisions(ctx context.Context, userID, postID uint) ([]domain.Revision, error) {
+	post, err := u.postRepo.GetByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	if post.User.ID != userID {
+		isModerator, err := u.isModerator(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isModerator {
+			return nil, domain.Forbidden("unauthorized to view revisions of this post")
+		}
+	}
+
+	return u.revisionRepo.GetByTarget(ctx, domain.RevisionTargetPost, postID)
+}
+
+// RollbackRevision lets a moderator put a post back to an earlier version. The
+// version being replaced is itself kept as a revision.
+func (u *postUsecase) RollbackRevision(ctx context.Context, userID, postID, revisionID uint) (*domain.Post, error) {
+	isModerator, err := u.isModerator(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isModerator {
+		return nil, domain.Forbidden("only moderators can roll back posts")
+	}
+
+	post, err := u.postRepo.GetByID(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := u.revisionRepo.GetByID(ctx, revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if revision.TargetType != domain.RevisionTargetPost || revision.TargetID != postID {
+		return nil, domain.NotFound("revision not found")
+	}
+	if err := u.loadAttachments(ctx, post); err != nil {
+		return nil, err
+	}
+
+	previous := *post
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Attachments = revision.Attachments
+	post.ImageURL = coverImage(revision.Attachments)
+	post.LinkURL = revision.LinkURL
+	post.UpdatedAt = time.Now()
+	post.Edited = true
+	post.EditedAt = &post.UpdatedAt
+
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.saveRevision(ctx, userID, &previous); err != nil {
+			return err
+		}
+		if err := u.postRepo.Update(ctx, post); err != nil {
+			return err
+		}
+		if err := u.attachmentRepo.Replace(ctx, post.ID, post.Attachments); err != nil {
+			return err
+		}
+		return u.postRepo.AddTags(ctx, post.ID, revision.Tags)
+	})
+	if err != nil {
+		return nil, err
+	}
+	post.Tags = revision.Tags
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditPostRollback,
+		TargetType: domain.AuditTargetPost,
+		TargetID:   postID,
+		Diff: auditDiff(map[string][2]string{
+			"title":    {previous.Title, post.Title},
+			"content":  {previous.Content, post.Content},
+			"link_url": {previous.LinkURL, post.LinkURL},
+		}),
+	})
+
+	if err := u.media.post(ctx, post); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+func (u *postUsecase) saveRevision(ctx context.Context, editorID uint, post *domain.Post) error {
+	tags, err := u.postRepo.GetTags(ctx, post.ID)
+	if err != nil {
+		return err
+	}
+
+	return u.revisionRepo.Create(ctx, &domain.Revision{
+		TargetType:  domain.RevisionTargetPost,
+		TargetID:    post.ID,
+		EditorID:    editorID,
+		Title:       post.Title,
+		Content:     post.Content,
+		ImageURL:    post.ImageURL,
+		LinkURL:     post.LinkURL,
+		Tags:        tags,
+		Attachments: post.Attachments,
+		CreatedAt:   time.Now(),
+	})
+}
+
+// sameTags reports whether two tag lists hold the same tags
+func sameTags(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
+
+// sameAttachments reports whether two attachment lists show the same files in
+// the same order with the same alt text
+func sameAttachments(a, b []domain.Attachment) bool {
+	return slices.EqualFunc(a, b, func(x, y domain.Attachment) bool {
+		return x.URL == y.URL && x.MimeType == y.MimeType && x.AltText == y.AltText
+	})
+}
+
+func (u *postUsecase) loadAttachments(ctx context.Context, post *domain.Post) error {
+	attachments, err := u.attachmentRepo.GetByPostIDs(ctx, []uint{post.ID})
+	if err != nil {
+		return err
+	}
+	post.Attachments = attachments[post.ID]
+	return nil
+}
+
+// resolveAttachments turns attachments from a client into the caller's
+// uploads, taking the URL, type and size from the stored upload.
+func (u *postUsecase) resolveAttachments(ctx context.Context, userID uint, inputs []domain.AttachmentInput) ([]domain.Attachment, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	if len(inputs) > domain.MaxAttachments {
+		return nil, domain.Validation(fmt.Sprintf("a post can have at most %d attachments", domain.MaxAttachments))
+	}
+
+	ids := make([]uint, len(inputs))
+	for i, in := range inputs {
+		ids[i] = in.UploadID
+	}
+	uploads, err := u.uploadRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]domain.Upload, len(uploads))
+	for _, upload := range uploads {
+		byID[upload.ID] = upload
+	}
+
+	attachments := make([]domain.Attachment, len(inputs))
+	for i, in := range inputs {
+		// Someone else's upload reads as missing, so IDs can't be probed
+		upload, ok := byID[in.UploadID]
+		if !ok || upload.OwnerID != userID {
+			return nil, domain.Validation(fmt.Sprintf("attachment %d: upload %d not found", i+1, in.UploadID))
+		}
+		if upload.Purpose != domain.UploadPurposePostAttachment && upload.Purpose != domain.UploadPurposePostImage {
+			return nil, domain.Validation(fmt.Sprintf("attachment %d: upload %d is not a post attachment", i+1, in.UploadID))
+		}
+		if upload.Status == domain.UploadStatusFailed {
+			return nil, domain.Validation(fmt.Sprintf("attachment %d: upload %d could not be processed", i+1, in.UploadID))
+		}
+
+		a := domain.Attachment{
+			UploadID: upload.ID,
+			URL:      upload.URL,
+			MimeType: upload.ContentType,
+			AltText:  strings.TrimSpace(in.AltText),
+			Width:    upload.Width,
+			Height:   upload.Height,
+		}
+		if !attachmentTypeAllowed(a.MimeType) {
+			return nil, domain.Validation(fmt.Sprintf("attachment %d must be an image, a PDF or audio", i+1))
+		}
+		if !a.IsImage() {
+			a.Width, a.Height = 0, 0
+		}
+		attachments[i] = a
+	}
+	return attachments, nil
+}
+
+// checkMedia makes sure every media item a post lists exists, once. Anyone's
+// media can be shared in a post, as media items are public.
+func (u *postUsecase) checkMedia(ctx context.Context, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > domain.MaxPostMedia {
+		return domain.Validation(fmt.Sprintf("a post can have at most %d media items", domain.MaxPostMedia))
+	}
+	seen := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return domain.Validation(fmt.Sprintf("media %d is listed more than once", id))
+		}
+		seen[id] = true
+	}
+
+	items, err := u.mediaRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		delete(seen, item.ID)
+	}
+	for _, id := range ids {
+		if seen[id] {
+			return domain.Validation(fmt.Sprintf("media %d not found", id))
+		}
+	}
+	return nil
+}
+
+func attachmentTypeAllowed(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/") ||
+		strings.HasPrefix(mimeType, "audio/") ||
+		mimeType == "application/pdf"
+}
+
+// legacyImage turns the image_url of older clients into an attachment. The
+// URL must be that of an upload; resolveAttachments checks whose.
+func (u *postUsecase) legacyImage(ctx context.Context, url string) (domain.AttachmentInput, error) {
+	uploads, err := u.uploadRepo.GetByURLs(ctx, []string{url})
+	if err != nil {
+		return domain.AttachmentInput{}, err
+	}
+	if len(uploads) == 0 || !strings.HasPrefix(uploads[0].ContentType, "image/") {
+		return domain.AttachmentInput{}, domain.Validation("image_url must be the URL of an image you uploaded")
+	}
+	return domain.AttachmentInput{UploadID: uploads[0].ID}, nil
+}
+
+// coverImage is the URL of the first image, which older clients show as the
+// post's image
+func coverImage(attachments []domain.Attachment) string {
+	for _, a := range attachments {
+		if a.IsImage() {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// replaceCover returns attachments with the first image swapped for image,
+// or with image added in front when there is none.
+func replaceCover(attachments []domain.Attachment, image domain.Attachment) ([]domain.Attachment, error) {
+	replaced := make([]domain.Attachment, 0, len(attachments)+1)
+	for i, a := range attachments {
+		if a.IsImage() {
+			replaced = append(replaced, attachments[:i]...)
+			replaced = append(replaced, image)
+			return append(replaced, attachments[i+1:]...), nil
+		}
+	}
+	if len(attachments) >= domain.MaxAttachments {
+		return nil, domain.Validation(fmt.Sprintf("a post can have at most %d attachments", domain.MaxAttachments))
+	}
+	replaced = append(replaced, image)
+	return append(replaced, attachments...), nil
+}
+
+func (u *postUsecase) isModerator(ctx context.Context, userID uint) (bool, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.IsModerator(), nil
 }
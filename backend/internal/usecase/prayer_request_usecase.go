@@ -2,7 +2,6 @@ package usecase
 
 import (
 	"context"
-	"errors"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 )
@@ -20,7 +19,7 @@ func NewPrayerRequestUsecase(repo domain.PrayerRequestRepository) domain.PrayerR
 
 func (u *prayerRequestUsecase) Create(ctx context.Context, pr *domain.PrayerRequest) error {
 	if pr.Content == "" {
-		return errors.New("prayer request content cannot be empty")
+		return domain.Validation("prayer request content cannot be empty")
 	}
 
 	return u.prayerRequestRepo.Create(ctx, pr)
@@ -39,7 +38,7 @@ func (u *prayerRequestUsecase) GetRandomPrayers(ctx context.Context, limit int)
 
 func (u *prayerRequestUsecase) Update(ctx context.Context, pr *domain.PrayerRequest) error {
 	if pr.Content == "" {
-		return errors.New("prayer request content cannot be empty")
+		return domain.Validation("prayer request content cannot be empty")
 	}
 
 	return u.prayerRequestRepo.Update(ctx, pr)
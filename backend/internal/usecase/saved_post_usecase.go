@@ -1,35 +1,40 @@
 package usecase
 
 import (
-	"fmt"
-
+	"context"
 	"github.com/ruth987/CHub.git/internal/domain"
 )
 
 type savedPostUsecase struct {
 	savedPostRepo domain.SavedPostRepository
 	postRepo      domain.PostRepository
+	media         media
 }
 
 func NewSavedPostUsecase(
 	savedPostRepo domain.SavedPostRepository,
 	postRepo domain.PostRepository,
+	uploadRepo domain.UploadRepository,
+	attachmentRepo domain.AttachmentRepository,
+	mediaRepo domain.MediaRepository,
+	previews domain.LinkPreviewUsecase,
 ) domain.SavedPostUsecase {
 	return &savedPostUsecase{
 		savedPostRepo: savedPostRepo,
 		postRepo:      postRepo,
+		media:         media{uploadRepo: uploadRepo, attachmentRepo: attachmentRepo, mediaRepo: mediaRepo, previews: previews},
 	}
 }
 
-func (u *savedPostUsecase) SavePost(userID, postID uint) error {
+func (u *savedPostUsecase) SavePost(ctx context.Context, userID, postID uint) error {
 	// Check if post exists
-	_, err := u.postRepo.GetByID(postID)
+	_, err := u.postRepo.GetByID(ctx, postID)
 	if err != nil {
 		return err
 	}
 
 	// Check if already saved
-	isSaved, err := u.savedPostRepo.IsSaved(userID, postID)
+	isSaved, err := u.savedPostRepo.IsSaved(ctx, userID, postID)
 	if err != nil {
 		return err
 	}
@@ -41,18 +46,35 @@ func (u *savedPostUsecase) SavePost(userID, postID uint) error {
 		UserID: userID,
 		PostID: postID,
 	}
-	return u.savedPostRepo.Create(savedPost)
+	return u.savedPostRepo.Create(ctx, savedPost)
 }
 
-func (u *savedPostUsecase) UnsavePost(userID, postID uint) error {
-	return u.savedPostRepo.Delete(userID, postID)
+func (u *savedPostUsecase) UnsavePost(ctx context.Context, userID, postID uint) error {
+	return u.savedPostRepo.Delete(ctx, userID, postID)
 }
 
-func (u *savedPostUsecase) GetSavedPosts(userID uint) ([]domain.SavedPost, error) {
-	fmt.Println("GetSavedPosts usecase")
-	return u.savedPostRepo.GetByUserID(userID)
+func (u *savedPostUsecase) GetSavedPosts(ctx context.Context, userID uint) ([]domain.SavedPost, error) {
+	saved, err := u.savedPostRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []*domain.Post
+	var users []*domain.User
+	for i := range saved {
+		if saved[i].Post != nil {
+			posts = append(posts, saved[i].Post)
+		}
+		if saved[i].User != nil {
+			users = append(users, saved[i].User)
+		}
+	}
+	if err := u.media.attach(ctx, posts, users); err != nil {
+		return nil, err
+	}
+	return saved, nil
 }
 
-func (u *savedPostUsecase) IsSaved(userID, postID uint) (bool, error) {
-	return u.savedPostRepo.IsSaved(userID, postID)
+func (u *savedPostUsecase) IsSaved(ctx context.Context, userID, postID uint) (bool, error) {
+	return u.savedPostRepo.IsSaved(ctx, userID, postID)
 }
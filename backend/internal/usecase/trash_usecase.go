@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type trashUsecase struct {
+	postRepo    domain.PostRepository
+	commentRepo domain.CommentRepository
+}
+
+func NewTrashUsecase(pr domain.PostRepository, cr domain.CommentRepository) domain.TrashUsecase {
+	return &trashUsecase{
+		postRepo:    pr,
+		commentRepo: cr,
+	}
+}
+
+func (u *trashUsecase) GetTrash(ctx context.Context, userID uint) (*domain.Trash, error) {
+	since := time.Now().Add(-domain.TrashRetention)
+
+	posts, err := u.postRepo.GetDeletedByUserID(ctx, userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, err := u.commentRepo.GetDeletedByUserID(ctx, userID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.Trash{
+		Posts:    posts,
+		Comments: comments,
+	}, nil
+}
+
+func (u *trashUsecase) RestorePost(ctx context.Context, userID, postID uint) error {
+	post, err := u.postRepo.GetDeletedByID(ctx, postID)
+	if err != nil {
+		return err
+	}
+
+	if post.User.ID != userID {
+		return domain.Forbidden("unauthorized to restore this post")
+	}
+	if isExpired(post.DeletedAt) {
+		return domain.Conflict("post can no longer be restored")
+	}
+
+	return u.postRepo.Restore(ctx, postID)
+}
+
+func (u *trashUsecase) RestoreComment(ctx context.Context, userID, commentID uint) error {
+	comment, err := u.commentRepo.GetDeletedByID(ctx, commentID)
+	if err != nil {
+		return err
+	}
+
+	if comment.UserID != userID {
+		return domain.Forbidden("unauthorized to restore this comment")
+	}
+	if isExpired(comment.DeletedAt) {
+		return domain.Conflict("comment can no longer be restored")
+	}
+
+	// A comment can't come back onto a post that is itself in the trash
+	if _, err := u.postRepo.GetByID(ctx, comment.PostID); err != nil {
+		return err
+	}
+
+	return u.commentRepo.Restore(ctx, commentID)
+}
+
+func (u *trashUsecase) Purge(ctx context.Context) (int64, int64, error) {
+	before := time.Now().Add(-domain.TrashRetention)
+
+	posts, err := u.postRepo.PurgeDeleted(ctx, before)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	comments, err := u.commentRepo.PurgeDeleted(ctx, before)
+	if err != nil {
+		return posts, 0, err
+	}
+
+	return posts, comments, nil
+}
+
+func isExpired(deletedAt *time.Time) bool {
+	return deletedAt == nil || time.Since(*deletedAt) > domain.TrashRetention
+}
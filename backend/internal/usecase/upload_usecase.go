@@ -0,0 +1,588 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/imaging"
+	"github.com/ruth987/CHub.git/pkg/storage"
+)
+
+// uploadPolicy limits what may be uploaded for one purpose.
+type uploadPolicy struct {
+	// prefix is the object store folder for the purpose
+	prefix  string
+	maxSize int64
+	// types maps each allowed sniffed MIME type to its file extension
+	types map[string]string
+	// kinds describes the allowed types in error messages
+	kinds string
+}
+
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"audio/mpeg":      ".mp3",
+	"audio/wave":      ".wav",
+}
+
+var sermonMediaTypes = map[string]string{
+	"audio/mpeg": ".mp3",
+	"audio/wave": ".wav",
+	"video/mp4":  ".mp4",
+	"video/webm": ".webm",
+}
+
+const (
+	imageKinds       = "JPEG, PNG or WebP images"
+	attachmentKinds  = "JPEG, PNG or WebP images, PDFs, or MP3 or WAV audio"
+	sermonMediaKinds = "MP3 or WAV audio, or MP4 or WebM video"
+)
+
+var uploadPolicies = map[domain.UploadPurpose]uploadPolicy{
+	domain.UploadPurposeAvatar:          {prefix: "avatars", maxSize: 2 << 20, types: imageTypes, kinds: imageKinds},
+	domain.UploadPurposePostImage:       {prefix: "posts", maxSize: 10 << 20, types: imageTypes, kinds: imageKinds},
+	domain.UploadPurposePostAttachment:  {prefix: "attachments", maxSize: 25 << 20, types: attachmentTypes, kinds: attachmentKinds},
+	domain.UploadPurposeDevotionalImage: {prefix: "devotionals", maxSize: 10 << 20, types: imageTypes, kinds: imageKinds},
+	domain.UploadPurposeSermonMedia:     {prefix: "media", maxSize: 2 << 30, types: sermonMediaTypes, kinds: sermonMediaKinds},
+}
+
+// sniffLen is how much of a file http.DetectContentType looks at
+const sniffLen = 512
+
+const (
+	// sessionTTL is how long a presigned upload request stays valid
+	sessionTTL = 15 * time.Minute
+	// finalizeWindow is how long after its request expires a session can
+	// still be finalized, and so how long an abandoned upload is kept
+	finalizeWindow = time.Hour
+	// cleanupBatch bounds how many abandoned sessions one cleanup removes
+	cleanupBatch = 100
+	// gcBatch bounds how many orphaned uploads one collection looks at
+	gcBatch = 500
+)
+
+// collectablePurposes are the uploads that garbage collection may delete.
+// Devotional images have nothing that references them yet, so they are kept.
+var collectablePurposes = []domain.UploadPurpose{
+	domain.UploadPurposeAvatar,
+	domain.UploadPurposePostImage,
+	domain.UploadPurposePostAttachment,
+	domain.UploadPurposeSermonMedia,
+}
+
+type uploadUsecase struct {
+	uploadRepo  domain.UploadRepository
+	sessionRepo domain.UploadSessionRepository
+	userRepo    domain.UserRepository
+	postUsecase domain.PostUsecase
+	store       storage.ObjectStore
+	queue       domain.ImageQueue
+	transactor  domain.Transactor
+	logger      *slog.Logger
+}
+
+func NewUploadUsecase(
+	ur domain.UploadRepository,
+	sr domain.UploadSessionRepository,
+	userRepo domain.UserRepository,
+	pu domain.PostUsecase,
+	store storage.ObjectStore,
+	queue domain.ImageQueue,
+	tx domain.Transactor,
+	logger *slog.Logger,
+) domain.UploadUsecase {
+	return &uploadUsecase{
+		uploadRepo:  ur,
+		sessionRepo: sr,
+		userRepo:    userRepo,
+		postUsecase: pu,
+		store:       store,
+		queue:       queue,
+		transactor:  tx,
+		logger:      logger,
+	}
+}
+
+// Upload checks a file against its purpose's policy, strips its metadata,
+// stores it under a key chosen here and records who uploaded it. The type
+// comes from the file's leading bytes; whatever the client claimed is
+// ignored. Resized variants of images are rendered in the background.
+func (u *uploadUsecase) Upload(ctx context.Context, ownerID uint, purpose domain.UploadPurpose, file domain.UploadFile) (*domain.Upload, error) {
+	policy, err := policyFor(purpose, file.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	body, contentType, err := readUpload(file.Body, purpose, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := uploadKey(policy.prefix, ownerID, policy.types[contentType])
+	if err != nil {
+		return nil, err
+	}
+	if err := u.store.Put(ctx, key, body, contentType); err != nil {
+		// A body that ran past the cap is the client's fault
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			return nil, domainErr
+		}
+		return nil, fmt.Errorf("failed to store upload: %w", err)
+	}
+
+	upload := &domain.Upload{
+		OwnerID:     ownerID,
+		Purpose:     purpose,
+		Key:         key,
+		URL:         u.store.URL(key),
+		ContentType: contentType,
+		Size:        body.n,
+		Status:      initialStatus(contentType),
+		CreatedAt:   time.Now(),
+	}
+	if err := u.uploadRepo.Create(ctx, upload); err != nil {
+		u.deleteObject(ctx, key)
+		return nil, err
+	}
+	if upload.Status == domain.UploadStatusProcessing {
+		u.queue.Enqueue(upload.ID)
+	}
+
+	return upload, nil
+}
+
+// CreateSession reserves a key for a file the client will upload straight to
+// storage. The presigned request pins the declared type and size; finalizing
+// checks the file itself.
+func (u *uploadUsecase) CreateSession(ctx context.Context, ownerID uint, req *domain.CreateUploadSessionRequest) (*domain.UploadSession, error) {
+	policy, err := policyFor(req.Purpose, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	ext, ok := policy.types[req.ContentType]
+	if !ok {
+		return nil, domain.Validation(fmt.Sprintf("%s uploads must be %s", req.Purpose, policy.kinds))
+	}
+
+	key, err := uploadKey(policy.prefix, ownerID, ext)
+	if err != nil {
+		return nil, err
+	}
+	presigned, err := u.store.PresignPut(ctx, key, req.ContentType, req.Size, sessionTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign upload: %w", err)
+	}
+
+	session := &domain.UploadSession{
+		OwnerID:     ownerID,
+		Purpose:     req.Purpose,
+		Key:         key,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		ExpiresAt:   presigned.ExpiresAt,
+		CreatedAt:   time.Now(),
+	}
+	if err := u.sessionRepo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	session.Request = &domain.PresignedUpload{
+		Method:  presigned.Method,
+		URL:     presigned.URL,
+		Headers: presigned.Headers,
+	}
+
+	return session, nil
+}
+
+// FinalizeSession checks the file uploaded for a session the same way Upload
+// checks one sent through the API, then records it. The upload, the session
+// and the post or avatar it is attached to change together.
+func (u *uploadUsecase) FinalizeSession(ctx context.Context, ownerID, sessionID uint, req *domain.FinalizeUploadRequest) (*domain.Upload, error) {
+	session, err := u.sessionRepo.GetByID(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.OwnerID != ownerID {
+		return nil, domain.NotFound("upload session not found")
+	}
+	if session.FinalizedAt != nil {
+		return nil, domain.Conflict("upload session has already been finalized")
+	}
+	if time.Now().After(session.ExpiresAt.Add(finalizeWindow)) {
+		return nil, domain.Validation("upload session has expired")
+	}
+	if req.PostID != 0 && session.Purpose != domain.UploadPurposePostImage {
+		return nil, domain.Validation("only post images can be attached to a post")
+	}
+
+	policy, err := policyFor(session.Purpose, session.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := u.store.Stat(ctx, session.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, domain.Validation("file has not been uploaded")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check upload: %w", err)
+	}
+	if info.Size != session.Size {
+		u.deleteObject(ctx, session.Key)
+		return nil, domain.Validation(fmt.Sprintf("uploaded file is %d bytes, expected %d", info.Size, session.Size))
+	}
+
+	object, err := u.store.Get(ctx, session.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer object.Close()
+	body, contentType, err := readUpload(object, session.Purpose, policy)
+	if err == nil && contentType != session.ContentType {
+		err = domain.Validation(fmt.Sprintf("uploaded file is %s, expected %s", contentType, session.ContentType))
+	}
+	if err != nil {
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			u.deleteObject(ctx, session.Key)
+		}
+		return nil, err
+	}
+
+	// Replace the client's image with the stripped copy before the URL is
+	// handed out anywhere. Other files are only sniffed and stay as they are;
+	// the store already held them to the signed size.
+	size := info.Size
+	if isImage(contentType) {
+		if err := u.store.Put(ctx, session.Key, body, contentType); err != nil {
+			return nil, fmt.Errorf("failed to store upload: %w", err)
+		}
+		size = body.n
+	}
+
+	upload := &domain.Upload{
+		OwnerID:     ownerID,
+		Purpose:     session.Purpose,
+		Key:         session.Key,
+		URL:         u.store.URL(session.Key),
+		ContentType: contentType,
+		Size:        size,
+		Status:      initialStatus(contentType),
+		CreatedAt:   time.Now(),
+	}
+	err = u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.uploadRepo.Create(ctx, upload); err != nil {
+			return err
+		}
+		if err := u.sessionRepo.MarkFinalized(ctx, session.ID, upload.ID); err != nil {
+			return err
+		}
+		return u.attach(ctx, ownerID, upload, req.PostID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if upload.Status == domain.UploadStatusProcessing {
+		u.queue.Enqueue(upload.ID)
+	}
+
+	return upload, nil
+}
+
+// attach makes a finalized upload the caller's avatar or a post's image.
+// Other uploads are only recorded.
+func (u *uploadUsecase) attach(ctx context.Context, ownerID uint, upload *domain.Upload, postID uint) error {
+	switch {
+	case upload.Purpose == domain.UploadPurposeAvatar:
+		user, err := u.userRepo.GetByID(ctx, ownerID)
+		if err != nil {
+			return err
+		}
+		user.AvatarURL = upload.URL
+		user.UpdatedAt = time.Now()
+		return u.userRepo.Update(ctx, user)
+	case postID != 0:
+		// Going through the post usecase checks ownership and keeps a revision
+		_, err := u.postUsecase.Update(ctx, ownerID, postID, &domain.UpdatePostRequest{ImageURL: upload.URL})
+		return err
+	default:
+		return nil
+	}
+}
+
+// CleanupSessions removes sessions whose finalize window has passed, along
+// with whatever the client uploaded for them.
+func (u *uploadUsecase) CleanupSessions(ctx context.Context) (int, error) {
+	sessions, err := u.sessionRepo.GetAbandoned(ctx, time.Now().Add(-finalizeWindow), cleanupBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, session := range sessions {
+		if err := u.store.Delete(ctx, session.Key); err != nil {
+			return removed, fmt.Errorf("failed to delete abandoned upload: %w", err)
+		}
+		if err := u.sessionRepo.Delete(ctx, session.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// ProcessImage renders the upload's variants from the stored original. Keys
+// are derived from the original's, so running it twice just overwrites the
+// same objects.
+func (u *uploadUsecase) ProcessImage(ctx context.Context, uploadID uint) error {
+	upload, err := u.uploadRepo.GetByID(ctx, uploadID)
+	if err != nil {
+		return err
+	}
+	if upload.Status != domain.UploadStatusProcessing {
+		return nil
+	}
+
+	original, err := u.store.Get(ctx, upload.Key)
+	if err != nil {
+		return fmt.Errorf("failed to read original: %w", err)
+	}
+	data, err := io.ReadAll(original)
+	original.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read original: %w", err)
+	}
+
+	// Uploads from before the url column was added get theirs here
+	upload.URL = u.store.URL(upload.Key)
+
+	result, err := imaging.Render(data, imaging.Variants)
+	if err != nil {
+		// Sniffing passed but the image is corrupt or too big to decode.
+		// Retrying won't help, so record the failure and keep the original.
+		u.logger.WarnContext(ctx, "failed to process image", "upload_id", upload.ID, "error", err)
+		upload.Status = domain.UploadStatusFailed
+		return u.uploadRepo.UpdateProcessed(ctx, upload)
+	}
+
+	upload.Width, upload.Height = result.Width, result.Height
+	upload.Variants = nil
+	for _, img := range result.Images {
+		key := variantKey(upload.Key, img.Variant.Name, img.Ext)
+		if err := u.store.Put(ctx, key, bytes.NewReader(img.Data), img.ContentType); err != nil {
+			return fmt.Errorf("failed to store %s variant: %w", img.Variant.Name, err)
+		}
+		upload.Variants = append(upload.Variants, domain.ImageVariant{
+			Name:        img.Variant.Name,
+			Key:         key,
+			URL:         u.store.URL(key),
+			ContentType: img.ContentType,
+			Width:       img.Width,
+			Height:      img.Height,
+		})
+	}
+	upload.Status = domain.UploadStatusReady
+
+	return u.uploadRepo.UpdateProcessed(ctx, upload)
+}
+
+// stalledBatch bounds how many stalled uploads one sweep picks up
+const stalledBatch = 100
+
+func (u *uploadUsecase) Stalled(ctx context.Context, age time.Duration) ([]uint, error) {
+	uploads, err := u.uploadRepo.GetProcessing(ctx, time.Now().Add(-age), stalledBatch)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uint, len(uploads))
+	for i, upload := range uploads {
+		ids[i] = upload.ID
+	}
+	return ids, nil
+}
+
+// initialStatus is processing for images, which get variants, and ready for
+// everything else
+func initialStatus(contentType string) domain.UploadStatus {
+	if isImage(contentType) {
+		return domain.UploadStatusProcessing
+	}
+	return domain.UploadStatusReady
+}
+
+func isImage(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/")
+}
+
+// policyFor returns the policy for purpose after checking the declared size
+// against it.
+func policyFor(purpose domain.UploadPurpose, size int64) (uploadPolicy, error) {
+	policy, ok := uploadPolicies[purpose]
+	if !ok {
+		return uploadPolicy{}, domain.Validation(fmt.Sprintf("unknown upload purpose %q", purpose))
+	}
+	if size > policy.maxSize {
+		return uploadPolicy{}, domain.TooLarge(fmt.Sprintf("%s uploads are limited to %d bytes", purpose, policy.maxSize))
+	}
+	return policy, nil
+}
+
+// readUpload checks a file against policy and returns it for storing. The
+// type comes from the file's leading bytes. Images are read whole so their
+// metadata can be stripped; anything else streams through, failing once it
+// runs past the cap.
+func readUpload(r io.Reader, purpose domain.UploadPurpose, policy uploadPolicy) (*cappedReader, string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	if n == 0 {
+		return nil, "", domain.Validation("file is empty")
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if _, ok := policy.types[contentType]; !ok {
+		return nil, "", domain.Validation(fmt.Sprintf("%s uploads must be %s", purpose, policy.kinds))
+	}
+
+	tooLarge := domain.TooLarge(fmt.Sprintf("%s uploads are limited to %d bytes", purpose, policy.maxSize))
+	body := &cappedReader{r: io.MultiReader(bytes.NewReader(head), r), max: policy.maxSize, err: tooLarge}
+	if !isImage(contentType) {
+		return body, contentType, nil
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	// The original is public as soon as it is stored, so its location and
+	// camera details go now rather than when the variants are ready
+	data, err = imaging.StripMetadata(data, contentType)
+	if err != nil {
+		return nil, "", domain.Validation("file is not a valid image")
+	}
+	return &cappedReader{r: bytes.NewReader(data), max: int64(len(data)), err: tooLarge}, contentType, nil
+}
+
+// cappedReader counts what is read through it and fails with err once more
+// than max bytes have gone by, so an understated size is caught.
+type cappedReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+	err error
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.n > c.max {
+		return n, c.err
+	}
+	return n, err
+}
+
+// CollectGarbage deletes uploads that no post, revision or profile has used
+// for at least grace, so replaced avatars and purged posts don't keep their
+// files forever. The grace period covers files that were uploaded but not
+// attached yet.
+func (u *uploadUsecase) CollectGarbage(ctx context.Context, grace time.Duration, dryRun bool) (*domain.MediaGCReport, error) {
+	orphans, err := u.uploadRepo.GetUnreferenced(ctx, collectablePurposes, time.Now().Add(-grace), gcBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &domain.MediaGCReport{DryRun: dryRun, Orphans: orphans}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, upload := range orphans {
+		var deleted bool
+		// The row goes only once its objects are gone, so a failure leaves
+		// it for the next run to retry
+		err := u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+			var err error
+			deleted, err = u.uploadRepo.DeleteUnreferenced(ctx, upload.ID)
+			if err != nil || !deleted {
+				return err
+			}
+			for _, key := range uploadObjectKeys(&upload) {
+				if err := u.store.Delete(ctx, key); err != nil {
+					return fmt.Errorf("failed to delete %s: %w", key, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return report, err
+		}
+		// An upload that was attached since the scan is skipped
+		if deleted {
+			report.Deleted++
+			report.Bytes += upload.Size
+		}
+	}
+	return report, nil
+}
+
+// uploadObjectKeys lists every object stored for an upload
+func uploadObjectKeys(upload *domain.Upload) []string {
+	keys := []string{upload.Key}
+	for _, v := range upload.Variants {
+		keys = append(keys, v.Key)
+	}
+	return keys
+}
+
+// deleteObject removes an object that will not be recorded. Failing only
+// leaves an orphan behind, so it is logged rather than returned.
+func (u *uploadUsecase) deleteObject(ctx context.Context, key string) {
+	if err := u.store.Delete(context.WithoutCancel(ctx), key); err != nil {
+		u.logger.Warn("failed to delete rejected upload", "key", key, "error", err)
+	}
+}
+
+// uploadKey returns a fresh, unguessable key such as
+// "avatars/42/5f1c….jpg".
+func uploadKey(prefix string, ownerID uint, ext string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d/%s%s", prefix, ownerID, hex.EncodeToString(raw), ext), nil
+}
+
+// variantKey places a variant beside its original, so
+// "avatars/42/5f1c.png" becomes "avatars/42/5f1c_thumbnail.jpg".
+func variantKey(key, name, ext string) string {
+	return strings.TrimSuffix(key, path.Ext(key)) + "_" + name + ext
+}
@@ -0,0 +1,311 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+	"github.com/ruth987/CHub.git/pkg/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errInvalidToken = domain.Validation("invalid or expired token")
+
+// SendVerificationEmail issues a fresh verification link for the user's
+// current address. Earlier links stop working.
+func (u *userUsecase) SendVerificationEmail(ctx context.Context, userID uint) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return domain.Conflict("email is already verified")
+	}
+
+	if err := u.tokenRepo.InvalidateForUser(ctx, user.ID, domain.TokenPurposeVerifyEmail); err != nil {
+		return err
+	}
+	token, err := u.issueToken(ctx, user.ID, domain.TokenPurposeVerifyEmail, "", domain.VerifyEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, u.link("/verify-email", token), domain.VerifyEmailTokenTTL,
+		),
+	})
+}
+
+func (u *userUsecase) VerifyEmail(ctx context.Context, req *domain.VerifyEmailRequest) error {
+	// The token is only spent if the user ends up verified
+	return u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		token, err := u.consumeToken(ctx, domain.TokenPurposeVerifyEmail, req.Token)
+		if err != nil {
+			return err
+		}
+
+		user, err := u.userRepo.GetByID(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		return u.userRepo.SetEmailVerified(ctx, user.ID, user.Email)
+	})
+}
+
+// ForgotPassword emails a reset link. It reports success for unknown
+// addresses so the endpoint can't be used to discover accounts.
+func (u *userUsecase) ForgotPassword(ctx context.Context, req *domain.ForgotPasswordRequest) error {
+	user, err := u.userRepo.GetByEmail(ctx, req.Email)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := u.issueToken(ctx, user.ID, domain.TokenPurposeResetPassword, "", domain.ResetPasswordTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	return u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your account. If it was you, open the link below:\n\n%s\n\nThe link expires in %s. If you didn't ask for this you can ignore this email.\n",
+			user.Username, u.link("/reset-password", token), domain.ResetPasswordTokenTTL,
+		),
+	})
+}
+
+func (u *userUsecase) ResetPassword(ctx context.Context, req *domain.ResetPasswordRequest) error {
+	var userID uint
+	err := u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		token, err := u.consumeToken(ctx, domain.TokenPurposeResetPassword, req.Token)
+		if err != nil {
+			return err
+		}
+
+		if _, err := u.setPassword(ctx, token.UserID, req.Password); err != nil {
+			return err
+		}
+
+		// Any other reset links that are still out there are now stale
+		userID = token.UserID
+		return u.tokenRepo.InvalidateForUser(ctx, token.UserID, domain.TokenPurposeResetPassword)
+	})
+	if err != nil {
+		return err
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditPasswordReset,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+	})
+	return nil
+}
+
+func (u *userUsecase) ChangePassword(ctx context.Context, userID uint, req *domain.ChangePasswordRequest) (string, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+		return "", domain.Validation("current password is incorrect")
+	}
+
+	version, err := u.setPassword(ctx, user.ID, req.NewPassword)
+	if err != nil {
+		return "", err
+	}
+	// Every other session is signed out; this one carries on with a new token
+	token, err := u.jwtService.GenerateToken(user.ID, version)
+	if err != nil {
+		return "", err
+	}
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditPasswordChange,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+	})
+
+	// The change has gone through and old tokens are dead; a failed notice
+	// mustn't leave the caller without the new one
+	err = u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Your password was changed",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nThe password for your account was just changed. If this wasn't you, reset your password right away.\n",
+			user.Username,
+		),
+	})
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to send password change notice", "user_id", user.ID, "error", err)
+	}
+	return token, nil
+}
+
+// ChangeEmail sends a confirmation link to the new address. The account keeps
+// its current email until the link is followed.
+func (u *userUsecase) ChangeEmail(ctx context.Context, userID uint, req *domain.ChangeEmailRequest) error {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return domain.Validation("password is incorrect")
+	}
+	if req.NewEmail == user.Email {
+		return domain.Validation("new email is the same as the current email")
+	}
+	if existing, err := u.userRepo.GetByEmail(ctx, req.NewEmail); err == nil && existing.ID != user.ID {
+		return domain.Conflict("email is already in use")
+	}
+
+	if err := u.tokenRepo.InvalidateForUser(ctx, user.ID, domain.TokenPurposeChangeEmail); err != nil {
+		return err
+	}
+	token, err := u.issueToken(ctx, user.ID, domain.TokenPurposeChangeEmail, req.NewEmail, domain.ChangeEmailTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	err = u.mailer.Send(&mailer.Message{
+		To:      req.NewEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nOpen the link below to make this your account's email address:\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, u.link("/confirm-email", token), domain.ChangeEmailTokenTTL,
+		),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The confirmation link is already out, so the request has succeeded
+	// whether or not the old address hears about it
+	err = u.mailer.Send(&mailer.Message{
+		To:      user.Email,
+		Subject: "Email change requested",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nA request was made to change your account's email address to %s. If this wasn't you, change your password right away.\n",
+			user.Username, req.NewEmail,
+		),
+	})
+	if err != nil {
+		u.logger.ErrorContext(ctx, "failed to send email change notice", "user_id", user.ID, "error", err)
+	}
+	return nil
+}
+
+func (u *userUsecase) ConfirmEmailChange(ctx context.Context, req *domain.VerifyEmailRequest) (*domain.User, error) {
+	var userID uint
+	var oldEmail, newEmail string
+	err := u.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		token, err := u.consumeToken(ctx, domain.TokenPurposeChangeEmail, req.Token)
+		if err != nil {
+			return err
+		}
+
+		if existing, err := u.userRepo.GetByEmail(ctx, token.NewEmail); err == nil && existing.ID != token.UserID {
+			return domain.Conflict("email is already in use")
+		}
+
+		user, err := u.userRepo.GetByID(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		userID, oldEmail, newEmail = user.ID, user.Email, token.NewEmail
+		return u.userRepo.SetEmailVerified(ctx, token.UserID, token.NewEmail)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    userID,
+		Action:     domain.AuditEmailChange,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   userID,
+		Diff:       map[string]domain.AuditChange{"email": {Old: oldEmail, New: newEmail}},
+	})
+
+	return u.GetProfile(ctx, userID)
+}
+
+func (u *userUsecase) setPassword(ctx context.Context, userID uint, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+	return u.userRepo.SetPassword(ctx, userID, string(hashedPassword))
+}
+
+// issueToken stores a new token and returns its plaintext, which is only ever
+// sent to the user.
+func (u *userUsecase) issueToken(ctx context.Context, userID uint, purpose, newEmail string, ttl time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	plaintext := hex.EncodeToString(raw)
+
+	now := time.Now()
+	err := u.tokenRepo.Create(ctx, &domain.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(plaintext),
+		NewEmail:  newEmail,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return plaintext, nil
+}
+
+func (u *userUsecase) consumeToken(ctx context.Context, purpose, plaintext string) (*domain.UserToken, error) {
+	token, err := u.tokenRepo.GetByHash(ctx, purpose, hashToken(plaintext))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, errInvalidToken
+	}
+	err = u.tokenRepo.MarkUsed(ctx, token.ID)
+	if errors.Is(err, domain.ErrConflict) {
+		return nil, errInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (u *userUsecase) link(path, token string) string {
+	return u.appURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
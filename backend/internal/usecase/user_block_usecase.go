@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type userBlockUsecase struct {
+	blockRepo domain.UserBlockRepository
+	userRepo  domain.UserRepository
+}
+
+func NewUserBlockUsecase(br domain.UserBlockRepository, ur domain.UserRepository) domain.UserBlockUsecase {
+	return &userBlockUsecase{
+		blockRepo: br,
+		userRepo:  ur,
+	}
+}
+
+func (u *userBlockUsecase) Block(ctx context.Context, blockerID, blockedID uint) error {
+	if blockerID == blockedID {
+		return domain.Validation("you cannot block yourself")
+	}
+
+	// Verify the user being blocked exists
+	if _, err := u.userRepo.GetByID(ctx, blockedID); err != nil {
+		return err
+	}
+
+	return u.blockRepo.Create(ctx, blockerID, blockedID)
+}
+
+func (u *userBlockUsecase) Unblock(ctx context.Context, blockerID, blockedID uint) error {
+	return u.blockRepo.Delete(ctx, blockerID, blockedID)
+}
+
+func (u *userBlockUsecase) GetBlockedUsers(ctx context.Context, blockerID uint) ([]domain.UserBlock, error) {
+	return u.blockRepo.GetByBlockerID(ctx, blockerID)
+}
+
+func (u *userBlockUsecase) IsBlockedBetween(ctx context.Context, userID, otherUserID uint) (bool, error) {
+	return u.blockRepo.ExistsBetween(ctx, userID, otherUserID)
+}
@@ -0,0 +1,40 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+type userMuteUsecase struct {
+	muteRepo domain.UserMuteRepository
+	userRepo domain.UserRepository
+}
+
+func NewUserMuteUsecase(mr domain.UserMuteRepository, ur domain.UserRepository) domain.UserMuteUsecase {
+	return &userMuteUsecase{
+		muteRepo: mr,
+		userRepo: ur,
+	}
+}
+
+func (u *userMuteUsecase) Mute(ctx context.Context, muterID, mutedID uint) error {
+	if muterID == mutedID {
+		return domain.Validation("you cannot mute yourself")
+	}
+
+	// Verify the user being muted exists
+	if _, err := u.userRepo.GetByID(ctx, mutedID); err != nil {
+		return err
+	}
+
+	return u.muteRepo.Create(ctx, muterID, mutedID)
+}
+
+func (u *userMuteUsecase) Unmute(ctx context.Context, muterID, mutedID uint) error {
+	return u.muteRepo.Delete(ctx, muterID, mutedID)
+}
+
+func (u *userMuteUsecase) GetMutedUsers(ctx context.Context, muterID uint) ([]domain.UserMute, error) {
+	return u.muteRepo.GetByMuterID(ctx, muterID)
+}
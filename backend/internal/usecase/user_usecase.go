@@ -1,40 +1,75 @@
 package usecase
 
 import (
+	"context"
 	"errors"
-	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/ruth987/CHub.git/internal/domain"
 	"github.com/ruth987/CHub.git/pkg/auth"
+	"github.com/ruth987/CHub.git/pkg/mailer"
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type userUsecase struct {
 	userRepo   domain.UserRepository
+	blockRepo  domain.UserBlockRepository
+	tokenRepo  domain.UserTokenRepository
+	transactor domain.Transactor
 	jwtService *auth.JWTService
+	mailer     mailer.Mailer
+	lockout    *ratelimit.Lockout
+	loginLimit *ratelimit.Limiter
+	media      media
+	audit      domain.AuditUsecase
+	logger     *slog.Logger
+	// appURL is the frontend origin used to build links in emails
+	appURL string
 }
 
-func NewUserUsecase(userRepo domain.UserRepository, jwtService *auth.JWTService) domain.UserUsecase {
+func NewUserUsecase(
+	userRepo domain.UserRepository,
+	blockRepo domain.UserBlockRepository,
+	tokenRepo domain.UserTokenRepository,
+	uploadRepo domain.UploadRepository,
+	attachmentRepo domain.AttachmentRepository,
+	mediaRepo domain.MediaRepository,
+	previews domain.LinkPreviewUsecase,
+	audit domain.AuditUsecase,
+	tx domain.Transactor,
+	jwtService *auth.JWTService,
+	m mailer.Mailer,
+	lockout *ratelimit.Lockout,
+	loginLimit *ratelimit.Limiter,
+	logger *slog.Logger,
+	appURL string,
+) domain.UserUsecase {
 	return &userUsecase{
 		userRepo:   userRepo,
+		blockRepo:  blockRepo,
+		tokenRepo:  tokenRepo,
+		transactor: tx,
 		jwtService: jwtService,
+		mailer:     m,
+		lockout:    lockout,
+		loginLimit: loginLimit,
+		media:      media{uploadRepo: uploadRepo, attachmentRepo: attachmentRepo, mediaRepo: mediaRepo, previews: previews},
+		audit:      audit,
+		logger:     logger,
+		appURL:     strings.TrimRight(appURL, "/"),
 	}
 }
 
-func (u *userUsecase) Register(req *domain.RegisterRequest) (*domain.User, error) {
-	fmt.Printf("Registration attempt - Email: %s, Password length: %d\n",
-		req.Email, len(req.Password))
-
+func (u *userUsecase) Register(ctx context.Context, req *domain.RegisterRequest) (*domain.User, error) {
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
-		fmt.Printf("Password hashing failed: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("Password successfully hashed, length: %d\n", len(hashedPassword))
-
 	now := time.Now()
 	user := &domain.User{
 		Username:  req.Username,
@@ -44,93 +79,216 @@ func (u *userUsecase) Register(req *domain.RegisterRequest) (*domain.User, error
 		UpdatedAt: now,
 	}
 
-	err = u.userRepo.Create(user)
+	err = u.userRepo.Create(ctx, user)
 	if err != nil {
-		fmt.Printf("User creation failed: %v\n", err)
 		return nil, err
 	}
 
-	fmt.Printf("User successfully registered with ID: %d\n", user.ID)
+	u.logger.InfoContext(ctx, "user registered", "user_id", user.ID)
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditSignup,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+	})
+
+	// A failed email shouldn't fail registration; the user can ask for a resend
+	if err := u.SendVerificationEmail(ctx, user.ID); err != nil {
+		u.logger.ErrorContext(ctx, "failed to send verification email", "user_id", user.ID, "error", err)
+	}
 
 	// Don't return the password
 	user.Password = ""
 	return user, nil
 }
 
-func (u *userUsecase) Login(req *domain.LoginRequest) (*domain.LoginResponse, error) {
-	fmt.Printf("Login attempt for email: %s with password: %s\n", req.Email, req.Password)
+func (u *userUsecase) Login(ctx context.Context, req *domain.LoginRequest) (*domain.LoginResponse, error) {
+	lockoutKey := loginLockoutKey(ctx, req.Email)
+	if err := u.lockout.Check(ctx, lockoutKey); err != nil {
+		return nil, err
+	}
+	// The lockout and the auth rate limit are both per IP; this slows down
+	// guesses at one account spread over many addresses without locking the
+	// owner out
+	if err := u.loginLimit.Allow(ctx, normalizeEmail(req.Email)); err != nil {
+		return nil, err
+	}
 
-	user, err := u.userRepo.GetByEmail(req.Email)
+	user, err := u.userRepo.GetByEmail(ctx, req.Email)
+	if errors.Is(err, domain.ErrNotFound) {
+		u.recordLoginFailure(ctx, lockoutKey, 0)
+		return nil, domain.Unauthorized("invalid email or password")
+	}
 	if err != nil {
-		fmt.Printf("Error getting user by email: %v\n", err)
-		return nil, errors.New("invalid email or password")
+		return nil, err
 	}
 
-	fmt.Printf("Comparing passwords:\nStored hash: %s\nProvided password: %s\n",
-		user.Password, req.Password)
-
 	// Compare passwords
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
-		fmt.Printf("Password comparison failed: %v\n", err)
-		return nil, errors.New("invalid email or password")
+		u.recordLoginFailure(ctx, lockoutKey, user.ID)
+		return nil, domain.Unauthorized("invalid email or password")
+	}
+
+	if err := u.lockout.Succeed(ctx, lockoutKey); err != nil {
+		u.logger.ErrorContext(ctx, "failed to reset login failures", "user_id", user.ID, "error", err)
 	}
 
 	// Generate JWT token
-	token, err := u.jwtService.GenerateToken(user.ID)
+	token, err := u.jwtService.GenerateToken(user.ID, user.TokenVersion)
 	if err != nil {
-		fmt.Printf("Token generation failed: %v\n", err)
 		return nil, err
 	}
-
-	fmt.Printf("Login successful for user: %s\n", user.Email)
+	u.audit.Record(ctx, &domain.AuditEvent{
+		ActorID:    user.ID,
+		Action:     domain.AuditLogin,
+		TargetType: domain.AuditTargetUser,
+		TargetID:   user.ID,
+	})
 
 	// Don't return the password
 	user.Password = ""
+	if err := u.media.users(ctx, user); err != nil {
+		return nil, err
+	}
 	return &domain.LoginResponse{
 		Token: token,
 		User:  *user,
 	}, nil
 }
 
-func (u *userUsecase) GetProfile(id uint) (*domain.User, error) {
-	user, err := u.userRepo.GetByID(id)
+// loginLockoutKey counts failures per account and client IP. Keyed on the
+// email alone, anyone could lock a member out by failing to log in as them;
+// the auth rate limit still slows a guesser working from one IP.
+func loginLockoutKey(ctx context.Context, email string) string {
+	return normalizeEmail(email) + "|" + domain.ClientFromContext(ctx).IP
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// recordLoginFailure counts a failed login towards the lockout and audits it.
+// userID is the account that was tried, or zero if the email has none.
+func (u *userUsecase) recordLoginFailure(ctx context.Context, key string, userID uint) {
+	if err := u.lockout.Fail(ctx, key); err != nil {
+		u.logger.ErrorContext(ctx, "failed to record login failure", "error", err)
+	}
+
+	event := &domain.AuditEvent{Action: domain.AuditLoginFailed}
+	if userID != 0 {
+		event.TargetType = domain.AuditTargetUser
+		event.TargetID = userID
+	}
+	u.audit.Record(ctx, event)
+}
+
+func (u *userUsecase) GetProfile(ctx context.Context, id uint) (*domain.User, error) {
+	user, err := u.userRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
 	// Don't return the password
 	user.Password = ""
+	if err := u.media.users(ctx, user); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-func (u *userUsecase) UpdateProfile(userID uint, req *domain.UpdateProfileRequest) (*domain.User, error) {
-	user, err := u.userRepo.GetByID(userID)
+// GetUserProfile returns another member's public profile. Users who have
+// blocked each other see it as missing.
+func (u *userUsecase) GetUserProfile(ctx context.Context, viewerID, userID uint) (*domain.User, error) {
+	if err := u.ensureVisible(ctx, viewerID, userID); err != nil {
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Don't return private fields
+	user.Password = ""
+	if viewerID != userID {
+		user.Email = ""
+	}
+	if err := u.media.users(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (u *userUsecase) UpdateProfile(ctx context.Context, userID uint, req *domain.UpdateProfileRequest) (*domain.User, error) {
+	user, err := u.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *user
+
 	// Update only the fields that are provided
 	if req.Bio != "" {
 		user.Bio = req.Bio
 	}
-	if req.AvatarURL != "" {
+	if req.AvatarURL != "" && req.AvatarURL != user.AvatarURL {
+		if err := u.checkAvatar(ctx, userID, req.AvatarURL); err != nil {
+			return nil, err
+		}
 		user.AvatarURL = req.AvatarURL
 	}
 
 	user.UpdatedAt = time.Now()
 
-	err = u.userRepo.Update(user)
+	err = u.userRepo.Update(ctx, user)
 	if err != nil {
 		return nil, err
 	}
+	diff := auditDiff(map[string][2]string{
+		"bio":        {previous.Bio, user.Bio},
+		"avatar_url": {previous.AvatarURL, user.AvatarURL},
+	})
+	if len(diff) > 0 {
+		u.audit.Record(ctx, &domain.AuditEvent{
+			ActorID:    userID,
+			Action:     domain.AuditProfileUpdate,
+			TargetType: domain.AuditTargetUser,
+			TargetID:   userID,
+			Diff:       diff,
+		})
+	}
 
 	// Don't return the password
 	user.Password = ""
+	if err := u.media.users(ctx, user); err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
-func (u *userUsecase) GetUserPosts(userID uint, page, limit int) ([]domain.Post, error) {
+// checkAvatar makes sure an avatar is one of the user's own avatar uploads.
+// Anything else would show a stranger's file, or an arbitrary URL, and keep
+// it from being garbage-collected.
+func (u *userUsecase) checkAvatar(ctx context.Context, userID uint, url string) error {
+	uploads, err := u.media.uploadRepo.GetByURLs(ctx, []string{url})
+	if err != nil {
+		return err
+	}
+	if len(uploads) == 0 || uploads[0].OwnerID != userID || uploads[0].Purpose != domain.UploadPurposeAvatar {
+		return domain.Validation("avatar_url must be the URL of an avatar you uploaded")
+	}
+	if uploads[0].Status == domain.UploadStatusFailed {
+		return domain.Validation("avatar could not be processed")
+	}
+	return nil
+}
+
+func (u *userUsecase) GetUserPosts(ctx context.Context, viewerID, userID uint, page, limit int) ([]domain.Post, error) {
+	if err := u.ensureVisible(ctx, viewerID, userID); err != nil {
+		return nil, err
+	}
+
 	// Validate page and limit
 	if page < 1 {
 		page = 1
@@ -139,5 +297,26 @@ func (u *userUsecase) GetUserPosts(userID uint, page, limit int) ([]domain.Post,
 		limit = 10
 	}
 
-	return u.userRepo.GetUserPosts(userID, page, limit)
+	posts, err := u.userRepo.GetUserPosts(ctx, userID, page, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.media.posts(ctx, posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (u *userUsecase) ensureVisible(ctx context.Context, viewerID, userID uint) error {
+	if viewerID == userID {
+		return nil
+	}
+	blocked, err := u.blockRepo.ExistsBetween(ctx, viewerID, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return domain.NotFound("user not found")
+	}
+	return nil
 }
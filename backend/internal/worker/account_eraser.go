@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// AccountEraser erases accounts whose deletion grace period has run out, then
+// scrubs erased accounts from backups and other copies.
+type AccountEraser struct {
+	accountUsecase domain.AccountUsecase
+	interval       time.Duration
+	logger         *slog.Logger
+}
+
+func NewAccountEraser(au domain.AccountUsecase, interval time.Duration, logger *slog.Logger) *AccountEraser {
+	return &AccountEraser{
+		accountUsecase: au,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+// Run erases once immediately and then on every tick until ctx is cancelled.
+func (e *AccountEraser) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.erase(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *AccountEraser) erase(ctx context.Context) {
+	erased, err := e.accountUsecase.EraseDue(ctx)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "account erasure failed", "error", err)
+	}
+	if erased > 0 {
+		e.logger.InfoContext(ctx, "erased accounts", "accounts", erased)
+	}
+
+	scrubbed, err := e.accountUsecase.ScrubErased(ctx)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "erased account scrub failed", "error", err)
+		return
+	}
+	if scrubbed > 0 {
+		e.logger.InfoContext(ctx, "scrubbed erased accounts", "accounts", scrubbed)
+	}
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// auditExportLookback is how many finished days the archiver makes sure are
+// exported, so a few days of downtime don't leave gaps
+const auditExportLookback = 7
+
+// AuditArchiver exports each finished day of audit events to storage, when
+// exporting is on, and deletes events past their retention.
+type AuditArchiver struct {
+	auditUsecase domain.AuditUsecase
+	export       bool
+	interval     time.Duration
+	logger       *slog.Logger
+}
+
+func NewAuditArchiver(au domain.AuditUsecase, export bool, interval time.Duration, logger *slog.Logger) *AuditArchiver {
+	return &AuditArchiver{
+		auditUsecase: au,
+		export:       export,
+		interval:     interval,
+		logger:       logger,
+	}
+}
+
+// Run archives once immediately and then on every tick until ctx is cancelled.
+func (a *AuditArchiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		a.archive(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *AuditArchiver) archive(ctx context.Context) {
+	if a.export {
+		today := time.Now().UTC()
+		for i := auditExportLookback; i >= 1; i-- {
+			day := today.AddDate(0, 0, -i)
+			export, err := a.auditUsecase.Export(ctx, day)
+			if err != nil {
+				a.logger.ErrorContext(ctx, "audit export failed", "day", day.Format(time.DateOnly), "error", err)
+				// Don't prune what may not have been exported
+				return
+			}
+			if export != nil {
+				a.logger.InfoContext(ctx, "exported audit events", "key", export.Key, "events", export.Events)
+			}
+		}
+	}
+
+	deleted, err := a.auditUsecase.Prune(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "audit prune failed", "error", err)
+		return
+	}
+	if deleted > 0 {
+		a.logger.InfoContext(ctx, "pruned audit events", "deleted", deleted)
+	}
+}
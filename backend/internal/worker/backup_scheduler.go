@@ -0,0 +1,81 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/services/backup"
+)
+
+// backupCheckInterval is how often the scheduler looks at the age of the
+// newest snapshot
+const backupCheckInterval = 15 * time.Minute
+
+// BackupScheduler makes a snapshot whenever the newest one is older than the
+// interval, then prunes the ones the retention policy no longer keeps.
+// Going by the stored snapshots rather than a timer means restarts don't
+// delay or repeat a backup.
+type BackupScheduler struct {
+	service  *backup.Service
+	interval time.Duration
+	policy   backup.Policy
+	logger   *slog.Logger
+}
+
+func NewBackupScheduler(service *backup.Service, interval time.Duration, policy backup.Policy, logger *slog.Logger) *BackupScheduler {
+	return &BackupScheduler{
+		service:  service,
+		interval: interval,
+		policy:   policy,
+		logger:   logger,
+	}
+}
+
+// Run checks once immediately and then on every tick until ctx is cancelled.
+func (s *BackupScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *BackupScheduler) check(ctx context.Context) {
+	snapshots, err := s.service.List(ctx)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to list backups", "error", err)
+		return
+	}
+	if len(snapshots) > 0 && time.Since(snapshots[len(snapshots)-1].CreatedAt) < s.interval {
+		return
+	}
+
+	start := time.Now()
+	snapshot, err := s.service.Create(ctx)
+	if errors.Is(err, backup.ErrInProgress) {
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "backup failed", "error", err)
+		return
+	}
+	s.logger.InfoContext(ctx, "backup completed", "key", snapshot.Key, "size", snapshot.Size, "duration", time.Since(start))
+
+	deleted, err := s.service.Prune(ctx, s.policy)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "backup pruning failed", "error", err, "deleted", len(deleted))
+		return
+	}
+	for _, snapshot := range deleted {
+		s.logger.InfoContext(ctx, "pruned backup", "key", snapshot.Key, "created_at", snapshot.CreatedAt)
+	}
+}
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// DataExporter builds the data exports users have asked for and deletes the
+// ones that have expired.
+type DataExporter struct {
+	accountUsecase domain.AccountUsecase
+	interval       time.Duration
+	logger         *slog.Logger
+}
+
+func NewDataExporter(au domain.AccountUsecase, interval time.Duration, logger *slog.Logger) *DataExporter {
+	return &DataExporter{
+		accountUsecase: au,
+		interval:       interval,
+		logger:         logger,
+	}
+}
+
+// Run exports once immediately and then on every tick until ctx is cancelled.
+func (e *DataExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.export(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *DataExporter) export(ctx context.Context) {
+	built, err := e.accountUsecase.ProcessExports(ctx)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "data export processing failed", "error", err)
+	}
+	if built > 0 {
+		e.logger.InfoContext(ctx, "built data exports", "exports", built)
+	}
+
+	removed, err := e.accountUsecase.DeleteExpiredExports(ctx)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "expired data export cleanup failed", "error", err)
+	}
+	if removed > 0 {
+		e.logger.InfoContext(ctx, "deleted expired data exports", "exports", removed)
+	}
+}
@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// ImageQueue is an in-memory queue of uploads waiting to be processed. It is
+// lost on restart; the processor's sweep finds anything left behind.
+type ImageQueue struct {
+	ch chan uint
+}
+
+func NewImageQueue(size int) *ImageQueue {
+	return &ImageQueue{ch: make(chan uint, size)}
+}
+
+// Enqueue adds an upload unless the queue is full.
+func (q *ImageQueue) Enqueue(uploadID uint) {
+	select {
+	case q.ch <- uploadID:
+	default:
+	}
+}
+
+// stalledAfter is how long an upload may sit in processing before the sweep
+// queues it again, long enough that it isn't simply still in the queue
+const stalledAfter = 2 * time.Minute
+
+// ImageProcessor renders image variants on a pool of workers so uploads
+// return before the resizing is done.
+type ImageProcessor struct {
+	uploadUsecase domain.UploadUsecase
+	queue         *ImageQueue
+	workers       int
+	interval      time.Duration
+	logger        *slog.Logger
+}
+
+func NewImageProcessor(uu domain.UploadUsecase, queue *ImageQueue, workers int, interval time.Duration, logger *slog.Logger) *ImageProcessor {
+	return &ImageProcessor{
+		uploadUsecase: uu,
+		queue:         queue,
+		workers:       workers,
+		interval:      interval,
+		logger:        logger,
+	}
+}
+
+// Run processes queued uploads until ctx is cancelled, sweeping for stalled
+// ones on every tick. It returns once the in-flight images are finished.
+func (p *ImageProcessor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *ImageProcessor) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-p.queue.ch:
+			// Finish the current image even if a shutdown starts meanwhile
+			if err := p.uploadUsecase.ProcessImage(context.WithoutCancel(ctx), id); err != nil {
+				p.logger.ErrorContext(ctx, "image processing failed", "upload_id", id, "error", err)
+			}
+		}
+	}
+}
+
+func (p *ImageProcessor) sweep(ctx context.Context) {
+	ids, err := p.uploadUsecase.Stalled(ctx, stalledAfter)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "image sweep failed", "error", err)
+		return
+	}
+	for _, id := range ids {
+		p.queue.Enqueue(id)
+	}
+	if len(ids) > 0 {
+		p.logger.InfoContext(ctx, "requeued stalled images", "count", len(ids))
+	}
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// LinkPreviewQueue is an in-memory queue of links waiting to be fetched. A
+// link already waiting or being fetched isn't queued twice.
+type LinkPreviewQueue struct {
+	ch      chan string
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+func NewLinkPreviewQueue(size int) *LinkPreviewQueue {
+	return &LinkPreviewQueue{
+		ch:      make(chan string, size),
+		pending: make(map[string]bool),
+	}
+}
+
+// Enqueue adds a link unless it is pending or the queue is full.
+func (q *LinkPreviewQueue) Enqueue(url string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.pending[url] {
+		return
+	}
+	select {
+	case q.ch <- url:
+		q.pending[url] = true
+	default:
+	}
+}
+
+func (q *LinkPreviewQueue) done(url string) {
+	q.mu.Lock()
+	delete(q.pending, url)
+	q.mu.Unlock()
+}
+
+// LinkPreviewFetcher fetches queued link previews on a pool of workers, so
+// reading a post never waits on someone else's web server.
+type LinkPreviewFetcher struct {
+	previewUsecase domain.LinkPreviewUsecase
+	queue          *LinkPreviewQueue
+	workers        int
+	logger         *slog.Logger
+}
+
+func NewLinkPreviewFetcher(pu domain.LinkPreviewUsecase, queue *LinkPreviewQueue, workers int, logger *slog.Logger) *LinkPreviewFetcher {
+	return &LinkPreviewFetcher{
+		previewUsecase: pu,
+		queue:          queue,
+		workers:        workers,
+		logger:         logger,
+	}
+}
+
+// Run fetches queued links until ctx is cancelled and the in-flight fetches
+// are finished.
+func (f *LinkPreviewFetcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range f.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (f *LinkPreviewFetcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case url := <-f.queue.ch:
+			if err := f.previewUsecase.Refresh(context.WithoutCancel(ctx), url); err != nil {
+				f.logger.ErrorContext(ctx, "link preview refresh failed", "url", url, "error", err)
+			}
+			f.queue.done(url)
+		}
+	}
+}
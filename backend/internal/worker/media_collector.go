@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// MediaCollector periodically deletes uploaded files that nothing uses any
+// more. In dry-run mode it only logs what it would delete.
+type MediaCollector struct {
+	uploadUsecase domain.UploadUsecase
+	interval      time.Duration
+	grace         time.Duration
+	dryRun        bool
+	logger        *slog.Logger
+}
+
+func NewMediaCollector(uu domain.UploadUsecase, interval, grace time.Duration, dryRun bool, logger *slog.Logger) *MediaCollector {
+	return &MediaCollector{
+		uploadUsecase: uu,
+		interval:      interval,
+		grace:         grace,
+		dryRun:        dryRun,
+		logger:        logger,
+	}
+}
+
+// Run collects once immediately and then on every tick until ctx is
+// cancelled.
+func (m *MediaCollector) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		m.collect(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *MediaCollector) collect(ctx context.Context) {
+	report, err := m.uploadUsecase.CollectGarbage(ctx, m.grace, m.dryRun)
+	if err != nil {
+		m.logger.ErrorContext(ctx, "media gc failed", "error", err)
+	}
+	if report == nil {
+		return
+	}
+
+	if report.DryRun {
+		for _, upload := range report.Orphans {
+			m.logger.InfoContext(ctx, "media gc would delete upload",
+				"upload_id", upload.ID,
+				"url", upload.URL,
+				"purpose", upload.Purpose,
+				"size", upload.Size,
+				"created_at", upload.CreatedAt,
+			)
+		}
+		if len(report.Orphans) > 0 {
+			m.logger.InfoContext(ctx, "media gc dry run completed", "orphans", len(report.Orphans))
+		}
+		return
+	}
+	if report.Deleted > 0 {
+		m.logger.InfoContext(ctx, "media gc completed", "deleted", report.Deleted, "bytes", report.Bytes)
+	}
+}
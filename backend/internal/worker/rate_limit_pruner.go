@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/pkg/ratelimit"
+)
+
+// RateLimitPruner periodically forgets rate limit buckets and login failures
+// nobody has touched in a while, so the store doesn't grow with every client
+// ever seen.
+type RateLimitPruner struct {
+	store    ratelimit.Store
+	interval time.Duration
+	idle     time.Duration
+	logger   *slog.Logger
+}
+
+func NewRateLimitPruner(store ratelimit.Store, interval, idle time.Duration, logger *slog.Logger) *RateLimitPruner {
+	return &RateLimitPruner{
+		store:    store,
+		interval: interval,
+		idle:     idle,
+		logger:   logger,
+	}
+}
+
+// Run prunes once immediately and then on every tick until ctx is cancelled.
+func (p *RateLimitPruner) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.prune(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *RateLimitPruner) prune(ctx context.Context) {
+	removed, err := p.store.Prune(ctx, p.idle)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "rate limit prune failed", "error", err)
+		return
+	}
+	if removed > 0 {
+		p.logger.InfoContext(ctx, "rate limit prune completed", "removed", removed)
+	}
+}
@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// TrashPurger periodically hard-deletes posts and comments whose trash
+// retention has run out.
+type TrashPurger struct {
+	trashUsecase domain.TrashUsecase
+	interval     time.Duration
+	logger       *slog.Logger
+}
+
+func NewTrashPurger(tu domain.TrashUsecase, interval time.Duration, logger *slog.Logger) *TrashPurger {
+	return &TrashPurger{
+		trashUsecase: tu,
+		interval:     interval,
+		logger:       logger,
+	}
+}
+
+// Run purges once immediately and then on every tick until ctx is cancelled.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) purge(ctx context.Context) {
+	posts, comments, err := p.trashUsecase.Purge(ctx)
+	if err != nil {
+		p.logger.ErrorContext(ctx, "trash purge failed", "error", err)
+		return
+	}
+	if posts > 0 || comments > 0 {
+		p.logger.InfoContext(ctx, "trash purge completed", "posts", posts, "comments", comments)
+	}
+}
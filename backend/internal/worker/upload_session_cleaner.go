@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/ruth987/CHub.git/internal/domain"
+)
+
+// UploadSessionCleaner periodically removes upload sessions that were never
+// finalized, and the files uploaded for them.
+type UploadSessionCleaner struct {
+	uploadUsecase domain.UploadUsecase
+	interval      time.Duration
+	logger        *slog.Logger
+}
+
+func NewUploadSessionCleaner(uu domain.UploadUsecase, interval time.Duration, logger *slog.Logger) *UploadSessionCleaner {
+	return &UploadSessionCleaner{
+		uploadUsecase: uu,
+		interval:      interval,
+		logger:        logger,
+	}
+}
+
+// Run cleans up once immediately and then on every tick until ctx is
+// cancelled.
+func (c *UploadSessionCleaner) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.clean(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *UploadSessionCleaner) clean(ctx context.Context) {
+	removed, err := c.uploadUsecase.CleanupSessions(ctx)
+	if err != nil {
+		c.logger.ErrorContext(ctx, "upload session cleanup failed", "error", err, "removed", removed)
+		return
+	}
+	if removed > 0 {
+		c.logger.InfoContext(ctx, "upload session cleanup completed", "removed", removed)
+	}
+}
@@ -0,0 +1,8 @@
+-- Create user_mutes table
+CREATE TABLE IF NOT EXISTS user_mutes (
+    muter_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    muted_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (muter_id, muted_id),
+    CHECK (muter_id <> muted_id)
+);
@@ -0,0 +1,23 @@
+-- Member roles; promote moderators by hand with
+-- UPDATE users SET role = 'moderator' WHERE id = ...;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member';
+
+-- Track when posts and comments were last edited
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS edited_at TIMESTAMP;
+
+-- Create content_revisions table; each row is the version that an edit replaced
+CREATE TABLE IF NOT EXISTS content_revisions (
+    id SERIAL PRIMARY KEY,
+    target_type VARCHAR(20) NOT NULL,
+    target_id INTEGER NOT NULL,
+    editor_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    title VARCHAR(255),
+    content TEXT NOT NULL,
+    image_url VARCHAR(255),
+    link_url VARCHAR(255),
+    tags TEXT[],
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_revisions_target ON content_revisions(target_type, target_id, id DESC);
@@ -0,0 +1,13 @@
+-- Files uploaded by users. The object lives in storage under key; the URL is
+-- derived from the key by the configured store.
+CREATE TABLE IF NOT EXISTS uploads (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    key VARCHAR(512) NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_uploads_owner ON uploads(owner_id, created_at DESC);
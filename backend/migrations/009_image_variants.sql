@@ -0,0 +1,10 @@
+-- Image processing state and the resized copies of each upload. url is kept so
+-- posts and profiles, which store URLs, can be matched to their upload.
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS url TEXT;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'processing';
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS width INTEGER;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS height INTEGER;
+ALTER TABLE uploads ADD COLUMN IF NOT EXISTS variants JSONB NOT NULL DEFAULT '[]';
+
+CREATE INDEX IF NOT EXISTS idx_uploads_url ON uploads(url);
+CREATE INDEX IF NOT EXISTS idx_uploads_processing ON uploads(created_at) WHERE status = 'processing';
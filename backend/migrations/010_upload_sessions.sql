@@ -0,0 +1,17 @@
+-- Slots for uploading straight to storage. A session is finalized once its
+-- file has been checked and recorded in uploads; unfinalized ones are removed
+-- after they expire.
+CREATE TABLE IF NOT EXISTS upload_sessions (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL,
+    key VARCHAR(512) NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    upload_id INTEGER REFERENCES uploads(id) ON DELETE SET NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    finalized_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_upload_sessions_abandoned ON upload_sessions(expires_at) WHERE finalized_at IS NULL;
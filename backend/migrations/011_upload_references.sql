@@ -0,0 +1,21 @@
+-- What uses each upload. Posts and profiles store plain URLs, so references
+-- are derived from those columns rather than kept in step by hand. Revisions
+-- count while their post exists, so a rollback never points at a deleted
+-- image; deleted posts count until the trash is purged.
+CREATE INDEX IF NOT EXISTS idx_posts_image_url ON posts(image_url) WHERE image_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_users_avatar_url ON users(avatar_url) WHERE avatar_url IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_content_revisions_image_url ON content_revisions(image_url) WHERE image_url IS NOT NULL;
+
+CREATE OR REPLACE VIEW upload_references AS
+    SELECT up.id AS upload_id, 'post' AS target_type, p.id AS target_id
+    FROM uploads up
+    JOIN posts p ON p.image_url = up.url
+    UNION ALL
+    SELECT up.id, 'post_revision', rv.id
+    FROM uploads up
+    JOIN content_revisions rv ON rv.image_url = up.url
+    JOIN posts p ON rv.target_type = 'post' AND p.id = rv.target_id
+    UNION ALL
+    SELECT up.id, 'user', u.id
+    FROM uploads up
+    JOIN users u ON u.avatar_url = up.url;
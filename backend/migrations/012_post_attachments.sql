@@ -0,0 +1,60 @@
+-- Ordered files shown with a post. posts.image_url stays as the first image
+-- for older clients.
+CREATE TABLE IF NOT EXISTS post_attachments (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    mime_type VARCHAR(100) NOT NULL,
+    alt_text TEXT NOT NULL DEFAULT '',
+    width INTEGER,
+    height INTEGER,
+    PRIMARY KEY (post_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_attachments_url ON post_attachments(url);
+
+-- Move existing images into the table. Images uploaded here take their type
+-- and size from uploads; for the rest the type is guessed from the extension.
+INSERT INTO post_attachments (post_id, position, url, mime_type, width, height)
+SELECT p.id, 0, p.image_url,
+    COALESCE(up.content_type, CASE
+        WHEN lower(p.image_url) ~ '\.png(\?|$)' THEN 'image/png'
+        WHEN lower(p.image_url) ~ '\.webp(\?|$)' THEN 'image/webp'
+        WHEN lower(p.image_url) ~ '\.gif(\?|$)' THEN 'image/gif'
+        WHEN lower(p.image_url) ~ '\.jpe?g(\?|$)' THEN 'image/jpeg'
+        ELSE 'image/*'
+    END),
+    up.width, up.height
+FROM posts p
+LEFT JOIN uploads up ON up.url = p.image_url
+WHERE COALESCE(p.image_url, '') <> ''
+ON CONFLICT DO NOTHING;
+
+-- Revisions keep the attachments they replaced
+ALTER TABLE content_revisions ADD COLUMN IF NOT EXISTS attachments JSONB NOT NULL DEFAULT '[]';
+
+UPDATE content_revisions
+SET attachments = jsonb_build_array(jsonb_build_object('url', image_url, 'mime_type', 'image/*'))
+WHERE target_type = 'post' AND COALESCE(image_url, '') <> '' AND attachments = '[]';
+
+CREATE INDEX IF NOT EXISTS idx_content_revisions_attachments ON content_revisions USING GIN (attachments jsonb_path_ops);
+
+-- Attachments, and those of revisions, count as uses of an upload
+CREATE OR REPLACE VIEW upload_references AS
+    SELECT up.id AS upload_id, 'post' AS target_type, p.id AS target_id
+    FROM uploads up
+    JOIN posts p ON p.image_url = up.url
+    UNION ALL
+    SELECT up.id, 'post', a.post_id
+    FROM uploads up
+    JOIN post_attachments a ON a.url = up.url
+    UNION ALL
+    SELECT up.id, 'post_revision', rv.id
+    FROM uploads up
+    JOIN content_revisions rv ON rv.image_url = up.url
+        OR rv.attachments @> jsonb_build_array(jsonb_build_object('url', up.url))
+    JOIN posts p ON rv.target_type = 'post' AND p.id = rv.target_id
+    UNION ALL
+    SELECT up.id, 'user', u.id
+    FROM uploads up
+    JOIN users u ON u.avatar_url = up.url;
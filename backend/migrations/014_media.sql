@@ -0,0 +1,70 @@
+-- Sermon recordings and other audio or video. The file itself is an upload;
+-- a media item adds what is needed to play and find it.
+CREATE TABLE IF NOT EXISTS media_items (
+    id SERIAL PRIMARY KEY,
+    owner_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    upload_id INTEGER NOT NULL REFERENCES uploads(id),
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('audio', 'video')),
+    title VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    -- Seconds; 0 when neither the file nor the uploader said
+    duration DOUBLE PRECISION NOT NULL DEFAULT 0,
+    -- [{"start": seconds, "title": "..."}], ordered by start
+    chapters JSONB NOT NULL DEFAULT '[]',
+    has_transcript BOOLEAN NOT NULL DEFAULT FALSE,
+    search TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', title), 'A') || setweight(to_tsvector('english', description), 'B')
+    ) STORED,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_items_owner ON media_items(owner_id, created_at DESC);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_media_items_upload ON media_items(upload_id);
+CREATE INDEX IF NOT EXISTS idx_media_items_search ON media_items USING GIN (search);
+
+-- Transcripts are kept one cue per row so a search can point at the moment
+-- something was said
+CREATE TABLE IF NOT EXISTS media_transcript_cues (
+    media_id INTEGER NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    start_ms BIGINT NOT NULL,
+    end_ms BIGINT NOT NULL,
+    text TEXT NOT NULL,
+    search TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', text)) STORED,
+    PRIMARY KEY (media_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_transcript_cues_search ON media_transcript_cues USING GIN (search);
+
+CREATE TABLE IF NOT EXISTS post_media (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    media_id INTEGER NOT NULL REFERENCES media_items(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (post_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_media_media ON post_media(media_id);
+
+-- A media item keeps its recording
+CREATE OR REPLACE VIEW upload_references AS
+    SELECT up.id AS upload_id, 'post' AS target_type, p.id AS target_id
+    FROM uploads up
+    JOIN posts p ON p.image_url = up.url
+    UNION ALL
+    SELECT up.id, 'post', a.post_id
+    FROM uploads up
+    JOIN post_attachments a ON a.url = up.url
+    UNION ALL
+    SELECT up.id, 'post_revision', rv.id
+    FROM uploads up
+    JOIN content_revisions rv ON rv.image_url = up.url
+        OR rv.attachments @> jsonb_build_array(jsonb_build_object('url', up.url))
+    JOIN posts p ON rv.target_type = 'post' AND p.id = rv.target_id
+    UNION ALL
+    SELECT up.id, 'user', u.id
+    FROM uploads up
+    JOIN users u ON u.avatar_url = up.url
+    UNION ALL
+    SELECT m.upload_id, 'media', m.id
+    FROM media_items m;
@@ -0,0 +1,20 @@
+-- Security-relevant actions: sign-ins, account and profile changes, deletions
+-- and moderation. actor_id has no foreign key so deleting an account doesn't
+-- rewrite the history of what it did.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    action VARCHAR(50) NOT NULL,
+    target_type VARCHAR(20) NOT NULL DEFAULT '',
+    target_id INTEGER,
+    ip INET,
+    user_agent TEXT NOT NULL DEFAULT '',
+    -- The fields the action changed, as {"field": {"old": ..., "new": ...}}
+    diff JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_action ON audit_events(action, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id, id DESC);
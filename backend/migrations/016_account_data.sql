@@ -0,0 +1,43 @@
+-- Data export jobs. The zip lives in the private backup store under key
+-- until expires_at.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    key VARCHAR(512) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_data_exports_status ON data_exports(status, id);
+-- One export in progress per user at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_exports_active ON data_exports(user_id)
+    WHERE status IN ('pending', 'running');
+
+-- Accounts waiting out the grace period before they are erased
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL,
+    requested_at TIMESTAMPTZ NOT NULL,
+    scheduled_for TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_scheduled ON account_deletions(scheduled_for);
+
+-- Erased accounts whose copies in backups and logs are still to be scrubbed.
+-- user_id outlives the account, so there is no foreign key.
+CREATE TABLE IF NOT EXISTS account_erasures (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    username VARCHAR(255),
+    mode VARCHAR(20) NOT NULL,
+    erased_at TIMESTAMPTZ NOT NULL,
+    scrubbed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_erasures_unscrubbed ON account_erasures(id) WHERE scrubbed_at IS NULL;
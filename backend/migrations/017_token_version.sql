@@ -0,0 +1,3 @@
+-- Tokens carry the version they were issued at. Changing or resetting the
+-- password bumps it, which signs the account out everywhere.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0;
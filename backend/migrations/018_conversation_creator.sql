@@ -0,0 +1,5 @@
+-- A conversation outlives the member who started it. Cascading from the
+-- creator deleted every other member's messages when that account was erased.
+ALTER TABLE conversations DROP CONSTRAINT IF EXISTS conversations_created_by_fkey;
+ALTER TABLE conversations ADD CONSTRAINT conversations_created_by_fkey
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL;
@@ -6,6 +6,12 @@ import (
 	"time"
 )
 
+// StreamTicketTTL is how long a stream ticket can be used to connect
+const StreamTicketTTL = time.Minute
+
+// streamTicketUse marks tokens that only open the message stream
+const streamTicketUse = "stream"
+
 type JWTService struct {
 	secretKey string
 }
@@ -14,9 +20,17 @@ func NewJWTService(secretKey string) *JWTService {
 	return &JWTService{secretKey: secretKey}
 }
 
-func (j *JWTService) GenerateToken(userID uint) (string, error) {
+// Claims are what a valid token says about its holder.
+type Claims struct {
+	UserID uint
+	// Version is the user's token version when the token was issued
+	Version int
+}
+
+func (j *JWTService) GenerateToken(userID uint, version int) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id": userID,
+		"ver":     version,
 		"exp":     time.Now().Add(time.Hour * 24).Unix(),
 	}
 
@@ -24,7 +38,39 @@ func (j *JWTService) GenerateToken(userID uint) (string, error) {
 	return token.SignedString([]byte(j.secretKey))
 }
 
-func (j *JWTService) ValidateToken(tokenString string) (uint, error) {
+// GenerateStreamTicket issues a short-lived token for opening the message
+// stream. Browsers can't set headers on an EventSource, so the ticket goes in
+// the query string instead, where it may end up in logs; it is only good for
+// the stream and only for a minute.
+func (j *JWTService) GenerateStreamTicket(userID uint, version int) (string, time.Time, error) {
+	expiresAt := time.Now().Add(StreamTicketTTL)
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"ver":     version,
+		"use":     streamTicketUse,
+		"exp":     expiresAt.Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(j.secretKey))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// ValidateToken checks a bearer token. Stream tickets are not accepted.
+func (j *JWTService) ValidateToken(tokenString string) (*Claims, error) {
+	return j.validate(tokenString, "")
+}
+
+// ValidateStreamTicket checks a ticket from GenerateStreamTicket. Bearer
+// tokens are not accepted, so they never have to go in a URL.
+func (j *JWTService) ValidateStreamTicket(ticket string) (*Claims, error) {
+	return j.validate(ticket, streamTicketUse)
+}
+
+func (j *JWTService) validate(tokenString, use string) (*Claims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -33,14 +79,23 @@ func (j *JWTService) ValidateToken(tokenString string) (uint, error) {
 	})
 
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return 0, errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
 
-	userID := uint(claims["user_id"].(float64))
-	return userID, nil
+	if got, _ := claims["use"].(string); got != use {
+		return nil, errors.New("invalid token claims")
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	// Tokens issued before versions existed carry none and count as version 0
+	version, _ := claims["ver"].(float64)
+	return &Claims{UserID: uint(userID), Version: int(version)}, nil
 }
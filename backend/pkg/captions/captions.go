@@ -0,0 +1,151 @@
+// Package captions reads WebVTT and SRT transcripts and writes WebVTT.
+package captions
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cue is one timed piece of a transcript.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	// Text is plain text; markup such as <v Speaker> and <i> is removed
+	Text string
+}
+
+// ErrNoCues is returned for a file without a single usable cue.
+var ErrNoCues = errors.New("transcript has no cues")
+
+// timing matches a cue timing line in either format, e.g.
+// "00:01:02.500 --> 00:01:05.000 align:start" or "00:01:02,500 --> 00:01:05,000".
+var timing = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})\s+-->\s+((?:\d+:)?\d{1,2}:\d{2}[.,]\d{1,3})`)
+
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+var entities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&nbsp;", " ", "&lrm;", "", "&rlm;", "")
+
+// Parse reads a WebVTT or SRT file. Blocks that aren't cues, like WebVTT
+// notes and styles or SRT sequence numbers, are skipped.
+func Parse(data []byte) ([]Cue, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var cues []Cue
+	var current *Cue
+	var text []string
+	flush := func() {
+		if current != nil {
+			current.Text = strings.TrimSpace(strings.Join(text, "\n"))
+			if current.Text != "" {
+				cues = append(cues, *current)
+			}
+		}
+		current, text = nil, nil
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		if m := timing.FindStringSubmatch(line); m != nil {
+			flush()
+			start, err := parseTimestamp(m[1])
+			if err != nil {
+				return nil, err
+			}
+			end, err := parseTimestamp(m[2])
+			if err != nil {
+				return nil, err
+			}
+			if end < start {
+				return nil, fmt.Errorf("cue at %s ends before it starts", m[1])
+			}
+			current = &Cue{Start: start, End: end}
+			continue
+		}
+		// Lines outside a cue are headers, identifiers and comment blocks
+		if current != nil {
+			text = append(text, cleanText(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if len(cues) == 0 {
+		return nil, ErrNoCues
+	}
+	return cues, nil
+}
+
+// parseTimestamp reads "hh:mm:ss.mmm", "mm:ss.mmm" or the SRT form with a
+// comma.
+func parseTimestamp(s string) (time.Duration, error) {
+	s = strings.Replace(s, ",", ".", 1)
+	clock, frac, _ := strings.Cut(s, ".")
+	parts := strings.Split(clock, ":")
+
+	var total time.Duration
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp %q", s)
+		}
+		total = total*60 + time.Duration(n)
+	}
+	total *= time.Second
+
+	// "5" is half a second, not five milliseconds
+	for len(frac) < 3 {
+		frac += "0"
+	}
+	ms, err := strconv.Atoi(frac)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return total + time.Duration(ms)*time.Millisecond, nil
+}
+
+func cleanText(line string) string {
+	return strings.TrimSpace(entities.Replace(tags.ReplaceAllString(line, "")))
+}
+
+// WriteVTT writes cues as a WebVTT file.
+func WriteVTT(w io.Writer, cues []Cue) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("WEBVTT\n")
+	for _, cue := range cues {
+		fmt.Fprintf(bw, "\n%s --> %s\n%s\n", formatTimestamp(cue.Start), formatTimestamp(cue.End), escape(cue.Text))
+	}
+	return bw.Flush()
+}
+
+func formatTimestamp(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3_600_000, ms/60_000%60, ms/1000%60, ms%1000)
+}
+
+// escape keeps cue text from being read as markup, and blank lines from
+// ending the cue early.
+func escape(text string) string {
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
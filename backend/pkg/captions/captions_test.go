@@ -0,0 +1,126 @@
+package captions
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	ts := func(h, m, s, ms int) time.Duration {
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+			time.Duration(s)*time.Second + time.Duration(ms)*time.Millisecond
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []Cue
+		wantErr string
+	}{
+		{
+			name: "webvtt",
+			input: "WEBVTT - Sunday service\n\n" +
+				"NOTE recorded live\nin the main hall\n\n" +
+				"intro\n00:00:01.000 --> 00:00:04.500 align:start\n<v Pastor>Welcome, <i>everyone</i>.\n\n" +
+				"00:01:02.250 --> 00:01:05.000\nLet us pray &amp; give thanks.\nAmen.\n",
+			want: []Cue{
+				{Start: ts(0, 0, 1, 0), End: ts(0, 0, 4, 500), Text: "Welcome, everyone."},
+				{Start: ts(0, 1, 2, 250), End: ts(0, 1, 5, 0), Text: "Let us pray & give thanks.\nAmen."},
+			},
+		},
+		{
+			name: "srt with a BOM and CRLF",
+			input: "\xef\xbb\xbf1\r\n00:00:01,000 --> 00:00:02,000\r\nFirst line\r\n\r\n" +
+				"2\r\n01:00:00,100 --> 01:00:03,000\r\nSecond line\r\n",
+			want: []Cue{
+				{Start: ts(0, 0, 1, 0), End: ts(0, 0, 2, 0), Text: "First line"},
+				{Start: ts(1, 0, 0, 100), End: ts(1, 0, 3, 0), Text: "Second line"},
+			},
+		},
+		{
+			name:  "short timestamps and fractions",
+			input: "WEBVTT\n\n01:02.5 --> 01:03.25\nShort\n",
+			want: []Cue{
+				{Start: ts(0, 1, 2, 500), End: ts(0, 1, 3, 250), Text: "Short"},
+			},
+		},
+		{
+			name:  "cues without text are dropped",
+			input: "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<i></i>\n\n00:00:03.000 --> 00:00:04.000\nKept\n",
+			want: []Cue{
+				{Start: ts(0, 0, 3, 0), End: ts(0, 0, 4, 0), Text: "Kept"},
+			},
+		},
+		{
+			name:    "cue ends before it starts",
+			input:   "WEBVTT\n\n00:00:05.000 --> 00:00:01.000\nBackwards\n",
+			wantErr: "ends before it starts",
+		},
+		{
+			name:    "no cues",
+			input:   "WEBVTT\n\nNOTE nothing here\n",
+			wantErr: ErrNoCues.Error(),
+		},
+		{
+			name:    "empty file",
+			input:   "",
+			wantErr: ErrNoCues.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cues, err := Parse([]byte(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cues, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", cues, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNoCues(t *testing.T) {
+	if _, err := Parse([]byte("WEBVTT\n")); !errors.Is(err, ErrNoCues) {
+		t.Errorf("got %v, want ErrNoCues", err)
+	}
+}
+
+func TestWriteVTT(t *testing.T) {
+	cues := []Cue{
+		{Start: 1500 * time.Millisecond, End: 4 * time.Second, Text: "Fish & loaves <5>"},
+		{Start: time.Hour + 2*time.Minute, End: time.Hour + 2*time.Minute + 3*time.Second, Text: "One\n\nTwo"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteVTT(&buf, cues); err != nil {
+		t.Fatal(err)
+	}
+	want := "WEBVTT\n\n" +
+		"00:00:01.500 --> 00:00:04.000\nFish &amp; loaves &lt;5&gt;\n\n" +
+		"01:02:00.000 --> 01:02:03.000\nOne\nTwo\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// What is written reads back the same, apart from the blank line
+	parsed, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues[1].Text = "One\nTwo"
+	if !reflect.DeepEqual(parsed, cues) {
+		t.Errorf("round trip: got %+v\nwant %+v", parsed, cues)
+	}
+}
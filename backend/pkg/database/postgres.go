@@ -1,9 +1,11 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -14,28 +16,68 @@ type Config struct {
 	User     string
 	Password string
 	DBName   string
+	// SSLMode is passed to lib/pq; "disable" when empty
+	SSLMode string
+	// StatementTimeout makes Postgres cancel any statement that runs longer.
+	// Zero leaves the server default in place.
+	StatementTimeout time.Duration
+	// ConnectTimeout bounds the initial ping
+	ConnectTimeout time.Duration
+	// Pool settings; zero keeps the database/sql defaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
 }
 
-func NewPostgresDB(config *Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		config.Host, config.Port, config.User, config.Password, config.DBName)
+// DefaultStatementTimeout keeps a slow query from tying up a connection
+// after the client that asked for it has long gone.
+const DefaultStatementTimeout = 5 * time.Second
 
-	// Log the connection string (remove sensitive info in production)
-	log.Printf("Attempting to connect to database with DSN: %s", dsn)
+func NewPostgresDB(config *Config, logger *slog.Logger) (*sql.DB, error) {
+	sslMode := config.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		config.Host, config.Port, config.User, config.Password, config.DBName, sslMode)
+	if config.StatementTimeout > 0 {
+		// lib/pq passes unknown keys through as session parameters
+		dsn += fmt.Sprintf(" statement_timeout=%d", config.StatementTimeout.Milliseconds())
+	}
+
+	// Never log the DSN itself, it contains the password
+	logger = logger.With("host", config.Host, "port", config.Port, "database", config.DBName, "user", config.User,
+		"statement_timeout", config.StatementTimeout)
+	logger.Info("connecting to database")
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
-		log.Printf("Error opening database: %v", err)
+		logger.Error("failed to open database", "error", err)
 		return nil, err
 	}
 
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+
+	connectTimeout := config.ConnectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
 	// Test the connection
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
-		log.Printf("Error pinging database: %v", err)
+		logger.Error("failed to ping database", "error", err)
 		return nil, err
 	}
 
-	log.Println("Successfully connected to database!")
+	logger.Info("connected to database")
 	return db, nil
 }
@@ -0,0 +1,196 @@
+// Package imaging turns uploaded photos into resized copies without their
+// metadata. It reads JPEG, PNG and WebP and writes JPEG, or PNG when the
+// image has transparency. Everything is pure Go.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Variant is one size an image is rendered at.
+type Variant struct {
+	Name string
+	// Width and Height bound the output. Images are never enlarged.
+	Width  int
+	Height int
+	// Crop fills the whole box, trimming the overflow evenly from both
+	// sides, instead of fitting inside it
+	Crop bool
+}
+
+var (
+	Thumbnail = Variant{Name: "thumbnail", Width: 320, Height: 320, Crop: true}
+	Feed      = Variant{Name: "feed", Width: 1080, Height: 1350}
+	Full      = Variant{Name: "full", Width: 2048, Height: 2048}
+)
+
+// Variants are the sizes every uploaded image is rendered at, smallest first.
+var Variants = []Variant{Thumbnail, Feed, Full}
+
+// MaxPixels caps the decoded size of an image so a small file that expands to
+// gigabytes of pixels is refused before it is decoded.
+const MaxPixels = 50_000_000
+
+const jpegQuality = 82
+
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// Image is one encoded variant.
+type Image struct {
+	Variant     Variant
+	Data        []byte
+	ContentType string
+	// Ext is the file extension for ContentType, including the dot
+	Ext    string
+	Width  int
+	Height int
+}
+
+// Result is a decoded image rendered at each requested variant.
+type Result struct {
+	// Width and Height are the upright size of the source image
+	Width  int
+	Height int
+	Images []Image
+}
+
+// Render decodes data, turns it upright according to its EXIF orientation and
+// encodes it at every variant. The encoded images carry no metadata.
+func Render(data []byte, variants []Variant) (*Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read image header: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	// Shrink to the largest box first and only then rotate, which is far
+	// cheaper than rotating a full-size photo. Orientations 5 to 8 swap the
+	// axes, so the box is swapped to match.
+	box := Variant{}
+	for _, v := range variants {
+		box.Width, box.Height = max(box.Width, v.Width), max(box.Height, v.Height)
+	}
+	if orientation >= 5 {
+		box.Width, box.Height = box.Height, box.Width
+	}
+	upright := orient(resize(src, box), orientation)
+
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		width, height = height, width
+	}
+	result := &Result{Width: width, Height: height}
+	for _, v := range variants {
+		img, err := encode(resize(upright, v))
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", v.Name, err)
+		}
+		img.Variant = v
+		result.Images = append(result.Images, *img)
+	}
+	return result, nil
+}
+
+// resize scales img to fit v, or to fill it when v.Crop is set.
+func resize(img image.Image, v Variant) *image.RGBA {
+	src := img.Bounds()
+	if v.Crop {
+		w, h := src.Dx(), src.Dy()
+		if w*v.Height > h*v.Width {
+			cw := h * v.Width / v.Height
+			src.Min.X += (w - cw) / 2
+			src.Max.X = src.Min.X + cw
+		} else {
+			ch := w * v.Height / v.Width
+			src.Min.Y += (h - ch) / 2
+			src.Max.Y = src.Min.Y + ch
+		}
+	}
+
+	w, h := float64(src.Dx()), float64(src.Dy())
+	scale := min(1, float64(v.Width)/w, float64(v.Height)/h)
+	dw := max(1, int(math.Round(w*scale)))
+	dh := max(1, int(math.Round(h*scale)))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, src, draw.Src, nil)
+	return dst
+}
+
+func encode(img *image.RGBA) (*Image, error) {
+	var buf bytes.Buffer
+	out := &Image{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		out.ContentType, out.Ext = "image/jpeg", ".jpg"
+	} else {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		out.ContentType, out.Ext = "image/png", ".png"
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
+
+// orient turns img upright for EXIF orientation o, where 1 is already upright
+// and 2 to 8 are the mirrored and rotated forms.
+func orient(img *image.RGBA, o int) *image.RGBA {
+	if o < 2 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	if o >= 5 {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // mirrored
+				dx, dy = w-1-x, y
+			case 3: // rotated 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // flipped
+				dx, dy = x, h-1-y
+			case 5: // transposed
+				dx, dy = y, x
+			case 6: // needs 90° clockwise
+				dx, dy = h-1-y, x
+			case 7: // transversed
+				dx, dy = h-1-y, w-1-x
+			case 8: // needs 90° anticlockwise
+				dx, dy = y, w-1-x
+			}
+			si := img.PixOffset(b.Min.X+x, b.Min.Y+y)
+			di := dst.PixOffset(dx, dy)
+			copy(dst.Pix[di:di+4], img.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// testJPEG encodes an opaque w×h image, tagged with an EXIF orientation
+// unless it is 0
+func testJPEG(t *testing.T, w, h, orientation int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if orientation == 0 {
+		return data
+	}
+	// The EXIF segment goes straight after the start-of-image marker
+	return append(append(append([]byte(nil), data[:2]...), orientationSegment(orientation)...), data[2:]...)
+}
+
+func testPNG(t *testing.T, w, h int, fill color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRender(t *testing.T) {
+	variants := []Variant{
+		{Name: "thumb", Width: 100, Height: 100, Crop: true},
+		{Name: "feed", Width: 400, Height: 400},
+		{Name: "full", Width: 2000, Height: 2000},
+	}
+	type size struct{ w, h int }
+
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+		// want is the source size followed by one size per variant
+		want            []size
+		wantContentType string
+	}{
+		{
+			name:            "landscape",
+			data:            func(t *testing.T) []byte { return testJPEG(t, 800, 400, 0) },
+			want:            []size{{800, 400}, {100, 100}, {400, 200}, {800, 400}},
+			wantContentType: "image/jpeg",
+		},
+		{
+			name:            "never enlarged",
+			data:            func(t *testing.T) []byte { return testJPEG(t, 60, 30, 0) },
+			want:            []size{{60, 30}, {30, 30}, {60, 30}, {60, 30}},
+			wantContentType: "image/jpeg",
+		},
+		{
+			name:            "rotated by EXIF",
+			data:            func(t *testing.T) []byte { return testJPEG(t, 800, 400, 6) },
+			want:            []size{{400, 800}, {100, 100}, {200, 400}, {400, 800}},
+			wantContentType: "image/jpeg",
+		},
+		{
+			name:            "transparent stays PNG",
+			data:            func(t *testing.T) []byte { return testPNG(t, 300, 600, color.NRGBA{A: 0x80}) },
+			want:            []size{{300, 600}, {100, 100}, {200, 400}, {300, 600}},
+			wantContentType: "image/png",
+		},
+		{
+			name:            "opaque PNG becomes JPEG",
+			data:            func(t *testing.T) []byte { return testPNG(t, 300, 600, color.White) },
+			want:            []size{{300, 600}, {100, 100}, {200, 400}, {300, 600}},
+			wantContentType: "image/jpeg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Render(tt.data(t), variants)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := (size{result.Width, result.Height}); got != tt.want[0] {
+				t.Errorf("source size = %v, want %v", got, tt.want[0])
+			}
+			if len(result.Images) != len(variants) {
+				t.Fatalf("got %d images, want %d", len(result.Images), len(variants))
+			}
+			for i, img := range result.Images {
+				if img.Variant != variants[i] {
+					t.Errorf("image %d is %s, want %s", i, img.Variant.Name, variants[i].Name)
+				}
+				if got := (size{img.Width, img.Height}); got != tt.want[i+1] {
+					t.Errorf("%s size = %v, want %v", img.Variant.Name, got, tt.want[i+1])
+				}
+				if img.ContentType != tt.wantContentType {
+					t.Errorf("%s content type = %s, want %s", img.Variant.Name, img.ContentType, tt.wantContentType)
+				}
+
+				// The encoded image must match what it claims to be
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+				if err != nil {
+					t.Fatalf("%s: %v", img.Variant.Name, err)
+				}
+				if "image/"+format != img.ContentType || cfg.Width != img.Width || cfg.Height != img.Height {
+					t.Errorf("%s decodes as %s %dx%d", img.Variant.Name, format, cfg.Width, cfg.Height)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderRejectsGarbage(t *testing.T) {
+	if _, err := Render([]byte("not an image"), Variants); err == nil {
+		t.Error("rendered data that isn't an image")
+	}
+}
@@ -0,0 +1,285 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed image")
+
+// StripMetadata removes EXIF, XMP, comments and text chunks from a JPEG, PNG
+// or WebP file without re-encoding it, so it is cheap enough to run while the
+// client waits. A JPEG keeps its orientation in a minimal EXIF block so it
+// still displays upright. Colour profiles are kept. Other types are returned
+// unchanged.
+func StripMetadata(data []byte, contentType string) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	}
+	return data, nil
+}
+
+// JPEG markers
+const (
+	markerSOI  = 0xd8
+	markerSOS  = 0xda
+	markerAPP0 = 0xe0
+	markerAPP1 = 0xe1 // EXIF and XMP
+	markerAPP2 = 0xe2 // ICC colour profile
+	// APP14 holds Adobe's colour transform, which decoders need
+	markerAPP14 = 0xee
+	markerAPP15 = 0xef
+	markerCOM   = 0xfe
+)
+
+var (
+	exifHeader = []byte("Exif\x00\x00")
+	jfifHeader = []byte("JFIF\x00")
+	iccHeader  = []byte("ICC_PROFILE\x00")
+)
+
+// jpegSegment is a marker segment before the scan data. data includes the
+// marker and length bytes.
+type jpegSegment struct {
+	marker byte
+	data   []byte
+}
+
+// jpegSegments splits a JPEG into the segments before the first scan and the
+// rest of the file from that scan on.
+func jpegSegments(data []byte) ([]jpegSegment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xff || data[1] != markerSOI {
+		return nil, nil, errMalformed
+	}
+	var segments []jpegSegment
+	i := 2
+	for {
+		// Markers may be padded with any number of 0xff fill bytes
+		for i < len(data) && data[i] == 0xff && i+1 < len(data) && data[i+1] == 0xff {
+			i++
+		}
+		if i+4 > len(data) || data[i] != 0xff {
+			return nil, nil, errMalformed
+		}
+		marker := data[i+1]
+		if marker == markerSOS {
+			return segments, data[i:], nil
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, nil, errMalformed
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data[i:end]})
+		i = end
+	}
+}
+
+func stripJPEG(data []byte) ([]byte, error) {
+	segments, scan, err := jpegSegments(data)
+	if err != nil {
+		return nil, err
+	}
+	orientation := 1
+	if tiff := findEXIF(segments); tiff != nil {
+		orientation = exifOrientation(tiff)
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write([]byte{0xff, markerSOI})
+	// EXIF goes straight after JFIF, or first if there is no JFIF
+	if len(segments) > 0 && isJFIF(segments[0]) {
+		out.Write(segments[0].data)
+		segments = segments[1:]
+	}
+	if orientation != 1 {
+		out.Write(orientationSegment(orientation))
+	}
+	for _, s := range segments {
+		if keepSegment(s) {
+			out.Write(s.data)
+		}
+	}
+	out.Write(scan)
+	return out.Bytes(), nil
+}
+
+func isJFIF(s jpegSegment) bool {
+	return s.marker == markerAPP0 && bytes.HasPrefix(s.data[4:], jfifHeader)
+}
+
+// keepSegment reports whether a segment is needed to display the image. The
+// other application segments and comments only carry metadata.
+func keepSegment(s jpegSegment) bool {
+	switch {
+	case isJFIF(s), s.marker == markerAPP14:
+		return true
+	case s.marker == markerAPP2:
+		return bytes.HasPrefix(s.data[4:], iccHeader)
+	case s.marker >= markerAPP0 && s.marker <= markerAPP15, s.marker == markerCOM:
+		return false
+	}
+	return true
+}
+
+func findEXIF(segments []jpegSegment) []byte {
+	for _, s := range segments {
+		if s.marker == markerAPP1 && bytes.HasPrefix(s.data[4:], exifHeader) {
+			return s.data[4+len(exifHeader):]
+		}
+	}
+	return nil
+}
+
+// jpegOrientation returns a JPEG's EXIF orientation, or 1 if it has none.
+func jpegOrientation(data []byte) int {
+	segments, _, err := jpegSegments(data)
+	if err != nil {
+		return 1
+	}
+	if tiff := findEXIF(segments); tiff != nil {
+		return exifOrientation(tiff)
+	}
+	return 1
+}
+
+const tagOrientation = 0x0112
+
+// exifOrientation reads the orientation tag from the first IFD of a TIFF
+// structure, returning 1 if it is missing or out of range.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != tagOrientation {
+			continue
+		}
+		// A SHORT value sits in the first two bytes of the value field
+		if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+			return o
+		}
+		return 1
+	}
+	return 1
+}
+
+// orientationSegment builds an APP1 segment whose EXIF holds only the
+// orientation tag.
+func orientationSegment(orientation int) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08, // header, first IFD at 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // orientation, SHORT, count 1
+		0x00, byte(orientation), 0x00, 0x00, // value
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+	length := 2 + len(exifHeader) + len(tiff)
+	seg := []byte{0xff, markerAPP1, byte(length >> 8), byte(length)}
+	seg = append(seg, exifHeader...)
+	return append(seg, tiff...)
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the ancillary chunks that carry metadata
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(pngSignature)
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		// length, type, data, CRC
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) {
+			return nil, errMalformed
+		}
+		if !pngMetadataChunks[string(data[i+4:i+8])] {
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+	return out.Bytes(), nil
+}
+
+// VP8X feature flags for metadata chunks
+const (
+	vp8xFlagEXIF = 0x08
+	vp8xFlagXMP  = 0x04
+)
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		// Chunks are padded to an even length
+		end := i + 8 + size + size%2
+		if end == len(data)+1 {
+			// Some encoders leave the padding off the last chunk
+			end = len(data)
+		}
+		if end > len(data) {
+			return nil, errMalformed
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[i:end])
+			if len(chunk) > 8 {
+				chunk[8] &^= vp8xFlagEXIF | vp8xFlagXMP
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
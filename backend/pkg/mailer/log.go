@@ -0,0 +1,49 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// logMailer is the local development driver. It logs who every message went
+// to and, when a directory is configured, writes it to a .eml file there so
+// links can be copied out. Bodies hold single-use links, so they stay out of
+// the log.
+type logMailer struct {
+	dir    string
+	from   string
+	logger *slog.Logger
+}
+
+func NewLogMailer(dir, from string, logger *slog.Logger) (Mailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create mail directory: %w", err)
+		}
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &logMailer{dir: dir, from: from, logger: logger}, nil
+}
+
+func (m *logMailer) Send(msg *Message) error {
+	m.logger.Info("mail sent", "to", msg.To, "subject", msg.Subject)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	content := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\nDate: %s\n\n%s\n",
+		m.from,
+		msg.To,
+		msg.Subject,
+		time.Now().Format(time.RFC3339),
+		msg.Body,
+	)
+	filename := filepath.Join(m.dir, fmt.Sprintf("%d.eml", time.Now().UnixNano()))
+	return os.WriteFile(filename, []byte(content), 0644)
+}
@@ -0,0 +1,47 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Supported drivers
+const (
+	DriverSMTP = "smtp"
+	DriverLog  = "log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional email such as verification and reset links.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+type Config struct {
+	Driver   string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// Dir is where the log driver writes messages; empty only logs them.
+	Dir    string
+	Logger *slog.Logger
+}
+
+// New returns the mailer selected by cfg.Driver, defaulting to the log driver.
+func New(cfg *Config) (Mailer, error) {
+	switch cfg.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(cfg)
+	case DriverLog, "":
+		return NewLogMailer(cfg.Dir, cfg.From, cfg.Logger)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,131 @@
+// Package mediainfo reads the duration of audio and video files from their
+// headers, without decoding them. MP4 (including M4A and MOV) and WAV are
+// supported; other formats report ErrUnsupported.
+package mediainfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrUnsupported = errors.New("duration can't be read from this format")
+	ErrMalformed   = errors.New("malformed media file")
+)
+
+// Duration returns how long the file at r, of the given size and sniffed
+// content type, plays for. Only the few boxes or chunks needed are read, so
+// r can fetch ranges of a remote object.
+func Duration(r io.ReaderAt, size int64, contentType string) (time.Duration, error) {
+	switch contentType {
+	case "video/mp4", "audio/mp4":
+		return mp4Duration(r, size)
+	case "audio/wave", "audio/wav":
+		return wavDuration(r, size)
+	}
+	return 0, ErrUnsupported
+}
+
+// mp4Duration finds moov/mvhd, which may be at either end of the file, and
+// divides its duration by its timescale.
+func mp4Duration(r io.ReaderAt, size int64) (time.Duration, error) {
+	moov, moovSize, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhd, _, err := findBox(r, moov, moov+moovSize, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	// version(1) flags(3), then creation and modification times, timescale
+	// and duration: 32-bit fields in version 0, 64-bit times and duration in
+	// version 1
+	header := make([]byte, 32)
+	if _, err := r.ReadAt(header, mvhd); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	var timescale, duration uint64
+	if header[0] == 1 {
+		timescale = uint64(binary.BigEndian.Uint32(header[20:24]))
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(header[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	if timescale == 0 {
+		return 0, ErrMalformed
+	}
+	return time.Duration(duration) * time.Second / time.Duration(timescale), nil
+}
+
+// findBox scans the boxes between start and end for one of the given type and
+// returns the offset and size of its payload.
+func findBox(r io.ReaderAt, start, end int64, boxType string) (int64, int64, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return 0, 0, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			// The box runs to the end of its parent
+			boxSize = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return 0, 0, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || offset+boxSize > end {
+			return 0, 0, ErrMalformed
+		}
+		if string(header[4:8]) == boxType {
+			return offset + headerSize, boxSize - headerSize, nil
+		}
+		offset += boxSize
+	}
+	return 0, 0, ErrMalformed
+}
+
+// wavDuration divides the size of the data chunk by the byte rate in the fmt
+// chunk.
+func wavDuration(r io.ReaderAt, size int64) (time.Duration, error) {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return 0, err
+	}
+	if string(header[:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, ErrMalformed
+	}
+
+	var byteRate, dataSize uint32
+	chunk := make([]byte, 8)
+	for offset := int64(12); offset+8 <= size && (byteRate == 0 || dataSize == 0); {
+		if _, err := r.ReadAt(chunk, offset); err != nil {
+			return 0, err
+		}
+		chunkSize := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch string(chunk[:4]) {
+		case "fmt ":
+			format := make([]byte, 12)
+			if _, err := r.ReadAt(format, offset+8); err != nil {
+				return 0, err
+			}
+			byteRate = binary.LittleEndian.Uint32(format[8:12])
+		case "data":
+			// Streaming writers leave the size unset; use what's there
+			dataSize = uint32(min(chunkSize, size-offset-8))
+		}
+		offset += 8 + chunkSize + chunkSize%2
+	}
+	if byteRate == 0 || dataSize == 0 {
+		return 0, ErrMalformed
+	}
+	return time.Duration(dataSize) * time.Second / time.Duration(byteRate), nil
+}
@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limiter throttles one kind of request with a token bucket per key. Unlike
+// Lockout it only ever slows callers down: an empty bucket refills on its own
+// whatever happens in the meantime.
+type Limiter struct {
+	store Store
+	name  string
+	limit Limit
+}
+
+// NewLimiter returns a limiter whose buckets are kept apart from others in
+// store by name.
+func NewLimiter(store Store, name string, limit Limit) *Limiter {
+	return &Limiter{store: store, name: name, limit: limit}
+}
+
+// Allow takes a token for key and returns a *LimitedError when there is none.
+func (l *Limiter) Allow(ctx context.Context, key string) error {
+	result, err := l.store.Take(ctx, l.name+":"+key, l.limit)
+	if err != nil {
+		return err
+	}
+	if !result.Allowed {
+		return &LimitedError{RetryAfter: result.RetryAfter}
+	}
+	return nil
+}
+
+// LimitedError is returned while a limiter's bucket is empty.
+type LimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitedError) Error() string {
+	return fmt.Sprintf("too many requests, try again in %s", e.RetryAfter.Round(time.Second))
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// LockoutPolicy controls progressive lockout: after Threshold failures inside
+// Window the key is locked for BaseDelay, doubling with every further failure
+// up to MaxDelay.
+type LockoutPolicy struct {
+	Threshold int
+	Window    time.Duration
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		Threshold: 5,
+		Window:    15 * time.Minute,
+		BaseDelay: time.Minute,
+		MaxDelay:  time.Hour,
+	}
+}
+
+type Lockout struct {
+	store  Store
+	policy LockoutPolicy
+}
+
+func NewLockout(store Store, policy LockoutPolicy) *Lockout {
+	return &Lockout{store: store, policy: policy}
+}
+
+// Check returns a *LockedError while key is locked.
+func (l *Lockout) Check(ctx context.Context, key string) error {
+	until, err := l.store.LockedUntil(ctx, l.key(key))
+	if err != nil {
+		return err
+	}
+	if wait := time.Until(until); wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Fail records a failed attempt and locks key once the threshold is reached.
+func (l *Lockout) Fail(ctx context.Context, key string) error {
+	count, err := l.store.AddFailure(ctx, l.key(key), l.policy.Window)
+	if err != nil {
+		return err
+	}
+	if count < l.policy.Threshold {
+		return nil
+	}
+
+	delay := l.policy.BaseDelay
+	for i := l.policy.Threshold; i < count && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.policy.MaxDelay {
+		delay = l.policy.MaxDelay
+	}
+	return l.store.Lock(ctx, l.key(key), time.Now().Add(delay))
+}
+
+// Succeed clears the failure history for key.
+func (l *Lockout) Succeed(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, l.key(key))
+}
+
+func (l *Lockout) key(key string) string {
+	return "lockout:" + key
+}
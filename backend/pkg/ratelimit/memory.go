@@ -0,0 +1,125 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type failures struct {
+	count       int
+	lockedUntil time.Time
+	updatedAt   time.Time
+}
+
+// memoryStore keeps everything in maps, which only shrink when Prune runs.
+type memoryStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	failures map[string]*failures
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failures),
+	}
+}
+
+func (s *memoryStore) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updatedAt: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = refill(b.tokens, now.Sub(b.updatedAt), limit)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		return &Result{RetryAfter: retryAfter(b.tokens, limit)}, nil
+	}
+
+	b.tokens--
+	return &Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+func (s *memoryStore) AddFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	f, ok := s.failures[key]
+	if !ok {
+		f = &failures{}
+		s.failures[key] = f
+	}
+	if now.Sub(f.updatedAt) > window {
+		f.count = 0
+	}
+
+	f.count++
+	f.updatedAt = now
+	return f.count, nil
+}
+
+func (s *memoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok {
+		f = &failures{updatedAt: time.Now()}
+		s.failures[key] = f
+	}
+	f.lockedUntil = until
+	return nil
+}
+
+func (s *memoryStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, ok := s.failures[key]; ok {
+		return f.lockedUntil, nil
+	}
+	return time.Time{}, nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+	return nil
+}
+
+func (s *memoryStore) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var removed int64
+	for key, b := range s.buckets {
+		if now.Sub(b.updatedAt) > idle {
+			delete(s.buckets, key)
+			removed++
+		}
+	}
+	for key, f := range s.failures {
+		if now.Sub(f.updatedAt) > idle && now.After(f.lockedUntil) {
+			delete(s.failures, key)
+			removed++
+		}
+	}
+	return removed, nil
+}
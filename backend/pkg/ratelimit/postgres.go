@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type postgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore keeps limiter state in the rate_limit_buckets and
+// login_failures tables so every instance sees the same counts.
+func NewPostgresStore(db *sql.DB) Store {
+	return &postgresStore{db: db}
+}
+
+func (s *postgresStore) Take(ctx context.Context, key string, limit Limit) (*Result, error) {
+	// Refill and take in one statement so concurrent requests can't both
+	// spend the last token.
+	query := `
+        INSERT INTO rate_limit_buckets (key, tokens, allowed, updated_at)
+        VALUES ($1, $2::DOUBLE PRECISION - 1, TRUE, NOW())
+        ON CONFLICT (key) DO UPDATE SET
+            tokens = CASE
+                WHEN LEAST($2::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * $3::DOUBLE PRECISION) >= 1
+                THEN LEAST($2::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * $3::DOUBLE PRECISION) - 1
+                ELSE LEAST($2::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * $3::DOUBLE PRECISION)
+            END,
+            allowed = LEAST($2::DOUBLE PRECISION, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM NOW() - rate_limit_buckets.updated_at) * $3::DOUBLE PRECISION) >= 1,
+            updated_at = NOW()
+        RETURNING tokens, allowed`
+
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, query, key, float64(limit.Burst), limit.Rate).Scan(&tokens, &allowed)
+	if err != nil {
+		return nil, err
+	}
+
+	if !allowed {
+		return &Result{RetryAfter: retryAfter(tokens, limit)}, nil
+	}
+	return &Result{Allowed: true, Remaining: int(tokens)}, nil
+}
+
+func (s *postgresStore) AddFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	query := `
+        INSERT INTO login_failures (key, failures, updated_at)
+        VALUES ($1, 1, NOW())
+        ON CONFLICT (key) DO UPDATE SET
+            failures = CASE
+                WHEN login_failures.updated_at < NOW() - $2::DOUBLE PRECISION * INTERVAL '1 second' THEN 1
+                ELSE login_failures.failures + 1
+            END,
+            updated_at = NOW()
+        RETURNING failures`
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&count)
+	return count, err
+}
+
+func (s *postgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	query := `
+        INSERT INTO login_failures (key, failures, locked_until, updated_at)
+        VALUES ($1, 0, $2, NOW())
+        ON CONFLICT (key) DO UPDATE SET locked_until = $2`
+
+	_, err := s.db.ExecContext(ctx, query, key, until)
+	return err
+}
+
+func (s *postgresStore) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	query := `SELECT locked_until FROM login_failures WHERE key = $1`
+
+	var until sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return until.Time, nil
+}
+
+func (s *postgresStore) Reset(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1`, key)
+	return err
+}
+
+func (s *postgresStore) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	query := `
+        WITH buckets AS (
+            DELETE FROM rate_limit_buckets
+            WHERE updated_at < NOW() - $1::DOUBLE PRECISION * INTERVAL '1 second'
+            RETURNING 1
+        ), failures AS (
+            DELETE FROM login_failures
+            WHERE updated_at < NOW() - $1::DOUBLE PRECISION * INTERVAL '1 second'
+              AND (locked_until IS NULL OR locked_until < NOW())
+            RETURNING 1
+        )
+        SELECT (SELECT COUNT(*) FROM buckets) + (SELECT COUNT(*) FROM failures)`
+
+	var removed int64
+	err := s.db.QueryRowContext(ctx, query, idle.Seconds()).Scan(&removed)
+	return removed, err
+}
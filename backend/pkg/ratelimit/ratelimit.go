@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limit describes a token bucket: it holds up to Burst tokens and refills at
+// Rate tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute allows n requests a minute with bursts of up to n.
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// PerHour allows n requests an hour with bursts of up to n.
+func PerHour(n int) Limit {
+	return Limit{Rate: float64(n) / 3600, Burst: n}
+}
+
+type Result struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// RetryAfter is how long until the next token is available when denied
+	RetryAfter time.Duration
+}
+
+// Store keeps bucket and login failure state. The in-memory store is enough
+// for a single instance; the Postgres store shares limits across instances.
+type Store interface {
+	// Take removes one token from the bucket identified by key.
+	Take(ctx context.Context, key string, limit Limit) (*Result, error)
+
+	// AddFailure records a failed attempt and returns the number of failures
+	// since the last reset. Failures older than window are forgotten.
+	AddFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	// Lock blocks key until the given time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// LockedUntil returns the zero time when key isn't locked.
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+	// Reset clears failures and any lock for key.
+	Reset(ctx context.Context, key string) error
+
+	// Prune forgets buckets and failure counts untouched for longer than
+	// idle, other than current locks, and returns how many entries went. A
+	// forgotten bucket starts full again, so idle must exceed the time the
+	// slowest limit takes to refill.
+	Prune(ctx context.Context, idle time.Duration) (int64, error)
+}
+
+// LockedError is returned while an account is locked out.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// refill returns the tokens in a bucket after elapsed time has passed.
+func refill(tokens float64, elapsed time.Duration, limit Limit) float64 {
+	tokens += elapsed.Seconds() * limit.Rate
+	if tokens > float64(limit.Burst) {
+		tokens = float64(limit.Burst)
+	}
+	return tokens
+}
+
+func retryAfter(tokens float64, limit Limit) time.Duration {
+	if limit.Rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+}
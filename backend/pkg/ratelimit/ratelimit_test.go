@@ -0,0 +1,200 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefill(t *testing.T) {
+	limit := PerMinute(60)
+
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		want    float64
+	}{
+		{name: "no time passed", tokens: 0.5, elapsed: 0, want: 0.5},
+		{name: "one token a second", tokens: 0, elapsed: 3 * time.Second, want: 3},
+		{name: "partial token", tokens: 2, elapsed: 500 * time.Millisecond, want: 2.5},
+		{name: "capped at burst", tokens: 59, elapsed: time.Hour, want: 60},
+		{name: "idle bucket", tokens: 0, elapsed: 24 * time.Hour, want: 60},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refill(tt.tokens, tt.elapsed, limit); got != tt.want {
+				t.Errorf("refill(%v, %s) = %v, want %v", tt.tokens, tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens float64
+		limit  Limit
+		want   time.Duration
+	}{
+		{name: "empty bucket", tokens: 0, limit: PerMinute(60), want: time.Second},
+		{name: "half a token", tokens: 0.5, limit: PerMinute(60), want: 500 * time.Millisecond},
+		{name: "slow limit", tokens: 0, limit: PerHour(10), want: 6 * time.Minute},
+		{name: "never refills", tokens: 0, limit: Limit{Burst: 1}, want: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryAfter(tt.tokens, tt.limit); got != tt.want {
+				t.Errorf("retryAfter(%v) = %s, want %s", tt.tokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limit := PerHour(3)
+
+	for i := 2; i >= 0; i-- {
+		result, err := store.Take(ctx, "ip:1", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("take %d: got %+v, want allowed with %d remaining", 3-i, result, i)
+		}
+	}
+
+	result, err := store.Take(ctx, "ip:1", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Fatal("took a token from an empty bucket")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 20*time.Minute {
+		t.Errorf("RetryAfter = %s, want up to the 20m one token takes", result.RetryAfter)
+	}
+
+	// Buckets are independent
+	if result, err := store.Take(ctx, "ip:2", limit); err != nil || !result.Allowed {
+		t.Errorf("other key: got %+v, %v", result, err)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	policy := LockoutPolicy{
+		Threshold: 3,
+		Window:    time.Hour,
+		BaseDelay: time.Minute,
+		MaxDelay:  4 * time.Minute,
+	}
+
+	tests := []struct {
+		name     string
+		failures int
+		// wantDelay is the lock after the last failure, zero for none
+		wantDelay time.Duration
+	}{
+		{name: "below threshold", failures: 2},
+		{name: "at threshold", failures: 3, wantDelay: time.Minute},
+		{name: "doubles", failures: 4, wantDelay: 2 * time.Minute},
+		{name: "doubles again", failures: 5, wantDelay: 4 * time.Minute},
+		{name: "capped", failures: 8, wantDelay: 4 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			lockout := NewLockout(NewMemoryStore(), policy)
+
+			for i := 0; i < tt.failures; i++ {
+				if err := lockout.Fail(ctx, "a@example.com"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := lockout.Check(ctx, "a@example.com")
+			if tt.wantDelay == 0 {
+				if err != nil {
+					t.Fatalf("locked after %d failures: %v", tt.failures, err)
+				}
+				return
+			}
+			var locked *LockedError
+			if !errors.As(err, &locked) {
+				t.Fatalf("got %v, want a *LockedError", err)
+			}
+			if locked.RetryAfter > tt.wantDelay || locked.RetryAfter < tt.wantDelay-time.Second {
+				t.Errorf("RetryAfter = %s, want %s", locked.RetryAfter, tt.wantDelay)
+			}
+
+			// Other keys are unaffected, and success clears the lock
+			if err := lockout.Check(ctx, "b@example.com"); err != nil {
+				t.Errorf("other key is locked: %v", err)
+			}
+			if err := lockout.Succeed(ctx, "a@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if err := lockout.Check(ctx, "a@example.com"); err != nil {
+				t.Errorf("still locked after success: %v", err)
+			}
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, "login:email", PerHour(2))
+
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow(ctx, "a@example.com"); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+	var limited *LimitedError
+	if err := limiter.Allow(ctx, "a@example.com"); !errors.As(err, &limited) || limited.RetryAfter <= 0 {
+		t.Fatalf("got %v, want a *LimitedError", err)
+	}
+
+	// Other keys and other limiters on the same store have their own buckets
+	if err := limiter.Allow(ctx, "b@example.com"); err != nil {
+		t.Errorf("other key: %v", err)
+	}
+	if err := NewLimiter(store, "signup", PerHour(2)).Allow(ctx, "a@example.com"); err != nil {
+		t.Errorf("other limiter: %v", err)
+	}
+}
+
+func TestMemoryStorePrune(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	if _, err := store.Take(ctx, "ip:1", PerMinute(10)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.AddFailure(ctx, "failed", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Lock(ctx, "locked", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond)
+
+	removed, err := store.Prune(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 2 {
+		t.Errorf("removed %d entries, want the bucket and the failure count", removed)
+	}
+	// Locks outlive pruning
+	if until, err := store.LockedUntil(ctx, "locked"); err != nil || until.IsZero() {
+		t.Errorf("lock was pruned: %v, %v", until, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Package server runs the HTTP server and shuts it down gracefully.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout bounds ordinary responses. Long-lived streams extend their
+	// own deadline as they write.
+	WriteTimeout   time.Duration
+	IdleTimeout    time.Duration
+	MaxHeaderBytes int
+	// ShutdownTimeout is how long in-flight requests get to finish once a
+	// shutdown starts
+	ShutdownTimeout time.Duration
+}
+
+type Server struct {
+	http            *http.Server
+	shutdownTimeout time.Duration
+	logger          *slog.Logger
+}
+
+func New(cfg Config, handler http.Handler, logger *slog.Logger) *Server {
+	return &Server{
+		http: &http.Server{
+			Addr:              cfg.Addr,
+			Handler:           handler,
+			ReadTimeout:       cfg.ReadTimeout,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			WriteTimeout:      cfg.WriteTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+			MaxHeaderBytes:    cfg.MaxHeaderBytes,
+			ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+		},
+		shutdownTimeout: cfg.ShutdownTimeout,
+		logger:          logger,
+	}
+}
+
+// RegisterOnShutdown runs f when a shutdown starts, before waiting on
+// in-flight requests. Use it to end long-lived connections such as event
+// streams, which would otherwise hold the shutdown until its deadline.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.http.RegisterOnShutdown(f)
+}
+
+// Run serves until ctx is cancelled and then drains in-flight requests,
+// giving up after the shutdown timeout.
+func (s *Server) Run(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		s.logger.Info("http server listening", "addr", s.http.Addr)
+		errCh <- s.http.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("http server: %w", err)
+	case <-ctx.Done():
+	}
+
+	s.logger.Info("shutting down http server", "timeout", s.shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err := s.http.Shutdown(shutdownCtx); err != nil {
+		// Deadline passed with requests still running; cut them off
+		s.http.Close()
+		return fmt.Errorf("http server shutdown: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("http server: %w", err)
+	}
+
+	s.logger.Info("http server stopped")
+	return nil
+}
@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// localStore keeps objects as files under a directory, for development and
+// single-instance deployments. The router serves the directory itself and
+// takes presigned uploads on the store's behalf.
+type localStore struct {
+	dir        string
+	publicURL  string
+	uploadURL  string
+	signingKey []byte
+}
+
+func NewLocalStore(cfg *Config) (ObjectStore, error) {
+	// Create the directory if it doesn't exist
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		publicURL = "/uploads"
+	}
+	uploadURL := cfg.UploadURL
+	if uploadURL == "" {
+		uploadURL = "/api/uploads/direct"
+	}
+	signingKey := []byte(cfg.SigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return nil, err
+		}
+	}
+	return &localStore{
+		dir:        cfg.Dir,
+		publicURL:  strings.TrimSuffix(publicURL, "/"),
+		uploadURL:  strings.TrimSuffix(uploadURL, "/"),
+		signingKey: signingKey,
+	}, nil
+}
+
+// path maps key onto the directory, refusing keys that would escape it.
+func (s *localStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *localStore) Put(ctx context.Context, key string, body io.Reader, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *localStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (s *localStore) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	rc, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	f := rc.(*os.File)
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Size: info.Size()}, nil
+}
+
+func (s *localStore) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Only walk the directory the prefix points into
+	root := s.dir
+	if dir := path.Dir(prefix); prefix != "" && dir != "." {
+		var err error
+		if root, err = s.path(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Skip directories and the temporary files of Puts in progress
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// WalkDir visits entries in lexical order per directory, which isn't key
+	// order across directories
+	slices.SortFunc(objects, func(a, b ObjectInfo) int { return strings.Compare(a.Key, b.Key) })
+	return objects, nil
+}
+
+// PresignPut returns a URL on the API's direct upload route carrying an HMAC
+// of the key, type, size and expiry, which AcceptPut checks.
+func (s *localStore) PresignPut(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (*PresignedRequest, error) {
+	if _, err := s.path(key); err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(ttl).Truncate(time.Second)
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("size", strconv.FormatInt(size, 10))
+	query.Set("signature", s.sign(key, contentType, size, expires.Unix()))
+
+	return &PresignedRequest{
+		Method:    http.MethodPut,
+		URL:       s.uploadURL + "/" + key + "?" + query.Encode(),
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: expires,
+	}, nil
+}
+
+func (s *localStore) AcceptPut(ctx context.Context, key string, r *http.Request) error {
+	query := r.URL.Query()
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	size, err := strconv.ParseInt(query.Get("size"), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	want := s.sign(key, r.Header.Get("Content-Type"), size, expires)
+	if !hmac.Equal([]byte(want), []byte(query.Get("signature"))) || time.Now().Unix() > expires {
+		return ErrInvalidSignature
+	}
+	// S3 rejects a body whose length differs from the signed one; so do we
+	if r.ContentLength != size {
+		return fmt.Errorf("%w: body must be exactly %d bytes", ErrInvalidSignature, size)
+	}
+
+	return s.Put(ctx, key, r.Body, r.Header.Get("Content-Type"))
+}
+
+func (s *localStore) sign(key, contentType string, size, expires int64) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	fmt.Fprintf(mac, "PUT\n%s\n%s\n%d\n%d", key, contentType, size, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *localStore) URL(key string) string {
+	return s.publicURL + "/" + key
+}
+
+func (s *localStore) Ping(ctx context.Context) error {
+	_, err := os.Stat(s.dir)
+	return err
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rangeStore serves one object and records the ranges asked for
+type rangeStore struct {
+	ObjectStore
+	data   []byte
+	ranges [][2]int64
+}
+
+func (s *rangeStore) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	s.ranges = append(s.ranges, [2]int64{offset, length})
+	end := min(offset+length, int64(len(s.data)))
+	return io.NopCloser(bytes.NewReader(s.data[offset:end])), nil
+}
+
+func TestReaderServesRanges(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 100))
+
+	tests := []struct {
+		name       string
+		rangeHdr   string
+		wantStatus int
+		wantBody   string
+		// wantFetched is the most bytes any single GetRange may ask for
+		wantFetched int64
+	}{
+		{name: "whole file", wantStatus: http.StatusOK, wantBody: string(data), wantFetched: 1000},
+		{name: "from an offset", rangeHdr: "bytes=995-", wantStatus: http.StatusPartialContent, wantBody: "56789", wantFetched: 5},
+		{name: "middle", rangeHdr: "bytes=10-14", wantStatus: http.StatusPartialContent, wantBody: "01234", wantFetched: 990},
+		{name: "suffix", rangeHdr: "bytes=-3", wantStatus: http.StatusPartialContent, wantBody: "789", wantFetched: 3},
+		{name: "past the end", rangeHdr: "bytes=2000-", wantStatus: http.StatusRequestedRangeNotSatisfiable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &rangeStore{data: data}
+			reader := NewReader(context.Background(), store, "sermon.mp3", int64(len(data)))
+			defer reader.Close()
+
+			req := httptest.NewRequest(http.MethodGet, "/media/1/stream", nil)
+			if tt.rangeHdr != "" {
+				req.Header.Set("Range", tt.rangeHdr)
+			}
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", "audio/mpeg")
+			http.ServeContent(rec, req, "", time.Time{}, reader)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			for _, r := range store.ranges {
+				if r[1] > tt.wantFetched {
+					t.Errorf("fetched %d bytes at %d, want at most %d", r[1], r[0], tt.wantFetched)
+				}
+			}
+		})
+	}
+}
+
+func TestReaderReadAt(t *testing.T) {
+	store := &rangeStore{data: []byte("in the beginning")}
+	reader := NewReader(context.Background(), store, "key", int64(len(store.data)))
+
+	p := make([]byte, 5)
+	if n, err := reader.ReadAt(p, 7); err != nil || string(p[:n]) != "begin" {
+		t.Errorf("ReadAt(7) = %q, %v", p[:n], err)
+	}
+	if n, err := reader.ReadAt(p, 13); err != io.EOF || string(p[:n]) != "ing" {
+		t.Errorf("ReadAt(13) = %q, %v, want \"ing\", EOF", p[:n], err)
+	}
+
+	// ReadAt leaves the read offset alone
+	all, err := io.ReadAll(reader)
+	if err != nil || string(all) != "in the beginning" {
+		t.Errorf("ReadAll = %q, %v", all, err)
+	}
+}
@@ -1,60 +1,102 @@
+// Package storage keeps uploaded files and other objects in S3 or on the local
+// disk behind one interface.
 package storage
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"mime/multipart"
-	"os"
-	"path/filepath"
+	"net/http"
 	"time"
 )
 
-type StorageService interface {
-	SaveFile(file *multipart.FileHeader) (string, error)
-	DeleteFile(filename string) error
-}
+// Supported drivers
+const (
+	DriverS3    = "s3"
+	DriverLocal = "local"
+)
 
-type localStorage struct {
-	uploadDir string
-}
+// ErrNotFound is returned by Get for a key that does not exist.
+var ErrNotFound = errors.New("object not found")
 
-func NewLocalStorage(uploadDir string) StorageService {
-	// Create uploads directory if it doesn't exist
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		panic(err)
-	}
-	return &localStorage{uploadDir: uploadDir}
+// ObjectStore stores objects under slash-separated keys such as
+// "uploads/123-photo.jpg".
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// GetRange reads length bytes of the object under key starting at offset
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// Stat returns the size and type of the object under key, or ErrNotFound
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// List returns the objects whose keys start with prefix, in key order
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// PresignPut returns a request that lets a client upload exactly size
+	// bytes of contentType to key without credentials, until ttl runs out
+	PresignPut(ctx context.Context, key, contentType string, size int64, ttl time.Duration) (*PresignedRequest, error)
+	// URL is where clients can download the object stored under key
+	URL(key string) string
+	// Ping checks that the backend is reachable
+	Ping(ctx context.Context) error
 }
 
-func (s *localStorage) SaveFile(file *multipart.FileHeader) (string, error) {
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%d%s", time.Now().UnixNano(), ext)
-	filepath := filepath.Join(s.uploadDir, filename)
+type ObjectInfo struct {
+	// Key is only set by List
+	Key  string
+	Size int64
+	// ContentType is empty when the backend doesn't record one
+	ContentType string
+}
 
-	// Open source file
-	src, err := file.Open()
-	if err != nil {
-		return "", err
-	}
-	defer src.Close()
+// PresignedRequest is an upload for the client to make itself. Headers must
+// be sent exactly as given or the signature won't match.
+type PresignedRequest struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
 
-	// Create destination file
-	dst, err := os.Create(filepath)
-	if err != nil {
-		return "", err
-	}
-	defer dst.Close()
+// DirectUploader accepts presigned uploads through the API. Stores whose
+// backend takes uploads itself, such as S3, don't implement it.
+type DirectUploader interface {
+	// AcceptPut checks r against the signature PresignPut gave it and stores
+	// the body. The error wraps ErrInvalidSignature when the request wasn't
+	// issued by PresignPut or has expired.
+	AcceptPut(ctx context.Context, key string, r *http.Request) error
+}
 
-	// Copy file content
-	if _, err = io.Copy(dst, src); err != nil {
-		return "", err
-	}
+// ErrInvalidSignature is returned for a direct upload that doesn't match its
+// signature or whose signature has expired.
+var ErrInvalidSignature = errors.New("invalid or expired upload signature")
 
-	return filename, nil
+type Config struct {
+	Driver string
+	// Bucket and Region select the S3 bucket
+	Bucket string
+	Region string
+	// Dir is where the local driver keeps files
+	Dir string
+	// PublicURL is the base of object URLs. It defaults to the bucket's
+	// virtual-hosted URL for S3 and to "/uploads" for the local driver.
+	PublicURL string
+	// UploadURL is the base of the local driver's presigned upload URLs,
+	// "/api/uploads/direct" by default
+	UploadURL string
+	// SigningKey signs the local driver's upload URLs. A random key is used
+	// when it is empty, which only works for a single instance.
+	SigningKey string
 }
 
-func (s *localStorage) DeleteFile(filename string) error {
-	filepath := filepath.Join(s.uploadDir, filename)
-	return os.Remove(filepath)
+// New returns the store selected by cfg.Driver.
+func New(ctx context.Context, cfg *Config) (ObjectStore, error) {
+	switch cfg.Driver {
+	case DriverS3:
+		return NewS3Store(ctx, cfg)
+	case DriverLocal:
+		return NewLocalStore(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
 }
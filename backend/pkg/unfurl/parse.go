@@ -0,0 +1,150 @@
+package unfurl
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// maxTextLen caps titles and descriptions, in runes
+const maxTextLen = 500
+
+// parse reads metadata from the head of an HTML document. Open Graph tags win
+// over Twitter card tags, which win over the plain title and description.
+func parse(r io.Reader, contentType string, base *url.URL) (*Metadata, error) {
+	r, err := charset.NewReader(r, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	var title string
+	var inTitle bool
+	meta := make(map[string]string)
+	var canonical string
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// EOF, or the page was cut off at the size limit
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = tt == html.StartTagToken
+			case "meta":
+				if hasAttr {
+					key, content := metaAttrs(z)
+					if key != "" && content != "" {
+						if _, seen := meta[key]; !seen {
+							meta[key] = content
+						}
+					}
+				}
+			case "link":
+				if hasAttr {
+					if rel, href := linkAttrs(z); rel == "canonical" && canonical == "" {
+						canonical = href
+					}
+				}
+			case "body":
+				break loop
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				break loop
+			}
+		case html.TextToken:
+			if inTitle && title == "" {
+				title = string(z.Text())
+			}
+		}
+	}
+
+	md := &Metadata{
+		URL:         base.String(),
+		Title:       first(meta["og:title"], meta["twitter:title"], title),
+		Description: first(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    first(meta["og:site_name"]),
+	}
+	if u := resolve(base, meta["og:url"], canonical); u != "" {
+		md.URL = u
+	}
+	md.Image = resolve(base, meta["og:image:secure_url"], meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"])
+	if md.SiteName == "" {
+		md.SiteName = base.Hostname()
+	}
+	return md, nil
+}
+
+// metaAttrs returns the property or name of a meta tag, lower-cased, and its
+// content.
+func metaAttrs(z *html.Tokenizer) (key, content string) {
+	for {
+		name, value, more := z.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(value)))
+			}
+		case "content":
+			content = string(value)
+		}
+		if !more {
+			return key, content
+		}
+	}
+}
+
+func linkAttrs(z *html.Tokenizer) (rel, href string) {
+	for {
+		name, value, more := z.TagAttr()
+		switch string(name) {
+		case "rel":
+			rel = strings.ToLower(strings.TrimSpace(string(value)))
+		case "href":
+			href = string(value)
+		}
+		if !more {
+			return rel, href
+		}
+	}
+}
+
+// first returns the first non-blank value, tidied up.
+func first(values ...string) string {
+	for _, v := range values {
+		if v = strings.Join(strings.Fields(v), " "); v != "" {
+			if r := []rune(v); len(r) > maxTextLen {
+				v = string(r[:maxTextLen-1]) + "…"
+			}
+			return v
+		}
+	}
+	return ""
+}
+
+// resolve makes the first usable ref absolute against base, keeping only
+// http and https URLs.
+func resolve(base *url.URL, refs ...string) string {
+	for _, ref := range refs {
+		if ref = strings.TrimSpace(ref); ref == "" {
+			continue
+		}
+		u, err := base.Parse(ref)
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			return u.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,191 @@
+// Package unfurl fetches web pages and reads the title, description and
+// image they advertise for link previews. Requests can only reach public
+// addresses, so user-supplied links can't be used to probe the internal
+// network.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrBlocked is returned for a URL that resolves to an address that
+	// isn't publicly routable, directly or through a redirect.
+	ErrBlocked = errors.New("address is not allowed")
+	// ErrNotHTML is returned when the page isn't an HTML document.
+	ErrNotHTML = errors.New("not an HTML page")
+)
+
+// Metadata is what a page says about itself.
+type Metadata struct {
+	// URL is the page's canonical URL, or where the request ended up
+	URL         string
+	Title       string
+	Description string
+	// Image is an absolute URL
+	Image    string
+	SiteName string
+}
+
+type Config struct {
+	// Timeout bounds the whole fetch, redirects and body included
+	Timeout time.Duration
+	// MaxBytes is how much of a page is read; metadata is in the head, so
+	// the rest of a large page is never downloaded
+	MaxBytes     int64
+	MaxRedirects int
+	UserAgent    string
+}
+
+// DefaultConfig returns limits suited to fetching previews while a request
+// waits.
+func DefaultConfig() Config {
+	return Config{
+		Timeout:      5 * time.Second,
+		MaxBytes:     1 << 20,
+		MaxRedirects: 5,
+		UserAgent:    "CHubBot/1.0 (+link previews)",
+	}
+}
+
+type Fetcher struct {
+	client *http.Client
+	cfg    Config
+	// allow reports whether an address may be dialled. It is Public, except
+	// in tests that need to reach a server of their own.
+	allow func(netip.AddrPort) bool
+}
+
+func New(cfg Config) *Fetcher {
+	f := &Fetcher{
+		cfg:   cfg,
+		allow: func(addr netip.AddrPort) bool { return Public(addr.Addr()) },
+	}
+
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		// The check runs on the address actually being dialled, after DNS,
+		// so a name that resolves to a private address is caught too
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlocked, address)
+			}
+			if !f.allow(addr) {
+				return fmt.Errorf("%w: %s", ErrBlocked, addr.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// No proxy: it would be the proxy's address that gets checked
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	f.client = &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			return checkURL(req.URL)
+		},
+	}
+	return f
+}
+
+// Fetch downloads the page at rawURL and reads its metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Metadata, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkURL(u); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	return parse(io.LimitReader(resp.Body, f.cfg.MaxBytes), contentType, resp.Request.URL)
+}
+
+// checkURL allows only http and https URLs without credentials.
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: scheme %q", ErrBlocked, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: URL has credentials", ErrBlocked)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: URL has no host", ErrBlocked)
+	}
+	return nil
+}
+
+// blockedPrefixes are ranges the net/netip helpers don't cover: shared
+// carrier-grade NAT, IETF protocol assignments, benchmarking, documentation,
+// reserved space and NAT64, which can map to any IPv4 address.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// Public reports whether addr is a publicly routable unicast address.
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
@@ -25,6 +25,7 @@ export function ImageUpload({ value, onChange, disabled }: ImageUploadProps) {
       // Create FormData
       const formData = new FormData()
       formData.append("file", file)
+      formData.append("purpose", "post_image")
 
       // Replace with your upload API endpoint
       const response = await fetch("/api/upload", {
@@ -49,7 +50,9 @@ export function ImageUpload({ value, onChange, disabled }: ImageUploadProps) {
   const { getRootProps, getInputProps, isDragActive } = useDropzone({
     onDrop,
     accept: {
-      'image/*': ['.png', '.jpg', '.jpeg', '.gif']
+      'image/jpeg': ['.jpg', '.jpeg'],
+      'image/png': ['.png'],
+      'image/webp': ['.webp']
     },
     maxFiles: 1,
     disabled: disabled || isUploading
@@ -0,0 +1,48 @@
+import { LinkPreview } from "@/types/posts"
+
+interface LinkPreviewCardProps {
+  url: string
+  preview?: LinkPreview
+}
+
+// Shows a post's link with the title, description and image the page
+// advertises. Until the server has fetched them only the address is shown.
+export function LinkPreviewCard({ url, preview }: LinkPreviewCardProps) {
+  let host = url
+  try {
+    host = new URL(url).hostname
+  } catch {}
+
+  return (
+    <a
+      href={url}
+      target="_blank"
+      rel="noopener noreferrer nofollow"
+      className="flex overflow-hidden rounded-lg bg-gray-700/50 hover:bg-gray-700 transition-colors"
+    >
+      {preview?.image_url && (
+        // Preview images come from any site, so they skip next/image's
+        // domain allowlist
+        // eslint-disable-next-line @next/next/no-img-element
+        <img
+          src={preview.image_url}
+          alt=""
+          loading="lazy"
+          referrerPolicy="no-referrer"
+          className="h-24 w-24 flex-shrink-0 object-cover"
+        />
+      )}
+      <div className="min-w-0 p-4">
+        <div className="text-xs uppercase text-gray-400 truncate">
+          {preview?.site_name || host}
+        </div>
+        <div className="text-sm font-medium text-yellow-400 truncate">
+          {preview?.title || url}
+        </div>
+        {preview?.description && (
+          <div className="text-sm text-gray-300 line-clamp-2">{preview.description}</div>
+        )}
+      </div>
+    </a>
+  )
+}
@@ -10,6 +10,7 @@ import { usePostInteractions } from "@/hooks/post-interactions"
 import { useSavedPosts } from "@/hooks/saved-posts"
 import { useState } from "react"
 import Image from "next/image"
+import { LinkPreviewCard } from "./link-preview-card"
 
 interface PostCardProps {
   post: Post
@@ -108,14 +109,7 @@ export function PostCard({ post }: PostCardProps) {
         )}
         
         {post.link_url && (
-          <a
-            href={post.link_url}
-            target="_blank"
-            rel="noopener noreferrer"
-            className="block p-4 rounded-lg bg-gray-700/50 hover:bg-gray-700 transition-colors"
-          >
-            <div className="text-sm text-yellow-400">View Bible Plan →</div>
-          </a>
+          <LinkPreviewCard url={post.link_url} preview={post.link_preview} />
         )}
         
         <div className="flex flex-wrap gap-2">
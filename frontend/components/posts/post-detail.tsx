@@ -20,6 +20,8 @@ import { CommentCard } from "@/components/comments/comment-card"
 import api from "@/lib/axios"
 import { CommentSection } from "../comments/comment-section"
 import { Comment } from "@/types/comment"
+import { LinkPreview } from "@/types/posts"
+import { LinkPreviewCard } from "./link-preview-card"
 
 interface Post {
   id: number
@@ -27,6 +29,7 @@ interface Post {
   content: string
   image_url?: string
   link_url?: string
+  link_preview?: LinkPreview
   created_at: string
   likes: number
   comment_count: number
@@ -156,14 +159,7 @@ export function PostDetail({ postId }: PostDetailProps) {
           )}
           
           {post.link_url && (
-            <a
-              href={post.link_url}
-              target="_blank"
-              rel="noopener noreferrer"
-              className="block p-4 rounded-lg bg-gray-700/50 hover:bg-gray-700 transition-colors"
-            >
-              <div className="text-sm text-yellow-400">View Bible Plan →</div>
-            </a>
+            <LinkPreviewCard url={post.link_url} preview={post.link_preview} />
           )}
           
           {post.tags && post.tags.length > 0 && (
@@ -1,11 +1,22 @@
 import { User } from './auth'
 
+export interface LinkPreview {
+  url: string
+  canonical_url?: string
+  title?: string
+  description?: string
+  image_url?: string
+  site_name?: string
+  fetched_at: string
+}
+
 export interface Post {
   id: number
   title: string
   content: string
   image_url?: string
   link_url?: string
+  link_preview?: LinkPreview
   likes: number
   is_liked: boolean
   is_saved: boolean